@@ -0,0 +1,93 @@
+package main
+
+import (
+	"carwash-bot/config"
+	"carwash-bot/internal/export"
+	"carwash-bot/internal/models"
+	"carwash-bot/internal/storage"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+)
+
+// runExport выполняет `carwash-bot export` — ту же выгрузку, что /export,
+// но в файл на диске
+func runExport(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	kind := fs.String("type", "bookings", "что выгрузить: bookings или customers")
+	format := fs.String("format", export.FormatXLSX, "формат: csv или xlsx")
+	fromStr := fs.String("from", "", "начало периода, ДД.ММ.ГГГГ (по умолчанию — начало месяца)")
+	toStr := fs.String("to", "", "конец периода включительно, ДД.ММ.ГГГГ (по умолчанию — сегодня)")
+	status := fs.String("status", "", "только записи в этом статусе")
+	service := fs.String("service", "", "только записи на эту услугу")
+	bay := fs.Int("bay", 0, "только записи на этот пост")
+	out := fs.String("o", "", "файл для записи (по умолчанию — имя по типу и периоду)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *kind != "bookings" && *kind != "customers" {
+		return fmt.Errorf("неизвестный тип выгрузки: %s", *kind)
+	}
+	if *format != export.FormatCSV && *format != export.FormatXLSX {
+		return fmt.Errorf("неизвестный формат: %s", *format)
+	}
+
+	now := time.Now()
+	to := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	from := to.AddDate(0, 0, 1-to.Day())
+	var err error
+	if *fromStr != "" {
+		if from, err = time.ParseInLocation("02.01.2006", *fromStr, time.Local); err != nil {
+			return fmt.Errorf("неверная дата начала: %s", *fromStr)
+		}
+	}
+	if *toStr != "" {
+		if to, err = time.ParseInLocation("02.01.2006", *toStr, time.Local); err != nil {
+			return fmt.Errorf("неверная дата конца: %s", *toStr)
+		}
+	}
+
+	cfg := config.Load()
+	db, err := storage.Open(cfg.DatabaseURL)
+	if err != nil {
+		return err
+	}
+	defer db.DB.Close()
+
+	exporter := export.New(db, cfg)
+	var (
+		table  *export.Table
+		period string
+	)
+	if *kind == "customers" {
+		table, err = exporter.Customers(context.Background())
+	} else {
+		filter := models.ScheduleFilter{Bay: *bay, Service: *service, Status: *status}
+		table, err = exporter.Bookings(context.Background(), from, to, filter)
+		period = from.Format("02.01.2006") + "-" + to.Format("02.01.2006")
+	}
+	if err != nil {
+		return err
+	}
+
+	path := *out
+	if path == "" {
+		path = export.FileName(*kind, period, *format)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := export.Write(f, *format, table); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	fmt.Printf("%s: %d строк → %s\n", table.Name, len(table.Rows), path)
+	return nil
+}
@@ -1,9 +1,13 @@
 package bot
 
 import (
+	"carwash-bot/internal/payments"
+	"carwash-bot/internal/ratelimit"
+	"carwash-bot/internal/services"
 	"carwash-bot/internal/storage"
 	"log"
 	"sync"
+	"time"
 
 	"carwash-bot/config"
 	"carwash-bot/internal/models"
@@ -13,18 +17,28 @@ import (
 type MessageHandler func(b *CarWashBot, update tgbotapi.Update, cfg *config.Config)
 
 type CarWashBot struct {
-	botAPI        *tgbotapi.BotAPI
-	storage       *storage.Storage // Заменяем schedule на storage
+	botAPI        *throttledAPI
+	storage       storage.Store            // Заменяем schedule на storage
+	bookings      *services.BookingService // Правила записи: доступность, создание, отмена, перенос
+	refunds       *services.RefundService  // Возврат предоплаты отменённых записей
+	loyalty       *services.LoyaltyService // Пересчёт бесплатных моек после отмен
 	userStates    map[int64]models.UserState
 	adminID       int64
 	lastMessageID map[int64]int
+	outboxWake    chan struct{} // Будит отправку очереди после deliverLater
 	msgIDLock     sync.Mutex
+	langs         map[int64]string // Язык интерфейса по Telegram ID
+	langLock      sync.Mutex
 	cfg           *config.Config // Добавляем конфиг в структуру бота
 	handlers      map[string]MessageHandler
-	store         *storage.Storage
+	payments      payments.Provider  // nil, если предоплата отключена
+	flood         *ratelimit.Limiter // Входящие сообщения и нажатия от пользователя
+	floodNotice   *ratelimit.Limiter // Чтобы не отвечать «слишком часто» на каждое сообщение
+	now           func() time.Time   // Текущее время; подменяется, чтобы проверять тайм-ауты мастера записи
 }
 
-func New(config *config.Config, store *storage.Storage) (*CarWashBot, error) {
+// New создаёт бота. provider — nil, если предоплата отключена
+func New(config *config.Config, store storage.Store, provider payments.Provider) (*CarWashBot, error) {
 	botAPI, err := tgbotapi.NewBotAPI(config.BotToken)
 	if err != nil {
 		return nil, err
@@ -32,16 +46,29 @@ func New(config *config.Config, store *storage.Storage) (*CarWashBot, error) {
 
 	botAPI.Debug = true
 
+	return newBot(botAPI, config, store, provider), nil
+}
+
+// newBot собирает бота вокруг готового клиента Telegram API
+func newBot(botAPI *tgbotapi.BotAPI, config *config.Config, store storage.Store, provider payments.Provider) *CarWashBot {
 	return &CarWashBot{
-		botAPI:        botAPI,
+		botAPI:        newThrottledAPI(botAPI),
 		userStates:    make(map[int64]models.UserState),
 		adminID:       config.AdminID,
 		lastMessageID: make(map[int64]int),
+		outboxWake:    make(chan struct{}, 1),
+		langs:         make(map[int64]string),
 		cfg:           config, // Сохраняем конфиг в структуре
 		handlers:      make(map[string]MessageHandler),
-		store:         store,
-	}, nil
-
+		storage:       store,
+		bookings:      services.NewBookingService(store, config),
+		refunds:       services.NewRefundService(store, provider, config),
+		loyalty:       services.NewLoyaltyService(store, config),
+		payments:      provider,
+		flood:         ratelimit.New(config.RateLimit.PerMinute, time.Minute, config.RateLimit.Burst),
+		floodNotice:   ratelimit.New(1, floodNoticeInterval, 1),
+		now:           time.Now,
+	}
 }
 
 func (b *CarWashBot) Start() {
@@ -51,11 +78,26 @@ func (b *CarWashBot) Start() {
 	u.Timeout = 60
 	updates := b.botAPI.GetUpdatesChan(u)
 
+	if b.payments != nil {
+		go b.runPaymentExpiry()
+	}
+	go b.runOutbox()
+	if b.cfg.Reminder > 0 {
+		go b.runReminders()
+	}
+	go b.runJobs()
+
 	for update := range updates {
+		if !b.allowUpdate(update) {
+			continue
+		}
+
 		if update.Message != nil {
 			b.handleMessage(update.Message)
 		} else if update.CallbackQuery != nil {
 			b.handleCallbackQuery(update.CallbackQuery)
+		} else if update.PreCheckoutQuery != nil {
+			b.handlePreCheckoutQuery(update.PreCheckoutQuery)
 		}
 	}
 }
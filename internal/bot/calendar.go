@@ -0,0 +1,146 @@
+package bot
+
+import (
+	"carwash-bot/internal/i18n"
+	"carwash-bot/internal/models"
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Состояние дня в календаре
+const (
+	dayOpen        = iota
+	dayUnavailable // Прошедший или за пределами горизонта записи
+	dayClosed
+	dayFull
+)
+
+func (b *CarWashBot) dayStatus(location *models.Location, date time.Time, occupancy map[string]map[string]int) int {
+	first, last := b.bookings.Window()
+	switch {
+	case date.Before(first) || date.After(last):
+		return dayUnavailable
+	case b.cfg.IsClosed(date):
+		return dayClosed
+	case b.bookings.FreeSlots(location, date, occupancy[date.Format("02.01.2006")]) == 0:
+		return dayFull
+	}
+	return dayOpen
+}
+
+// showCalendar показывает календарь выбранной мойки на месяц. Если
+// messageID не 0, календарь заменяет это сообщение
+func (b *CarWashBot) showCalendar(chatID int64, month time.Time, messageID int) {
+	l := b.tr(chatID)
+	location := b.selectedLocation(chatID)
+	markup, err := b.calendarKeyboard(l, location, month)
+	if err != nil {
+		log.Printf("Ошибка построения календаря: %v", err)
+		b.sendMessage(chatID, l.T("error.schedule"))
+		return
+	}
+
+	text := l.T("calendar.title")
+	if b.hasLocations() {
+		text = l.T("booking.location", locationName(l, location)) + "\n" + text
+	}
+
+	if messageID != 0 {
+		edit := tgbotapi.NewEditMessageTextAndMarkup(chatID, messageID, text, markup)
+		if _, err := b.botAPI.Request(edit); err != nil {
+			log.Printf("Ошибка обновления календаря: %v", err)
+		}
+		return
+	}
+
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ReplyMarkup = markup
+	b.sendMessageWithSave(chatID, msg)
+}
+
+func (b *CarWashBot) calendarKeyboard(l *i18n.Localizer, location *models.Location, month time.Time) (tgbotapi.InlineKeyboardMarkup, error) {
+	first, last := b.bookings.Window()
+	month = time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, time.Local)
+
+	occupancy, err := b.bookings.MonthOccupancy(context.Background(), location, month.Month(), month.Year())
+	if err != nil {
+		return tgbotapi.InlineKeyboardMarkup{}, err
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("%s %d", l.Month(month.Month()), month.Year()), "noop"),
+	))
+
+	var header []tgbotapi.InlineKeyboardButton
+	for i := range 7 {
+		day := time.Weekday((i + 1) % 7)
+		header = append(header, tgbotapi.NewInlineKeyboardButtonData(l.WeekdayShort(day), "noop"))
+	}
+	rows = append(rows, header)
+
+	// Неделя начинается с понедельника
+	offset := (int(month.Weekday()) + 6) % 7
+	week := make([]tgbotapi.InlineKeyboardButton, 0, 7)
+	for i := 0; i < offset; i++ {
+		week = append(week, tgbotapi.NewInlineKeyboardButtonData(" ", "noop"))
+	}
+
+	for day := month; day.Month() == month.Month(); day = day.AddDate(0, 0, 1) {
+		label, data := fmt.Sprint(day.Day()), "day_"+day.Format("02.01.2006")
+		switch b.dayStatus(location, day, occupancy) {
+		case dayUnavailable:
+			label, data = "·", "noop"
+		case dayClosed:
+			label, data = "✖️", "noop"
+		case dayFull:
+			label, data = "🔴", "noop"
+		}
+		week = append(week, tgbotapi.NewInlineKeyboardButtonData(label, data))
+
+		if len(week) == 7 {
+			rows = append(rows, week)
+			week = make([]tgbotapi.InlineKeyboardButton, 0, 7)
+		}
+	}
+	if len(week) > 0 {
+		for len(week) < 7 {
+			week = append(week, tgbotapi.NewInlineKeyboardButtonData(" ", "noop"))
+		}
+		rows = append(rows, week)
+	}
+
+	// Листаем только в пределах горизонта записи
+	prev := tgbotapi.NewInlineKeyboardButtonData(" ", "noop")
+	if month.After(first) {
+		prev = tgbotapi.NewInlineKeyboardButtonData("◀️", "cal_"+month.AddDate(0, -1, 0).Format("2006-01"))
+	}
+	next := tgbotapi.NewInlineKeyboardButtonData(" ", "noop")
+	if nextMonth := month.AddDate(0, 1, 0); !nextMonth.After(last) {
+		next = tgbotapi.NewInlineKeyboardButtonData("▶️", "cal_"+nextMonth.Format("2006-01"))
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(prev, next))
+
+	// В сети из нескольких моек можно вернуться к выбору мойки
+	if b.hasLocations() {
+		rows = append(rows, backButton(l, "location"))
+	} else {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(l.T("button.abort"), "wizard_abort"),
+		))
+	}
+	return tgbotapi.NewInlineKeyboardMarkup(rows...), nil
+}
+
+// handleCalendarNavigation листает календарь на месяц вида 2006-01
+func (b *CarWashBot) handleCalendarNavigation(chatID int64, messageID int, value string) {
+	month, err := time.ParseInLocation("2006-01", value, time.Local)
+	if err != nil {
+		return
+	}
+	b.showCalendar(chatID, month, messageID)
+}
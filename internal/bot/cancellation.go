@@ -0,0 +1,249 @@
+package bot
+
+import (
+	"carwash-bot/config"
+	"carwash-bot/internal/i18n"
+	"carwash-bot/internal/models"
+	"carwash-bot/internal/services"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const strikesHelp = `🚫 Команды для нарушений:
+/strikes Telegram_ID — поздние отмены и неявки клиента
+/strikes_reset Telegram_ID — обнулить счётчики`
+
+// bookingStart возвращает время начала записи
+func bookingStart(booking *models.Booking) (time.Time, error) {
+	return time.ParseInLocation("02.01.2006 15:04", booking.Date+" "+booking.Time, time.Local)
+}
+
+func (b *CarWashBot) askLateCancelConfirmation(chatID int64, booking *models.Booking) {
+	l := b.tr(chatID)
+	text := l.T("cancel.late_warning", formatHours(l, b.cfg.Cancel.FreeBefore), booking.Date, booking.Time)
+
+	if payment, err := b.storage.GetBookingPayment(context.Background(), booking.ID); err == nil &&
+		payment != nil && payment.Status == models.PaymentPaid {
+		if percent := b.cfg.Cancel.LateRefundPercent; percent > 0 {
+			text += "\n" + l.T("cancel.late_refund", percent)
+		} else {
+			text += "\n" + l.T("cancel.no_refund")
+		}
+	}
+	if b.cfg.Cancel.StrikeThreshold > 0 && b.cfg.Cancel.Restriction != config.RestrictNone {
+		text += "\n" + l.T("cancel.strikes",
+			b.cfg.Cancel.StrikeThreshold, restrictionName(l, b.cfg.Cancel.Restriction))
+	}
+
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(l.T("cancel.confirm_late"), "latecancel_"+booking.ID),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(l.T("cancel.keep"), "main_menu"),
+		),
+	)
+	b.sendMessageWithSave(chatID, msg)
+}
+
+// bookingRestriction возвращает ограничение, действующее для автора записи
+func (b *CarWashBot) bookingRestriction(booking *models.Booking) string {
+	// Корпоративные записи оплачивает организация
+	if booking.OrganizationID != 0 {
+		return config.RestrictNone
+	}
+
+	user, err := b.storage.GetUserByID(context.Background(), booking.UserID)
+	if err != nil || user == nil {
+		log.Printf("Ошибка получения пользователя: %v", err)
+		return config.RestrictNone
+	}
+	return b.userRestriction(user)
+}
+
+func (b *CarWashBot) userRestriction(user *models.User) string {
+	threshold := b.cfg.Cancel.StrikeThreshold
+	if threshold <= 0 || user.LateCancels+user.NoShows < threshold {
+		return config.RestrictNone
+	}
+	return b.cfg.Cancel.Restriction
+}
+
+func restrictionName(l *i18n.Localizer, restriction string) string {
+	switch restriction {
+	case config.RestrictPrepayment:
+		return l.T("restriction.prepayment")
+	case config.RestrictApproval:
+		return l.T("restriction.approval")
+	}
+	return l.T("restriction.none")
+}
+
+// formatHours выводит длительность в часах, например «2 ч»
+func formatHours(l *i18n.Localizer, d time.Duration) string {
+	if d%time.Hour == 0 {
+		return l.T("duration.hours", int(d.Hours()))
+	}
+	return l.T("duration.minutes", int(d.Minutes()))
+}
+
+// requestApproval отправляет администраторам мойки запись клиента с ограничением
+func (b *CarWashBot) requestApproval(booking *models.Booking) {
+	text := fmt.Sprintf(`⏳ Запись требует подтверждения:
+📅 %s в %s
+🧽 %s — %d ₽
+🚗 %s %s`,
+		booking.Date,
+		booking.Time,
+		b.serviceName(booking.Service),
+		booking.Price,
+		booking.CarModel,
+		booking.CarNumber)
+	if user, err := b.storage.GetUserByID(context.Background(), booking.UserID); err == nil && user != nil {
+		text += fmt.Sprintf("\n👤 %s (%d): поздних отмен %d, неявок %d",
+			user.FirstName, user.TelegramID, user.LateCancels, user.NoShows)
+	}
+
+	for _, adminID := range b.bookingAdmins(booking.LocationID) {
+		msg := tgbotapi.NewMessage(adminID, text)
+		msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("✅ Подтвердить", "approve_"+booking.ID),
+				tgbotapi.NewInlineKeyboardButtonData("❌ Отклонить", "reject_"+booking.ID),
+			),
+		)
+		b.deliver(msg)
+	}
+}
+
+func (b *CarWashBot) handleBookingApproval(chatID, userID int64, bookingID string, approved bool) {
+	if !b.isStaff(userID) {
+		b.sendMessage(chatID, "⛔ Подтверждать записи может только администратор.")
+		return
+	}
+
+	booking, err := b.storage.GetBooking(context.Background(), bookingID)
+	if err != nil || booking == nil || booking.Status != models.StatusPendingApproval {
+		b.sendMessage(chatID, "❌ Запись не найдена или уже обработана.")
+		return
+	}
+	if !b.canManageBooking(userID, booking) {
+		b.sendMessage(chatID, "⛔ Эта запись на другую мойку.")
+		return
+	}
+
+	customer, err := b.storage.GetUserByID(context.Background(), booking.UserID)
+	if err != nil || customer == nil {
+		log.Printf("Ошибка получения клиента: %v", err)
+		b.sendMessage(chatID, "⚠️ Ошибка системы.")
+		return
+	}
+
+	if !approved {
+		if _, err := b.bookings.Reject(context.Background(), booking); err != nil {
+			log.Printf("Ошибка отклонения записи: %v", err)
+			b.sendMessage(chatID, "⚠️ Не удалось отклонить запись.")
+			return
+		}
+		b.sendMessage(chatID, fmt.Sprintf("❌ Запись %s %s отклонена", booking.Date, booking.Time))
+		b.sendMessage(customer.TelegramID, b.tr(customer.TelegramID).T("booking.rejected", booking.Date, booking.Time))
+		b.recheckFreeVisits(customer.ID)
+		return
+	}
+
+	booking, err = b.bookings.Approve(context.Background(), booking)
+	if err != nil {
+		log.Printf("Ошибка подтверждения записи: %v", err)
+		b.sendMessage(chatID, "❌ Запись не найдена или уже обработана.")
+		return
+	}
+
+	b.sendMessage(chatID, fmt.Sprintf("✅ Запись %s %s подтверждена", booking.Date, booking.Time))
+	b.sendBookingConfirmation(customer.TelegramID, booking)
+	b.notifyAdmin(booking)
+}
+
+// handleNoShow вызывается администратором, если клиент не приехал на мойку
+func (b *CarWashBot) handleNoShow(chatID, userID int64, bookingID string) {
+	if !b.isStaff(userID) {
+		b.sendMessage(chatID, "⛔ Отмечать неявки может только администратор.")
+		return
+	}
+
+	booking, err := b.storage.GetBooking(context.Background(), bookingID)
+	if err != nil || booking == nil {
+		log.Printf("Ошибка получения записи: %v", err)
+		b.sendMessage(chatID, "❌ Запись не найдена.")
+		return
+	}
+	if !b.canManageBooking(userID, booking) {
+		b.sendMessage(chatID, "⛔ Эта запись на другую мойку.")
+		return
+	}
+
+	booking, err = b.bookings.MarkNoShow(context.Background(), booking)
+	if errors.Is(err, services.ErrNotStarted) {
+		b.sendMessage(chatID, "❌ Мойка ещё не началась.")
+		return
+	}
+	if err != nil {
+		log.Printf("Ошибка отметки неявки: %v", err)
+		b.sendMessage(chatID, "❌ Запись уже выполнена или отменена.")
+		return
+	}
+
+	b.sendMessage(chatID, fmt.Sprintf("🚫 Неявка отмечена: %s %s — %s %s",
+		booking.Date, booking.Time, booking.CarModel, booking.CarNumber))
+
+	customer, err := b.storage.GetUserByID(context.Background(), booking.UserID)
+	if err != nil || customer == nil {
+		log.Printf("Ошибка получения клиента: %v", err)
+		return
+	}
+	l := b.tr(customer.TelegramID)
+	text := l.T("booking.no_show", booking.Date, booking.Time)
+	if restriction := b.userRestriction(customer); restriction != config.RestrictNone {
+		text += "\n" + l.T("booking.restricted", restrictionName(l, restriction))
+	}
+	b.sendMessage(customer.TelegramID, text)
+}
+
+func (b *CarWashBot) handleStrikesCommand(chatID, userID int64, text string) {
+	if !b.isAdmin(userID) {
+		b.sendMessage(chatID, "⛔ Команда доступна только администратору.")
+		return
+	}
+
+	fields := strings.Fields(text)
+	if len(fields) < 2 {
+		b.sendMessage(chatID, strikesHelp)
+		return
+	}
+	user := b.findCustomer(chatID, fields[1])
+	if user == nil {
+		return
+	}
+
+	switch fields[0] {
+	case "/strikes":
+		b.sendMessage(chatID, fmt.Sprintf("🚫 %s (%d)\nПоздних отмен: %d\nНеявок: %d\nЗапись: %s",
+			user.FirstName, user.TelegramID, user.LateCancels, user.NoShows,
+			restrictionName(i18n.New(i18n.Default), b.userRestriction(user))))
+	case "/strikes_reset":
+		if err := b.storage.ResetUserStrikes(context.Background(), user.ID); err != nil {
+			log.Printf("Ошибка сброса нарушений: %v", err)
+			b.sendMessage(chatID, "⚠️ Не удалось обнулить счётчики.")
+			return
+		}
+		b.sendMessage(chatID, fmt.Sprintf("✅ Счётчики клиента %d обнулены", user.TelegramID))
+	default:
+		b.sendMessage(chatID, strikesHelp)
+	}
+}
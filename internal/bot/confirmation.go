@@ -0,0 +1,88 @@
+package bot
+
+import (
+	"carwash-bot/internal/models"
+	"log"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// showConfirmation показывает итог записи перед сохранением. Время
+// удерживается за пользователем, пока он не подтвердит или не уйдёт
+func (b *CarWashBot) showConfirmation(chatID, userID int64, booking *models.Booking) {
+	l := b.tr(userID)
+
+	// Продлеваем удержание: пользователь мог долго вводить данные
+	err := b.holdSlot(userID, booking.Date, booking.Time)
+	if isSlotError(err) {
+		b.setNotice(userID, l.T("time.lost"))
+		b.handleWizardBack(chatID, userID, "time")
+		return
+	}
+	if err != nil {
+		log.Printf("Ошибка удержания времени: %v", err)
+		b.sendMessage(chatID, l.T("error.system"))
+		return
+	}
+
+	b.applyLoyalty(booking)
+
+	state := b.userStates[userID]
+	state.AwaitingCarInfo = false
+	state.AwaitingPromo = false
+	state.AwaitingConfirm = true
+	state.Draft = booking
+	b.userStates[userID] = state
+
+	b.renderConfirmation(chatID, userID)
+}
+
+func (b *CarWashBot) renderConfirmation(chatID, userID int64) {
+	l := b.tr(userID)
+	state := b.userStates[userID]
+	text := l.T("confirm.title", b.bookingSummary(l, state.Draft), l.N("minutes", int(b.cfg.Hold.Minutes())))
+
+	b.showWizardStep(chatID, userID, text, tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(l.T("confirm.ok"), "book_confirm"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(l.T("confirm.edit"), "book_edit"),
+			tgbotapi.NewInlineKeyboardButtonData(l.T("button.abort"), "wizard_abort"),
+		),
+	))
+}
+
+func (b *CarWashBot) handleBookingConfirm(chatID, userID int64) {
+	state, exists := b.userStates[userID]
+	if !exists || !state.AwaitingConfirm || state.Draft == nil {
+		b.sendMessage(chatID, b.tr(userID).T("confirm.stale"))
+		return
+	}
+
+	b.saveBooking(chatID, userID, state.Draft)
+}
+
+// showBookingEditMenu предлагает вернуться к нужному шагу записи
+func (b *CarWashBot) showBookingEditMenu(chatID, userID int64) {
+	l := b.tr(userID)
+	state, exists := b.userStates[userID]
+	if !exists || state.Draft == nil {
+		b.sendMessage(chatID, l.T("confirm.stale"))
+		return
+	}
+
+	b.showWizardStep(chatID, userID, l.T("confirm.edit_title"), tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(l.T("confirm.edit_date"), "back_calendar"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(l.T("confirm.edit_time"), "back_time"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(l.T("confirm.edit_service"), "back_service"),
+			tgbotapi.NewInlineKeyboardButtonData(l.T("confirm.edit_car"), "back_car"),
+		),
+		backButton(l, "confirm"),
+	))
+}
@@ -0,0 +1,222 @@
+package bot
+
+import (
+	"carwash-bot/internal/models"
+	"carwash-bot/internal/scheduler"
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// runJobs запускает ежедневные сводки администраторам
+func (b *CarWashBot) runJobs() {
+	jobs := scheduler.New()
+	if at := b.cfg.Digest.Morning; at != "" {
+		if err := jobs.Daily("утренняя сводка", at, b.sendMorningDigest); err != nil {
+			log.Printf("Ошибка настройки сводки: %v", err)
+		}
+	}
+	if at := b.cfg.Digest.Evening; at != "" {
+		if err := jobs.Daily("итоги дня", at, b.sendEveningReport); err != nil {
+			log.Printf("Ошибка настройки отчёта: %v", err)
+		}
+	}
+	jobs.Run(time.Minute)
+}
+
+func (b *CarWashBot) handleDigestCommand(chatID, userID int64, text string) {
+	scope, ok := b.staffLocations(userID)
+	if !ok {
+		b.sendMessage(chatID, "⛔ Команда доступна только администратору.")
+		return
+	}
+
+	var (
+		msg string
+		err error
+	)
+	if strings.HasPrefix(text, "/report") {
+		msg, err = b.eveningReportText(time.Now(), userID, scope)
+	} else {
+		msg, err = b.morningDigestText(time.Now(), userID, scope)
+	}
+	if err != nil {
+		log.Printf("Ошибка подготовки сводки: %v", err)
+		b.sendMessage(chatID, "⚠️ Не удалось подготовить сводку.")
+		return
+	}
+	if msg == "" {
+		b.sendMessage(chatID, "✖️ Сегодня выходной, записей нет.")
+		return
+	}
+	chunks := splitMessage(msg, maxMessageLength)
+	if len(chunks) == 1 {
+		b.sendMessage(chatID, chunks[0])
+		return
+	}
+	msgs := make([]tgbotapi.MessageConfig, len(chunks))
+	for i, chunk := range chunks {
+		msgs[i] = tgbotapi.NewMessage(chatID, chunk)
+	}
+	b.deliverLater(msgs...)
+}
+
+// sendMorningDigest рассылает утренние сводки. В выходной сводку
+// получают только администраторы, у чьих моек всё же есть записи
+func (b *CarWashBot) sendMorningDigest(now time.Time) {
+	for adminID, scope := range b.digestRecipients() {
+		text, err := b.morningDigestText(now, adminID, scope)
+		if err != nil {
+			log.Printf("Ошибка подготовки утренней сводки: %v", err)
+			continue
+		}
+		if text != "" {
+			b.sendToAdmin(adminID, text)
+		}
+	}
+}
+
+func (b *CarWashBot) sendEveningReport(now time.Time) {
+	for adminID, scope := range b.digestRecipients() {
+		text, err := b.eveningReportText(now, adminID, scope)
+		if err != nil {
+			log.Printf("Ошибка подготовки итогов дня: %v", err)
+			continue
+		}
+		if text != "" {
+			b.sendToAdmin(adminID, text)
+		}
+	}
+}
+
+// digestRecipients возвращает получателей сводок и их мойки: главный
+// администратор получает сводку по всей сети, администраторы моек — по своим
+func (b *CarWashBot) digestRecipients() map[int64][]int64 {
+	recipients := map[int64][]int64{b.adminID: nil}
+
+	locations, err := b.storage.GetLocations(context.Background())
+	if err != nil {
+		log.Printf("Ошибка получения моек: %v", err)
+		return recipients
+	}
+	for _, location := range locations {
+		for _, adminID := range location.Admins {
+			if adminID != b.adminID {
+				recipients[adminID] = append(recipients[adminID], location.ID)
+			}
+		}
+	}
+	return recipients
+}
+
+// sendToAdmin доставляет сводку через outbox, разбивая длинный текст
+func (b *CarWashBot) sendToAdmin(adminID int64, text string) {
+	for _, chunk := range splitMessage(text, maxMessageLength) {
+		b.deliver(tgbotapi.NewMessage(adminID, chunk))
+	}
+}
+
+// reportLocation возвращает мойку, если сводка охватывает ровно одну
+// мойку, иначе nil
+func reportLocation(locations map[int64]*models.Location, scope []int64) *models.Location {
+	if scope == nil && len(locations) == 1 {
+		return locations[0]
+	}
+	if len(scope) == 1 {
+		return locations[scope[0]]
+	}
+	return nil
+}
+
+// morningDigestText — записи на день на мойках scope с услугами и
+// телефонами клиентов, nil — по всей сети. Пустая строка, если сеть
+// не работает и записей нет
+func (b *CarWashBot) morningDigestText(now time.Time, adminID int64, scope []int64) (string, error) {
+	filter := models.ScheduleFilter{Locations: scope}
+	bookings, err := b.storage.GetSchedule(context.Background(), []string{now.Format("02.01.2006")}, filter)
+	if err != nil {
+		return "", err
+	}
+	if len(bookings) == 0 && b.cfg.IsClosed(now) {
+		return "", nil
+	}
+	locations, err := b.locationIndex()
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("☀️ Записи на сегодня, %s:\n", b.tr(adminID).Date(now)))
+	if location := reportLocation(locations, scope); location != nil && len(locations) > 1 {
+		sb.WriteString("📍 " + locationName(nil, location) + "\n")
+	}
+	sb.WriteString("\n")
+	if len(bookings) == 0 {
+		sb.WriteString("Записей нет.")
+		return sb.String(), nil
+	}
+
+	for _, booking := range bookings {
+		sb.WriteString(b.adminScheduleLine(booking, locations) + "\n")
+		sb.WriteString("    🧽 " + b.serviceName(booking.Service))
+		if booking.User != nil && booking.User.Phone != "" {
+			sb.WriteString(" 📱 " + booking.User.Phone)
+		}
+		sb.WriteString("\n")
+	}
+	sb.WriteString(fmt.Sprintf("\nВсего записей: %d", len(bookings)))
+	return sb.String(), nil
+}
+
+// eveningReportText — итоги дня на мойках scope, nil — по всей сети.
+// Пустая строка, если мойка не работала и записей не было
+func (b *CarWashBot) eveningReportText(now time.Time, adminID int64, scope []int64) (string, error) {
+	report, err := b.storage.GetDayReport(context.Background(), now.Format("02.01.2006"), scope)
+	if err != nil {
+		return "", err
+	}
+	locations, err := b.locationIndex()
+	if err != nil {
+		return "", err
+	}
+	location := reportLocation(locations, scope)
+	total := report.Completed + report.NoShows + report.Cancelled + report.Unmarked
+	if total == 0 && b.cfg.IsClosed(now) {
+		return "", nil
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("🌙 Итоги дня, %s:\n", b.tr(adminID).Date(now)))
+	if location != nil && len(locations) > 1 {
+		sb.WriteString("📍 " + locationName(nil, location) + "\n")
+	}
+	sb.WriteString("\n")
+	sb.WriteString(fmt.Sprintf("✅ Выполнено: %d\n", report.Completed))
+	sb.WriteString(fmt.Sprintf("🚫 Не приехали: %d\n", report.NoShows))
+	sb.WriteString(fmt.Sprintf("❌ Отмен: %d (поздних: %d)\n", report.Cancelled, report.LateCancels))
+	if report.Unmarked > 0 {
+		sb.WriteString(fmt.Sprintf("⏳ Не отмечено: %d\n", report.Unmarked))
+	}
+
+	sb.WriteString(fmt.Sprintf("\n💰 Выручка: %d ₽\n", report.Revenue))
+	for _, stats := range report.Services {
+		sb.WriteString(fmt.Sprintf("  • %s — %d шт., %d ₽\n", b.serviceName(stats.Service), stats.Count, stats.Revenue))
+	}
+
+	// Загрузку постов считаем, только если сводка по одной мойке:
+	// номера постов у моек сети совпадают
+	if location == nil {
+		return sb.String(), nil
+	}
+	slots := len(b.bookings.SlotTimes(location))
+	sb.WriteString("\n🅿️ Загрузка постов:\n")
+	for bay := 1; bay <= b.bookings.Bays(location); bay++ {
+		booked := report.Bays[bay]
+		sb.WriteString(fmt.Sprintf("  Пост %d: %d из %d (%d%%)\n", bay, booked, slots, booked*100/max(slots, 1)))
+	}
+	return sb.String(), nil
+}
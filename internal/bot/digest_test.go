@@ -0,0 +1,54 @@
+package bot
+
+import (
+	"carwash-bot/internal/models"
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestMorningDigestOnClosedDay проверяет, что в выходной сводку получают
+// администраторы моек, на которые всё же есть записи
+func TestMorningDigestOnClosedDay(t *testing.T) {
+	cfg := testConfig()
+	cfg.Closed = []int{1, 2, 3, 4, 5, 6, 7}
+	b, telegram, store := newTestBot(t, cfg)
+	ctx := context.Background()
+
+	busy := &models.Location{Name: "Северная", StartTime: 9, EndTime: 18, Bays: 1}
+	idle := &models.Location{Name: "Южная", StartTime: 9, EndTime: 18, Bays: 1}
+	for admin, location := range map[int64]*models.Location{2000: busy, 3000: idle} {
+		if err := store.CreateLocation(ctx, location); err != nil {
+			t.Fatalf("CreateLocation: %v", err)
+		}
+		if err := store.AddLocationAdmin(ctx, location.ID, admin); err != nil {
+			t.Fatalf("AddLocationAdmin: %v", err)
+		}
+	}
+
+	if err := store.CreateOrUpdateUser(ctx, &models.User{TelegramID: 42, FirstName: "Тест"}); err != nil {
+		t.Fatalf("CreateOrUpdateUser: %v", err)
+	}
+	user, _ := store.GetUserByTelegramID(ctx, 42)
+	now := time.Now()
+	// Запись сделана до того, как день объявили выходным
+	err := store.CreateBooking(ctx, &models.Booking{UserID: user.ID, LocationID: busy.ID,
+		Date: now.Format("02.01.2006"), Time: "23:00", CarModel: "Kia", CarNumber: "A123BC",
+		Service: "express", Price: 400})
+	if err != nil {
+		t.Fatalf("CreateBooking: %v", err)
+	}
+
+	b.sendMorningDigest(now)
+
+	for _, adminID := range []int64{cfg.AdminID, 2000} {
+		texts := telegram.texts(adminID)
+		if len(texts) != 1 || !strings.Contains(texts[0], "A123BC") {
+			t.Errorf("сводка администратору %d: %q; нужна запись A123BC", adminID, texts)
+		}
+	}
+	if texts := telegram.texts(3000); len(texts) != 0 {
+		t.Errorf("администратор мойки без записей получил сводку в выходной: %q", texts)
+	}
+}
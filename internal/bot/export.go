@@ -0,0 +1,146 @@
+package bot
+
+import (
+	"bytes"
+	"carwash-bot/internal/export"
+	"carwash-bot/internal/models"
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const exportHelp = `📤 Выгрузка для Excel:
+/export — записи за текущий месяц в XLSX
+/export bookings csv 30 — записи за 30 дней в CSV
+/export bookings prev status=completed — выполненные за прошлый месяц
+/export bookings 01.10.2026-15.10.2026 service=complex bay=2
+/export bookings location=2 — записи мойки сети
+/export customers — все клиенты
+
+Период — как в /stats: today, week, 30, month, prev или ДД.ММ.ГГГГ-ДД.ММ.ГГГГ.
+Статусы: active, awaiting_payment, pending_approval, completed, no_show, cancelled, expired.`
+
+// exportRequest — разобранные параметры команды /export
+type exportRequest struct {
+	kind     string // bookings или customers
+	format   string
+	period   string
+	from, to time.Time
+	filter   models.ScheduleFilter
+}
+
+func parseExportRequest(text string, now time.Time) (exportRequest, error) {
+	req := exportRequest{kind: "bookings", format: export.FormatXLSX, period: "month"}
+	for _, arg := range strings.Fields(text)[1:] {
+		key, value, isFilter := strings.Cut(arg, "=")
+		switch {
+		case arg == "bookings" || arg == "customers":
+			req.kind = arg
+		case arg == export.FormatCSV || arg == export.FormatXLSX:
+			req.format = arg
+		case isFilter && key == "status":
+			if !slices.Contains(scheduleStatuses[1:], value) && value != models.StatusExpired {
+				return req, fmt.Errorf("неизвестный статус: %s", value)
+			}
+			req.filter.Status = value
+		case isFilter && key == "service":
+			req.filter.Service = value
+		case isFilter && key == "bay":
+			bay, err := strconv.Atoi(value)
+			if err != nil || bay < 1 {
+				return req, fmt.Errorf("неверный номер поста: %s", value)
+			}
+			req.filter.Bay = bay
+		case isFilter && key == "location":
+			location, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || location < 0 {
+				return req, fmt.Errorf("неверный ID мойки: %s", value)
+			}
+			req.filter.Locations = []int64{location}
+		case isFilter:
+			return req, fmt.Errorf("неизвестный фильтр: %s", key)
+		default:
+			req.period = arg
+		}
+	}
+
+	var err error
+	req.from, req.to, err = statsPeriod(req.period, now)
+	return req, err
+}
+
+func (b *CarWashBot) handleExportCommand(chatID, userID int64, text string) {
+	scope, ok := b.staffLocations(userID)
+	if !ok {
+		b.sendMessage(chatID, "⛔ Команда доступна только администратору.")
+		return
+	}
+	if strings.TrimSpace(text) == "/export help" {
+		b.sendMessage(chatID, exportHelp)
+		return
+	}
+
+	req, err := parseExportRequest(text, time.Now())
+	if err != nil {
+		b.sendMessage(chatID, "❌ "+err.Error()+"\n\n"+exportHelp)
+		return
+	}
+	if _, ok := b.cfg.GetService(req.filter.Service); req.filter.Service != "" && !ok {
+		b.sendMessage(chatID, "❌ Неизвестная услуга: "+req.filter.Service)
+		return
+	}
+
+	// Администратор мойки выгружает только записи своих моек
+	if scope != nil {
+		if req.kind == "customers" {
+			b.sendMessage(chatID, "⛔ Клиентов выгружает только главный администратор.")
+			return
+		}
+		if req.filter.Locations == nil {
+			req.filter.Locations = scope
+		} else if !slices.Contains(scope, req.filter.Locations[0]) {
+			b.sendMessage(chatID, "⛔ Эта мойка вам недоступна.")
+			return
+		}
+	}
+
+	exporter := export.New(b.storage, b.cfg)
+	var (
+		table  *export.Table
+		period string
+	)
+	if req.kind == "customers" {
+		table, err = exporter.Customers(context.Background())
+	} else {
+		table, err = exporter.Bookings(context.Background(), req.from, req.to, req.filter)
+		period = req.from.Format("02.01.2006") + "-" + req.to.Format("02.01.2006")
+	}
+	if err != nil {
+		log.Printf("Ошибка выгрузки: %v", err)
+		b.sendMessage(chatID, "⚠️ Не удалось подготовить выгрузку.")
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := export.Write(&buf, req.format, table); err != nil {
+		log.Printf("Ошибка выгрузки: %v", err)
+		b.sendMessage(chatID, "⚠️ Не удалось подготовить выгрузку.")
+		return
+	}
+
+	doc := tgbotapi.NewDocument(chatID, tgbotapi.FileBytes{
+		Name:  export.FileName(req.kind, period, req.format),
+		Bytes: buf.Bytes(),
+	})
+	doc.Caption = fmt.Sprintf("📤 %s: %d строк", table.Name, len(table.Rows))
+	if _, err := b.botAPI.Send(doc); err != nil {
+		log.Printf("Ошибка отправки выгрузки: %v", err)
+		b.sendMessage(chatID, "⚠️ Не удалось отправить файл.")
+	}
+}
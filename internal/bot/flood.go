@@ -0,0 +1,43 @@
+package bot
+
+import (
+	"log"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Как часто напоминать пользователю, что он пишет слишком часто
+const floodNoticeInterval = 30 * time.Second
+
+// allowUpdate проверяет, не превысил ли пользователь частоту запросов.
+// Платежи и запросы администратора не ограничиваются
+func (b *CarWashBot) allowUpdate(update tgbotapi.Update) bool {
+	var userID int64
+	switch {
+	case update.Message != nil:
+		if update.Message.SuccessfulPayment != nil || update.Message.From == nil {
+			return true
+		}
+		userID = update.Message.From.ID
+	case update.CallbackQuery != nil:
+		userID = update.CallbackQuery.From.ID
+	default:
+		return true
+	}
+
+	if b.isAdmin(userID) || b.flood.Allow(userID) {
+		return true
+	}
+
+	if update.CallbackQuery != nil {
+		// На нажатие нужно ответить, иначе кнопка «зависнет»
+		callback := tgbotapi.NewCallback(update.CallbackQuery.ID, b.tr(userID).T("flood.notice"))
+		if _, err := b.botAPI.Request(callback); err != nil {
+			log.Printf("Ошибка ответа на callback: %v", err)
+		}
+	} else if b.floodNotice.Allow(userID) {
+		b.sendMessage(update.Message.Chat.ID, b.tr(userID).T("flood.notice"))
+	}
+	return false
+}
@@ -1,12 +1,16 @@
 package bot
 
 import (
+	"carwash-bot/config"
+	"carwash-bot/internal/i18n"
 	"carwash-bot/internal/models"
+	"carwash-bot/internal/services"
 	"context"
+	"errors"
 	"fmt"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"log"
-	"sort"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -16,47 +20,133 @@ func (b *CarWashBot) handleMessage(msg *tgbotapi.Message) {
 	userID := msg.From.ID
 	text := msg.Text
 
+	if msg.SuccessfulPayment != nil {
+		b.handleSuccessfulPayment(msg)
+		return
+	}
+
 	// Сначала сохраняем/обновляем пользователя
 	user := &models.User{
 		TelegramID: userID,
 		Username:   msg.From.UserName,
 		FirstName:  msg.From.FirstName,
 		LastName:   msg.From.LastName,
+		Language:   i18n.Detect(msg.From.LanguageCode),
 	}
 	if err := b.storage.CreateOrUpdateUser(context.Background(), user); err != nil {
 		log.Printf("Ошибка сохранения пользователя: %v", err)
 	}
 
+	b.expireWizard(userID)
+
+	if msg.Contact != nil {
+		b.handleContact(chatID, userID, msg.Contact)
+		return
+	}
+
+	// Геопозицию присылают, чтобы записаться на ближайшую мойку
+	if msg.Location != nil {
+		b.handleSharedLocation(chatID, userID, msg.Location)
+		return
+	}
+
+	if isAbort(text) {
+		b.abortWizard(chatID, userID, 0)
+		return
+	}
+
 	// Проверяем состояние пользователя
-	if state, exists := b.userStates[userID]; exists {
-		if state.AwaitingCarInfo {
-			b.handleCarInfoInput(chatID, userID, text)
+	if state, exists := b.userStates[userID]; exists && (state.AwaitingCarInfo || state.AwaitingPromo) {
+		if isCommand(b.tr(userID), text) {
+			// Пользователь ушёл в меню — брошенную запись сбрасываем
+			b.resetWizard(userID)
+		} else {
+			// Ответ пользователя убираем, мастер записи покажет его в своём сообщении
+			b.botAPI.Request(tgbotapi.NewDeleteMessage(chatID, msg.MessageID))
+			if state.AwaitingCarInfo {
+				b.handleCarInfoInput(chatID, userID, text)
+			} else {
+				b.handlePromoInput(chatID, userID, text)
+			}
 			return
 		}
 	}
 
 	// Обрабатываем команды
 	switch {
-	case text == "/start" || text == "/menu" || text == "🏠 Главное меню":
+	case text == "/start" || text == "/menu" || i18n.Matches("menu.main", text):
 		b.sendWelcomeMessage(chatID)
 
-	case text == "📝 Записаться" || text == "/book":
-		b.showDaySelection(chatID)
+	case text == "/book" || i18n.Matches("menu.book", text):
+		if b.canBook(chatID, userID) {
+			b.startBooking(chatID, userID)
+		}
 
-	case text == "🕒 Расписание" || text == "/schedule":
-		b.showSchedule(chatID)
+	case text == "/schedule" || i18n.Matches("menu.schedule", text):
+		b.showSchedule(chatID, userID)
 
-	case text == "❌ Мои записи" || text == "/mybookings":
+	case text == "/mybookings" || i18n.Matches("menu.my_bookings", text):
 		b.showUserBookings(chatID, userID)
 
-	case text == "🧹 Очистить чат" || text == "/clear":
+	case text == "/clear" || i18n.Matches("menu.clear", text):
 		b.clearChat(chatID)
 
-	case text == "❌ Отменить запись" || text == "/cancel":
+	case text == "/cancel" || i18n.Matches("menu.cancel", text):
 		b.handleCancelCommand(chatID, userID)
 
+	case text == "/profile" || i18n.Matches("menu.profile", text):
+		b.showProfile(chatID, userID)
+
+	case text == "/language":
+		b.showLanguageMenu(chatID, userID)
+
+	case text == "/phone":
+		b.askPhone(chatID, userID)
+
+	case strings.HasPrefix(text, "/org"):
+		b.handleOrganizationCommand(chatID, userID, text)
+
+	case strings.HasPrefix(text, "/location"):
+		b.handleLocationCommand(chatID, userID, text)
+
+	case strings.HasPrefix(text, "/points"):
+		b.handlePointsCommand(chatID, userID, text)
+
+	case strings.HasPrefix(text, "/promo"):
+		b.handlePromoCommand(chatID, userID, text)
+
+	case strings.HasPrefix(text, "/refund"):
+		b.handleRefundsCommand(chatID, userID, text)
+
+	case strings.HasPrefix(text, "/strikes"):
+		b.handleStrikesCommand(chatID, userID, text)
+
+	case strings.HasPrefix(text, "/outbox"):
+		b.handleOutboxCommand(chatID, userID, text)
+
+	case strings.HasPrefix(text, "/limits"):
+		b.handleLimitsCommand(chatID, userID, text)
+
+	case strings.HasPrefix(text, "/export"):
+		b.handleExportCommand(chatID, userID, text)
+
+	case strings.HasPrefix(text, "/stats"):
+		b.handleStatsCommand(chatID, userID, text)
+
+	case strings.HasPrefix(text, "/digest") || strings.HasPrefix(text, "/report"):
+		b.handleDigestCommand(chatID, userID, text)
+
+	case strings.HasPrefix(text, "/calendar"):
+		b.handleCalendarCommand(chatID, userID, text)
+
+	case strings.HasPrefix(text, "/template"):
+		b.handleTemplateCommand(chatID, userID, text)
+
+	case strings.HasPrefix(text, "/ban") || strings.HasPrefix(text, "/unban"):
+		b.handleBanCommand(chatID, userID, text)
+
 	default:
-		b.sendMessage(chatID, "Я не понимаю эту команду. Используйте кнопки меню.")
+		b.sendMessage(chatID, b.tr(userID).T("menu.unknown"))
 	}
 }
 
@@ -70,183 +160,318 @@ func (b *CarWashBot) handleCallbackQuery(query *tgbotapi.CallbackQuery) {
 		log.Printf("Ошибка ответа на callback: %v", err)
 	}
 
+	b.expireWizard(userID)
+
 	switch {
+	case data == "noop":
+		// Заголовки календаря, пустые клетки и занятое время
+
+	case strings.HasPrefix(data, "back_"):
+		b.handleWizardBack(chatID, userID, strings.TrimPrefix(data, "back_"))
+
+	case strings.HasPrefix(data, "cal_"):
+		b.handleCalendarNavigation(chatID, query.Message.MessageID, strings.TrimPrefix(data, "cal_"))
+
+	case strings.HasPrefix(data, "sched_"):
+		b.handleScheduleCallback(chatID, userID, query.Message.MessageID, strings.TrimPrefix(data, "sched_"))
+
+	case strings.HasPrefix(data, "stats_") || strings.HasPrefix(data, "chart_"):
+		b.handleStatsCallback(chatID, userID, query.Message.MessageID, data)
+
+	case strings.HasPrefix(data, "loc_"):
+		b.handleLocationSelection(chatID, userID, query.Message.MessageID, strings.TrimPrefix(data, "loc_"))
+
 	case strings.HasPrefix(data, "day_"):
 		dateStr := strings.TrimPrefix(data, "day_")
-		b.handleDaySelection(chatID, userID, dateStr)
+		b.handleDaySelection(chatID, userID, query.Message.MessageID, dateStr)
 
 	case strings.HasPrefix(data, "time_"):
 		timeStr := strings.TrimPrefix(data, "time_")
 		b.handleTimeSelection(chatID, userID, timeStr)
 
+	case strings.HasPrefix(data, "service_"):
+		code := strings.TrimPrefix(data, "service_")
+		b.handleServiceSelection(chatID, userID, code)
+
+	case strings.HasPrefix(data, "vehicle_"):
+		vehicleID, _ := strconv.ParseInt(strings.TrimPrefix(data, "vehicle_"), 10, 64)
+		b.handleVehicleSelection(chatID, userID, vehicleID)
+
+	case data == "lang_menu":
+		b.showLanguageMenu(chatID, userID)
+
+	case strings.HasPrefix(data, "lang_"):
+		b.handleLanguageSelection(chatID, userID, strings.TrimPrefix(data, "lang_"))
+
 	case data == "main_menu":
+		b.resetWizard(userID)
 		b.sendWelcomeMessage(chatID)
 
 	case strings.HasPrefix(data, "cancel_"):
 		bookingID := strings.TrimPrefix(data, "cancel_")
-		b.handleBookingCancellation(chatID, userID, bookingID)
+		b.handleBookingCancellation(chatID, userID, bookingID, false)
+
+	case strings.HasPrefix(data, "latecancel_"):
+		bookingID := strings.TrimPrefix(data, "latecancel_")
+		b.handleBookingCancellation(chatID, userID, bookingID, true)
+
+	case strings.HasPrefix(data, "pay_"):
+		paymentID := strings.TrimPrefix(data, "pay_")
+		b.handleOfflinePayment(chatID, paymentID)
+
+	case data == "book_confirm":
+		b.handleBookingConfirm(chatID, userID)
+
+	case data == "book_edit":
+		b.showBookingEditMenu(chatID, userID)
+
+	case data == "wizard_abort":
+		b.abortWizard(chatID, userID, query.Message.MessageID)
+
+	case data == "promo_skip":
+		b.handlePromoSkip(chatID, userID)
+
+	case strings.HasPrefix(data, "complete_"):
+		bookingID := strings.TrimPrefix(data, "complete_")
+		b.handleBookingCompletion(chatID, userID, bookingID)
+
+	case strings.HasPrefix(data, "noshow_"):
+		bookingID := strings.TrimPrefix(data, "noshow_")
+		b.handleNoShow(chatID, userID, bookingID)
+
+	case strings.HasPrefix(data, "approve_"):
+		bookingID := strings.TrimPrefix(data, "approve_")
+		b.handleBookingApproval(chatID, userID, bookingID, true)
+
+	case strings.HasPrefix(data, "reject_"):
+		bookingID := strings.TrimPrefix(data, "reject_")
+		b.handleBookingApproval(chatID, userID, bookingID, false)
 
 	default:
 		log.Printf("Неизвестный callback: %s", data)
 	}
 }
 func (b *CarWashBot) sendWelcomeMessage(chatID int64) {
-	msg := tgbotapi.NewMessage(chatID, `🚗 *Добро пожаловать в бота автомойки!* 🧼
-    
-Выберите действие:`)
-	msg.ParseMode = "Markdown"
+	l := b.tr(chatID)
+	text, custom := b.messageText(models.TemplateWelcome, l, b.welcomeTemplateData(chatID))
+	msg := tgbotapi.NewMessage(chatID, text)
+	if !custom {
+		msg.ParseMode = "Markdown"
+	}
 	msg.ReplyMarkup = tgbotapi.NewReplyKeyboard(
 		tgbotapi.NewKeyboardButtonRow(
-			tgbotapi.NewKeyboardButton("📝 Записаться"),
-			tgbotapi.NewKeyboardButton("🕒 Расписание"),
+			tgbotapi.NewKeyboardButton(l.T("menu.book")),
+			tgbotapi.NewKeyboardButton(l.T("menu.schedule")),
 		),
 		tgbotapi.NewKeyboardButtonRow(
-			tgbotapi.NewKeyboardButton("❌ Отменить запись"),
-			tgbotapi.NewKeyboardButton("🧹 Очистить чат"),
+			tgbotapi.NewKeyboardButton(l.T("menu.cancel")),
+			tgbotapi.NewKeyboardButton(l.T("menu.clear")),
+		),
+		tgbotapi.NewKeyboardButtonRow(
+			tgbotapi.NewKeyboardButton(l.T("menu.profile")),
 		),
 	)
 	b.sendMessageWithSave(chatID, msg)
 }
 
 func (b *CarWashBot) handleTimeSelection(chatID, userID int64, timeStr string) {
-	state := b.userStates[userID]
+	l := b.tr(userID)
+	state, exists := b.userStates[userID]
+	if !exists || !state.AwaitingTime {
+		b.sendMessage(chatID, l.T("day.first"))
+		b.showDaySelection(chatID)
+		return
+	}
 
-	// Проверяем доступность времени
-	available, err := b.storage.IsTimeAvailable(context.Background(), state.SelectedDate, timeStr)
+	// Удерживаем время, пока пользователь оформляет запись
+	err := b.holdSlot(userID, state.SelectedDate, timeStr)
+	if isSlotError(err) {
+		b.setNotice(userID, l.T("time.taken"))
+		b.showTimeSlots(chatID, userID, state.SelectedDate)
+		return
+	}
 	if err != nil {
 		log.Printf("Ошибка проверки времени: %v", err)
-		b.sendMessage(chatID, "⚠️ Ошибка системы. Попробуйте позже.")
+		b.sendMessage(chatID, l.T("error.system"))
 		return
 	}
 
-	if !available {
-		b.sendMessage(chatID, "❌ Это время уже занято! Выберите другое время.")
-		b.showTimeSlots(chatID, state.SelectedDate)
+	state.AwaitingTime = false
+	state.AwaitingService = true
+	state.SelectedTime = timeStr
+	b.userStates[userID] = state
+
+	b.showServiceSelection(chatID, userID)
+}
+
+func (b *CarWashBot) showServiceSelection(chatID, userID int64) {
+	l := b.tr(userID)
+	state := b.userStates[userID]
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, service := range b.bookings.Services(b.selectedLocation(userID)) {
+		btnText := fmt.Sprintf("🧽 %s — %d ₽", service.Name, service.Price)
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(btnText, "service_"+service.Code),
+		))
+	}
+	rows = append(rows, backButton(l, "time"))
+
+	text := l.T("service.title", state.SelectedDate, state.SelectedTime)
+	b.showWizardStep(chatID, userID, text, tgbotapi.NewInlineKeyboardMarkup(rows...))
+}
+
+func (b *CarWashBot) handleServiceSelection(chatID, userID int64, code string) {
+	state, exists := b.userStates[userID]
+	if !exists || !state.AwaitingService {
+		b.sendMessage(chatID, b.tr(userID).T("service.first"))
+		b.showDaySelection(chatID)
 		return
 	}
 
-	b.userStates[userID] = models.UserState{
-		AwaitingCarInfo: true,
-		SelectedDate:    state.SelectedDate,
-		SelectedTime:    timeStr,
+	if !b.bookings.OffersService(b.selectedLocation(userID), code) {
+		b.setNotice(userID, b.tr(userID).T("service.unknown"))
+		b.showServiceSelection(chatID, userID)
+		return
 	}
 
-	msg := tgbotapi.NewMessage(chatID, "Введите марку и номер машины через пробел\nПример: Лада 123")
-	b.sendMessageWithSave(chatID, msg)
-}
+	state.AwaitingService = false
+	state.SelectedService = code
+	b.userStates[userID] = state
 
-func (b *CarWashBot) handleCarInfoInput(chatID, userID int64, text string) {
-	b.deleteLastMessage(chatID)
+	b.showCarSelection(chatID, userID)
+}
 
-	// Проверка на команды
-	if b.isCommand(text) {
-		msg := tgbotapi.NewMessage(chatID, "❌ Введите марку и номер машины, а не команду\nПример: Toyota CAM777")
-		b.sendMessageWithSave(chatID, msg)
+// showCarSelection предлагает машину из автопарка организации или ввод вручную
+func (b *CarWashBot) showCarSelection(chatID, userID int64) {
+	// Водителям организаций предлагаем выбрать машину из автопарка
+	if b.showOrganizationVehicles(chatID, userID) {
 		return
 	}
 
+	state := b.userStates[userID]
+	state.AwaitingCarInfo = true
+	b.userStates[userID] = state
+	b.askCarInfo(chatID, userID)
+}
+
+func (b *CarWashBot) askCarInfo(chatID, userID int64) {
+	l := b.tr(userID)
+	b.showWizardStep(chatID, userID, l.T("car.ask"), tgbotapi.NewInlineKeyboardMarkup(backButton(l, "service")))
+}
+
+func (b *CarWashBot) handleCarInfoInput(chatID, userID int64, text string) {
 	parts := strings.SplitN(text, " ", 2)
 	if len(parts) < 2 || len(parts[1]) < 3 {
-		msg := tgbotapi.NewMessage(chatID, "❌ Неверный формат!\nВведите: Марка Номер\nПример: Kia ABC123")
-		b.sendMessageWithSave(chatID, msg)
+		b.setNotice(userID, b.tr(userID).T("car.invalid"))
+		b.askCarInfo(chatID, userID)
 		return
 	}
 
+	state := b.userStates[userID]
+	state.AwaitingCarInfo = false
+	state.AwaitingPromo = true
+	state.CarModel = parts[0]
+	state.CarNumber = parts[1]
+	b.userStates[userID] = state
+
+	b.askPromoCode(chatID, userID)
+}
+
+// createPersonalBooking готовит личную запись по данным из состояния пользователя
+func (b *CarWashBot) createPersonalBooking(chatID, userID int64, promo *models.PromoCode) {
 	state := b.userStates[userID]
 	user, err := b.storage.GetUserByTelegramID(context.Background(), userID)
-	if err != nil {
+	if err != nil || user == nil {
 		log.Printf("Ошибка получения пользователя: %v", err)
-		b.sendMessage(chatID, "⚠️ Ошибка системы. Попробуйте позже.")
+		b.sendMessage(chatID, b.tr(userID).T("error.system"))
 		return
 	}
 
+	service, _ := b.cfg.GetService(state.SelectedService)
 	booking := &models.Booking{
-		UserID:    user.ID,
-		Date:      state.SelectedDate,
-		Time:      state.SelectedTime,
-		CarModel:  parts[0],
-		CarNumber: parts[1],
-	}
+		UserID:     user.ID,
+		Date:       state.SelectedDate,
+		Time:       state.SelectedTime,
+		LocationID: state.SelectedLocation,
+		CarModel:   state.CarModel,
+		CarNumber:  state.CarNumber,
+		Service:    service.Code,
+		Price:      service.Price,
+	}
+	applyPromo(booking, promo)
+
+	b.showConfirmation(chatID, userID, booking)
+}
 
-	if err := b.storage.CreateBooking(context.Background(), booking); err != nil {
-		log.Printf("Ошибка создания записи: %v", err)
-		b.sendMessage(chatID, "⚠️ Это время уже занято! Выберите другое.")
-		b.showTimeSlots(chatID, state.SelectedDate)
-		return
+func (b *CarWashBot) saveBooking(chatID, userID int64, booking *models.Booking) {
+	// Слот держится за клиентом, пока он не внесёт предоплату
+	deposit := b.requiredDeposit(booking)
+	approval := false
+	switch b.bookingRestriction(booking) {
+	case config.RestrictPrepayment:
+		if b.payments != nil && booking.Price > 0 {
+			deposit = booking.Price
+		} else {
+			approval = true
+		}
+	case config.RestrictApproval:
+		approval = true
 	}
 
-	delete(b.userStates, userID)
-	b.sendBookingConfirmation(chatID, booking)
-	b.notifyAdmin(booking)
-}
+	if deposit > 0 {
+		booking.Status = models.StatusAwaitingPayment
+		booking.PaymentDeadline = time.Now().Add(b.cfg.Payments.Timeout)
+	} else if approval {
+		booking.Status = models.StatusPendingApproval
+	}
 
-func (b *CarWashBot) showSchedule(chatID int64) {
-	bookings, err := b.storage.GetAllBookings(context.Background())
-	if err != nil {
-		log.Printf("Ошибка получения расписания: %v", err)
-		b.sendMessage(chatID, "⚠️ Ошибка загрузки расписания.")
+	l := b.tr(userID)
+	err := b.bookings.Create(context.Background(), booking)
+	if isPromoError(err) {
+		b.setNotice(userID, errorText(l, err))
+		b.handleWizardBack(chatID, userID, "promo")
 		return
 	}
-
-	bookingsByDate := make(map[string][]*models.Booking)
-	for _, booking := range bookings {
-		bookingsByDate[booking.Date] = append(bookingsByDate[booking.Date], booking)
+	if isLimitError(err) {
+		b.finishWizard(chatID, userID, errorText(l, err))
+		return
 	}
-
-	var sb strings.Builder
-	sb.WriteString("📅 Полное расписание:\n\n")
-
-	now := time.Now()
-	today := now.Format("02.01.2006")
-	tomorrow := now.AddDate(0, 0, 1).Format("02.01.2006")
-
-	// Сортируем даты
-	var dates []string
-	for date := range bookingsByDate {
-		dates = append(dates, date)
-	}
-	sort.Strings(dates)
-
-	for _, date := range dates {
-		bookings := bookingsByDate[date]
-		sort.Slice(bookings, func(i, j int) bool {
-			return bookings[i].Time < bookings[j].Time
-		})
-
-		// Форматируем заголовок
-		parsedDate, _ := time.Parse("02.01.2006", date)
-		dayName := b.getDayName(parsedDate.Weekday())
-
-		switch date {
-		case today:
-			sb.WriteString("=== Сегодня (" + dayName + ") ===\n")
-		case tomorrow:
-			sb.WriteString("=== Завтра (" + dayName + ") ===\n")
-		default:
-			sb.WriteString(fmt.Sprintf("=== %s, %s ===\n", dayName, date))
-		}
-
-		for _, booking := range bookings {
-			sb.WriteString(fmt.Sprintf("🕒 %s - %s %s\n",
-				booking.Time,
-				booking.CarModel,
-				booking.CarNumber))
+	if err != nil {
+		if !isSlotError(err) {
+			log.Printf("Ошибка создания записи: %v", err)
 		}
-		sb.WriteString("\n")
+		b.setNotice(userID, l.T("booking.slot_taken"))
+		b.handleWizardBack(chatID, userID, "time")
+		return
 	}
 
-	if len(dates) == 0 {
-		sb.WriteString("Нет записей\n")
+	if deposit > 0 {
+		b.finishWizard(chatID, userID, b.bookingSummary(l, booking)+"\n\n"+l.T("booking.await_payment"))
+		b.requestPayment(chatID, booking, deposit)
+		return
+	}
+	if approval {
+		b.finishWizard(chatID, userID, b.bookingSummary(l, booking)+"\n\n"+l.T("booking.await_approval"))
+		b.requestApproval(booking)
+		b.askPhoneIfMissing(chatID, userID)
+		return
 	}
+	b.finishWizard(chatID, userID, b.confirmationText(l, booking))
+	b.sendCalendarFile(chatID, booking)
+	b.notifyAdmin(booking)
+	b.askPhoneIfMissing(chatID, userID)
+}
 
-	msg := tgbotapi.NewMessage(chatID, sb.String())
-	msg.ReplyMarkup = tgbotapi.NewReplyKeyboard(
-		tgbotapi.NewKeyboardButtonRow(
-			tgbotapi.NewKeyboardButton("📝 Записаться"),
-			tgbotapi.NewKeyboardButton("🏠 Главное меню"),
-		),
-	)
-	b.sendMessageWithSave(chatID, msg)
+// serviceName возвращает название услуги для отображения
+func (b *CarWashBot) serviceName(code string) string {
+	if service, ok := b.cfg.GetService(code); ok {
+		return service.Name
+	}
+	if code == "" {
+		return "—"
+	}
+	return code
 }
 
 func (b *CarWashBot) notifyAdminAboutNewBooking(timeStr, carModel, carNumber string) {
@@ -262,11 +487,13 @@ func (b *CarWashBot) sendMessage(chatID int64, text string) {
 	msg := tgbotapi.NewMessage(chatID, text)
 	if _, err := b.botAPI.Send(msg); err != nil {
 		log.Printf("Ошибка отправки сообщения: %v", err)
+		b.retryLater(msg, err)
 	}
 }
 func (b *CarWashBot) sendMessageWithSave(chatID int64, msg tgbotapi.MessageConfig) {
 	sentMsg, err := b.botAPI.Send(msg)
 	if err != nil {
+		// Меню и шаги записи не досылаем: устаревшие кнопки только запутают
 		log.Printf("Ошибка отправки сообщения: %v", err)
 		return
 	}
@@ -286,127 +513,128 @@ func (b *CarWashBot) deleteLastMessage(chatID int64) {
 	}
 }
 
-func (b *CarWashBot) showTimeSlots(chatID int64, dateStr string) {
-	date, _ := time.Parse("02.01.2006", dateStr)
-	dayName := b.getDayName(date.Weekday())
+func (b *CarWashBot) showTimeSlots(chatID, userID int64, dateStr string) {
+	l := b.tr(userID)
+	date, _ := time.ParseInLocation("02.01.2006", dateStr, time.Local)
+	location := b.selectedLocation(userID)
 
-	var rows [][]tgbotapi.InlineKeyboardButton
-	for hour := 8; hour <= 20; hour++ {
-		timeStr := fmt.Sprintf("%02d:00", hour)
+	slots, err := b.bookings.DaySlots(context.Background(), location, dateStr, b.customerID(userID))
+	if err != nil {
+		log.Printf("Ошибка проверки времени: %v", err)
+		b.sendMessage(chatID, l.T("error.system"))
+		return
+	}
 
-		available, err := b.IsTimeAvailable(dateStr, timeStr)
-		if err != nil {
-			log.Printf("Ошибка проверки времени: %v", err)
-			continue
+	var rows [][]tgbotapi.InlineKeyboardButton
+	var row []tgbotapi.InlineKeyboardButton
+	for _, slot := range slots {
+		data := "time_" + slot.Time
+		if slot.Free <= 0 {
+			data = "noop"
 		}
-
-		btnText := fmt.Sprintf("🕒 %s", timeStr)
-		if !available {
-			btnText = "🔴 " + timeStr + " (Занято)"
-		} else {
-			btnText = "🔵 " + timeStr + " (Свободно)"
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(slotLabel(slot.Time, slot.Free, b.bookings.Bays(location)), data))
+		if len(row) == slotColumns {
+			rows = append(rows, row)
+			row = nil
 		}
-
-		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData(btnText, "time_"+timeStr),
-		))
+	}
+	if len(row) > 0 {
+		rows = append(rows, row)
 	}
 
-	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
-		tgbotapi.NewInlineKeyboardButtonData("🔙 В главное меню", "main_menu"),
-	))
+	text := l.T("time.title", l.Date(date), b.bookings.Bays(location))
+	if len(rows) == 0 {
+		text = l.T("time.none", l.Date(date))
+	}
+	rows = append(rows, backButton(l, "calendar"))
 
-	msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("Выберите время на %s, %s:", dayName, dateStr))
-	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
-	b.sendMessageWithSave(chatID, msg)
-}
-func (b *CarWashBot) IsTimeAvailable(date, time string) (bool, error) {
-	return b.storage.IsTimeAvailable(context.Background(), date, time)
+	b.showWizardStep(chatID, userID, text, tgbotapi.NewInlineKeyboardMarkup(rows...))
 }
 func (b *CarWashBot) showDaySelection(chatID int64) {
-	now := time.Now()
-	var buttons [][]tgbotapi.InlineKeyboardButton
-
-	for i := 0; i < 7; i++ {
-		date := now.AddDate(0, 0, i)
-		dateStr := date.Format("02.01.2006")
-		dayName := b.getDayName(date.Weekday())
-
-		btnText := fmt.Sprintf("📅 %s, %s", dayName, date.Format("02.01"))
-		if i == 0 {
-			btnText = "🔵 Сегодня, " + date.Format("02.01")
-		} else if i == 1 {
-			btnText = "🔵 Завтра, " + date.Format("02.01")
+	b.showCalendar(chatID, time.Now(), 0)
+}
+func (b *CarWashBot) handleDaySelection(chatID, userID int64, messageID int, dateStr string) {
+	l := b.tr(userID)
+	if _, err := b.bookings.ValidateDay(dateStr); err != nil {
+		switch {
+		case errors.Is(err, services.ErrPastDay):
+			b.sendMessage(chatID, l.T("day.past"))
+		case errors.Is(err, services.ErrBeyondHorizon):
+			b.sendMessage(chatID, l.N("day.horizon", b.cfg.Horizon))
+		case errors.Is(err, services.ErrClosedDay):
+			b.sendMessage(chatID, l.T("day.closed"))
+		default:
+			b.sendMessage(chatID, l.T("day.invalid"))
 		}
-
-		buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData(btnText, "day_"+dateStr),
-		))
+		b.showDaySelection(chatID)
+		return
 	}
 
-	buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(
-		tgbotapi.NewInlineKeyboardButtonData("🔙 В главное меню", "main_menu"),
-	))
-
-	msg := tgbotapi.NewMessage(chatID, "Выберите день для записи:")
-	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(buttons...)
-	b.sendMessageWithSave(chatID, msg)
-}
-func (b *CarWashBot) handleDaySelection(chatID, userID int64, dateStr string) {
-	selectedDate, err := time.Parse("02.01.2006", dateStr)
-	if err != nil || selectedDate.Before(time.Now().Truncate(24*time.Hour)) {
-		b.sendMessage(chatID, "❌ Нельзя записаться на прошедшую дату")
-		b.showDaySelection(chatID)
+	// Старый календарь без начатой записи не знает, какую мойку выбрали
+	state, exists := b.userStates[userID]
+	if !exists && b.hasLocations() {
+		b.startBooking(chatID, userID)
 		return
 	}
 
+	// Дальше мастер записи редактирует сообщение с календарём
 	b.userStates[userID] = models.UserState{
-		AwaitingTime: true,
-		SelectedDate: dateStr,
+		SelectedLocation: state.SelectedLocation,
+		AwaitingTime:     true,
+		SelectedDate:     dateStr,
+		MessageID:        messageID,
 	}
 
-	b.showTimeSlots(chatID, dateStr)
+	b.showTimeSlots(chatID, userID, dateStr)
 }
 
 func (b *CarWashBot) showUserBookings(chatID, userID int64) {
+	l := b.tr(userID)
 	user, err := b.storage.GetUserByTelegramID(context.Background(), userID)
 	if err != nil || user == nil {
 		log.Printf("Ошибка получения пользователя: %v", err)
-		b.sendMessage(chatID, "⚠️ Ошибка загрузки записей.")
+		b.sendMessage(chatID, l.T("error.bookings"))
 		return
 	}
 
 	bookings, err := b.storage.GetUserBookings(context.Background(), user.ID)
 	if err != nil {
 		log.Printf("Ошибка получения записей: %v", err)
-		b.sendMessage(chatID, "⚠️ Ошибка загрузки записей.")
+		b.sendMessage(chatID, l.T("error.bookings"))
 		return
 	}
 
 	if len(bookings) == 0 {
-		b.sendMessage(chatID, "У вас нет активных записей.")
+		b.sendMessage(chatID, l.T("bookings.none"))
 		return
 	}
 
 	var sb strings.Builder
-	sb.WriteString("📋 Ваши записи:\n\n")
+	sb.WriteString(l.T("bookings.title") + "\n\n")
 
 	var buttons [][]tgbotapi.InlineKeyboardButton
 	for _, booking := range bookings {
 		sb.WriteString(fmt.Sprintf(
-			"📅 %s\n🕒 %s\n🚗 %s %s\n\n",
+			"📅 %s\n🕒 %s\n🚗 %s %s\n",
 			booking.Date,
 			booking.Time,
 			booking.CarModel,
 			booking.CarNumber))
+		switch booking.Status {
+		case models.StatusAwaitingPayment:
+			sb.WriteString(l.T("bookings.await_payment") + "\n")
+		case models.StatusPendingApproval:
+			sb.WriteString(l.T("bookings.await_approval") + "\n")
+		}
+		sb.WriteString("\n")
 
 		buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("❌ Отменить", "cancel_"+booking.ID),
+			tgbotapi.NewInlineKeyboardButtonData(l.T("button.cancel_booking"), "cancel_"+booking.ID),
 		))
 	}
 
 	buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(
-		tgbotapi.NewInlineKeyboardButtonData("🏠 В главное меню", "main_menu"),
+		tgbotapi.NewInlineKeyboardButtonData(l.T("button.main_menu"), "main_menu"),
 	))
 
 	msg := tgbotapi.NewMessage(chatID, sb.String())
@@ -416,20 +644,21 @@ func (b *CarWashBot) showUserBookings(chatID, userID int64) {
 }
 
 func (b *CarWashBot) handleCancelCommand(chatID, userID int64) {
+	l := b.tr(userID)
 	user, err := b.storage.GetUserByTelegramID(context.Background(), userID)
 	if err != nil || user == nil {
-		b.sendMessage(chatID, "⚠️ Ошибка загрузки записей")
+		b.sendMessage(chatID, l.T("error.bookings"))
 		return
 	}
 
 	bookings, err := b.storage.GetUserBookings(context.Background(), user.ID)
 	if err != nil {
-		b.sendMessage(chatID, "⚠️ Ошибка загрузки записей")
+		b.sendMessage(chatID, l.T("error.bookings"))
 		return
 	}
 
 	if len(bookings) == 0 {
-		b.sendMessage(chatID, "У вас нет активных записей.")
+		b.sendMessage(chatID, l.T("bookings.none"))
 		return
 	}
 
@@ -443,106 +672,163 @@ func (b *CarWashBot) handleCancelCommand(chatID, userID int64) {
 	}
 
 	buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(
-		tgbotapi.NewInlineKeyboardButtonData("🔙 Назад", "main_menu"),
+		tgbotapi.NewInlineKeyboardButtonData(l.T("button.back"), "main_menu"),
 	))
 
-	msg := tgbotapi.NewMessage(chatID, "Выберите запись для отмены:")
+	msg := tgbotapi.NewMessage(chatID, l.T("bookings.choose_cancel"))
 	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(buttons...)
 	b.sendMessageWithSave(chatID, msg)
 }
-func (b *CarWashBot) handleBookingCancellation(chatID, userID int64, bookingID string) {
+func (b *CarWashBot) handleBookingCancellation(chatID, userID int64, bookingID string, confirmed bool) {
+	l := b.tr(userID)
 	user, err := b.storage.GetUserByTelegramID(context.Background(), userID)
 	if err != nil || user == nil {
 		log.Printf("Ошибка получения пользователя: %v", err)
-		b.sendMessage(chatID, "⚠️ Ошибка системы.")
+		b.sendMessage(chatID, l.T("error.system"))
 		return
 	}
 
-	booking, err := b.storage.CancelBooking(context.Background(), bookingID, user.ID)
-	if err != nil {
+	booking, err := b.storage.GetBooking(context.Background(), bookingID)
+	if err != nil || booking == nil || booking.UserID != user.ID {
+		log.Printf("Ошибка получения брони: %v", err)
+		b.sendMessage(chatID, l.T("bookings.cancel_failed"))
+		return
+	}
+
+	// Поздняя отмена выполняется только после подтверждения клиентом
+	cancelled, err := b.bookings.Cancel(context.Background(), booking, confirmed)
+	switch {
+	case errors.Is(err, services.ErrLateCancel):
+		b.askLateCancelConfirmation(chatID, booking)
+		return
+	case errors.Is(err, services.ErrNotUpcoming):
+		b.sendMessage(chatID, l.T("cancel.not_upcoming"))
+		return
+	case errors.Is(err, services.ErrStarted):
+		b.sendMessage(chatID, l.T("cancel.started"))
+		return
+	case err != nil:
 		log.Printf("Ошибка отмены брони: %v", err)
-		b.sendMessage(chatID, "❌ Не удалось отменить запись.")
+		b.sendMessage(chatID, l.T("bookings.cancel_failed"))
 		return
 	}
+	booking = cancelled
+	late := booking.LateCancel
 
-	msg := fmt.Sprintf("✅ Запись отменена:\n%s %s - %s %s",
+	msg := fmt.Sprintf("%s\n%s %s - %s %s",
+		l.T("bookings.cancelled"),
 		booking.Date, booking.Time, booking.CarModel, booking.CarNumber)
+	if refund := b.refundCancelled(l, booking); refund != "" {
+		msg += "\n" + refund
+	}
 	b.sendMessage(chatID, msg)
-}
-func (b *CarWashBot) createDayButtons() tgbotapi.InlineKeyboardMarkup {
-	var rows [][]tgbotapi.InlineKeyboardButton
-	days := b.cfg.Days // Используем дни из конфига
+	b.recheckFreeVisits(user.ID)
 
-	// Создаем кнопки для каждого дня
-	for engDay, ruDay := range days {
-		btn := tgbotapi.NewInlineKeyboardButtonData(ruDay, "day_"+engDay)
-		row := []tgbotapi.InlineKeyboardButton{btn}
-		rows = append(rows, row)
+	if late {
+		for _, adminID := range b.bookingAdmins(booking.LocationID) {
+			b.sendMessage(adminID, b.tr(adminID).T("admin.late_cancel",
+				booking.Date, booking.Time, booking.CarModel, booking.CarNumber))
+		}
 	}
-
-	return tgbotapi.NewInlineKeyboardMarkup(rows...)
-}
-
-// Добавляем метод для получения русских названий дней
-func (b *CarWashBot) getDayName(day time.Weekday) string {
-	daysMap := map[time.Weekday]string{
-		time.Monday:    "Понедельник",
-		time.Tuesday:   "Вторник",
-		time.Wednesday: "Среда",
-		time.Thursday:  "Четверг",
-		time.Friday:    "Пятница",
-		time.Saturday:  "Суббота",
-		time.Sunday:    "Воскресенье",
-	}
-	return daysMap[day]
 }
 
-func (b *CarWashBot) isCommand(text string) bool {
-	commands := []string{
-		"📝 Записаться", "🕒 Расписание", "❌ Отменить запись",
-		"🏠 Главное меню", "/start", "/menu", "/book", "/schedule", "/cancel",
-	}
-
-	for _, cmd := range commands {
-		if strings.EqualFold(text, cmd) {
-			return true
-		}
-	}
-	return false
+// isCommand сообщает, что вместо ответа на шаг записи пользователь
+// отправил команду или нажал кнопку меню на своём языке
+func isCommand(l *i18n.Localizer, text string) bool {
+	return strings.HasPrefix(text, "/") || l.IsLabel("menu.", text)
 }
 
 func (b *CarWashBot) sendBookingConfirmation(chatID int64, booking *models.Booking) {
-	msgText := fmt.Sprintf(`✅ Запись подтверждена!
-📅 Дата: %s
-🕒 Время: %s
-🚗 Авто: %s %s`,
-		booking.Date,
-		booking.Time,
-		booking.CarModel,
-		booking.CarNumber)
-
-	msg := tgbotapi.NewMessage(chatID, msgText)
+	l := b.tr(chatID)
+	msg := tgbotapi.NewMessage(chatID, b.confirmationText(l, booking))
 	msg.ReplyMarkup = tgbotapi.NewReplyKeyboard(
 		tgbotapi.NewKeyboardButtonRow(
-			tgbotapi.NewKeyboardButton("🏠 Главное меню"),
+			tgbotapi.NewKeyboardButton(l.T("menu.main")),
 		),
 	)
 	b.sendMessageWithSave(chatID, msg)
+	b.sendCalendarFile(chatID, booking)
+}
+
+// bookingSummary описывает запись для клиента на его языке
+func (b *CarWashBot) bookingSummary(l *i18n.Localizer, booking *models.Booking) string {
+	date := booking.Date
+	if t, err := time.ParseInLocation("02.01.2006", booking.Date, time.Local); err == nil {
+		date = l.Date(t)
+	}
+
+	location := b.location(booking.LocationID)
+	var lines []string
+	if b.hasLocations() {
+		lines = append(lines, l.T("booking.location", locationName(l, location)))
+	}
+	lines = append(lines,
+		l.T("booking.date", date),
+		l.T("booking.time", booking.Time),
+		l.T("booking.service", b.serviceName(booking.Service)),
+		l.T("booking.price", b.money(booking.Price)),
+		l.T("booking.car", booking.CarModel, booking.CarNumber),
+	)
+	if b.bookings.Bays(location) > 1 && booking.Bay > 0 {
+		lines = append(lines, l.T("booking.bay", booking.Bay))
+	}
+	if booking.PromoCode != "" {
+		lines = append(lines, l.T("booking.promo", booking.PromoCode))
+	}
+	if booking.Discount > 0 {
+		lines = append(lines, l.T("booking.discount", b.money(booking.Discount)))
+	}
+	if booking.OrganizationID != 0 {
+		lines = append(lines, l.T("booking.org_paid"))
+	}
+	return strings.Join(lines, "\n")
 }
 
+// notifyAdmin сообщает о новой записи главному администратору и
+// администраторам мойки
 func (b *CarWashBot) notifyAdmin(booking *models.Booking) {
+	for _, adminID := range b.bookingAdmins(booking.LocationID) {
+		l := b.tr(adminID)
+		msgText, _ := b.messageText(models.TemplateAdminBooking, l, b.bookingTemplateData(l, booking))
+
+		msg := tgbotapi.NewMessage(adminID, msgText)
+		msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData(l.T("admin.complete"), "complete_"+booking.ID),
+				tgbotapi.NewInlineKeyboardButtonData(l.T("admin.no_show"), "noshow_"+booking.ID),
+			),
+		)
+		b.deliver(msg)
+	}
+}
+
+// adminBookingText — уведомление администратора о новой записи по умолчанию
+func (b *CarWashBot) adminBookingText(booking *models.Booking) string {
 	msgText := fmt.Sprintf(`🆕 Новая запись:
 📅 %s в %s
+🧽 %s — %d ₽
 🚗 %s %s`,
 		booking.Date,
 		booking.Time,
+		b.serviceName(booking.Service),
+		booking.Price,
 		booking.CarModel,
 		booking.CarNumber)
-
-	msg := tgbotapi.NewMessage(b.adminID, msgText)
-	if _, err := b.botAPI.Send(msg); err != nil {
-		log.Printf("Ошибка уведомления админа: %v", err)
+	if b.hasLocations() {
+		msgText += "\n📍 Мойка: " + locationName(nil, b.location(booking.LocationID))
+	}
+	if booking.OrganizationID != 0 {
+		if org, err := b.storage.GetOrganization(context.Background(), booking.OrganizationID); err == nil && org != nil {
+			msgText += "\n🏢 Организация: " + org.Name
+		}
+	}
+	if booking.PromoCode != "" {
+		msgText += "\n🎟 Промокод: " + booking.PromoCode
+	}
+	if booking.Discount > 0 {
+		msgText += fmt.Sprintf("\n🎁 Скидка: %d ₽ (без скидки %d ₽)", booking.Discount, booking.Price+booking.Discount)
 	}
+	return msgText
 }
 func (b *CarWashBot) GetAllBookings() ([]*models.Booking, error) {
 	return b.storage.GetAllBookings(context.Background())
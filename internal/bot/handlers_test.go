@@ -0,0 +1,131 @@
+package bot
+
+import (
+	"carwash-bot/internal/models"
+	"context"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const customerID = 42
+
+func (b *CarWashBot) send(text string) {
+	b.handleMessage(&tgbotapi.Message{
+		MessageID: 1,
+		Chat:      &tgbotapi.Chat{ID: customerID},
+		From:      &tgbotapi.User{ID: customerID, FirstName: "Тест", LanguageCode: "ru"},
+		Text:      text,
+	})
+}
+
+func (b *CarWashBot) press(data string) {
+	b.handleCallbackQuery(&tgbotapi.CallbackQuery{
+		ID:      "1",
+		From:    &tgbotapi.User{ID: customerID},
+		Message: &tgbotapi.Message{MessageID: 1, Chat: &tgbotapi.Chat{ID: customerID}},
+		Data:    data,
+	})
+}
+
+// wizardBot доводит запись до ввода автомобиля. Часы мастера записи
+// стоят, пока тест не сдвинет clock
+func wizardBot(t *testing.T) (*CarWashBot, *time.Time) {
+	t.Helper()
+	b, _, _ := newTestBot(t, testConfig())
+	clock := time.Now()
+	b.now = func() time.Time { return clock }
+
+	b.send("/book")
+	b.press("day_" + time.Now().AddDate(0, 0, 2).Format("02.01.2006"))
+	b.press("time_11:00")
+	b.press("service_express")
+	if !b.userStates[customerID].AwaitingCarInfo {
+		t.Fatalf("мастер записи не дошёл до автомобиля: %+v", b.userStates[customerID])
+	}
+	return b, &clock
+}
+
+func TestMenuButtonsLeaveWizard(t *testing.T) {
+	for _, text := range []string{"🧹 Очистить чат", "👤 Профиль", "/help", "/phone"} {
+		t.Run(text, func(t *testing.T) {
+			b, _ := wizardBot(t)
+			b.send(text)
+			if state, ok := b.userStates[customerID]; ok {
+				t.Fatalf("%q принят как ответ мастеру: %+v", text, state)
+			}
+		})
+	}
+}
+
+func TestMenuButtonLeavesPromoStep(t *testing.T) {
+	b, _ := wizardBot(t)
+	b.send("Kia A123BC")
+	if !b.userStates[customerID].AwaitingPromo {
+		t.Fatalf("после автомобиля нет шага промокода: %+v", b.userStates[customerID])
+	}
+
+	b.send("🧹 Очистить чат")
+	if state, ok := b.userStates[customerID]; ok {
+		t.Fatalf("кнопка меню принята как промокод: %+v", state)
+	}
+}
+
+func TestMenuButtonInUserLanguage(t *testing.T) {
+	b, _ := wizardBot(t)
+	b.langs[customerID] = "en"
+
+	b.send("🧹 Clear chat")
+	if state, ok := b.userStates[customerID]; ok {
+		t.Fatalf("кнопка меню на английском принята как автомобиль: %+v", state)
+	}
+}
+
+func TestCarInfoBeforeWizardTimeout(t *testing.T) {
+	b, clock := wizardBot(t)
+	*clock = clock.Add(b.cfg.Wizard - time.Minute)
+
+	b.send("Kia A123BC")
+	state := b.userStates[customerID]
+	if !state.AwaitingPromo || state.CarModel != "Kia" || state.CarNumber != "A123BC" {
+		t.Fatalf("автомобиль не сохранён: %+v", state)
+	}
+}
+
+func TestCarInfoAfterWizardTimeout(t *testing.T) {
+	b, clock := wizardBot(t)
+	*clock = clock.Add(b.cfg.Wizard + time.Minute)
+
+	b.send("Kia A123BC")
+	if state, ok := b.userStates[customerID]; ok {
+		t.Fatalf("ответ брошенному мастеру записи принят: %+v", state)
+	}
+}
+
+func TestVehicleStepInUserLanguage(t *testing.T) {
+	b, telegram, store := newTestBot(t, testConfig())
+	ctx := context.Background()
+	org := &models.Organization{Name: "Taxi"}
+	if err := store.CreateOrganization(ctx, org); err != nil {
+		t.Fatalf("CreateOrganization: %v", err)
+	}
+	err := store.AddOrganizationMember(ctx, &models.OrganizationMember{OrganizationID: org.ID, TelegramID: customerID, Role: models.RoleDriver})
+	if err != nil {
+		t.Fatalf("AddOrganizationMember: %v", err)
+	}
+	if err := store.AddVehicle(ctx, &models.Vehicle{OrganizationID: org.ID, CarModel: "Kia", CarNumber: "T001TT"}); err != nil {
+		t.Fatalf("AddVehicle: %v", err)
+	}
+
+	b.send("/book")
+	b.langs[customerID] = "en"
+	b.press("day_" + time.Now().AddDate(0, 0, 2).Format("02.01.2006"))
+	b.press("time_11:00")
+	b.press("service_express")
+
+	texts := telegram.texts(customerID)
+	if last := texts[len(texts)-1]; last != "Choose a car:" {
+		t.Fatalf("шаг выбора автомобиля: %q; нужен английский текст", last)
+	}
+}
@@ -0,0 +1,75 @@
+package bot
+
+import (
+	"carwash-bot/internal/ical"
+	"carwash-bot/internal/models"
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Напоминание в файле .ics, если напоминания бота отключены
+const defaultICSAlarm = time.Hour
+
+// sendCalendarFile отправляет клиенту запись файлом .ics с напоминанием,
+// чтобы её можно было добавить в календарь телефона
+func (b *CarWashBot) sendCalendarFile(chatID int64, booking *models.Booking) {
+	event, err := ical.BookingEvent(booking)
+	if err != nil {
+		log.Printf("Ошибка подготовки файла календаря: %v", err)
+		return
+	}
+
+	l := b.tr(chatID)
+	event.Summary = l.T("ics.summary", b.serviceName(booking.Service))
+	event.Description = b.bookingSummary(l, booking)
+	event.Location = b.location(booking.LocationID).Address
+	event.Alarm = b.cfg.Reminder
+	if event.Alarm == 0 {
+		event.Alarm = defaultICSAlarm
+	}
+
+	doc := tgbotapi.NewDocument(chatID, tgbotapi.FileBytes{
+		Name:  "booking.ics",
+		Bytes: ical.Calendar("", event),
+	})
+	doc.Caption = l.T("ics.caption")
+	if _, err := b.botAPI.Send(doc); err != nil {
+		log.Printf("Ошибка отправки файла календаря: %v", err)
+	}
+}
+
+// handleCalendarCommand выдаёт сотруднику секретную ссылку на ленту
+// записей его моек для Google Calendar, Apple Calendar или Outlook.
+// «/calendar reset» выпускает новую ссылку, старая перестаёт работать
+func (b *CarWashBot) handleCalendarCommand(chatID, userID int64, text string) {
+	if !b.isStaff(userID) {
+		b.sendMessage(chatID, "⛔ Команда доступна только администратору.")
+		return
+	}
+	if b.cfg.HTTP.Addr == "" || b.cfg.HTTP.PublicURL == "" {
+		b.sendMessage(chatID, "⚠️ Лента календаря отключена: задайте HTTP_ADDR и PUBLIC_URL.")
+		return
+	}
+
+	reset := strings.TrimSpace(strings.TrimPrefix(text, "/calendar")) == "reset"
+	token, err := b.storage.CalendarFeedToken(context.Background(), userID, reset)
+	if err != nil {
+		log.Printf("Ошибка получения ленты календаря: %v", err)
+		b.sendMessage(chatID, "⚠️ Не удалось получить ссылку на календарь.")
+		return
+	}
+
+	msgText := "📅 Лента записей для календаря:\n" +
+		b.cfg.HTTP.PublicURL + "/calendar/" + token + ".ics\n\n" +
+		"Добавьте её в календарь как подписку по URL. Новые, отменённые и перенесённые записи " +
+		"появятся при следующей синхронизации.\n" +
+		"Ссылка секретная: не пересылайте её. /calendar reset — выпустить новую."
+	if reset {
+		msgText = "🔄 Старая ссылка больше не работает.\n\n" + msgText
+	}
+	b.sendMessage(chatID, msgText)
+}
@@ -0,0 +1,115 @@
+package bot
+
+import (
+	"carwash-bot/internal/i18n"
+	"carwash-bot/internal/services"
+	"carwash-bot/internal/storage"
+	"context"
+	"errors"
+	"log"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// tr возвращает переводчик на язык пользователя. В личных чатах
+// chatID совпадает с userID, поэтому сюда можно передавать и chatID
+func (b *CarWashBot) tr(userID int64) *i18n.Localizer {
+	b.langLock.Lock()
+	lang, ok := b.langs[userID]
+	b.langLock.Unlock()
+	if ok {
+		return i18n.New(lang)
+	}
+
+	user, err := b.storage.GetUserByTelegramID(context.Background(), userID)
+	if err != nil {
+		log.Printf("Ошибка получения пользователя: %v", err)
+		return i18n.New(i18n.Default)
+	}
+	lang = i18n.Default
+	if user != nil && user.Language != "" {
+		lang = user.Language
+	}
+
+	b.langLock.Lock()
+	b.langs[userID] = lang
+	b.langLock.Unlock()
+	return i18n.New(lang)
+}
+
+// errorKeys — переводы ошибок сервисов, которые можно показать клиенту
+var errorKeys = []struct {
+	err error
+	key string
+}{
+	{storage.ErrUserBlocked, "error.user_blocked"},
+	{storage.ErrTooManyActive, "error.too_many_active"},
+	{storage.ErrTooManyPerDay, "error.too_many_per_day"},
+	{storage.ErrBookingTooClose, "error.too_close"},
+	{storage.ErrPromoNotFound, "promo.not_found"},
+	{storage.ErrPromoExpired, "promo.expired"},
+	{storage.ErrPromoNotStarted, "promo.not_started"},
+	{storage.ErrPromoExhausted, "promo.exhausted"},
+	{storage.ErrPromoUserLimit, "promo.user_limit"},
+	{storage.ErrPromoWrongService, "promo.wrong_service"},
+	{storage.ErrPromoWrongSchedule, "promo.wrong_schedule"},
+	{storage.ErrSlotTaken, "time.taken"},
+	{services.ErrInvalidDate, "day.invalid"},
+	{services.ErrPastDay, "day.past"},
+	{services.ErrClosedDay, "day.closed"},
+	{services.ErrNotUpcoming, "cancel.not_upcoming"},
+	{services.ErrStarted, "cancel.started"},
+}
+
+// errorText переводит ошибку сервиса для клиента. Текст ошибки
+// на русском клиенту не показывается: неизвестные ошибки — «ошибка системы»
+func errorText(l *i18n.Localizer, err error) string {
+	for _, e := range errorKeys {
+		if errors.Is(err, e.err) {
+			return l.T(e.key)
+		}
+	}
+	return l.T("error.system")
+}
+
+func (b *CarWashBot) showLanguageMenu(chatID, userID int64) {
+	l := b.tr(userID)
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, lang := range i18n.Languages {
+		text := i18n.Name(lang)
+		if lang == l.Lang {
+			text = "✅ " + text
+		}
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(text, "lang_"+lang),
+		))
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(l.T("button.main_menu"), "main_menu"),
+	))
+
+	msg := tgbotapi.NewMessage(chatID, l.T("language.choose"))
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+	b.sendMessageWithSave(chatID, msg)
+}
+
+func (b *CarWashBot) handleLanguageSelection(chatID, userID int64, lang string) {
+	if !i18n.Supported(lang) {
+		return
+	}
+
+	if err := b.storage.SetUserLanguage(context.Background(), userID, lang); err != nil {
+		log.Printf("Ошибка сохранения языка: %v", err)
+		b.sendMessage(chatID, b.tr(userID).T("error.system"))
+		return
+	}
+
+	b.langLock.Lock()
+	b.langs[userID] = lang
+	b.langLock.Unlock()
+
+	b.sendMessage(chatID, b.tr(userID).T("language.set", i18n.Name(lang)))
+	// Обычная клавиатура меню тоже должна смениться на новый язык
+	b.sendWelcomeMessage(chatID)
+}
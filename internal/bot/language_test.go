@@ -0,0 +1,20 @@
+package bot
+
+import (
+	"carwash-bot/internal/i18n"
+	"carwash-bot/internal/storage"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestErrorTextIsLocalized(t *testing.T) {
+	en := i18n.New("en")
+	err := fmt.Errorf("создание записи: %w", storage.ErrPromoExhausted)
+	if got, want := errorText(en, err), en.T("promo.exhausted"); got != want {
+		t.Errorf("errorText = %q; нужно %q", got, want)
+	}
+	if got := errorText(en, errors.New("disk I/O error")); got != en.T("error.system") {
+		t.Errorf("неизвестная ошибка показана клиенту: %q", got)
+	}
+}
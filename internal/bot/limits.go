@@ -0,0 +1,228 @@
+package bot
+
+import (
+	"carwash-bot/internal/models"
+	"carwash-bot/internal/storage"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const limitsHelp = `📏 Ограничения на записи:
+/limits Telegram_ID — ограничения клиента
+/limits_set Telegram_ID [active=N] [day=N] [gap=минуты] — задать свои (0 — без ограничения)
+/limits_reset Telegram_ID — вернуть ограничения по умолчанию
+
+⛔ Блокировка:
+/ban Telegram_ID [причина] — запретить запись
+/unban Telegram_ID — снять запрет
+/banlist — заблокированные`
+
+func isLimitError(err error) bool {
+	for _, target := range []error{
+		storage.ErrUserBlocked,
+		storage.ErrTooManyActive,
+		storage.ErrTooManyPerDay,
+		storage.ErrBookingTooClose,
+	} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// canBook проверяет, что пользователю не запрещено записываться
+func (b *CarWashBot) canBook(chatID, userID int64) bool {
+	blocked, err := b.storage.GetBlockedUser(context.Background(), userID)
+	if err != nil {
+		log.Printf("Ошибка проверки блокировки: %v", err)
+		return true
+	}
+	if blocked != nil {
+		b.sendMessage(chatID, "⛔ "+storage.ErrUserBlocked.Error()+".")
+		return false
+	}
+	return true
+}
+
+func (b *CarWashBot) handleLimitsCommand(chatID, userID int64, text string) {
+	if !b.isAdmin(userID) {
+		b.sendMessage(chatID, "⛔ Команда доступна только администратору.")
+		return
+	}
+
+	fields := strings.Fields(text)
+	if len(fields) < 2 {
+		b.sendMessage(chatID, limitsHelp)
+		return
+	}
+	user := b.findCustomer(chatID, fields[1])
+	if user == nil {
+		return
+	}
+
+	ctx := context.Background()
+	switch fields[0] {
+	case "/limits":
+		b.showUserLimits(chatID, user)
+	case "/limits_set":
+		limits, _, err := b.storage.GetUserLimits(ctx, user.ID)
+		if err != nil {
+			log.Printf("Ошибка получения ограничений: %v", err)
+			b.sendMessage(chatID, "⚠️ Ошибка загрузки ограничений.")
+			return
+		}
+		if err := parseLimits(&limits, fields[2:]); err != nil {
+			b.sendMessage(chatID, "❌ "+err.Error())
+			return
+		}
+		if err := b.storage.SetUserLimits(ctx, user.ID, limits); err != nil {
+			log.Printf("Ошибка сохранения ограничений: %v", err)
+			b.sendMessage(chatID, "⚠️ Не удалось сохранить ограничения.")
+			return
+		}
+		b.showUserLimits(chatID, user)
+	case "/limits_reset":
+		if err := b.storage.ResetUserLimits(ctx, user.ID); err != nil {
+			log.Printf("Ошибка сброса ограничений: %v", err)
+			b.sendMessage(chatID, "⚠️ Не удалось сбросить ограничения.")
+			return
+		}
+		b.showUserLimits(chatID, user)
+	default:
+		b.sendMessage(chatID, limitsHelp)
+	}
+}
+
+// parseLimits разбирает параметры команды /limits_set
+func parseLimits(limits *models.BookingLimits, args []string) error {
+	if len(args) == 0 {
+		return errors.New("укажите хотя бы один параметр: active, day или gap")
+	}
+
+	for _, arg := range args {
+		key, value, ok := strings.Cut(arg, "=")
+		n, err := strconv.Atoi(value)
+		if !ok || err != nil || n < 0 {
+			return fmt.Errorf("неверный параметр %q, ожидается ключ=число", arg)
+		}
+
+		switch key {
+		case "active":
+			limits.MaxActive = n
+		case "day":
+			limits.MaxPerDay = n
+		case "gap":
+			limits.MinGap = time.Duration(n) * time.Minute
+		default:
+			return fmt.Errorf("неизвестный параметр %q", key)
+		}
+	}
+	return nil
+}
+
+func (b *CarWashBot) showUserLimits(chatID int64, user *models.User) {
+	limits, custom, err := b.storage.GetUserLimits(context.Background(), user.ID)
+	if err != nil {
+		log.Printf("Ошибка получения ограничений: %v", err)
+		b.sendMessage(chatID, "⚠️ Ошибка загрузки ограничений.")
+		return
+	}
+
+	source := "по умолчанию"
+	if custom {
+		source = "заданы администратором"
+	}
+	b.sendMessage(chatID, fmt.Sprintf("📏 %s (%d), ограничения %s:\nПредстоящих записей: %s\nЗаписей в день: %s\nИнтервал между записями: %s",
+		user.FirstName, user.TelegramID, source,
+		formatLimit(limits.MaxActive), formatLimit(limits.MaxPerDay), formatLimit(int(limits.MinGap/time.Minute))+" мин"))
+}
+
+func formatLimit(n int) string {
+	if n == 0 {
+		return "без ограничений"
+	}
+	return strconv.Itoa(n)
+}
+
+func (b *CarWashBot) handleBanCommand(chatID, userID int64, text string) {
+	if !b.isAdmin(userID) {
+		b.sendMessage(chatID, "⛔ Команда доступна только администратору.")
+		return
+	}
+
+	fields := strings.Fields(text)
+	if fields[0] == "/banlist" {
+		b.showBlockedUsers(chatID)
+		return
+	}
+	if len(fields) < 2 {
+		b.sendMessage(chatID, limitsHelp)
+		return
+	}
+	telegramID, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		b.sendMessage(chatID, "❌ Неверный Telegram ID.")
+		return
+	}
+
+	ctx := context.Background()
+	switch fields[0] {
+	case "/ban":
+		err := b.storage.BlockUser(ctx, &models.BlockedUser{
+			TelegramID: telegramID,
+			Reason:     strings.Join(fields[2:], " "),
+			AdminID:    userID,
+		})
+		if err != nil {
+			log.Printf("Ошибка блокировки: %v", err)
+			b.sendMessage(chatID, "⚠️ Не удалось заблокировать.")
+			return
+		}
+		b.sendMessage(chatID, fmt.Sprintf("⛔ %d больше не может записываться", telegramID))
+	case "/unban":
+		err := b.storage.UnblockUser(ctx, telegramID)
+		if errors.Is(err, sql.ErrNoRows) {
+			b.sendMessage(chatID, "❌ Этот Telegram ID не заблокирован.")
+			return
+		}
+		if err != nil {
+			log.Printf("Ошибка снятия блокировки: %v", err)
+			b.sendMessage(chatID, "⚠️ Не удалось снять блокировку.")
+			return
+		}
+		b.sendMessage(chatID, fmt.Sprintf("✅ %d снова может записываться", telegramID))
+	default:
+		b.sendMessage(chatID, limitsHelp)
+	}
+}
+
+func (b *CarWashBot) showBlockedUsers(chatID int64) {
+	blocked, err := b.storage.GetBlockedUsers(context.Background())
+	if err != nil {
+		log.Printf("Ошибка получения блокировок: %v", err)
+		b.sendMessage(chatID, "⚠️ Ошибка загрузки списка.")
+		return
+	}
+	if len(blocked) == 0 {
+		b.sendMessage(chatID, "Заблокированных нет.")
+		return
+	}
+
+	var sb strings.Builder
+	sb.WriteString("⛔ Заблокированы:\n\n")
+	for _, u := range blocked {
+		sb.WriteString(fmt.Sprintf("%d — %s", u.TelegramID, u.CreatedAt.Format("02.01.2006")))
+		if u.Reason != "" {
+			sb.WriteString(": " + u.Reason)
+		}
+		sb.WriteString("\n")
+	}
+	b.sendMessage(chatID, sb.String())
+}
@@ -0,0 +1,469 @@
+package bot
+
+import (
+	"carwash-bot/internal/i18n"
+	"carwash-bot/internal/models"
+	"carwash-bot/internal/storage"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const locationHelp = `📍 Команды для моек сети:
+/locations — список моек
+/location_new Название; Адрес; широта, долгота; 8-20; посты[; услуги] — добавить мойку
+/location_edit ID Название; Адрес; широта, долгота; 8-20; посты[; услуги] — изменить мойку
+/location_admin ID Telegram_ID — назначить администратора мойки
+/location_unadmin ID Telegram_ID — снять администратора мойки
+
+Координаты можно не указывать: «-». Услуги — коды через запятую, по умолчанию весь каталог.
+Основная мойка (#0) описана в настройках.
+
+Пример: /location_new На Ленина; ул. Ленина, 1; 43.2567, 76.9286; 9-21; 2; body,complex`
+
+func (b *CarWashBot) handleLocationCommand(chatID, userID int64, text string) {
+	if !b.isAdmin(userID) {
+		b.sendMessage(chatID, "⛔ Управлять мойками сети может только администратор.")
+		return
+	}
+
+	fields := strings.Fields(text)
+	args := strings.TrimSpace(strings.TrimPrefix(text, fields[0]))
+
+	switch fields[0] {
+	case "/locations":
+		b.showLocations(chatID)
+	case "/location_new":
+		b.handleLocationCreate(chatID, args)
+	case "/location_edit":
+		b.handleLocationEdit(chatID, args)
+	case "/location_admin":
+		b.handleLocationAdmin(chatID, fields[1:], true)
+	case "/location_unadmin":
+		b.handleLocationAdmin(chatID, fields[1:], false)
+	default:
+		b.sendMessage(chatID, locationHelp)
+	}
+}
+
+func (b *CarWashBot) showLocations(chatID int64) {
+	locations, err := b.bookings.Locations(context.Background())
+	if err != nil {
+		log.Printf("Ошибка получения моек: %v", err)
+		b.sendMessage(chatID, "⚠️ Ошибка загрузки моек.")
+		return
+	}
+
+	var sb strings.Builder
+	sb.WriteString("📍 Мойки сети:\n\n")
+	for _, location := range locations {
+		sb.WriteString(fmt.Sprintf("#%d %s\n", location.ID, locationName(nil, location)))
+		if location.Address != "" && location.Name != "" {
+			sb.WriteString("   " + location.Address + "\n")
+		}
+		sb.WriteString(fmt.Sprintf("   🕒 %02d:00–%02d:00, постов: %d\n",
+			location.StartTime, location.EndTime, b.bookings.Bays(location)))
+		if location.HasCoordinates() {
+			sb.WriteString(fmt.Sprintf("   🗺 %.5f, %.5f\n", location.Latitude, location.Longitude))
+		}
+		if len(location.Services) > 0 {
+			sb.WriteString("   🧽 " + strings.Join(location.Services, ", ") + "\n")
+		}
+		if len(location.Admins) > 0 {
+			admins := make([]string, len(location.Admins))
+			for i, id := range location.Admins {
+				admins[i] = strconv.FormatInt(id, 10)
+			}
+			sb.WriteString("   👤 " + strings.Join(admins, ", ") + "\n")
+		}
+	}
+
+	sb.WriteString("\n")
+	sb.WriteString(locationHelp)
+	b.sendMessage(chatID, sb.String())
+}
+
+func (b *CarWashBot) handleLocationCreate(chatID int64, args string) {
+	location, err := b.parseLocation(args)
+	if err != nil {
+		b.sendMessage(chatID, "❌ "+err.Error()+"\n\n"+locationHelp)
+		return
+	}
+
+	if err := b.storage.CreateLocation(context.Background(), location); err != nil {
+		log.Printf("Ошибка создания мойки: %v", err)
+		b.sendMessage(chatID, "⚠️ Ошибка сохранения мойки.")
+		return
+	}
+
+	b.sendMessage(chatID, fmt.Sprintf("✅ Мойка «%s» добавлена, ID: %d\nНазначить администратора: /location_admin %d Telegram_ID",
+		locationName(nil, location), location.ID, location.ID))
+}
+
+func (b *CarWashBot) handleLocationEdit(chatID int64, args string) {
+	id, rest, _ := strings.Cut(args, " ")
+	locationID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil || locationID <= 0 {
+		b.sendMessage(chatID, "❌ Неверный ID мойки. Основная мойка (#0) меняется в настройках.")
+		return
+	}
+
+	location, err := b.parseLocation(rest)
+	if err != nil {
+		b.sendMessage(chatID, "❌ "+err.Error()+"\n\n"+locationHelp)
+		return
+	}
+	location.ID = locationID
+
+	err = b.storage.UpdateLocation(context.Background(), location)
+	if errors.Is(err, storage.ErrLocationNotFound) {
+		b.sendMessage(chatID, "❌ Мойка не найдена.")
+		return
+	}
+	if err != nil {
+		log.Printf("Ошибка изменения мойки: %v", err)
+		b.sendMessage(chatID, "⚠️ Ошибка сохранения мойки.")
+		return
+	}
+
+	b.sendMessage(chatID, fmt.Sprintf("✅ Мойка #%d «%s» изменена", location.ID, locationName(nil, location)))
+}
+
+func (b *CarWashBot) handleLocationAdmin(chatID int64, args []string, add bool) {
+	if len(args) != 2 {
+		b.sendMessage(chatID, "❌ Формат: /location_admin ID Telegram_ID")
+		return
+	}
+	locationID, err1 := strconv.ParseInt(args[0], 10, 64)
+	telegramID, err2 := strconv.ParseInt(args[1], 10, 64)
+	if err1 != nil || err2 != nil {
+		b.sendMessage(chatID, "❌ ID мойки и Telegram ID должны быть числами.")
+		return
+	}
+
+	ctx := context.Background()
+	location, err := b.storage.GetLocation(ctx, locationID)
+	if err != nil {
+		log.Printf("Ошибка получения мойки: %v", err)
+		b.sendMessage(chatID, "⚠️ Ошибка системы. Попробуйте позже.")
+		return
+	}
+	if location == nil {
+		b.sendMessage(chatID, "❌ Мойка не найдена. Основной мойкой (#0) управляет главный администратор.")
+		return
+	}
+
+	if !add {
+		err := b.storage.RemoveLocationAdmin(ctx, locationID, telegramID)
+		if errors.Is(err, sql.ErrNoRows) {
+			b.sendMessage(chatID, "❌ Этот пользователь не администратор мойки.")
+			return
+		}
+		if err != nil {
+			log.Printf("Ошибка снятия администратора мойки: %v", err)
+			b.sendMessage(chatID, "⚠️ Ошибка системы. Попробуйте позже.")
+			return
+		}
+		b.sendMessage(chatID, fmt.Sprintf("✅ %d больше не администратор мойки «%s»", telegramID, locationName(nil, location)))
+		return
+	}
+
+	if err := b.storage.AddLocationAdmin(ctx, locationID, telegramID); err != nil {
+		log.Printf("Ошибка назначения администратора мойки: %v", err)
+		b.sendMessage(chatID, "⚠️ Ошибка системы. Попробуйте позже.")
+		return
+	}
+	b.sendMessage(chatID, fmt.Sprintf("✅ %d назначен администратором мойки «%s»", telegramID, locationName(nil, location)))
+	b.sendMessage(telegramID, fmt.Sprintf("📍 Вы назначены администратором мойки «%s». Расписание: /schedule, сводка: /digest", locationName(nil, location)))
+}
+
+// parseLocation разбирает мойку из строки
+// «Название; Адрес; широта, долгота; 8-20; посты[; услуги]»
+func (b *CarWashBot) parseLocation(text string) (*models.Location, error) {
+	parts := strings.Split(text, ";")
+	for i := range parts {
+		parts[i] = strings.TrimSpace(parts[i])
+	}
+	if len(parts) < 5 || len(parts) > 6 {
+		return nil, errors.New("неверный формат мойки")
+	}
+
+	location := &models.Location{Name: parts[0], Address: parts[1]}
+	if location.Name == "" {
+		return nil, errors.New("укажите название мойки")
+	}
+
+	if parts[2] != "-" && parts[2] != "" {
+		lat, lon, ok := strings.Cut(parts[2], ",")
+		var err1, err2 error
+		location.Latitude, err1 = strconv.ParseFloat(strings.TrimSpace(lat), 64)
+		location.Longitude, err2 = strconv.ParseFloat(strings.TrimSpace(lon), 64)
+		if !ok || err1 != nil || err2 != nil ||
+			math.Abs(location.Latitude) > 90 || math.Abs(location.Longitude) > 180 {
+			return nil, fmt.Errorf("неверные координаты: %s", parts[2])
+		}
+	}
+
+	start, end, ok := strings.Cut(parts[3], "-")
+	var err1, err2 error
+	location.StartTime, err1 = strconv.Atoi(strings.TrimSpace(start))
+	location.EndTime, err2 = strconv.Atoi(strings.TrimSpace(end))
+	if !ok || err1 != nil || err2 != nil ||
+		location.StartTime < 0 || location.EndTime > 23 || location.StartTime > location.EndTime {
+		return nil, fmt.Errorf("неверные часы работы: %s", parts[3])
+	}
+
+	bays, err := strconv.Atoi(parts[4])
+	if err != nil || bays < 1 {
+		return nil, fmt.Errorf("неверное количество постов: %s", parts[4])
+	}
+	location.Bays = bays
+
+	if len(parts) == 6 && parts[5] != "" {
+		for _, code := range strings.Split(parts[5], ",") {
+			code = strings.TrimSpace(code)
+			if _, ok := b.cfg.GetService(code); !ok {
+				return nil, fmt.Errorf("неизвестная услуга: %s", code)
+			}
+			location.Services = append(location.Services, code)
+		}
+	}
+	return location, nil
+}
+
+// locationName подписывает мойку: название, адрес или «основная мойка».
+// Без l подпись для администратора
+func locationName(l *i18n.Localizer, location *models.Location) string {
+	switch {
+	case location.Name != "":
+		return location.Name
+	case location.Address != "":
+		return location.Address
+	case l != nil:
+		return l.T("location.main")
+	}
+	return "Основная мойка"
+}
+
+// staffLocations возвращает мойки, записи которых видит сотрудник:
+// nil — все мойки сети у главного администратора. ok = false, если
+// пользователь не администратор
+func (b *CarWashBot) staffLocations(userID int64) (locations []int64, ok bool) {
+	if b.isAdmin(userID) {
+		return nil, true
+	}
+
+	locations, err := b.storage.GetAdminLocations(context.Background(), userID)
+	if err != nil {
+		log.Printf("Ошибка получения моек администратора: %v", err)
+		return nil, false
+	}
+	return locations, len(locations) > 0
+}
+
+// isStaff сообщает, что пользователь — главный администратор или
+// администратор одной из моек
+func (b *CarWashBot) isStaff(userID int64) bool {
+	_, ok := b.staffLocations(userID)
+	return ok
+}
+
+// canManageBooking проверяет, что запись относится к мойке сотрудника
+func (b *CarWashBot) canManageBooking(userID int64, booking *models.Booking) bool {
+	locations, ok := b.staffLocations(userID)
+	return ok && (locations == nil || slices.Contains(locations, booking.LocationID))
+}
+
+// bookingAdmins возвращает, кому сообщать о записях мойки: главному
+// администратору и администраторам этой мойки
+func (b *CarWashBot) bookingAdmins(locationID int64) []int64 {
+	admins := []int64{b.adminID}
+	if locationID == 0 {
+		return admins
+	}
+
+	location, err := b.storage.GetLocation(context.Background(), locationID)
+	if err != nil {
+		log.Printf("Ошибка получения мойки: %v", err)
+		return admins
+	}
+	if location != nil {
+		for _, id := range location.Admins {
+			if id != b.adminID {
+				admins = append(admins, id)
+			}
+		}
+	}
+	return admins
+}
+
+// location возвращает мойку по ID. Если мойку не удалось получить,
+// возвращает основную, чтобы показать хотя бы её часы и посты
+func (b *CarWashBot) location(locationID int64) *models.Location {
+	location, err := b.bookings.Location(context.Background(), locationID)
+	if err != nil {
+		log.Printf("Ошибка получения мойки %d: %v", locationID, err)
+		location, _ = b.bookings.Location(context.Background(), 0)
+	}
+	return location
+}
+
+// locationIndex возвращает все мойки сети по ID
+func (b *CarWashBot) locationIndex() (map[int64]*models.Location, error) {
+	locations, err := b.bookings.Locations(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	index := make(map[int64]*models.Location, len(locations))
+	for _, location := range locations {
+		index[location.ID] = location
+	}
+	return index, nil
+}
+
+// hasLocations сообщает, что в сети больше одной мойки и клиенту нужно
+// выбирать, куда записаться
+func (b *CarWashBot) hasLocations() bool {
+	locations, err := b.storage.GetLocations(context.Background())
+	if err != nil {
+		log.Printf("Ошибка получения моек: %v", err)
+		return false
+	}
+	return len(locations) > 0
+}
+
+// selectedLocation возвращает мойку, выбранную пользователем в мастере записи
+func (b *CarWashBot) selectedLocation(userID int64) *models.Location {
+	return b.location(b.userStates[userID].SelectedLocation)
+}
+
+// startBooking начинает запись: если в сети несколько моек, сначала
+// предлагает выбрать мойку, иначе сразу показывает календарь
+func (b *CarWashBot) startBooking(chatID, userID int64) {
+	if !b.hasLocations() {
+		b.showDaySelection(chatID)
+		return
+	}
+
+	b.resetWizard(userID)
+	b.userStates[userID] = models.UserState{AwaitingLocation: true}
+	b.showLocationSelection(chatID, userID)
+}
+
+func (b *CarWashBot) showLocationSelection(chatID, userID int64) {
+	l := b.tr(userID)
+	locations, err := b.bookings.Locations(context.Background())
+	if err != nil {
+		log.Printf("Ошибка получения моек: %v", err)
+		b.sendMessage(chatID, l.T("error.system"))
+		return
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, location := range locations {
+		label := "📍 " + locationName(l, location)
+		if location.Name != "" && location.Address != "" {
+			label += " — " + location.Address
+		}
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(label, fmt.Sprintf("loc_%d", location.ID)),
+		))
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(l.T("button.abort"), "wizard_abort"),
+	))
+
+	b.showWizardStep(chatID, userID, l.T("location.title"), tgbotapi.NewInlineKeyboardMarkup(rows...))
+}
+
+func (b *CarWashBot) handleLocationSelection(chatID, userID int64, messageID int, value string) {
+	l := b.tr(userID)
+	locationID, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return
+	}
+	if _, err := b.bookings.Location(context.Background(), locationID); err != nil {
+		log.Printf("Ошибка получения мойки %d: %v", locationID, err)
+		b.setNotice(userID, l.T("location.unknown"))
+		b.showLocationSelection(chatID, userID)
+		return
+	}
+
+	state := b.userStates[userID]
+	if state.MessageID == 0 {
+		state.MessageID = messageID
+	}
+	state.AwaitingLocation = false
+	state.SelectedLocation = locationID
+	state.UpdatedAt = b.now()
+	b.userStates[userID] = state
+
+	b.showCalendar(chatID, time.Now(), state.MessageID)
+}
+
+// handleSharedLocation предлагает ближайшую к клиенту мойку сети по
+// отправленной геопозиции
+func (b *CarWashBot) handleSharedLocation(chatID, userID int64, point *tgbotapi.Location) {
+	l := b.tr(userID)
+	if !b.canBook(chatID, userID) {
+		return
+	}
+
+	locations, err := b.bookings.Locations(context.Background())
+	if err != nil {
+		log.Printf("Ошибка получения моек: %v", err)
+		b.sendMessage(chatID, l.T("error.system"))
+		return
+	}
+
+	nearest, distance := nearestLocation(locations, point.Latitude, point.Longitude)
+	if nearest == nil {
+		b.sendMessage(chatID, l.T("location.none_nearby"))
+		b.startBooking(chatID, userID)
+		return
+	}
+
+	b.resetWizard(userID)
+	b.userStates[userID] = models.UserState{SelectedLocation: nearest.ID}
+	b.sendMessage(chatID, l.T("location.nearest", locationName(l, nearest), distance))
+	b.showDaySelection(chatID)
+}
+
+// nearestLocation возвращает ближайшую мойку с координатами и расстояние
+// до неё в километрах
+func nearestLocation(locations []*models.Location, lat, lon float64) (*models.Location, float64) {
+	var (
+		nearest  *models.Location
+		distance float64
+	)
+	for _, location := range locations {
+		if !location.HasCoordinates() {
+			continue
+		}
+		d := distanceKm(lat, lon, location.Latitude, location.Longitude)
+		if nearest == nil || d < distance {
+			nearest, distance = location, d
+		}
+	}
+	return nearest, distance
+}
+
+// distanceKm — расстояние между точками по поверхности Земли
+func distanceKm(lat1, lon1, lat2, lon2 float64) float64 {
+	const earthRadius = 6371.0
+	rad := func(deg float64) float64 { return deg * math.Pi / 180 }
+
+	dLat, dLon := rad(lat2-lat1), rad(lon2-lon1)
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(rad(lat1))*math.Cos(rad(lat2))*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadius * math.Asin(math.Sqrt(a))
+}
@@ -0,0 +1,338 @@
+package bot
+
+import (
+	"carwash-bot/config"
+	"carwash-bot/internal/i18n"
+	"carwash-bot/internal/models"
+	"carwash-bot/internal/storage"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// applyLoyalty применяет к новой записи скидку по программе лояльности
+func (b *CarWashBot) applyLoyalty(booking *models.Booking) {
+	// Корпоративные мойки оплачивает организация, бонусы на них не действуют
+	if booking.OrganizationID != 0 || booking.Price == 0 {
+		return
+	}
+
+	ctx := context.Background()
+	account, err := b.storage.GetLoyaltyAccount(ctx, booking.UserID)
+	if err != nil {
+		log.Printf("Ошибка получения бонусного счёта: %v", err)
+		return
+	}
+
+	switch b.cfg.Loyalty.Mode {
+	case config.LoyaltyNthFree:
+		if b.cfg.Loyalty.EveryNth <= 0 {
+			return
+		}
+		upcoming, err := b.storage.CountUpcomingBookings(ctx, booking.UserID)
+		if err != nil {
+			log.Printf("Ошибка подсчёта записей: %v", err)
+			return
+		}
+		// Порядковый номер мойки с учётом уже оформленных записей. После
+		// отмены одной из них номер пересчитывает recheckFreeVisits
+		if (account.Visits+upcoming+1)%b.cfg.Loyalty.EveryNth == 0 {
+			// Мойка и так бесплатная, промокод не расходуем
+			booking.Discount += booking.Price
+			booking.Price = 0
+			booking.PromoCode = ""
+		}
+
+	case config.LoyaltyCashback:
+		points := min(account.Points, booking.Price)
+		if points > 0 {
+			booking.PointsUsed = points
+			booking.Discount += points
+			booking.Price -= points
+		}
+	}
+}
+
+// recheckFreeVisits пересчитывает бесплатные мойки клиента после отмены
+// или истечения его записи и сообщает, какие мойки снова платные
+func (b *CarWashBot) recheckFreeVisits(customerID int64) {
+	revoked, err := b.loyalty.RecheckFreeVisits(context.Background(), customerID)
+	if err != nil {
+		log.Printf("Ошибка пересчёта бесплатных моек: %v", err)
+		return
+	}
+	if len(revoked) == 0 {
+		return
+	}
+
+	customer, err := b.storage.GetUserByID(context.Background(), customerID)
+	if err != nil || customer == nil {
+		log.Printf("Ошибка получения клиента: %v", err)
+		return
+	}
+	l := b.tr(customer.TelegramID)
+	for _, booking := range revoked {
+		b.sendMessage(customer.TelegramID, l.T("loyalty.free_revoked",
+			booking.Date, booking.Time, b.cfg.Loyalty.EveryNth, b.money(booking.Price)))
+	}
+}
+
+// loyaltyProgress описывает состояние бонусной программы для клиента
+func (b *CarWashBot) loyaltyProgress(l *i18n.Localizer, account *models.LoyaltyAccount) string {
+	switch b.cfg.Loyalty.Mode {
+	case config.LoyaltyNthFree:
+		n := b.cfg.Loyalty.EveryNth
+		if n <= 0 {
+			return ""
+		}
+		left := n - account.Visits%n
+		if left == 1 {
+			return l.T("loyalty.next_free")
+		}
+		return l.T("loyalty.until_free", left-1)
+	case config.LoyaltyCashback:
+		return l.T("loyalty.points", account.Points, b.money(1), b.cfg.Loyalty.CashbackPercent)
+	}
+	return ""
+}
+
+func (b *CarWashBot) showProfile(chatID, userID int64) {
+	l := b.tr(userID)
+	user, err := b.storage.GetUserByTelegramID(context.Background(), userID)
+	if err != nil || user == nil {
+		log.Printf("Ошибка получения пользователя: %v", err)
+		b.sendMessage(chatID, l.T("error.profile"))
+		return
+	}
+
+	account, err := b.storage.GetLoyaltyAccount(context.Background(), user.ID)
+	if err != nil {
+		log.Printf("Ошибка получения бонусного счёта: %v", err)
+		b.sendMessage(chatID, l.T("error.profile"))
+		return
+	}
+
+	var sb strings.Builder
+	sb.WriteString(l.T("profile.title") + "\n\n")
+	sb.WriteString(l.T("profile.name", strings.TrimSpace(user.FirstName+" "+user.LastName)) + "\n")
+	sb.WriteString(l.T("language.current", i18n.Name(l.Lang)) + "\n")
+	if user.Phone != "" {
+		sb.WriteString(l.T("profile.phone", user.Phone) + "\n")
+	} else {
+		sb.WriteString(l.T("profile.no_phone") + "\n")
+	}
+	sb.WriteString(l.T("profile.visits", account.Visits) + "\n")
+	if progress := b.loyaltyProgress(l, account); progress != "" {
+		sb.WriteString(progress + "\n")
+	}
+
+	if restriction := b.userRestriction(user); restriction != config.RestrictNone {
+		sb.WriteString(l.T("profile.strikes", user.LateCancels, user.NoShows, restrictionName(l, restriction)) + "\n")
+	}
+
+	memberships, err := b.storage.GetUserMemberships(context.Background(), userID)
+	if err != nil {
+		log.Printf("Ошибка получения организаций: %v", err)
+	}
+	for _, m := range memberships {
+		sb.WriteString(l.T("profile.organization", m.Organization.Name, roleName(l, m.Role)) + "\n")
+	}
+
+	msg := tgbotapi.NewMessage(chatID, sb.String())
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(l.T("button.language"), "lang_menu"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(l.T("button.main_menu"), "main_menu"),
+		),
+	)
+	b.sendMessageWithSave(chatID, msg)
+}
+
+// handleBookingCompletion вызывается администратором после выполнения мойки
+func (b *CarWashBot) handleBookingCompletion(chatID, userID int64, bookingID string) {
+	if !b.isStaff(userID) {
+		b.sendMessage(chatID, "⛔ Отмечать мойки выполненными может только администратор.")
+		return
+	}
+
+	booking, err := b.storage.GetBooking(context.Background(), bookingID)
+	if err != nil || booking == nil {
+		log.Printf("Ошибка получения записи: %v", err)
+		b.sendMessage(chatID, "❌ Запись не найдена.")
+		return
+	}
+	if !b.canManageBooking(userID, booking) {
+		b.sendMessage(chatID, "⛔ Эта запись на другую мойку.")
+		return
+	}
+
+	booking, err = b.bookings.Complete(context.Background(), booking)
+	if err != nil {
+		log.Printf("Ошибка завершения записи: %v", err)
+		b.sendMessage(chatID, "❌ Запись уже выполнена или отменена.")
+		return
+	}
+
+	b.sendMessage(chatID, fmt.Sprintf("✅ Мойка %s %s — %s %s отмечена выполненной",
+		booking.Date, booking.Time, booking.CarModel, booking.CarNumber))
+
+	customer, err := b.storage.GetUserByID(context.Background(), booking.UserID)
+	if err != nil || customer == nil {
+		log.Printf("Ошибка получения клиента: %v", err)
+		return
+	}
+	account, err := b.storage.GetLoyaltyAccount(context.Background(), booking.UserID)
+	if err != nil {
+		log.Printf("Ошибка получения бонусного счёта: %v", err)
+		return
+	}
+
+	l := b.tr(customer.TelegramID)
+	text := l.T("loyalty.thanks", account.Visits)
+	if cashback := b.bookings.Cashback(booking); cashback > 0 {
+		text += "\n" + l.T("loyalty.earned", cashback)
+	}
+	if progress := b.loyaltyProgress(l, account); progress != "" {
+		text += "\n" + progress
+	}
+	b.sendMessage(customer.TelegramID, text)
+}
+
+func (b *CarWashBot) handlePointsCommand(chatID, userID int64, text string) {
+	if !b.isAdmin(userID) {
+		b.sendMessage(chatID, "⛔ Команда доступна только администратору.")
+		return
+	}
+
+	fields := strings.Fields(text)
+	switch fields[0] {
+	case "/points":
+		if len(fields) < 2 {
+			b.sendMessage(chatID, "❌ Формат: /points Telegram_ID")
+			return
+		}
+		b.showLoyaltyAccount(chatID, fields[1])
+	case "/points_add":
+		if len(fields) < 4 {
+			b.sendMessage(chatID, "❌ Формат: /points_add Telegram_ID ±баллы причина")
+			return
+		}
+		b.adjustLoyaltyPoints(chatID, userID, fields[1], fields[2], strings.Join(fields[3:], " "))
+	}
+}
+
+func (b *CarWashBot) findCustomer(chatID int64, arg string) *models.User {
+	telegramID, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil {
+		b.sendMessage(chatID, "❌ Неверный Telegram ID.")
+		return nil
+	}
+
+	user, err := b.storage.GetUserByTelegramID(context.Background(), telegramID)
+	if err != nil {
+		log.Printf("Ошибка получения пользователя: %v", err)
+		b.sendMessage(chatID, "⚠️ Ошибка системы. Попробуйте позже.")
+		return nil
+	}
+	if user == nil {
+		b.sendMessage(chatID, "❌ Клиент не найден.")
+		return nil
+	}
+	return user
+}
+
+func (b *CarWashBot) showLoyaltyAccount(chatID int64, arg string) {
+	user := b.findCustomer(chatID, arg)
+	if user == nil {
+		return
+	}
+
+	account, err := b.storage.GetLoyaltyAccount(context.Background(), user.ID)
+	if err != nil {
+		log.Printf("Ошибка получения бонусного счёта: %v", err)
+		b.sendMessage(chatID, "⚠️ Ошибка загрузки бонусного счёта.")
+		return
+	}
+
+	transactions, err := b.storage.GetLoyaltyTransactions(context.Background(), user.ID, 10)
+	if err != nil {
+		log.Printf("Ошибка получения журнала баллов: %v", err)
+		b.sendMessage(chatID, "⚠️ Ошибка загрузки бонусного счёта.")
+		return
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("💎 %s (%d)\nВизитов: %d\nБаллов: %d\n\n",
+		user.FirstName, user.TelegramID, account.Visits, account.Points))
+
+	sb.WriteString("Последние операции:\n")
+	for _, t := range transactions {
+		sb.WriteString(fmt.Sprintf("%s %+d — %s", t.CreatedAt.Format("02.01.2006 15:04"), t.Delta, loyaltyReasonName(t.Reason)))
+		if t.Comment != "" {
+			sb.WriteString(": " + t.Comment)
+		}
+		if t.AdminID != 0 {
+			sb.WriteString(fmt.Sprintf(" (админ %d)", t.AdminID))
+		}
+		sb.WriteString("\n")
+	}
+	if len(transactions) == 0 {
+		sb.WriteString("Операций нет\n")
+	}
+
+	b.sendMessage(chatID, sb.String())
+}
+
+func (b *CarWashBot) adjustLoyaltyPoints(chatID, adminID int64, userArg, deltaArg, comment string) {
+	user := b.findCustomer(chatID, userArg)
+	if user == nil {
+		return
+	}
+
+	delta, err := strconv.Atoi(deltaArg)
+	if err != nil || delta == 0 {
+		b.sendMessage(chatID, "❌ Укажите количество баллов, например +100 или -50")
+		return
+	}
+
+	err = b.storage.AdjustLoyaltyPoints(context.Background(), &models.LoyaltyTransaction{
+		UserID:  user.ID,
+		Delta:   delta,
+		Reason:  models.LoyaltyManual,
+		Comment: comment,
+		AdminID: adminID,
+	})
+	if errors.Is(err, storage.ErrInsufficientPoints) {
+		b.sendMessage(chatID, "❌ У клиента недостаточно баллов для списания.")
+		return
+	}
+	if err != nil {
+		log.Printf("Ошибка изменения баллов: %v", err)
+		b.sendMessage(chatID, "⚠️ Не удалось изменить баланс.")
+		return
+	}
+
+	b.sendMessage(chatID, fmt.Sprintf("✅ Баланс клиента %d изменён на %+d", user.TelegramID, delta))
+	b.showLoyaltyAccount(chatID, userArg)
+}
+
+func loyaltyReasonName(reason string) string {
+	switch reason {
+	case models.LoyaltyCashback:
+		return "кэшбэк"
+	case models.LoyaltyRedeem:
+		return "оплата баллами"
+	case models.LoyaltyRefund:
+		return "возврат"
+	case models.LoyaltyManual:
+		return "вручную"
+	}
+	return reason
+}
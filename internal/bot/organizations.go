@@ -0,0 +1,407 @@
+package bot
+
+import (
+	"carwash-bot/internal/i18n"
+	"carwash-bot/internal/models"
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const organizationHelp = `🏢 Команды для организаций:
+/org — мои организации
+/org_new Название — создать организацию (админ)
+/org_add ID_организации Telegram_ID manager|driver — добавить участника
+/org_remove ID_организации Telegram_ID — удалить участника
+/org_car ID_организации Марка Номер — добавить автомобиль
+/org_report ID_организации [ММ.ГГГГ] — выписка за месяц`
+
+func (b *CarWashBot) isAdmin(userID int64) bool {
+	return userID == b.adminID
+}
+
+func (b *CarWashBot) handleOrganizationCommand(chatID, userID int64, text string) {
+	fields := strings.Fields(text)
+	args := fields[1:]
+
+	switch fields[0] {
+	case "/org":
+		b.showOrganizations(chatID, userID)
+	case "/org_new":
+		b.handleOrganizationCreate(chatID, userID, strings.Join(args, " "))
+	case "/org_add":
+		b.handleOrganizationAddMember(chatID, userID, args)
+	case "/org_remove":
+		b.handleOrganizationRemoveMember(chatID, userID, args)
+	case "/org_car":
+		b.handleOrganizationAddVehicle(chatID, userID, args)
+	case "/org_report":
+		b.handleOrganizationReport(chatID, userID, args)
+	default:
+		b.sendMessage(chatID, organizationHelp)
+	}
+}
+
+// canManageOrganization проверяет, что пользователь — админ или менеджер организации
+func (b *CarWashBot) canManageOrganization(userID, orgID int64) bool {
+	if b.isAdmin(userID) {
+		return true
+	}
+
+	member, err := b.storage.GetOrganizationMember(context.Background(), orgID, userID)
+	if err != nil {
+		log.Printf("Ошибка получения участника организации: %v", err)
+		return false
+	}
+	return member != nil && member.Role == models.RoleManager
+}
+
+// parseOrganizationID разбирает ID организации и проверяет права доступа
+func (b *CarWashBot) parseOrganizationID(chatID, userID int64, arg string) (*models.Organization, bool) {
+	orgID, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil {
+		b.sendMessage(chatID, "❌ Неверный ID организации.")
+		return nil, false
+	}
+
+	if !b.canManageOrganization(userID, orgID) {
+		b.sendMessage(chatID, "⛔ Недостаточно прав для управления этой организацией.")
+		return nil, false
+	}
+
+	org, err := b.storage.GetOrganization(context.Background(), orgID)
+	if err != nil {
+		log.Printf("Ошибка получения организации: %v", err)
+		b.sendMessage(chatID, "⚠️ Ошибка системы. Попробуйте позже.")
+		return nil, false
+	}
+	if org == nil {
+		b.sendMessage(chatID, "❌ Организация не найдена.")
+		return nil, false
+	}
+	return org, true
+}
+
+func (b *CarWashBot) showOrganizations(chatID, userID int64) {
+	var sb strings.Builder
+
+	if b.isAdmin(userID) {
+		orgs, err := b.storage.GetAllOrganizations(context.Background())
+		if err != nil {
+			log.Printf("Ошибка получения организаций: %v", err)
+			b.sendMessage(chatID, "⚠️ Ошибка загрузки организаций.")
+			return
+		}
+
+		sb.WriteString("🏢 Все организации:\n\n")
+		for _, org := range orgs {
+			sb.WriteString(fmt.Sprintf("#%d %s\n", org.ID, org.Name))
+		}
+		if len(orgs) == 0 {
+			sb.WriteString("Организаций пока нет\n")
+		}
+	} else {
+		memberships, err := b.storage.GetUserMemberships(context.Background(), userID)
+		if err != nil {
+			log.Printf("Ошибка получения организаций: %v", err)
+			b.sendMessage(chatID, "⚠️ Ошибка загрузки организаций.")
+			return
+		}
+		if len(memberships) == 0 {
+			b.sendMessage(chatID, "Вы не состоите ни в одной организации.")
+			return
+		}
+
+		sb.WriteString("🏢 Ваши организации:\n\n")
+		for _, m := range memberships {
+			sb.WriteString(fmt.Sprintf("#%d %s — %s\n", m.OrganizationID, m.Organization.Name, roleName(b.tr(userID), m.Role)))
+		}
+	}
+
+	sb.WriteString("\n")
+	sb.WriteString(organizationHelp)
+	b.sendMessage(chatID, sb.String())
+}
+
+func (b *CarWashBot) handleOrganizationCreate(chatID, userID int64, name string) {
+	if !b.isAdmin(userID) {
+		b.sendMessage(chatID, "⛔ Создавать организации может только администратор.")
+		return
+	}
+	if strings.TrimSpace(name) == "" {
+		b.sendMessage(chatID, "❌ Укажите название: /org_new Название")
+		return
+	}
+
+	org := &models.Organization{Name: strings.TrimSpace(name)}
+	if err := b.storage.CreateOrganization(context.Background(), org); err != nil {
+		log.Printf("Ошибка создания организации: %v", err)
+		b.sendMessage(chatID, "⚠️ Не удалось создать организацию.")
+		return
+	}
+
+	b.sendMessage(chatID, fmt.Sprintf("✅ Организация «%s» создана, ID: %d", org.Name, org.ID))
+}
+
+func (b *CarWashBot) handleOrganizationAddMember(chatID, userID int64, args []string) {
+	if len(args) < 2 {
+		b.sendMessage(chatID, "❌ Формат: /org_add ID_организации Telegram_ID manager|driver")
+		return
+	}
+
+	org, ok := b.parseOrganizationID(chatID, userID, args[0])
+	if !ok {
+		return
+	}
+
+	telegramID, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		b.sendMessage(chatID, "❌ Неверный Telegram ID.")
+		return
+	}
+
+	role := models.RoleDriver
+	if len(args) > 2 {
+		role = args[2]
+	}
+	if role != models.RoleDriver && role != models.RoleManager {
+		b.sendMessage(chatID, "❌ Роль должна быть manager или driver.")
+		return
+	}
+	// Назначать менеджеров может только администратор
+	if role == models.RoleManager && !b.isAdmin(userID) {
+		b.sendMessage(chatID, "⛔ Назначать менеджеров может только администратор.")
+		return
+	}
+
+	member := &models.OrganizationMember{
+		OrganizationID: org.ID,
+		TelegramID:     telegramID,
+		Role:           role,
+	}
+	if err := b.storage.AddOrganizationMember(context.Background(), member); err != nil {
+		log.Printf("Ошибка добавления участника: %v", err)
+		b.sendMessage(chatID, "⚠️ Не удалось добавить участника.")
+		return
+	}
+
+	b.sendMessage(chatID, fmt.Sprintf("✅ %d добавлен в «%s» как %s", telegramID, org.Name, roleName(i18n.New(i18n.Default), role)))
+}
+
+func (b *CarWashBot) handleOrganizationRemoveMember(chatID, userID int64, args []string) {
+	if len(args) < 2 {
+		b.sendMessage(chatID, "❌ Формат: /org_remove ID_организации Telegram_ID")
+		return
+	}
+
+	org, ok := b.parseOrganizationID(chatID, userID, args[0])
+	if !ok {
+		return
+	}
+
+	telegramID, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		b.sendMessage(chatID, "❌ Неверный Telegram ID.")
+		return
+	}
+
+	if err := b.storage.RemoveOrganizationMember(context.Background(), org.ID, telegramID); err != nil {
+		log.Printf("Ошибка удаления участника: %v", err)
+		b.sendMessage(chatID, "❌ Участник не найден.")
+		return
+	}
+
+	b.sendMessage(chatID, fmt.Sprintf("✅ %d удалён из «%s»", telegramID, org.Name))
+}
+
+func (b *CarWashBot) handleOrganizationAddVehicle(chatID, userID int64, args []string) {
+	if len(args) < 3 {
+		b.sendMessage(chatID, "❌ Формат: /org_car ID_организации Марка Номер")
+		return
+	}
+
+	org, ok := b.parseOrganizationID(chatID, userID, args[0])
+	if !ok {
+		return
+	}
+
+	vehicle := &models.Vehicle{
+		OrganizationID: org.ID,
+		CarModel:       args[1],
+		CarNumber:      strings.Join(args[2:], " "),
+	}
+	if err := b.storage.AddVehicle(context.Background(), vehicle); err != nil {
+		log.Printf("Ошибка добавления автомобиля: %v", err)
+		b.sendMessage(chatID, "⚠️ Не удалось добавить автомобиль.")
+		return
+	}
+
+	b.sendMessage(chatID, fmt.Sprintf("✅ Автомобиль %s %s добавлен в «%s»",
+		vehicle.CarModel, vehicle.CarNumber, org.Name))
+}
+
+func (b *CarWashBot) handleOrganizationReport(chatID, userID int64, args []string) {
+	if len(args) < 1 {
+		b.sendMessage(chatID, "❌ Формат: /org_report ID_организации [ММ.ГГГГ]")
+		return
+	}
+
+	org, ok := b.parseOrganizationID(chatID, userID, args[0])
+	if !ok {
+		return
+	}
+
+	period := time.Now()
+	if len(args) > 1 {
+		parsed, err := time.Parse("01.2006", args[1])
+		if err != nil {
+			b.sendMessage(chatID, "❌ Месяц укажите в формате ММ.ГГГГ, например 03.2025")
+			return
+		}
+		period = parsed
+	}
+
+	bookings, err := b.storage.GetOrganizationStatement(context.Background(), org.ID, int(period.Month()), period.Year())
+	if err != nil {
+		log.Printf("Ошибка формирования выписки: %v", err)
+		b.sendMessage(chatID, "⚠️ Не удалось сформировать выписку.")
+		return
+	}
+
+	b.sendMessage(chatID, b.formatOrganizationStatement(org, period, bookings))
+}
+
+func (b *CarWashBot) formatOrganizationStatement(org *models.Organization, period time.Time, bookings []*models.Booking) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("🧾 Выписка «%s» за %s\n\n", org.Name, period.Format("01.2006")))
+
+	total := 0
+	for _, booking := range bookings {
+		driver := booking.User.FirstName
+		if booking.User.Username != "" {
+			driver = "@" + booking.User.Username
+		}
+
+		sb.WriteString(fmt.Sprintf("📅 %s %s | 🚗 %s %s | %s | %d ₽ | %s\n",
+			booking.Date,
+			booking.Time,
+			booking.CarModel,
+			booking.CarNumber,
+			b.serviceName(booking.Service),
+			booking.Price,
+			driver))
+		total += booking.Price
+	}
+
+	if len(bookings) == 0 {
+		sb.WriteString("За этот месяц моек не было\n")
+	} else {
+		sb.WriteString(fmt.Sprintf("\nИтого: %d моек на сумму %d ₽", len(bookings), total))
+	}
+	return sb.String()
+}
+
+// showOrganizationVehicles предлагает водителю машины его организаций.
+// Возвращает false, если выбирать не из чего и нужно ввести авто вручную
+func (b *CarWashBot) showOrganizationVehicles(chatID, userID int64) bool {
+	memberships, err := b.storage.GetUserMemberships(context.Background(), userID)
+	if err != nil {
+		log.Printf("Ошибка получения организаций: %v", err)
+		return false
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, m := range memberships {
+		vehicles, err := b.storage.GetOrganizationVehicles(context.Background(), m.OrganizationID)
+		if err != nil {
+			log.Printf("Ошибка получения автомобилей: %v", err)
+			continue
+		}
+
+		for _, v := range vehicles {
+			btnText := fmt.Sprintf("🚕 %s %s (%s)", v.CarModel, v.CarNumber, m.Organization.Name)
+			rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData(btnText, fmt.Sprintf("vehicle_%d", v.ID)),
+			))
+		}
+	}
+
+	if len(rows) == 0 {
+		return false
+	}
+
+	l := b.tr(userID)
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(l.T("vehicle.personal"), "vehicle_0"),
+	))
+	rows = append(rows, backButton(l, "service"))
+
+	b.showWizardStep(chatID, userID, l.T("vehicle.choose"), tgbotapi.NewInlineKeyboardMarkup(rows...))
+	return true
+}
+
+func (b *CarWashBot) handleVehicleSelection(chatID, userID int64, vehicleID int64) {
+	l := b.tr(userID)
+	state, exists := b.userStates[userID]
+	if !exists || state.SelectedService == "" {
+		b.sendMessage(chatID, l.T("vehicle.first"))
+		b.showDaySelection(chatID)
+		return
+	}
+
+	// Личный автомобиль вводится вручную, оплата — как обычно
+	if vehicleID == 0 {
+		state.AwaitingCarInfo = true
+		b.userStates[userID] = state
+		b.askCarInfo(chatID, userID)
+		return
+	}
+
+	vehicle, err := b.storage.GetVehicle(context.Background(), vehicleID)
+	if err != nil || vehicle == nil {
+		log.Printf("Ошибка получения автомобиля: %v", err)
+		b.sendMessage(chatID, l.T("vehicle.not_found"))
+		return
+	}
+
+	member, err := b.storage.GetOrganizationMember(context.Background(), vehicle.OrganizationID, userID)
+	if err != nil || member == nil {
+		b.sendMessage(chatID, l.T("vehicle.foreign"))
+		return
+	}
+
+	user, err := b.storage.GetUserByTelegramID(context.Background(), userID)
+	if err != nil || user == nil {
+		log.Printf("Ошибка получения пользователя: %v", err)
+		b.sendMessage(chatID, l.T("error.system"))
+		return
+	}
+
+	service, _ := b.cfg.GetService(state.SelectedService)
+	booking := &models.Booking{
+		UserID:         user.ID,
+		Date:           state.SelectedDate,
+		Time:           state.SelectedTime,
+		LocationID:     state.SelectedLocation,
+		CarModel:       vehicle.CarModel,
+		CarNumber:      vehicle.CarNumber,
+		Service:        service.Code,
+		Price:          service.Price,
+		OrganizationID: vehicle.OrganizationID,
+	}
+
+	b.showConfirmation(chatID, userID, booking)
+}
+
+// roleName возвращает название роли участника организации
+func roleName(l *i18n.Localizer, role string) string {
+	if role == models.RoleManager {
+		return l.T("role.manager")
+	}
+	return l.T("role.driver")
+}
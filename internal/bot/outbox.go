@@ -0,0 +1,260 @@
+package bot
+
+import (
+	"carwash-bot/internal/models"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	outboxInterval   = 15 * time.Second
+	outboxRetryBase  = 15 * time.Second
+	outboxRetryMax   = time.Hour
+	outboxBatch      = 20
+	outboxKeepSent   = 7 * 24 * time.Hour // Сколько хранить доставленные сообщения
+	outboxListLength = 20
+	outboxSendLease  = time.Minute // Если отправка не завершилась за это время, сообщение отправится снова
+)
+
+// deliver сохраняет важное уведомление в очередь и сразу пытается его
+// отправить. При ошибке отправка повторится в фоне
+func (b *CarWashBot) deliver(msg tgbotapi.MessageConfig) {
+	m, err := toOutboxMessage(msg)
+	if err == nil {
+		err = b.storage.EnqueueMessage(context.Background(), m)
+	}
+	if err != nil {
+		log.Printf("Ошибка сохранения сообщения в очередь: %v", err)
+		if _, err := b.botAPI.Send(msg); err != nil {
+			log.Printf("Ошибка отправки сообщения: %v", err)
+		}
+		return
+	}
+
+	b.attemptDelivery(m)
+}
+
+// deliverLater ставит сообщения в очередь по порядку и будит фоновую
+// отправку. Так длинные расписания и сводки из нескольких сообщений не
+// ждут в обработке обновлений, пока Telegram разрешит писать в чат
+func (b *CarWashBot) deliverLater(msgs ...tgbotapi.MessageConfig) {
+	for _, msg := range msgs {
+		m, err := toOutboxMessage(msg)
+		if err == nil {
+			err = b.storage.EnqueueMessage(context.Background(), m)
+		}
+		if err != nil {
+			log.Printf("Ошибка сохранения сообщения в очередь: %v", err)
+		}
+	}
+
+	select {
+	case b.outboxWake <- struct{}{}:
+	default:
+	}
+}
+
+// retryLater ставит в очередь сообщение, которое не удалось отправить сразу
+func (b *CarWashBot) retryLater(msg tgbotapi.MessageConfig, sendErr error) {
+	if isPermanentSendError(sendErr) {
+		return
+	}
+
+	m, err := toOutboxMessage(msg)
+	if err != nil {
+		log.Printf("Ошибка сохранения сообщения в очередь: %v", err)
+		return
+	}
+	m.Attempts = 1
+	m.LastError = sendErr.Error()
+	m.NextAttemptAt = time.Now().Add(max(outboxBackoff(1), retryAfter(sendErr)))
+	if err := b.storage.EnqueueMessage(context.Background(), m); err != nil {
+		log.Printf("Ошибка сохранения сообщения в очередь: %v", err)
+	}
+}
+
+// attemptDelivery отправляет сообщение из очереди. deliver и runOutbox
+// могут взять одно сообщение одновременно, отправит его тот, кто захватит
+func (b *CarWashBot) attemptDelivery(m *models.OutboxMessage) {
+	ctx := context.Background()
+
+	now := time.Now()
+	claimed, err := b.storage.ClaimMessage(ctx, m.ID, now, now.Add(outboxSendLease))
+	if err != nil {
+		log.Printf("Ошибка обновления очереди сообщений: %v", err)
+		return
+	}
+	if !claimed {
+		return
+	}
+
+	_, err = b.botAPI.Send(fromOutboxMessage(m))
+	if err == nil {
+		if err := b.storage.MarkMessageSent(ctx, m.ID); err != nil {
+			log.Printf("Ошибка обновления очереди сообщений: %v", err)
+		}
+		return
+	}
+
+	attempts := m.Attempts + 1
+	// Ожидание по 429 временное и попыткой не считается
+	dead := isPermanentSendError(err) || (attempts >= b.cfg.Outbox.MaxAttempts && retryAfter(err) == 0)
+	log.Printf("Ошибка отправки сообщения %d (попытка %d): %v", m.ID, attempts, err)
+	next := time.Now().Add(max(outboxBackoff(attempts), retryAfter(err)))
+	if err := b.storage.MarkMessageFailed(ctx, m.ID, err.Error(), next, dead); err != nil {
+		log.Printf("Ошибка обновления очереди сообщений: %v", err)
+	}
+}
+
+// runOutbox периодически досылает сообщения из очереди, а после
+// deliverLater — сразу
+func (b *CarWashBot) runOutbox() {
+	ticker := time.NewTicker(outboxInterval)
+	defer ticker.Stop()
+
+	var lastCleanup time.Time
+	for {
+		var now time.Time
+		select {
+		case now = <-ticker.C:
+		case <-b.outboxWake:
+			now = time.Now()
+		}
+		b.sendDueMessages(now)
+
+		if now.Sub(lastCleanup) > time.Hour {
+			lastCleanup = now
+			if err := b.storage.DeleteSentMessages(context.Background(), now.Add(-outboxKeepSent)); err != nil {
+				log.Printf("Ошибка очистки очереди сообщений: %v", err)
+			}
+		}
+	}
+}
+
+// sendDueMessages отправляет все сообщения, которые пора отправить,
+// пачками по outboxBatch
+func (b *CarWashBot) sendDueMessages(now time.Time) {
+	for {
+		messages, err := b.storage.GetDueMessages(context.Background(), now, outboxBatch)
+		if err != nil {
+			log.Printf("Ошибка получения очереди сообщений: %v", err)
+			return
+		}
+		for _, m := range messages {
+			b.attemptDelivery(m)
+		}
+		if len(messages) < outboxBatch {
+			return
+		}
+	}
+}
+
+// outboxBackoff возвращает паузу перед следующей попыткой: 15 с, 30 с, 1 мин…
+func outboxBackoff(attempts int) time.Duration {
+	delay := outboxRetryBase
+	for i := 1; i < attempts && delay < outboxRetryMax; i++ {
+		delay *= 2
+	}
+	return min(delay, outboxRetryMax)
+}
+
+// isPermanentSendError сообщает, что повтор не поможет: чат не найден,
+// бот заблокирован пользователем и т.п.
+func isPermanentSendError(err error) bool {
+	var apiErr *tgbotapi.Error
+	return errors.As(err, &apiErr) && (apiErr.Code == 400 || apiErr.Code == 403)
+}
+
+func toOutboxMessage(msg tgbotapi.MessageConfig) (*models.OutboxMessage, error) {
+	m := &models.OutboxMessage{
+		ChatID:    msg.ChatID,
+		Text:      msg.Text,
+		ParseMode: msg.ParseMode,
+	}
+	if msg.ReplyMarkup != nil {
+		markup, err := json.Marshal(msg.ReplyMarkup)
+		if err != nil {
+			return nil, err
+		}
+		m.ReplyMarkup = string(markup)
+	}
+	return m, nil
+}
+
+func fromOutboxMessage(m *models.OutboxMessage) tgbotapi.MessageConfig {
+	msg := tgbotapi.NewMessage(m.ChatID, m.Text)
+	msg.ParseMode = m.ParseMode
+	if m.ReplyMarkup != "" {
+		msg.ReplyMarkup = json.RawMessage(m.ReplyMarkup)
+	}
+	return msg
+}
+
+func (b *CarWashBot) handleOutboxCommand(chatID, userID int64, text string) {
+	if !b.isAdmin(userID) {
+		b.sendMessage(chatID, "⛔ Команда доступна только администратору.")
+		return
+	}
+
+	fields := strings.Fields(text)
+	if fields[0] == "/outbox_retry" {
+		if len(fields) < 2 {
+			b.sendMessage(chatID, "❌ Формат: /outbox_retry ID")
+			return
+		}
+		id, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			b.sendMessage(chatID, "❌ Неверный ID сообщения.")
+			return
+		}
+		err = b.storage.RetryMessage(context.Background(), id)
+		if errors.Is(err, sql.ErrNoRows) {
+			b.sendMessage(chatID, "❌ Сообщение не найдено или уже доставлено.")
+			return
+		}
+		if err != nil {
+			log.Printf("Ошибка повтора сообщения: %v", err)
+			b.sendMessage(chatID, "⚠️ Не удалось поставить сообщение в очередь.")
+			return
+		}
+		b.sendMessage(chatID, "🔁 Сообщение будет отправлено повторно")
+		return
+	}
+
+	messages, err := b.storage.GetUndeliveredMessages(context.Background(), outboxListLength)
+	if err != nil {
+		log.Printf("Ошибка получения очереди сообщений: %v", err)
+		b.sendMessage(chatID, "⚠️ Ошибка загрузки очереди.")
+		return
+	}
+	if len(messages) == 0 {
+		b.sendMessage(chatID, "📭 Все уведомления доставлены.")
+		return
+	}
+
+	var sb strings.Builder
+	sb.WriteString("📮 Недоставленные уведомления:\n\n")
+	for _, m := range messages {
+		status := fmt.Sprintf("⏳ повтор в %s", m.NextAttemptAt.Format("15:04"))
+		if m.Status == models.OutboxDead {
+			status = "☠️ попытки исчерпаны"
+		}
+		preview := []rune(m.Text)
+		if len(preview) > 60 {
+			preview = append(preview[:60], '…')
+		}
+		sb.WriteString(fmt.Sprintf("#%d → %d, %s, попыток %d: %s\n%s\nОшибка: %s\n/outbox_retry %d\n\n",
+			m.ID, m.ChatID, m.CreatedAt.Format("02.01 15:04"), m.Attempts, status,
+			string(preview), m.LastError, m.ID))
+	}
+	b.sendMessage(chatID, sb.String())
+}
@@ -0,0 +1,114 @@
+package bot
+
+import (
+	"carwash-bot/internal/models"
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestOutboxSendsClaimedMessageOnce(t *testing.T) {
+	b, telegram, store := newTestBot(t, testConfig())
+	ctx := context.Background()
+	m := &models.OutboxMessage{ChatID: 42, Text: "🆕 Новая запись"}
+	if err := store.EnqueueMessage(ctx, m); err != nil {
+		t.Fatalf("EnqueueMessage: %v", err)
+	}
+
+	// deliver и фоновая отправка берут одно сообщение одновременно
+	var wg sync.WaitGroup
+	for range 2 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			b.attemptDelivery(m)
+		}()
+	}
+	wg.Wait()
+	b.attemptDelivery(m)
+
+	if texts := telegram.texts(42); len(texts) != 1 {
+		t.Fatalf("отправлено %d раз: %q; нужно один", len(texts), texts)
+	}
+	if got, _ := store.GetOutboxMessage(ctx, m.ID); got.Status != models.OutboxSent {
+		t.Fatalf("статус = %s; нужно sent", got.Status)
+	}
+}
+
+func TestDeliverIsNotPickedUpAgain(t *testing.T) {
+	b, telegram, store := newTestBot(t, testConfig())
+	ctx := context.Background()
+
+	b.deliver(tgbotapi.NewMessage(42, "✅ Запись подтверждена"))
+	if due, _ := store.GetDueMessages(ctx, time.Now().Add(time.Hour), 10); len(due) != 0 {
+		t.Fatalf("доставленное сообщение снова в очереди: %+v", due[0])
+	}
+	if texts := telegram.texts(42); len(texts) != 1 {
+		t.Fatalf("отправлено %q; нужно одно сообщение", texts)
+	}
+}
+
+func TestOutboxResendsAbandonedClaim(t *testing.T) {
+	b, telegram, store := newTestBot(t, testConfig())
+	ctx := context.Background()
+	m := &models.OutboxMessage{ChatID: 42, Text: "⏰ Напоминание"}
+	if err := store.EnqueueMessage(ctx, m); err != nil {
+		t.Fatalf("EnqueueMessage: %v", err)
+	}
+
+	// Бот захватил сообщение и упал, не отметив результат
+	now := time.Now()
+	if claimed, err := store.ClaimMessage(ctx, m.ID, now, now.Add(time.Minute)); !claimed || err != nil {
+		t.Fatalf("ClaimMessage = %v, %v", claimed, err)
+	}
+	if claimed, _ := store.ClaimMessage(ctx, m.ID, now, now.Add(time.Minute)); claimed {
+		t.Fatal("сообщение захвачено дважды")
+	}
+	if due, _ := store.GetDueMessages(ctx, now, 10); len(due) != 0 {
+		t.Fatal("захваченное сообщение в очереди до конца захвата")
+	}
+
+	due, err := store.GetDueMessages(ctx, now.Add(2*time.Minute), 10)
+	if err != nil || len(due) != 1 {
+		t.Fatalf("после конца захвата очередь = %v, %v; нужно сообщение", due, err)
+	}
+	if claimed, _ := store.ClaimMessage(ctx, m.ID, now.Add(2*time.Minute), now.Add(3*time.Minute)); !claimed {
+		t.Fatal("брошенное сообщение не захватывается снова")
+	}
+	if err := store.MarkMessageSent(ctx, m.ID); err != nil {
+		t.Fatalf("MarkMessageSent: %v", err)
+	}
+	b.attemptDelivery(due[0])
+	if texts := telegram.texts(42); len(texts) != 0 {
+		t.Fatalf("доставленное сообщение отправлено снова: %q", texts)
+	}
+}
+
+// Части длинного расписания не отправляются в обработке обновления:
+// они ждут в очереди и уходят фоновой отправкой по порядку
+func TestDeliverLaterQueuesInOrder(t *testing.T) {
+	b, telegram, _ := newTestBot(t, testConfig())
+
+	last := tgbotapi.NewMessage(1000, "3")
+	last.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("🗓 Неделя", "sched")))
+	b.deliverLater(tgbotapi.NewMessage(1000, "1"), tgbotapi.NewMessage(1000, "2"), last)
+
+	if texts := telegram.texts(1000); len(texts) != 0 {
+		t.Fatalf("deliverLater отправил сразу: %q", texts)
+	}
+	select {
+	case <-b.outboxWake:
+	default:
+		t.Fatal("deliverLater не разбудил отправку очереди")
+	}
+
+	b.sendDueMessages(time.Now())
+	if texts := telegram.texts(1000); strings.Join(texts, ",") != "1,2,3" {
+		t.Fatalf("отправлено %q; нужно 1, 2, 3 по порядку", texts)
+	}
+}
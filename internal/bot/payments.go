@@ -0,0 +1,309 @@
+package bot
+
+import (
+	"carwash-bot/internal/i18n"
+	"carwash-bot/internal/models"
+	"carwash-bot/internal/payments"
+	"carwash-bot/internal/services"
+	"carwash-bot/internal/storage"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// money форматирует сумму в валюте мойки
+func (b *CarWashBot) money(amount int) string {
+	return i18n.Money(amount, b.cfg.Payments.Currency)
+}
+
+// requiredDeposit возвращает сумму предоплаты для записи, 0 — без предоплаты
+func (b *CarWashBot) requiredDeposit(booking *models.Booking) int {
+	if b.payments == nil || booking.OrganizationID != 0 {
+		return 0
+	}
+
+	service, ok := b.cfg.GetService(booking.Service)
+	if !ok {
+		return 0
+	}
+	return min(service.Deposit, booking.Price)
+}
+
+// requestPayment выставляет счёт на предоплату записи
+func (b *CarWashBot) requestPayment(chatID int64, booking *models.Booking, amount int) {
+	l := b.tr(chatID)
+	payment := &models.Payment{
+		BookingID: booking.ID,
+		UserID:    booking.UserID,
+		Amount:    amount,
+		Currency:  b.cfg.Payments.Currency,
+		Provider:  b.payments.Name(),
+	}
+	if err := b.storage.CreatePayment(context.Background(), payment); err != nil {
+		log.Printf("Ошибка создания платежа: %v", err)
+		b.sendMessage(chatID, l.T("payment.invoice_failed"))
+		return
+	}
+
+	description := l.T("payment.description",
+		b.serviceName(booking.Service),
+		booking.Date,
+		booking.Time,
+		booking.CarModel,
+		booking.CarNumber,
+		booking.PaymentDeadline.Format("15:04"))
+
+	// Тестовый провайдер проводит оплату сам, без счёта Telegram
+	if _, offline := b.payments.(payments.OfflineCharger); offline {
+		msg := tgbotapi.NewMessage(chatID, l.T("payment.offline", i18n.Money(amount, payment.Currency), description))
+		msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData(l.T("payment.pay_test", i18n.Money(amount, payment.Currency)), "pay_"+payment.ID),
+			),
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData(l.T("button.cancel_booking"), "cancel_"+booking.ID),
+			),
+		)
+		b.sendMessageWithSave(chatID, msg)
+		return
+	}
+
+	invoice := tgbotapi.NewInvoice(chatID,
+		l.T("payment.title"),
+		description,
+		payment.ID,
+		b.payments.Token(),
+		"",
+		payment.Currency,
+		[]tgbotapi.LabeledPrice{{Label: b.serviceName(booking.Service), Amount: amount * 100}})
+	if _, err := b.botAPI.Send(invoice); err != nil {
+		log.Printf("Ошибка отправки счёта: %v", err)
+		b.sendMessage(chatID, l.T("payment.invoice_failed"))
+	}
+}
+
+// validatePayment проверяет, что счёт ещё можно оплатить.
+// Возвращает текст ошибки для клиента или пустую строку
+func (b *CarWashBot) validatePayment(l *i18n.Localizer, payment *models.Payment, currency string, totalAmount int) string {
+	if payment == nil {
+		return l.T("payment.not_found")
+	}
+	if payment.Status != models.PaymentPending {
+		return l.T("payment.processed")
+	}
+	if payment.Currency != currency || payment.Amount*100 != totalAmount {
+		return l.T("payment.amount_changed")
+	}
+
+	booking, err := b.storage.GetBooking(context.Background(), payment.BookingID)
+	if err != nil || booking == nil {
+		return l.T("payment.booking_not_found")
+	}
+	if booking.Status != models.StatusAwaitingPayment || time.Now().After(booking.PaymentDeadline) {
+		return l.T("payment.expired")
+	}
+	return ""
+}
+
+func (b *CarWashBot) handlePreCheckoutQuery(query *tgbotapi.PreCheckoutQuery) {
+	payment, err := b.storage.GetPayment(context.Background(), query.InvoicePayload)
+	if err != nil {
+		log.Printf("Ошибка получения платежа: %v", err)
+	}
+
+	answer := tgbotapi.PreCheckoutConfig{PreCheckoutQueryID: query.ID, OK: true}
+	if errText := b.validatePayment(b.tr(query.From.ID), payment, query.Currency, query.TotalAmount); errText != "" {
+		answer.OK = false
+		answer.ErrorMessage = errText
+	}
+
+	if _, err := b.botAPI.Request(answer); err != nil {
+		log.Printf("Ошибка ответа на pre_checkout_query: %v", err)
+	}
+}
+
+func (b *CarWashBot) handleSuccessfulPayment(msg *tgbotapi.Message) {
+	p := msg.SuccessfulPayment
+	b.completePayment(msg.Chat.ID, p.InvoicePayload, p.TelegramPaymentChargeID, p.ProviderPaymentChargeID)
+}
+
+// handleOfflinePayment проводит оплату через провайдера без счёта Telegram
+func (b *CarWashBot) handleOfflinePayment(chatID int64, paymentID string) {
+	charger, ok := b.payments.(payments.OfflineCharger)
+	if !ok {
+		return
+	}
+
+	l := b.tr(chatID)
+	payment, err := b.storage.GetPayment(context.Background(), paymentID)
+	if err != nil || payment == nil {
+		log.Printf("Ошибка получения платежа: %v", err)
+		b.sendMessage(chatID, "❌ "+l.T("payment.not_found"))
+		return
+	}
+	if errText := b.validatePayment(l, payment, payment.Currency, payment.Amount*100); errText != "" {
+		b.sendMessage(chatID, "❌ "+errText)
+		return
+	}
+
+	chargeID, err := charger.Charge(context.Background(), payment)
+	if err != nil {
+		log.Printf("Ошибка оплаты: %v", err)
+		b.sendMessage(chatID, l.T("payment.failed"))
+		return
+	}
+
+	b.completePayment(chatID, payment.ID, "", chargeID)
+}
+
+// completePayment активирует запись после успешной оплаты
+func (b *CarWashBot) completePayment(chatID int64, paymentID, telegramChargeID, providerChargeID string) {
+	l := b.tr(chatID)
+	booking, err := b.storage.ConfirmPayment(context.Background(), paymentID, telegramChargeID, providerChargeID)
+	if errors.Is(err, storage.ErrPaymentExpired) {
+		// Деньги пришли, но слот уже освобождён — возвращаем оплату
+		if err := b.storage.RecordPaymentCharge(context.Background(), paymentID, telegramChargeID, providerChargeID); err != nil {
+			log.Printf("Ошибка сохранения оплаты: %v", err)
+		}
+		text := l.T("payment.late")
+		payment, err := b.storage.GetPayment(context.Background(), paymentID)
+		if err != nil || payment == nil {
+			log.Printf("Ошибка получения платежа: %v", err)
+		} else {
+			text += "\n" + b.refundPayment(l, payment, payment.Amount)
+		}
+		b.sendMessage(chatID, text)
+		return
+	}
+	if err != nil {
+		log.Printf("Ошибка подтверждения оплаты: %v", err)
+		b.sendMessage(chatID, l.T("payment.unconfirmed"))
+		return
+	}
+
+	b.sendMessage(chatID, l.T("payment.received"))
+	b.sendBookingConfirmation(chatID, booking)
+	b.notifyAdmin(booking)
+}
+
+// refundCancelled возвращает предоплату отменённой записи.
+// Возвращает текст для клиента или пустую строку, если возвращать нечего
+func (b *CarWashBot) refundCancelled(l *i18n.Localizer, booking *models.Booking) string {
+	refund, err := b.refunds.RefundCancelled(context.Background(), booking)
+	if err != nil {
+		log.Printf("Ошибка возврата предоплаты: %v", err)
+	}
+	return b.refundText(l, booking.LocationID, refund)
+}
+
+func (b *CarWashBot) refundPayment(l *i18n.Localizer, payment *models.Payment, amount int) string {
+	refund, err := b.refunds.Refund(context.Background(), payment, amount)
+	if err != nil {
+		log.Printf("Ошибка сохранения возврата: %v", err)
+	}
+
+	var locationID int64
+	booking, err := b.storage.GetBooking(context.Background(), payment.BookingID)
+	if err != nil || booking == nil {
+		log.Printf("Ошибка получения записи: %v", err)
+	} else {
+		locationID = booking.LocationID
+	}
+	return b.refundText(l, locationID, refund)
+}
+
+// refundText сообщает администраторам мойки locationID о ручном возврате
+// и возвращает текст для клиента
+func (b *CarWashBot) refundText(l *i18n.Localizer, locationID int64, refund *services.Refund) string {
+	if refund == nil {
+		return ""
+	}
+	amount := i18n.Money(refund.Amount, refund.Payment.Currency)
+	if refund.Manual {
+		for _, adminID := range b.bookingAdmins(locationID) {
+			b.sendMessage(adminID, b.tr(adminID).T("admin.manual_refund",
+				amount, refund.Payment.ID, refund.Payment.BookingID))
+		}
+		return l.T("refund.pending", amount)
+	}
+	return l.T("refund.done", amount)
+}
+
+// runPaymentExpiry периодически освобождает слоты неоплаченных записей
+func (b *CarWashBot) runPaymentExpiry() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		b.releaseExpiredBookings()
+	}
+}
+
+func (b *CarWashBot) releaseExpiredBookings() {
+	released, err := b.storage.ReleaseExpiredBookings(context.Background(), time.Now())
+	if err != nil {
+		log.Printf("Ошибка освобождения неоплаченных записей: %v", err)
+	}
+
+	for _, booking := range released {
+		customer, err := b.storage.GetUserByID(context.Background(), booking.UserID)
+		if err != nil || customer == nil {
+			log.Printf("Ошибка получения клиента: %v", err)
+			continue
+		}
+		b.sendMessage(customer.TelegramID, b.tr(customer.TelegramID).T("payment.released", booking.Date, booking.Time))
+		b.recheckFreeVisits(customer.ID)
+	}
+}
+
+func (b *CarWashBot) handleRefundsCommand(chatID, userID int64, text string) {
+	if !b.isAdmin(userID) {
+		b.sendMessage(chatID, "⛔ Команда доступна только администратору.")
+		return
+	}
+
+	fields := strings.Fields(text)
+	if fields[0] == "/refund_done" {
+		if len(fields) < 2 {
+			b.sendMessage(chatID, "❌ Формат: /refund_done ID_платежа")
+			return
+		}
+		payment, err := b.storage.GetPayment(context.Background(), fields[1])
+		if err != nil || payment == nil || payment.Status != models.PaymentRefundPending {
+			b.sendMessage(chatID, "❌ Платёж не найден или не ожидает возврата.")
+			return
+		}
+		if err := b.storage.RecordRefund(context.Background(), payment.ID, 0, models.PaymentRefunded); err != nil {
+			log.Printf("Ошибка сохранения возврата: %v", err)
+			b.sendMessage(chatID, "⚠️ Не удалось отметить возврат.")
+			return
+		}
+		b.sendMessage(chatID, "✅ Возврат отмечен выполненным")
+		return
+	}
+
+	pending, err := b.storage.GetPaymentsByStatus(context.Background(), models.PaymentRefundPending)
+	if err != nil {
+		log.Printf("Ошибка получения возвратов: %v", err)
+		b.sendMessage(chatID, "⚠️ Ошибка загрузки возвратов.")
+		return
+	}
+	if len(pending) == 0 {
+		b.sendMessage(chatID, "Возвратов, ожидающих обработки, нет.")
+		return
+	}
+
+	var sb strings.Builder
+	sb.WriteString("💸 Ожидают ручного возврата:\n\n")
+	for _, p := range pending {
+		sb.WriteString(fmt.Sprintf("%s — %d ₽ из %d ₽ (%s)\nTelegram: %s\nПровайдер: %s\n/refund_done %s\n\n",
+			p.CreatedAt.Format("02.01.2006"), p.RefundedAmount, p.Amount, p.Provider,
+			p.TelegramChargeID, p.ProviderChargeID, p.ID))
+	}
+	b.sendMessage(chatID, sb.String())
+}
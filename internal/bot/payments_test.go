@@ -0,0 +1,52 @@
+package bot
+
+import (
+	"carwash-bot/internal/models"
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Ручной возврат нужен, если провайдер не вернул деньги сам. О нём узнают
+// администраторы мойки записи, сумма — в валюте платежа
+func TestManualRefundAlertsLocationAdmins(t *testing.T) {
+	b, telegram, store := newTestBot(t, testConfig())
+	ctx := context.Background()
+
+	branch := &models.Location{Name: "Филиал", StartTime: 9, EndTime: 18, Bays: 1}
+	if err := store.CreateLocation(ctx, branch); err != nil {
+		t.Fatalf("CreateLocation: %v", err)
+	}
+	if err := store.AddLocationAdmin(ctx, branch.ID, 2000); err != nil {
+		t.Fatalf("AddLocationAdmin: %v", err)
+	}
+	if err := store.CreateOrUpdateUser(ctx, &models.User{TelegramID: customerID, FirstName: "Тест"}); err != nil {
+		t.Fatalf("CreateOrUpdateUser: %v", err)
+	}
+	user, _ := store.GetUserByTelegramID(ctx, customerID)
+
+	booking := &models.Booking{UserID: user.ID, LocationID: branch.ID, Service: "express", Price: 400,
+		Date: time.Now().AddDate(0, 0, 2).Format("02.01.2006"), Time: "10:00", CarModel: "Kia", CarNumber: "A123BC"}
+	if err := store.CreateBooking(ctx, booking); err != nil {
+		t.Fatalf("CreateBooking: %v", err)
+	}
+	// Предоплата отключена, поэтому вернуть деньги может только администратор
+	payment := &models.Payment{BookingID: booking.ID, UserID: user.ID, Amount: 300, Currency: "KZT",
+		Provider: "telegram", Status: models.PaymentPaid}
+	if err := store.CreatePayment(ctx, payment); err != nil {
+		t.Fatalf("CreatePayment: %v", err)
+	}
+
+	b.handleBookingCancellation(customerID, customerID, booking.ID, false)
+
+	for _, adminID := range []int64{1000, 2000} {
+		texts := telegram.texts(adminID)
+		if len(texts) != 1 || !strings.Contains(texts[0], "300 ₸") || !strings.Contains(texts[0], payment.ID) {
+			t.Errorf("администратору %d: %q; нужен ручной возврат 300 ₸", adminID, texts)
+		}
+	}
+	if texts := telegram.texts(customerID); len(texts) != 1 || !strings.Contains(texts[0], "300 ₸") {
+		t.Errorf("клиенту: %q; нужна отмена с возвратом 300 ₸", texts)
+	}
+}
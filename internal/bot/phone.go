@@ -0,0 +1,53 @@
+package bot
+
+import (
+	"context"
+	"log"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// askPhone просит клиента поделиться телефоном, чтобы администратор
+// мог связаться с ним. Номер можно отправить только кнопкой обычной клавиатуры
+func (b *CarWashBot) askPhone(chatID, userID int64) {
+	l := b.tr(userID)
+	msg := tgbotapi.NewMessage(chatID, l.T("phone.ask"))
+	msg.ReplyMarkup = tgbotapi.NewReplyKeyboard(
+		tgbotapi.NewKeyboardButtonRow(
+			tgbotapi.NewKeyboardButtonContact(l.T("phone.button")),
+		),
+		tgbotapi.NewKeyboardButtonRow(
+			tgbotapi.NewKeyboardButton(l.T("menu.main")),
+		),
+	)
+	b.sendMessageWithSave(chatID, msg)
+}
+
+// askPhoneIfMissing просит телефон после записи, если его ещё нет
+func (b *CarWashBot) askPhoneIfMissing(chatID, userID int64) {
+	user, err := b.storage.GetUserByTelegramID(context.Background(), userID)
+	if err != nil {
+		log.Printf("Ошибка получения пользователя: %v", err)
+		return
+	}
+	if user != nil && user.Phone == "" {
+		b.askPhone(chatID, userID)
+	}
+}
+
+func (b *CarWashBot) handleContact(chatID, userID int64, contact *tgbotapi.Contact) {
+	l := b.tr(userID)
+	// Чужой контакт не сохраняем: нужен номер самого клиента
+	if contact.UserID != userID {
+		b.sendMessage(chatID, l.T("phone.foreign"))
+		return
+	}
+
+	if err := b.storage.SetUserPhone(context.Background(), userID, contact.PhoneNumber); err != nil {
+		log.Printf("Ошибка сохранения телефона: %v", err)
+		b.sendMessage(chatID, l.T("error.system"))
+		return
+	}
+	b.sendMessage(chatID, l.T("phone.saved"))
+	b.sendWelcomeMessage(chatID)
+}
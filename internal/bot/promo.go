@@ -0,0 +1,319 @@
+package bot
+
+import (
+	"carwash-bot/internal/i18n"
+	"carwash-bot/internal/models"
+	"carwash-bot/internal/storage"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const promoHelp = `🎟 Команды для промокодов:
+/promo_list — список промокодов
+/promo_off КОД — отключить промокод
+/promo_new КОД ПРОЦЕНТ [параметры] — создать промокод
+
+Параметры (все необязательные):
+from=01.03.2025 to=31.05.2025 — срок действия
+limit=100 — всего использований
+per_user=1 — использований на клиента
+services=body,complex — коды услуг
+days=1-5 — дни недели (1 — Пн, 7 — Вс)
+hours=8-12 — часы начала мойки
+
+Пример: /promo_new ВЕСНА20 20 days=1-5 hours=8-12 per_user=1`
+
+func (b *CarWashBot) askPromoCode(chatID, userID int64) {
+	l := b.tr(userID)
+	b.showWizardStep(chatID, userID, l.T("promo.ask"),
+		tgbotapi.NewInlineKeyboardMarkup(
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData(l.T("promo.skip"), "promo_skip"),
+			),
+			backButton(l, "car"),
+		))
+}
+
+func (b *CarWashBot) handlePromoSkip(chatID, userID int64) {
+	state, exists := b.userStates[userID]
+	if !exists || !state.AwaitingPromo {
+		return
+	}
+
+	b.createPersonalBooking(chatID, userID, nil)
+}
+
+func (b *CarWashBot) handlePromoInput(chatID, userID int64, text string) {
+	state := b.userStates[userID]
+	code := strings.ToUpper(strings.TrimSpace(text))
+
+	user, err := b.storage.GetUserByTelegramID(context.Background(), userID)
+	if err != nil || user == nil {
+		log.Printf("Ошибка получения пользователя: %v", err)
+		b.sendMessage(chatID, b.tr(userID).T("error.system"))
+		return
+	}
+
+	at, err := time.ParseInLocation("02.01.2006 15:04", state.SelectedDate+" "+state.SelectedTime, time.Local)
+	if err != nil {
+		log.Printf("Ошибка разбора времени записи: %v", err)
+		b.sendMessage(chatID, b.tr(userID).T("error.system"))
+		return
+	}
+
+	promo, err := b.storage.ValidatePromoCode(context.Background(), code, user.ID, state.SelectedService, at)
+	if isPromoError(err) {
+		b.setNotice(userID, errorText(b.tr(userID), err))
+		b.askPromoCode(chatID, userID)
+		return
+	}
+	if err != nil {
+		log.Printf("Ошибка проверки промокода: %v", err)
+		b.setNotice(userID, b.tr(userID).T("promo.check_failed"))
+		b.askPromoCode(chatID, userID)
+		return
+	}
+
+	b.createPersonalBooking(chatID, userID, promo)
+}
+
+// applyPromo уменьшает стоимость записи на процент промокода
+func applyPromo(booking *models.Booking, promo *models.PromoCode) {
+	if promo == nil {
+		return
+	}
+
+	discount := booking.Price * promo.Percent / 100
+	booking.Price -= discount
+	booking.Discount += discount
+	booking.PromoCode = promo.Code
+}
+
+func isPromoError(err error) bool {
+	for _, target := range []error{
+		storage.ErrPromoNotFound,
+		storage.ErrPromoExpired,
+		storage.ErrPromoNotStarted,
+		storage.ErrPromoExhausted,
+		storage.ErrPromoUserLimit,
+		storage.ErrPromoWrongService,
+		storage.ErrPromoWrongSchedule,
+	} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+func (b *CarWashBot) handlePromoCommand(chatID, userID int64, text string) {
+	if !b.isAdmin(userID) {
+		b.sendMessage(chatID, "⛔ Команда доступна только администратору.")
+		return
+	}
+
+	fields := strings.Fields(text)
+	switch fields[0] {
+	case "/promo_new":
+		b.handlePromoCreate(chatID, fields[1:])
+	case "/promo_list":
+		b.showPromoCodes(chatID)
+	case "/promo_off":
+		if len(fields) < 2 {
+			b.sendMessage(chatID, "❌ Формат: /promo_off КОД")
+			return
+		}
+		code := strings.ToUpper(fields[1])
+		if err := b.storage.DeactivatePromoCode(context.Background(), code); err != nil {
+			b.sendMessage(chatID, "❌ "+err.Error())
+			return
+		}
+		b.sendMessage(chatID, "✅ Промокод "+code+" отключён")
+	default:
+		b.sendMessage(chatID, promoHelp)
+	}
+}
+
+func (b *CarWashBot) handlePromoCreate(chatID int64, args []string) {
+	if len(args) < 2 {
+		b.sendMessage(chatID, promoHelp)
+		return
+	}
+
+	promo, err := parsePromoCode(args)
+	if err != nil {
+		b.sendMessage(chatID, "❌ "+err.Error())
+		return
+	}
+	for _, code := range promo.Services {
+		if _, ok := b.cfg.GetService(code); !ok {
+			b.sendMessage(chatID, "❌ Неизвестная услуга: "+code)
+			return
+		}
+	}
+
+	if err := b.storage.CreatePromoCode(context.Background(), promo); err != nil {
+		log.Printf("Ошибка создания промокода: %v", err)
+		b.sendMessage(chatID, "❌ Не удалось создать промокод. Возможно, такой код уже есть.")
+		return
+	}
+
+	b.sendMessage(chatID, "✅ Промокод создан:\n"+b.formatPromoCode(promo))
+}
+
+// parsePromoCode разбирает аргументы команды /promo_new
+func parsePromoCode(args []string) (*models.PromoCode, error) {
+	percent, err := strconv.Atoi(strings.TrimSuffix(args[1], "%"))
+	if err != nil || percent <= 0 || percent > 100 {
+		return nil, errors.New("процент скидки должен быть числом от 1 до 100")
+	}
+
+	today := time.Now()
+	promo := &models.PromoCode{
+		Code:      strings.ToUpper(args[0]),
+		Percent:   percent,
+		ValidFrom: time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, time.Local),
+		ValidTo:   time.Date(today.Year()+1, today.Month(), today.Day(), 0, 0, 0, 0, time.Local),
+	}
+
+	for _, arg := range args[2:] {
+		key, value, ok := strings.Cut(arg, "=")
+		if !ok {
+			return nil, fmt.Errorf("неверный параметр %q, ожидается ключ=значение", arg)
+		}
+
+		switch key {
+		case "from", "to":
+			date, err := time.ParseInLocation("02.01.2006", value, time.Local)
+			if err != nil {
+				return nil, fmt.Errorf("дату %q укажите в формате ДД.ММ.ГГГГ", value)
+			}
+			if key == "from" {
+				promo.ValidFrom = date
+			} else {
+				promo.ValidTo = date
+			}
+		case "limit", "per_user":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("неверное значение %q", arg)
+			}
+			if key == "limit" {
+				promo.MaxUses = n
+			} else {
+				promo.PerUserLimit = n
+			}
+		case "services":
+			promo.Services = strings.Split(value, ",")
+		case "days":
+			from, to, err := parseRange(value, 1, 7)
+			if err != nil {
+				return nil, fmt.Errorf("дни недели: %v", err)
+			}
+			for day := from; day <= to; day++ {
+				promo.Weekdays = append(promo.Weekdays, day)
+			}
+		case "hours":
+			from, to, err := parseRange(value, 0, 24)
+			if err != nil {
+				return nil, fmt.Errorf("часы: %v", err)
+			}
+			promo.HourFrom, promo.HourTo = from, to
+		default:
+			return nil, fmt.Errorf("неизвестный параметр %q", key)
+		}
+	}
+
+	if promo.ValidTo.Before(promo.ValidFrom) {
+		return nil, errors.New("дата окончания раньше даты начала")
+	}
+	return promo, nil
+}
+
+// parseRange разбирает диапазон вида "1-5" или одно число
+func parseRange(value string, lo, hi int) (int, int, error) {
+	fromStr, toStr, isRange := strings.Cut(value, "-")
+	if !isRange {
+		toStr = fromStr
+	}
+
+	from, err1 := strconv.Atoi(fromStr)
+	to, err2 := strconv.Atoi(toStr)
+	if err1 != nil || err2 != nil || from < lo || to > hi || from > to {
+		return 0, 0, fmt.Errorf("ожидается диапазон от %d до %d, например %d-%d", lo, hi, lo, hi)
+	}
+	return from, to, nil
+}
+
+func (b *CarWashBot) showPromoCodes(chatID int64) {
+	promos, err := b.storage.GetAllPromoCodes(context.Background())
+	if err != nil {
+		log.Printf("Ошибка получения промокодов: %v", err)
+		b.sendMessage(chatID, "⚠️ Ошибка загрузки промокодов.")
+		return
+	}
+
+	if len(promos) == 0 {
+		b.sendMessage(chatID, "Промокодов пока нет.\n\n"+promoHelp)
+		return
+	}
+
+	var sb strings.Builder
+	sb.WriteString("🎟 Промокоды:\n\n")
+	for _, promo := range promos {
+		sb.WriteString(b.formatPromoCode(promo))
+		sb.WriteString("\n\n")
+	}
+	b.sendMessage(chatID, sb.String())
+}
+
+func (b *CarWashBot) formatPromoCode(promo *models.PromoCode) string {
+	ru := i18n.New(i18n.Default)
+	var sb strings.Builder
+	status := "✅"
+	if !promo.Active {
+		status = "⛔"
+	}
+
+	sb.WriteString(fmt.Sprintf("%s %s — %d%%\n", status, promo.Code, promo.Percent))
+	sb.WriteString(fmt.Sprintf("📅 %s – %s\n", promo.ValidFrom.Format("02.01.2006"), promo.ValidTo.Format("02.01.2006")))
+
+	limit := "без ограничений"
+	if promo.MaxUses > 0 {
+		limit = strconv.Itoa(promo.MaxUses)
+	}
+	sb.WriteString(fmt.Sprintf("Использований: %d из %s", promo.Uses, limit))
+	if promo.PerUserLimit > 0 {
+		sb.WriteString(fmt.Sprintf(", на клиента: %d", promo.PerUserLimit))
+	}
+
+	if len(promo.Services) > 0 {
+		names := make([]string, len(promo.Services))
+		for i, code := range promo.Services {
+			names[i] = b.serviceName(code)
+		}
+		sb.WriteString("\nУслуги: " + strings.Join(names, ", "))
+	}
+	if len(promo.Weekdays) > 0 {
+		days := make([]string, len(promo.Weekdays))
+		for i, day := range promo.Weekdays {
+			days[i] = ru.Weekday(time.Weekday(day % 7))
+		}
+		sb.WriteString("\nДни: " + strings.Join(days, ", "))
+	}
+	if promo.HourFrom > 0 || promo.HourTo > 0 {
+		to := promo.HourTo
+		if to == 0 {
+			to = 24
+		}
+		sb.WriteString(fmt.Sprintf("\nЧасы: %02d:00–%02d:00", promo.HourFrom, to))
+	}
+	return sb.String()
+}
@@ -0,0 +1,610 @@
+package bot
+
+import (
+	"carwash-bot/config"
+	"carwash-bot/internal/i18n"
+	"carwash-bot/internal/models"
+	"cmp"
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Сколько слотов выводить в одной строке расписания
+const slotsPerLine = 4
+
+// Статусы, по которым администратор может отфильтровать расписание.
+// Пустой статус — все неотменённые записи
+var scheduleStatuses = []string{
+	"",
+	models.StatusActive,
+	models.StatusAwaitingPayment,
+	models.StatusPendingApproval,
+	models.StatusCompleted,
+	models.StatusNoShow,
+	models.StatusCancelled,
+}
+
+// scheduleView — что показывается в расписании. Всё состояние хранится
+// в callback-данных кнопок, поэтому листать можно любое старое сообщение
+type scheduleView struct {
+	Week   bool
+	From   time.Time
+	Filter models.ScheduleFilter
+	Past   bool // Показывать прошедшие записи
+}
+
+func (v scheduleView) span() int {
+	if v.Week {
+		return 7
+	}
+	return 1
+}
+
+// location возвращает мойку, выбранную в расписании, или -1 для всех моек
+func (v scheduleView) location() int64 {
+	if len(v.Filter.Locations) != 1 {
+		return -1
+	}
+	return v.Filter.Locations[0]
+}
+
+// data кодирует вид в callback: sched_w:20102026:0:-:-:0:-
+func (v scheduleView) data() string {
+	kind, past := "d", "0"
+	if v.Week {
+		kind = "w"
+	}
+	if v.Past {
+		past = "1"
+	}
+	service, status := v.Filter.Service, v.Filter.Status
+	if service == "" {
+		service = "-"
+	}
+	if status == "" {
+		status = "-"
+	}
+	location := "-"
+	if id := v.location(); id >= 0 {
+		location = strconv.FormatInt(id, 10)
+	}
+	return fmt.Sprintf("sched_%s:%s:%d:%s:%s:%s:%s", kind, v.From.Format("02012006"), v.Filter.Bay, service, status, past, location)
+}
+
+func parseScheduleView(data string) (scheduleView, error) {
+	// В старых сообщениях мойки в callback нет
+	parts := strings.Split(data, ":")
+	if (len(parts) != 6 && len(parts) != 7) || (parts[0] != "d" && parts[0] != "w") {
+		return scheduleView{}, fmt.Errorf("неверный вид расписания: %q", data)
+	}
+
+	from, err := time.ParseInLocation("02012006", parts[1], time.Local)
+	if err != nil {
+		return scheduleView{}, err
+	}
+	bay, err := strconv.Atoi(parts[2])
+	if err != nil {
+		return scheduleView{}, err
+	}
+
+	v := scheduleView{Week: parts[0] == "w", From: from, Past: parts[5] == "1"}
+	v.Filter.Bay = bay
+	if parts[3] != "-" {
+		v.Filter.Service = parts[3]
+	}
+	if parts[4] != "-" {
+		v.Filter.Status = parts[4]
+	}
+	if len(parts) == 7 && parts[6] != "-" {
+		location, err := strconv.ParseInt(parts[6], 10, 64)
+		if err != nil {
+			return scheduleView{}, err
+		}
+		v.Filter.Locations = []int64{location}
+	}
+	return v, nil
+}
+
+// showSchedule показывает расписание на неделю начиная с сегодня.
+// Администратор видит записи своих моек с номерами машин и клиентами,
+// остальные — только занятость одной мойки
+func (b *CarWashBot) showSchedule(chatID, userID int64) {
+	today, _ := b.bookings.Window()
+	b.renderSchedule(chatID, userID, 0, scheduleView{Week: true, From: today})
+}
+
+func (b *CarWashBot) handleScheduleCallback(chatID, userID int64, messageID int, data string) {
+	v, err := parseScheduleView(data)
+	if err != nil {
+		log.Printf("Ошибка разбора расписания: %v", err)
+		b.showSchedule(chatID, userID)
+		return
+	}
+	b.renderSchedule(chatID, userID, messageID, v)
+}
+
+// renderSchedule выводит расписание. Если messageID не 0 и текст
+// помещается в одно сообщение, оно редактируется на месте
+func (b *CarWashBot) renderSchedule(chatID, userID int64, messageID int, v scheduleView) {
+	l := b.tr(userID)
+	scope, admin := b.staffLocations(userID)
+	v = b.clampScheduleView(v, admin, scope)
+
+	locations, err := b.locationIndex()
+	if err != nil {
+		log.Printf("Ошибка получения моек: %v", err)
+		b.sendMessage(chatID, l.T("error.schedule"))
+		return
+	}
+
+	var text string
+	if admin {
+		text, err = b.adminScheduleText(l, v, locations)
+	} else {
+		text, err = b.occupancyText(l, v, locations)
+	}
+	if err != nil {
+		log.Printf("Ошибка получения расписания: %v", err)
+		b.sendMessage(chatID, l.T("error.schedule"))
+		return
+	}
+
+	markup := b.scheduleKeyboard(l, v, admin, scheduleLocations(locations, scope))
+	chunks := splitMessage(text, maxMessageLength)
+	if messageID != 0 && len(chunks) == 1 {
+		edit := tgbotapi.NewEditMessageTextAndMarkup(chatID, messageID, chunks[0], markup)
+		if _, err := b.botAPI.Request(edit); err != nil {
+			log.Printf("Ошибка обновления расписания: %v", err)
+		}
+		return
+	}
+
+	if len(chunks) == 1 {
+		msg := tgbotapi.NewMessage(chatID, chunks[0])
+		msg.ReplyMarkup = markup
+		b.sendMessageWithSave(chatID, msg)
+		return
+	}
+
+	// Длинное расписание отправляется заново несколькими сообщениями через
+	// очередь, кнопки — под последним
+	if messageID != 0 {
+		if _, err := b.botAPI.Request(tgbotapi.NewDeleteMessage(chatID, messageID)); err != nil {
+			log.Printf("Ошибка удаления расписания: %v", err)
+		}
+	}
+	msgs := make([]tgbotapi.MessageConfig, len(chunks))
+	for i, chunk := range chunks {
+		msgs[i] = tgbotapi.NewMessage(chatID, chunk)
+	}
+	msgs[len(msgs)-1].ReplyMarkup = markup
+	b.deliverLater(msgs...)
+}
+
+// clampScheduleView не даёт клиентам выйти за горизонт записи и
+// использовать фильтры, администратору мойки — увидеть чужие мойки,
+// а всем администраторам — прошедшие дни, пока они скрыты. scope —
+// мойки администратора, nil — все
+func (b *CarWashBot) clampScheduleView(v scheduleView, admin bool, scope []int64) scheduleView {
+	first, last := b.bookings.Window()
+	if !admin {
+		// Клиент смотрит занятость одной мойки, по умолчанию основной
+		location := max(v.location(), 0)
+		v.Filter, v.Past = models.ScheduleFilter{Locations: []int64{location}}, false
+		if v.From.After(last) {
+			v.From = last
+		}
+	}
+	if admin && scope != nil && !slices.Contains(scope, v.location()) {
+		v.Filter.Locations = scope
+	}
+	if !v.Past && v.From.Before(first) {
+		v.From = first
+	}
+	return v
+}
+
+// scheduleDays возвращает дни вида. Клиенту не показываются дни за
+// горизонтом записи
+func (b *CarWashBot) scheduleDays(v scheduleView, admin bool) []time.Time {
+	_, last := b.bookings.Window()
+	var days []time.Time
+	for i := range v.span() {
+		day := v.From.AddDate(0, 0, i)
+		if !admin && day.After(last) {
+			break
+		}
+		days = append(days, day)
+	}
+	return days
+}
+
+// scheduleLocations возвращает мойки, между которыми можно переключать
+// расписание: все мойки сети или мойки администратора из scope
+func scheduleLocations(locations map[int64]*models.Location, scope []int64) []*models.Location {
+	var options []*models.Location
+	for id, location := range locations {
+		if scope == nil || slices.Contains(scope, id) {
+			options = append(options, location)
+		}
+	}
+	slices.SortFunc(options, func(x, y *models.Location) int { return cmp.Compare(x.ID, y.ID) })
+	return options
+}
+
+func (b *CarWashBot) scheduleKeyboard(l *i18n.Localizer, v scheduleView, admin bool, locations []*models.Location) tgbotapi.InlineKeyboardMarkup {
+	first, last := b.bookings.Window()
+
+	var nav []tgbotapi.InlineKeyboardButton
+	if v.Past || v.From.After(first) {
+		prev := v
+		prev.From = v.From.AddDate(0, 0, -v.span())
+		nav = append(nav, tgbotapi.NewInlineKeyboardButtonData("◀️", prev.data()))
+	}
+	toggle := v
+	toggle.Week = !v.Week
+	if v.Week {
+		nav = append(nav, tgbotapi.NewInlineKeyboardButtonData(l.T("schedule.day_view"), toggle.data()))
+	} else {
+		nav = append(nav, tgbotapi.NewInlineKeyboardButtonData(l.T("schedule.week_view"), toggle.data()))
+	}
+	if next := v.From.AddDate(0, 0, v.span()); admin || !next.After(last) {
+		nextView := v
+		nextView.From = next
+		nav = append(nav, tgbotapi.NewInlineKeyboardButtonData("▶️", nextView.data()))
+	}
+
+	rows := [][]tgbotapi.InlineKeyboardButton{nav}
+	if len(locations) > 1 {
+		rows = append(rows, locationToggle(l, v, admin, locations))
+	}
+	if admin {
+		rows = append(rows, b.scheduleFilterRows(l, v, locations)...)
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(l.T("button.main_menu"), "main_menu"),
+	))
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// locationToggle — кнопка переключения мойки. Администратор может
+// выбрать и все свои мойки сразу, клиент — только одну
+func locationToggle(l *i18n.Localizer, v scheduleView, admin bool, locations []*models.Location) []tgbotapi.InlineKeyboardButton {
+	current := slices.IndexFunc(locations, func(location *models.Location) bool { return location.ID == v.location() })
+
+	next := v
+	next.Filter.Bay = 0
+	switch {
+	case current+1 < len(locations):
+		next.Filter.Locations = []int64{locations[current+1].ID}
+	case admin:
+		next.Filter.Locations = nil
+	default:
+		next.Filter.Locations = []int64{locations[0].ID}
+	}
+
+	label := l.T("booking.location", l.T("schedule.all"))
+	if current >= 0 {
+		label = l.T("booking.location", locationName(l, locations[current]))
+	}
+	return tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(label, next.data()))
+}
+
+// scheduleFilterRows — кнопки фильтров администратора. Каждое нажатие
+// переключает фильтр на следующее значение
+func (b *CarWashBot) scheduleFilterRows(l *i18n.Localizer, v scheduleView, locations []*models.Location) [][]tgbotapi.InlineKeyboardButton {
+	var rows [][]tgbotapi.InlineKeyboardButton
+
+	// Номера постов — по самой большой из показанных моек
+	bays := 0
+	for _, location := range locations {
+		if v.location() < 0 || location.ID == v.location() {
+			bays = max(bays, b.bookings.Bays(location))
+		}
+	}
+	if bays > 1 {
+		next := v
+		next.Filter.Bay = (v.Filter.Bay + 1) % (bays + 1)
+		label := l.T("schedule.filter_bay", l.T("schedule.all"))
+		if v.Filter.Bay > 0 {
+			label = l.T("schedule.filter_bay", strconv.Itoa(v.Filter.Bay))
+		}
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(label, next.data())))
+	}
+
+	services := []string{""}
+	for _, service := range b.cfg.Services {
+		services = append(services, service.Code)
+	}
+	next := v
+	next.Filter.Service = services[(slices.Index(services, v.Filter.Service)+1)%len(services)]
+	label := l.T("schedule.filter_service", l.T("schedule.all"))
+	if v.Filter.Service != "" {
+		label = l.T("schedule.filter_service", b.serviceName(v.Filter.Service))
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(label, next.data())))
+
+	next = v
+	next.Filter.Status = scheduleStatuses[(slices.Index(scheduleStatuses, v.Filter.Status)+1)%len(scheduleStatuses)]
+	label = l.T("schedule.filter_status", l.T("schedule.all"))
+	if v.Filter.Status != "" {
+		label = l.T("schedule.filter_status", l.Status(v.Filter.Status))
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(label, next.data())))
+
+	next = v
+	next.Past = !v.Past
+	label = l.T("schedule.past_hidden")
+	if v.Past {
+		label = l.T("schedule.past_shown")
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(label, next.data())))
+	return rows
+}
+
+// dayHeading — заголовок дня в расписании
+func dayHeading(l *i18n.Localizer, date time.Time) string {
+	today := time.Now()
+	switch date.Format("02.01.2006") {
+	case today.Format("02.01.2006"):
+		return "=== " + l.T("schedule.today", l.Weekday(date.Weekday())) + " ==="
+	case today.AddDate(0, 0, 1).Format("02.01.2006"):
+		return "=== " + l.T("schedule.tomorrow", l.Weekday(date.Weekday())) + " ==="
+	}
+	return "=== " + l.Date(date) + " ==="
+}
+
+func dateKeys(days []time.Time) []string {
+	keys := make([]string, len(days))
+	for i, day := range days {
+		keys[i] = day.Format("02.01.2006")
+	}
+	return keys
+}
+
+// occupancyText — расписание мойки для клиентов без чужих номеров и имён.
+// Подробность задаётся cfg.Schedule
+func (b *CarWashBot) occupancyText(l *i18n.Localizer, v scheduleView, locations map[int64]*models.Location) (string, error) {
+	days := b.scheduleDays(v, false)
+	location, ok := locations[v.location()]
+	if !ok {
+		location = b.location(0)
+		v.Filter.Locations = []int64{0}
+	}
+
+	bookingsByDate := make(map[string][]*models.Booking)
+	if b.cfg.Schedule == config.ScheduleCars {
+		bookings, err := b.storage.GetSchedule(context.Background(), dateKeys(days), models.ScheduleFilter{Locations: v.Filter.Locations})
+		if err != nil {
+			return "", err
+		}
+		now := time.Now()
+		for _, booking := range bookings {
+			if start, err := bookingStart(booking); err == nil && start.Before(now) {
+				continue
+			}
+			bookingsByDate[booking.Date] = append(bookingsByDate[booking.Date], booking)
+		}
+	}
+
+	var sb strings.Builder
+	sb.WriteString(l.T("schedule.occupancy_title") + "\n")
+	if len(locations) > 1 {
+		sb.WriteString(l.T("booking.location", locationName(l, location)) + "\n")
+	}
+	if b.cfg.Schedule != config.ScheduleDays && b.cfg.Schedule != config.ScheduleCars {
+		sb.WriteString(l.T("schedule.legend") + "\n")
+	}
+	sb.WriteString("\n")
+
+	for _, date := range days {
+		sb.WriteString(dayHeading(l, date) + "\n")
+		if b.cfg.IsClosed(date) {
+			sb.WriteString(l.T("schedule.closed") + "\n\n")
+			continue
+		}
+
+		key := date.Format("02.01.2006")
+		occupancy, err := b.bookings.DayOccupancy(context.Background(), location, key)
+		if err != nil {
+			return "", err
+		}
+
+		switch b.cfg.Schedule {
+		case config.ScheduleDays:
+			sb.WriteString(l.T("schedule.free", b.bookings.FreeSlots(location, date, occupancy), len(b.bookings.SlotTimes(location))) + "\n")
+		case config.ScheduleCars:
+			sb.WriteString(carsLines(l, bookingsByDate[key]))
+		default:
+			sb.WriteString(b.slotLines(l, location, date, occupancy))
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String(), nil
+}
+
+// slotLines отмечает каждое оставшееся время дня: 🟢 — свободно,
+// 🟡 — занята часть постов, 🔴 — занято
+func (b *CarWashBot) slotLines(l *i18n.Localizer, location *models.Location, date time.Time, occupancy map[string]int) string {
+	now := time.Now()
+	var cells []string
+	for _, slot := range b.bookings.SlotTimes(location) {
+		start, err := time.ParseInLocation("02.01.2006 15:04", date.Format("02.01.2006")+" "+slot, time.Local)
+		if err != nil || start.Before(now) {
+			continue
+		}
+
+		mark := "🟢"
+		switch {
+		case occupancy[slot] >= b.bookings.Bays(location):
+			mark = "🔴"
+		case occupancy[slot] > 0:
+			mark = "🟡"
+		}
+		cells = append(cells, mark+" "+slot)
+	}
+	if len(cells) == 0 {
+		return l.T("schedule.no_slots") + "\n"
+	}
+
+	var sb strings.Builder
+	for i := 0; i < len(cells); i += slotsPerLine {
+		sb.WriteString(strings.Join(cells[i:min(i+slotsPerLine, len(cells))], "  ") + "\n")
+	}
+	return sb.String()
+}
+
+// carsLines перечисляет занятое время с марками машин, но без номеров
+func carsLines(l *i18n.Localizer, bookings []*models.Booking) string {
+	if len(bookings) == 0 {
+		return l.T("schedule.empty") + "\n"
+	}
+
+	var sb strings.Builder
+	for _, booking := range bookings {
+		sb.WriteString(fmt.Sprintf("🕒 %s - %s\n", booking.Time, booking.CarModel))
+	}
+	return sb.String()
+}
+
+// adminScheduleText — записи с номерами машин и клиентами по дням вида
+func (b *CarWashBot) adminScheduleText(l *i18n.Localizer, v scheduleView, locations map[int64]*models.Location) (string, error) {
+	days := b.scheduleDays(v, true)
+	bookings, err := b.storage.GetSchedule(context.Background(), dateKeys(days), v.Filter)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	bookingsByDate := make(map[string][]*models.Booking)
+	for _, booking := range bookings {
+		if !v.Past {
+			if start, err := bookingStart(booking); err == nil && start.Before(now) {
+				continue
+			}
+		}
+		bookingsByDate[booking.Date] = append(bookingsByDate[booking.Date], booking)
+	}
+
+	var sb strings.Builder
+	sb.WriteString(l.T("schedule.title") + "\n")
+	if filter := b.filterSummary(l, v.Filter, locations); filter != "" {
+		sb.WriteString("🔎 " + filter + "\n")
+	}
+	sb.WriteString("\n")
+
+	for _, date := range days {
+		sb.WriteString(dayHeading(l, date) + "\n")
+		dayBookings := bookingsByDate[date.Format("02.01.2006")]
+		if len(dayBookings) == 0 {
+			sb.WriteString(l.T("schedule.empty") + "\n")
+		}
+		for _, booking := range dayBookings {
+			sb.WriteString(b.adminScheduleLine(booking, locations) + "\n")
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String(), nil
+}
+
+func (b *CarWashBot) filterSummary(l *i18n.Localizer, filter models.ScheduleFilter, locations map[int64]*models.Location) string {
+	var parts []string
+	if len(filter.Locations) == 1 && len(locations) > 1 {
+		if location, ok := locations[filter.Locations[0]]; ok {
+			parts = append(parts, locationName(l, location))
+		}
+	}
+	if filter.Bay > 0 {
+		parts = append(parts, l.T("booking.bay", filter.Bay))
+	}
+	if filter.Service != "" {
+		parts = append(parts, b.serviceName(filter.Service))
+	}
+	if filter.Status != "" {
+		parts = append(parts, l.Status(filter.Status))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// adminScheduleLine описывает запись для администратора. Мойка
+// указывается, если в сети их несколько
+func (b *CarWashBot) adminScheduleLine(booking *models.Booking, locations map[int64]*models.Location) string {
+	line := fmt.Sprintf("🕒 %s - %s %s", booking.Time, booking.CarModel, booking.CarNumber)
+	location, ok := locations[booking.LocationID]
+	if ok && b.bookings.Bays(location) > 1 && booking.Bay > 0 {
+		line += fmt.Sprintf(" (пост %d)", booking.Bay)
+	}
+	if ok && len(locations) > 1 {
+		line += " 📍 " + locationName(nil, location)
+	}
+	if booking.User != nil {
+		if booking.User.Username != "" {
+			line += fmt.Sprintf(", %s @%s", booking.User.FirstName, booking.User.Username)
+		} else if booking.User.FirstName != "" {
+			line += ", " + booking.User.FirstName
+		}
+	}
+	switch booking.Status {
+	case models.StatusAwaitingPayment:
+		line += " 💳"
+	case models.StatusPendingApproval:
+		line += " ⏳"
+	case models.StatusCompleted:
+		line += " ✅"
+	case models.StatusNoShow:
+		line += " 🚫"
+	case models.StatusCancelled:
+		line += " ❌"
+	}
+	return line
+}
+
+// messageLength возвращает длину текста так, как её считает Telegram:
+// в единицах UTF-16. Эмодзи вне BMP занимают по две единицы
+func messageLength(text string) int {
+	n := 0
+	for _, r := range text {
+		n += max(utf16.RuneLen(r), 1)
+	}
+	return n
+}
+
+// splitMessage делит текст на части не длиннее limit единиц UTF-16 по
+// границам строк. Слишком длинная строка режется посередине
+func splitMessage(text string, limit int) []string {
+	var (
+		chunks []string
+		sb     strings.Builder
+		size   int
+	)
+	flush := func() {
+		chunks = append(chunks, sb.String())
+		sb.Reset()
+		size = 0
+	}
+	for _, line := range strings.SplitAfter(text, "\n") {
+		if n := messageLength(line); size+n > limit && size > 0 {
+			flush()
+		}
+		for _, r := range line {
+			n := max(utf16.RuneLen(r), 1)
+			if size+n > limit {
+				flush()
+			}
+			sb.WriteRune(r)
+			size += n
+		}
+	}
+	if size > 0 || len(chunks) == 0 {
+		flush()
+	}
+	return chunks
+}
@@ -0,0 +1,67 @@
+package bot
+
+import (
+	"carwash-bot/internal/i18n"
+	"carwash-bot/internal/models"
+	"strings"
+	"testing"
+)
+
+func TestSplitMessageCountsUTF16(t *testing.T) {
+	// Каждая строка — как строка расписания администратора: 🕒 и 🟢
+	// занимают по две единицы UTF-16
+	line := "🕒 10:00 🟢 Kia A123BC — Экспресс\n"
+	text := strings.Repeat(line, 200)
+	if messageLength(line) <= len([]rune(line)) {
+		t.Fatalf("messageLength(%q) = %d; эмодзи должны считаться за две единицы", line, messageLength(line))
+	}
+
+	chunks := splitMessage(text, maxMessageLength)
+	if len(chunks) < 2 {
+		t.Fatalf("текст длиной %d разбит на %d частей", messageLength(text), len(chunks))
+	}
+	for i, chunk := range chunks {
+		if n := messageLength(chunk); n > maxMessageLength {
+			t.Errorf("часть %d длиной %d единиц UTF-16 больше %d", i, n, maxMessageLength)
+		}
+		if !strings.HasSuffix(chunk, "\n") {
+			t.Errorf("часть %d разрезала строку: ...%q", i, chunk[len(chunk)-20:])
+		}
+	}
+	if got := strings.Join(chunks, ""); got != text {
+		t.Error("после склейки частей текст изменился")
+	}
+}
+
+func TestSplitMessageLongLine(t *testing.T) {
+	// Строка без переносов режется по символам, эмодзи не разрываются
+	text := strings.Repeat("🚗", 3000)
+	chunks := splitMessage(text, maxMessageLength)
+	for i, chunk := range chunks {
+		if n := messageLength(chunk); n > maxMessageLength {
+			t.Errorf("часть %d длиной %d единиц UTF-16 больше %d", i, n, maxMessageLength)
+		}
+	}
+	if got := strings.Join(chunks, ""); got != text {
+		t.Error("после склейки частей текст изменился")
+	}
+}
+
+// Кнопка мойки в расписании подписана на языке сотрудника, как и у клиента
+func TestLocationToggleLocalized(t *testing.T) {
+	l := i18n.New("en")
+	locations := []*models.Location{{ID: 0}, {ID: 7, Name: "Branch"}}
+
+	for _, tc := range []struct {
+		view scheduleView
+		want string
+	}{
+		{scheduleView{}, "📍 Car wash: all"},
+		{scheduleView{Filter: models.ScheduleFilter{Locations: []int64{0}}}, "📍 Car wash: " + l.T("location.main")},
+		{scheduleView{Filter: models.ScheduleFilter{Locations: []int64{7}}}, "📍 Car wash: Branch"},
+	} {
+		if got := locationToggle(l, tc.view, true, locations)[0].Text; got != tc.want {
+			t.Errorf("locationToggle(%v) = %q; нужно %q", tc.view.Filter.Locations, got, tc.want)
+		}
+	}
+}
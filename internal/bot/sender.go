@@ -0,0 +1,132 @@
+package bot
+
+import (
+	"carwash-bot/internal/ratelimit"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Ограничения Telegram на исходящие сообщения
+const (
+	globalSendsPerSecond = 30
+	privateChatBurst     = 3
+	groupSendsPerMinute  = 20
+)
+
+// throttledAPI ограничивает частоту запросов к Telegram. Запросы,
+// отклонённые с кодом 429, не повторяются на месте, чтобы не держать
+// обработку обновлений: сообщения досылает очередь (см. retryLater), а
+// до истечения retry_after запросы в этот чат сразу получают ту же ошибку
+type throttledAPI struct {
+	*tgbotapi.BotAPI
+
+	global  *ratelimit.Limiter
+	private *ratelimit.Limiter // По личным чатам
+	groups  *ratelimit.Limiter // По групповым чатам
+
+	mu     sync.Mutex
+	paused map[int64]time.Time // До какого времени Telegram просил не писать в чат, 0 — без чата
+}
+
+func newThrottledAPI(api *tgbotapi.BotAPI) *throttledAPI {
+	return &throttledAPI{
+		BotAPI:  api,
+		global:  ratelimit.New(globalSendsPerSecond, time.Second, globalSendsPerSecond),
+		private: ratelimit.New(1, time.Second, privateChatBurst),
+		groups:  ratelimit.New(groupSendsPerMinute, time.Minute, privateChatBurst),
+		paused:  make(map[int64]time.Time),
+	}
+}
+
+func (t *throttledAPI) Request(c tgbotapi.Chattable) (*tgbotapi.APIResponse, error) {
+	chatID := targetChat(c)
+	if err := t.checkPaused(chatID); err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+	if chatID > 0 {
+		t.private.Wait(ctx, chatID)
+	} else if chatID < 0 {
+		t.groups.Wait(ctx, chatID)
+	}
+	t.global.Wait(ctx, 0)
+
+	resp, err := t.BotAPI.Request(c)
+	if after := retryAfter(err); after > 0 {
+		log.Printf("Telegram просит подождать %s перед повтором запроса в чат %d", after, chatID)
+		t.mu.Lock()
+		t.paused[chatID] = time.Now().Add(after)
+		t.mu.Unlock()
+	}
+	return resp, err
+}
+
+// checkPaused возвращает ошибку 429, если Telegram ещё не разрешил писать в чат
+func (t *throttledAPI) checkPaused(chatID int64) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	until, ok := t.paused[chatID]
+	if !ok {
+		return nil
+	}
+	left := time.Until(until)
+	if left <= 0 {
+		delete(t.paused, chatID)
+		return nil
+	}
+	return &tgbotapi.Error{
+		Code:               http.StatusTooManyRequests,
+		Message:            "Too Many Requests: повтор отложен",
+		ResponseParameters: tgbotapi.ResponseParameters{RetryAfter: int(left.Seconds()) + 1},
+	}
+}
+
+// retryAfter возвращает, сколько Telegram просит подождать после
+// ошибки 429, или 0
+func retryAfter(err error) time.Duration {
+	var apiErr *tgbotapi.Error
+	if !errors.As(err, &apiErr) || apiErr.RetryAfter <= 0 {
+		return 0
+	}
+	return time.Duration(apiErr.RetryAfter) * time.Second
+}
+
+func (t *throttledAPI) Send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	resp, err := t.Request(c)
+	if err != nil {
+		return tgbotapi.Message{}, err
+	}
+
+	var message tgbotapi.Message
+	err = json.Unmarshal(resp.Result, &message)
+	return message, err
+}
+
+// targetChat возвращает чат, в который отправляется сообщение, или 0
+// для запросов, не ограниченных по чату
+func targetChat(c tgbotapi.Chattable) int64 {
+	switch v := c.(type) {
+	case tgbotapi.MessageConfig:
+		return v.ChatID
+	case tgbotapi.InvoiceConfig:
+		return v.ChatID
+	case tgbotapi.DocumentConfig:
+		return v.ChatID
+	case tgbotapi.PhotoConfig:
+		return v.ChatID
+	case tgbotapi.EditMessageTextConfig:
+		return v.ChatID
+	case tgbotapi.EditMessageReplyMarkupConfig:
+		return v.ChatID
+	}
+	return 0
+}
@@ -0,0 +1,53 @@
+package bot
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestThrottledAPIDoesNotWaitOn429(t *testing.T) {
+	telegram := newFakeTelegram(t)
+	api := newThrottledAPI(telegram.api(t))
+	telegram.floodChat(1, 30)
+
+	start := time.Now()
+	_, err := api.Send(tgbotapi.NewMessage(1, "первое"))
+	if after := retryAfter(err); after != 30*time.Second {
+		t.Fatalf("ошибка = %v; нужно 429 с retry_after 30 с", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("отправка ждала %s; 429 не должна задерживать обработку", elapsed)
+	}
+
+	// До истечения retry_after запросы в этот чат в Telegram не уходят
+	calls := telegram.callCount()
+	if _, err := api.Send(tgbotapi.NewMessage(1, "второе")); retryAfter(err) <= 0 {
+		t.Fatalf("второе сообщение: %v; нужно 429", err)
+	}
+	if telegram.callCount() != calls {
+		t.Fatal("запрос в приостановленный чат ушёл в Telegram")
+	}
+
+	if _, err := api.Send(tgbotapi.NewMessage(2, "другой чат")); err != nil {
+		t.Fatalf("сообщение в другой чат: %v", err)
+	}
+}
+
+func TestSendMessageQueues429(t *testing.T) {
+	b, telegram, store := newTestBot(t, testConfig())
+	telegram.floodChat(42, 60)
+
+	b.sendMessage(42, "✅ Запись подтверждена")
+
+	ctx := context.Background()
+	if due, _ := store.GetDueMessages(ctx, time.Now().Add(30*time.Second), 10); len(due) != 0 {
+		t.Fatalf("сообщение поставлено в очередь раньше retry_after: %+v", due[0])
+	}
+	due, err := store.GetDueMessages(ctx, time.Now().Add(2*time.Minute), 10)
+	if err != nil || len(due) != 1 || due[0].ChatID != 42 || due[0].Text != "✅ Запись подтверждена" {
+		t.Fatalf("очередь = %+v, %v; нужно одно сообщение после retry_after", due, err)
+	}
+}
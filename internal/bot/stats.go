@@ -0,0 +1,330 @@
+package bot
+
+import (
+	"carwash-bot/internal/charts"
+	"carwash-bot/internal/i18n"
+	"carwash-bot/internal/models"
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const statsHelp = `📊 Статистика:
+/stats — за последние 7 дней
+/stats today — за сегодня
+/stats 30 — за последние 30 дней
+/stats month — за текущий месяц
+/stats prev — за прошлый месяц
+/stats 01.10.2026-15.10.2026 — за произвольный период`
+
+// Период длиннее этого выводится без разбивки по дням, её видно на графике
+const statsDaysLimit = 31
+
+// Самый длинный столбик в разбивке по дням
+const statsBarWidth = 10
+
+// Порядок дней недели в тепловой карте
+var heatmapWeekdays = []time.Weekday{
+	time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday, time.Saturday, time.Sunday,
+}
+
+// statsPeriod разбирает период статистики. Конец периода включается
+func statsPeriod(arg string, now time.Time) (time.Time, time.Time, error) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	switch arg {
+	case "", "week":
+		return today.AddDate(0, 0, -6), today, nil
+	case "today":
+		return today, today, nil
+	case "30":
+		return today.AddDate(0, 0, -29), today, nil
+	case "month":
+		return today.AddDate(0, 0, 1-today.Day()), today, nil
+	case "prev":
+		first := today.AddDate(0, 0, 1-today.Day())
+		return first.AddDate(0, -1, 0), first.AddDate(0, 0, -1), nil
+	}
+
+	fromStr, toStr, ok := strings.Cut(arg, "-")
+	if !ok {
+		return time.Time{}, time.Time{}, errors.New("неизвестный период")
+	}
+	from, err := time.ParseInLocation("02.01.2006", fromStr, time.Local)
+	if err != nil {
+		return time.Time{}, time.Time{}, errors.New("неверная дата начала")
+	}
+	to, err := time.ParseInLocation("02.01.2006", toStr, time.Local)
+	if err != nil {
+		return time.Time{}, time.Time{}, errors.New("неверная дата конца")
+	}
+	if to.Before(from) {
+		return time.Time{}, time.Time{}, errors.New("конец периода раньше начала")
+	}
+	return from, to, nil
+}
+
+func (b *CarWashBot) handleStatsCommand(chatID, userID int64, text string) {
+	if !b.isAdmin(userID) {
+		b.sendMessage(chatID, "⛔ Команда доступна только администратору.")
+		return
+	}
+
+	arg := strings.TrimSpace(strings.TrimPrefix(text, "/stats"))
+	if arg == "help" {
+		b.sendMessage(chatID, statsHelp)
+		return
+	}
+	b.showStats(chatID, 0, arg)
+}
+
+func (b *CarWashBot) handleStatsCallback(chatID, userID int64, messageID int, data string) {
+	if !b.isAdmin(userID) {
+		return
+	}
+	if arg, ok := strings.CutPrefix(data, "chart_"); ok {
+		b.sendStatsCharts(chatID, arg)
+		return
+	}
+	b.showStats(chatID, messageID, strings.TrimPrefix(data, "stats_"))
+}
+
+// showStats выводит статистику за период. Если messageID не 0,
+// сообщение с прежним периодом заменяется
+func (b *CarWashBot) showStats(chatID int64, messageID int, arg string) {
+	from, to, err := statsPeriod(arg, time.Now())
+	if err != nil {
+		b.sendMessage(chatID, "❌ "+err.Error()+"\n\n"+statsHelp)
+		return
+	}
+
+	stats, err := b.storage.GetStats(context.Background(), from, to)
+	if err != nil {
+		log.Printf("Ошибка получения статистики: %v", err)
+		b.sendMessage(chatID, "⚠️ Ошибка загрузки статистики.")
+		return
+	}
+
+	text := b.statsText(b.tr(b.adminID), stats)
+	markup := statsKeyboard(arg)
+	if messageID != 0 {
+		edit := tgbotapi.NewEditMessageTextAndMarkup(chatID, messageID, text, markup)
+		edit.ParseMode = tgbotapi.ModeHTML
+		if _, err := b.botAPI.Request(edit); err != nil {
+			log.Printf("Ошибка обновления статистики: %v", err)
+		}
+		return
+	}
+
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ParseMode = tgbotapi.ModeHTML
+	msg.ReplyMarkup = markup
+	b.sendMessageWithSave(chatID, msg)
+}
+
+func statsKeyboard(arg string) tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("Сегодня", "stats_today"),
+			tgbotapi.NewInlineKeyboardButtonData("7 дней", "stats_week"),
+			tgbotapi.NewInlineKeyboardButtonData("30 дней", "stats_30"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("Этот месяц", "stats_month"),
+			tgbotapi.NewInlineKeyboardButtonData("Прошлый месяц", "stats_prev"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("📈 Графики", "chart_"+arg),
+		),
+	)
+}
+
+// statsDays возвращает дни периода
+func statsDays(stats *models.Stats) []time.Time {
+	var days []time.Time
+	for day := stats.From; !day.After(stats.To); day = day.AddDate(0, 0, 1) {
+		days = append(days, day)
+	}
+	return days
+}
+
+// capacity — сколько записей мойки сети могли принять за период
+func (b *CarWashBot) capacity(days []time.Time) int {
+	open := 0
+	for _, day := range days {
+		if !b.cfg.IsClosed(day) {
+			open++
+		}
+	}
+
+	locations, err := b.bookings.Locations(context.Background())
+	if err != nil {
+		log.Printf("Ошибка получения моек: %v", err)
+		return 0
+	}
+	slots := 0
+	for _, location := range locations {
+		slots += len(b.bookings.SlotTimes(location)) * b.bookings.Bays(location)
+	}
+	return open * slots
+}
+
+func percent(part, total int) int {
+	if total == 0 {
+		return 0
+	}
+	return part * 100 / total
+}
+
+// formatLeadTime округляет время до записи до часов или минут
+func formatLeadTime(d time.Duration) string {
+	switch {
+	case d >= 24*time.Hour:
+		return fmt.Sprintf("%d д %d ч", int(d.Hours())/24, int(d.Hours())%24)
+	case d >= time.Hour:
+		return fmt.Sprintf("%d ч %d мин", int(d.Hours()), int(d.Minutes())%60)
+	}
+	return fmt.Sprintf("%d мин", int(d.Minutes()))
+}
+
+// statsText — статистика в HTML: показатели, услуги, дни и тепловая карта
+func (b *CarWashBot) statsText(l *i18n.Localizer, stats *models.Stats) string {
+	days := statsDays(stats)
+	booked := stats.Bookings - stats.Cancelled
+	attended := stats.Completed + stats.NoShows
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("📊 <b>Статистика за %s — %s</b>\n\n",
+		stats.From.Format("02.01.2006"), stats.To.Format("02.01.2006")))
+	sb.WriteString(fmt.Sprintf("📝 Записей: %d\n", stats.Bookings))
+	sb.WriteString(fmt.Sprintf("✅ Выполнено: %d\n", stats.Completed))
+	sb.WriteString(fmt.Sprintf("❌ Отмен: %d (%d%%), поздних: %d\n",
+		stats.Cancelled, percent(stats.Cancelled, stats.Bookings), stats.LateCancels))
+	sb.WriteString(fmt.Sprintf("🚫 Неявок: %d (%d%%)\n", stats.NoShows, percent(stats.NoShows, attended)))
+	sb.WriteString(fmt.Sprintf("💰 Выручка: %d ₽", stats.Revenue))
+	if stats.Completed > 0 {
+		sb.WriteString(fmt.Sprintf(", средний чек %d ₽", stats.Revenue/stats.Completed))
+	}
+	sb.WriteString("\n")
+	sb.WriteString(fmt.Sprintf("🅿️ Загрузка: %d%%\n", percent(booked, b.capacity(days))))
+	sb.WriteString(fmt.Sprintf("⏱ Записываются заранее в среднем за %s\n", formatLeadTime(stats.LeadTime)))
+	sb.WriteString(fmt.Sprintf("👥 Клиентов: новых %d, вернувшихся %d\n",
+		stats.NewCustomers, stats.ReturningCustomers))
+
+	if len(stats.TopServices) > 0 {
+		sb.WriteString("\n🏆 <b>Популярные услуги</b>\n<pre>")
+		for _, service := range stats.TopServices {
+			sb.WriteString(fmt.Sprintf("%-20s %4d %8d ₽\n",
+				html.EscapeString(truncate(b.serviceName(service.Service), 20)), service.Count, service.Revenue))
+		}
+		sb.WriteString("</pre>")
+	}
+
+	if len(days) > 1 && len(days) <= statsDaysLimit {
+		sb.WriteString("\n📅 <b>По дням</b>\n<pre>")
+		peak := 0
+		for _, day := range days {
+			peak = max(peak, stats.PerDay[day.Format("02.01.2006")])
+		}
+		for _, day := range days {
+			count := stats.PerDay[day.Format("02.01.2006")]
+			width := 0
+			if peak > 0 {
+				width = (count*statsBarWidth + peak - 1) / peak
+			}
+			sb.WriteString(fmt.Sprintf("%s %s %-*s %d\n", day.Format("02.01"), l.WeekdayShort(day.Weekday()),
+				statsBarWidth, strings.Repeat("▇", width), count))
+		}
+		sb.WriteString("</pre>")
+	}
+
+	sb.WriteString("\n🔥 <b>Записи по дням недели и часам</b>\n<pre>")
+	sb.WriteString(b.heatmapText(l, stats))
+	sb.WriteString("</pre>")
+	return sb.String()
+}
+
+func (b *CarWashBot) heatmapText(l *i18n.Localizer, stats *models.Stats) string {
+	var sb strings.Builder
+	sb.WriteString("  ")
+	for hour := b.cfg.StartTime; hour <= min(b.cfg.EndTime, 23); hour++ {
+		sb.WriteString(fmt.Sprintf(" %02d", hour))
+	}
+	sb.WriteString("\n")
+
+	for _, weekday := range heatmapWeekdays {
+		sb.WriteString(l.WeekdayShort(weekday))
+		for hour := b.cfg.StartTime; hour <= min(b.cfg.EndTime, 23); hour++ {
+			if count := stats.Heatmap[weekday][hour]; count > 0 {
+				sb.WriteString(fmt.Sprintf(" %2d", min(count, 99)))
+			} else {
+				sb.WriteString("  ·")
+			}
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n-1]) + "…"
+}
+
+// sendStatsCharts отправляет графики записей по дням и тепловую карту
+func (b *CarWashBot) sendStatsCharts(chatID int64, arg string) {
+	from, to, err := statsPeriod(arg, time.Now())
+	if err != nil {
+		b.sendMessage(chatID, "❌ "+err.Error())
+		return
+	}
+	stats, err := b.storage.GetStats(context.Background(), from, to)
+	if err != nil {
+		log.Printf("Ошибка получения статистики: %v", err)
+		b.sendMessage(chatID, "⚠️ Ошибка загрузки статистики.")
+		return
+	}
+
+	days := statsDays(stats)
+	perDay := make([]int, len(days))
+	for i, day := range days {
+		perDay[i] = stats.PerDay[day.Format("02.01.2006")]
+	}
+	bars, err := charts.Bars(perDay)
+	if err != nil {
+		log.Printf("Ошибка построения графика: %v", err)
+		return
+	}
+
+	var grid [][]int
+	for _, weekday := range heatmapWeekdays {
+		grid = append(grid, stats.Heatmap[weekday][b.cfg.StartTime:min(b.cfg.EndTime, 23)+1])
+	}
+	heatmap, err := charts.Heatmap(grid)
+	if err != nil {
+		log.Printf("Ошибка построения графика: %v", err)
+		return
+	}
+
+	period := fmt.Sprintf("%s — %s", from.Format("02.01.2006"), to.Format("02.01.2006"))
+	photo := tgbotapi.NewPhoto(chatID, tgbotapi.FileBytes{Name: "bookings.png", Bytes: bars})
+	photo.Caption = "📈 Записи по дням, " + period
+	if _, err := b.botAPI.Send(photo); err != nil {
+		log.Printf("Ошибка отправки графика: %v", err)
+	}
+
+	photo = tgbotapi.NewPhoto(chatID, tgbotapi.FileBytes{Name: "heatmap.png", Bytes: heatmap})
+	photo.Caption = fmt.Sprintf("🔥 Записи по дням недели (строки, с понедельника) и часам (столбцы, с %02d:00), %s",
+		b.cfg.StartTime, period)
+	if _, err := b.botAPI.Send(photo); err != nil {
+		log.Printf("Ошибка отправки графика: %v", err)
+	}
+}
@@ -0,0 +1,154 @@
+package bot
+
+import (
+	"carwash-bot/config"
+	"carwash-bot/internal/ratelimit"
+	"carwash-bot/internal/storage"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// fakeTelegram заменяет api.telegram.org в тестах: запоминает вызовы
+// и отвечает на них успехом
+type fakeTelegram struct {
+	server *httptest.Server
+
+	mu     sync.Mutex
+	calls  []telegramCall
+	lastID int
+	// flood — чаты, которым следующий запрос вернёт 429 с retry_after в секундах
+	flood map[int64]int
+}
+
+type telegramCall struct {
+	Method string
+	Params url.Values
+}
+
+func newFakeTelegram(t *testing.T) *fakeTelegram {
+	t.Helper()
+	f := &fakeTelegram{flood: make(map[int64]int)}
+	f.server = httptest.NewServer(http.HandlerFunc(f.handle))
+	t.Cleanup(f.server.Close)
+	return f
+}
+
+func (f *fakeTelegram) handle(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	method := path.Base(r.URL.Path)
+	chatID, _ := strconv.ParseInt(r.PostForm.Get("chat_id"), 10, 64)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if method != "getMe" {
+		f.calls = append(f.calls, telegramCall{Method: method, Params: r.PostForm})
+	}
+	if after, ok := f.flood[chatID]; ok {
+		delete(f.flood, chatID)
+		w.WriteHeader(http.StatusTooManyRequests)
+		fmt.Fprintf(w, `{"ok":false,"error_code":429,"description":"Too Many Requests: retry after %d","parameters":{"retry_after":%d}}`,
+			after, after)
+		return
+	}
+
+	var result any = true
+	switch method {
+	case "getMe":
+		result = tgbotapi.User{ID: 1, IsBot: true, FirstName: "Автомойка", UserName: "carwash_bot"}
+	case "sendMessage", "sendDocument", "sendPhoto":
+		f.lastID++
+		result = tgbotapi.Message{MessageID: f.lastID, Chat: &tgbotapi.Chat{ID: chatID}, Text: r.PostForm.Get("text")}
+	}
+	raw, _ := json.Marshal(result)
+	json.NewEncoder(w).Encode(tgbotapi.APIResponse{Ok: true, Result: raw})
+}
+
+func (f *fakeTelegram) api(t *testing.T) *tgbotapi.BotAPI {
+	t.Helper()
+	api, err := tgbotapi.NewBotAPIWithClient("test", f.server.URL+"/bot%s/%s", f.server.Client())
+	if err != nil {
+		t.Fatalf("NewBotAPIWithClient: %v", err)
+	}
+	return api
+}
+
+// texts возвращает тексты отправленных и отредактированных сообщений в чат
+func (f *fakeTelegram) texts(chatID int64) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var texts []string
+	for _, call := range f.calls {
+		if call.Params.Get("chat_id") != strconv.FormatInt(chatID, 10) {
+			continue
+		}
+		if call.Method == "sendMessage" || call.Method == "editMessageText" {
+			texts = append(texts, call.Params.Get("text"))
+		}
+	}
+	return texts
+}
+
+// floodChat отвечает на следующий запрос в чат ошибкой 429
+func (f *fakeTelegram) floodChat(chatID int64, retryAfter int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.flood[chatID] = retryAfter
+}
+
+func (f *fakeTelegram) callCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.calls)
+}
+
+// testConfig — настройки мойки для тестов бота
+func testConfig() *config.Config {
+	return &config.Config{
+		AdminID:   1000,
+		StartTime: 9,
+		EndTime:   18,
+		Horizon:   14,
+		Bays:      1,
+		Hold:      10 * time.Minute,
+		Wizard:    30 * time.Minute,
+		Services:  []config.Service{{Code: "express", Name: "Экспресс", Price: 400}},
+		Cancel:    config.CancellationConfig{FreeBefore: 2 * time.Hour, Restriction: config.RestrictNone},
+		Loyalty:   config.LoyaltyConfig{Mode: config.LoyaltyOff},
+		Outbox:    config.OutboxConfig{MaxAttempts: 5},
+		RateLimit: config.RateLimitConfig{PerMinute: 1000, Burst: 1000},
+	}
+}
+
+// newTestBot собирает бота над хранилищем SQLite во временном каталоге
+// и поддельным Telegram
+func newTestBot(t *testing.T, cfg *config.Config) (*CarWashBot, *fakeTelegram, *storage.Storage) {
+	t.Helper()
+	store, err := storage.NewSQLiteStorage(filepath.Join(t.TempDir(), "carwash.db"))
+	if err != nil {
+		t.Fatalf("NewSQLiteStorage: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	store.Bays = cfg.Bays
+
+	telegram := newFakeTelegram(t)
+	b := newBot(telegram.api(t), cfg, store, nil)
+	// Ограничение Telegram по чату в тестах только замедляет отправку
+	b.botAPI.private = ratelimit.New(1000, time.Second, 1000)
+	return b, telegram, store
+}
@@ -0,0 +1,346 @@
+package bot
+
+import (
+	"carwash-bot/internal/i18n"
+	"carwash-bot/internal/models"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const templatesHelp = `📝 Шаблоны сообщений:
+/template — список шаблонов
+/template ИМЯ [язык] — текущий текст и предпросмотр
+/template_set ИМЯ [язык] — сохранить шаблон, текст со следующей строки
+/template_preview ИМЯ [язык] — предпросмотр без сохранения, текст со следующей строки
+/template_reset ИМЯ [язык] — вернуть текст по умолчанию
+
+Шаблоны: welcome — приветствие, confirmation — подтверждение записи,
+reminder — напоминание, admin_booking — новая запись для администратора.
+Язык: ru, en, kk, uz (по умолчанию ru).
+
+Поля: {{.Name}} {{.Date}} {{.Weekday}} {{.Time}} {{.Service}} {{.Price}}
+{{.Car}} {{.Bay}} {{.Promo}} {{.Discount}} {{.Organization}} {{.Summary}}
+Пример: Ждём вас {{.Date}} в {{.Time}}, {{.Name}}! Адрес: ул. Ленина, 1`
+
+var templateNames = []string{
+	models.TemplateWelcome,
+	models.TemplateConfirmation,
+	models.TemplateReminder,
+	models.TemplateAdminBooking,
+}
+
+// Telegram не принимает сообщения длиннее 4096 единиц UTF-16
+const maxMessageLength = 4096
+
+// templateData — поля, доступные в шаблонах сообщений
+type templateData struct {
+	Name         string
+	Date         string
+	Weekday      string
+	Time         string
+	Service      string
+	Price        int
+	Car          string
+	Bay          int
+	Promo        string
+	Discount     int
+	Organization string
+	Summary      string // Стандартное описание записи на языке клиента
+
+	booking *models.Booking
+}
+
+// bookingTemplateData заполняет поля шаблона по записи
+func (b *CarWashBot) bookingTemplateData(l *i18n.Localizer, booking *models.Booking) templateData {
+	data := templateData{
+		Date:     booking.Date,
+		Time:     booking.Time,
+		Service:  b.serviceName(booking.Service),
+		Price:    booking.Price,
+		Car:      strings.TrimSpace(booking.CarModel + " " + booking.CarNumber),
+		Bay:      booking.Bay,
+		Promo:    booking.PromoCode,
+		Discount: booking.Discount,
+		Summary:  b.bookingSummary(l, booking),
+		booking:  booking,
+	}
+	if date, err := time.ParseInLocation("02.01.2006", booking.Date, time.Local); err == nil {
+		data.Weekday = l.Weekday(date.Weekday())
+	}
+	if user, err := b.storage.GetUserByID(context.Background(), booking.UserID); err == nil && user != nil {
+		data.Name = user.FirstName
+	}
+	if booking.OrganizationID != 0 {
+		if org, err := b.storage.GetOrganization(context.Background(), booking.OrganizationID); err == nil && org != nil {
+			data.Organization = org.Name
+		}
+	}
+	return data
+}
+
+// welcomeTemplateData заполняет поля шаблона приветствия по клиенту.
+// Поля записи в приветствии пустые
+func (b *CarWashBot) welcomeTemplateData(userID int64) templateData {
+	var data templateData
+	user, err := b.storage.GetUserByTelegramID(context.Background(), userID)
+	if err != nil || user == nil {
+		log.Printf("Ошибка получения пользователя: %v", err)
+		return data
+	}
+	data.Name = user.FirstName
+
+	memberships, err := b.storage.GetUserMemberships(context.Background(), userID)
+	if err != nil {
+		log.Printf("Ошибка получения организаций: %v", err)
+	}
+	if len(memberships) > 0 && memberships[0].Organization != nil {
+		data.Organization = memberships[0].Organization.Name
+	}
+	return data
+}
+
+// messageText возвращает текст сообщения по шаблону администратора.
+// Если шаблона нет или он не сработал, возвращается текст по умолчанию;
+// custom сообщает, что текст взят из шаблона
+func (b *CarWashBot) messageText(name string, l *i18n.Localizer, data templateData) (text string, custom bool) {
+	body, err := b.storage.GetMessageTemplate(context.Background(), name, l.Lang)
+	if err != nil {
+		log.Printf("Ошибка получения шаблона %s: %v", name, err)
+	}
+	if body != "" {
+		text, err := executeTemplate(name, body, data)
+		if err == nil {
+			return text, true
+		}
+		log.Printf("Ошибка шаблона %s (%s), используется текст по умолчанию: %v", name, l.Lang, err)
+	}
+	return b.defaultText(name, l, data), false
+}
+
+func executeTemplate(name, body string, data templateData) (string, error) {
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(body)
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, data); err != nil {
+		return "", err
+	}
+	text := strings.TrimSpace(sb.String())
+	if text == "" {
+		return "", errors.New("шаблон дал пустой текст")
+	}
+	if messageLength(text) > maxMessageLength {
+		return "", fmt.Errorf("текст длиннее %d символов", maxMessageLength)
+	}
+	return text, nil
+}
+
+// defaultText — текст сообщения, если администратор не задал шаблон
+func (b *CarWashBot) defaultText(name string, l *i18n.Localizer, data templateData) string {
+	switch name {
+	case models.TemplateWelcome:
+		return l.T("menu.welcome")
+	case models.TemplateConfirmation:
+		return l.T("booking.confirmed") + "\n" + data.Summary
+	case models.TemplateReminder:
+		return l.T("booking.reminder", data.Summary)
+	case models.TemplateAdminBooking:
+		return b.adminBookingText(data.booking)
+	}
+	return ""
+}
+
+// confirmationText — подтверждение записи для клиента
+func (b *CarWashBot) confirmationText(l *i18n.Localizer, booking *models.Booking) string {
+	text, _ := b.messageText(models.TemplateConfirmation, l, b.bookingTemplateData(l, booking))
+	return text
+}
+
+// sampleTemplateData — запись для предпросмотра шаблона
+func (b *CarWashBot) sampleTemplateData(l *i18n.Localizer, adminID int64) templateData {
+	booking := &models.Booking{
+		Date:      time.Now().AddDate(0, 0, 1).Format("02.01.2006"),
+		Time:      fmt.Sprintf("%02d:00", b.cfg.StartTime+2),
+		CarModel:  "Toyota",
+		CarNumber: "А123ВС",
+		Bay:       1,
+	}
+	if len(b.cfg.Services) > 0 {
+		booking.Service = b.cfg.Services[0].Code
+		booking.Price = b.cfg.Services[0].Price
+	}
+	if user, err := b.storage.GetUserByTelegramID(context.Background(), adminID); err == nil && user != nil {
+		booking.UserID = user.ID
+	}
+	return b.bookingTemplateData(l, booking)
+}
+
+func (b *CarWashBot) handleTemplateCommand(chatID, userID int64, text string) {
+	if !b.isAdmin(userID) {
+		b.sendMessage(chatID, "⛔ Команда доступна только администратору.")
+		return
+	}
+
+	// Текст шаблона идёт со второй строки сообщения
+	head, body, _ := strings.Cut(text, "\n")
+	fields := strings.Fields(head)
+	if fields[0] == "/template" && len(fields) == 1 {
+		b.showTemplates(chatID)
+		return
+	}
+	if len(fields) < 2 || !slices.Contains(templateNames, fields[1]) {
+		b.sendMessage(chatID, templatesHelp)
+		return
+	}
+
+	name, lang := fields[1], i18n.Default
+	if len(fields) > 2 {
+		lang = fields[2]
+	}
+	if !i18n.Supported(lang) {
+		b.sendMessage(chatID, "❌ Неизвестный язык: "+lang)
+		return
+	}
+	l := i18n.New(lang)
+	sample := b.sampleTemplateData(l, userID)
+
+	switch fields[0] {
+	case "/template":
+		current, custom := b.messageText(name, l, sample)
+		stored, err := b.storage.GetMessageTemplate(context.Background(), name, lang)
+		if err != nil {
+			log.Printf("Ошибка получения шаблона: %v", err)
+		}
+		status := "используется текст по умолчанию"
+		if stored != "" && custom {
+			status = "изменён администратором"
+		} else if stored != "" {
+			status = "⚠️ шаблон с ошибкой, используется текст по умолчанию"
+		}
+		msg := fmt.Sprintf("📝 %s (%s): %s", name, lang, status)
+		if stored != "" {
+			msg += "\n\nШаблон:\n" + stored
+		}
+		b.sendMessage(chatID, msg+"\n\nПредпросмотр:\n"+current)
+
+	case "/template_set", "/template_preview":
+		body = strings.TrimSpace(body)
+		if body == "" {
+			b.sendMessage(chatID, "❌ Текст шаблона укажите со следующей строки.\n\n"+templatesHelp)
+			return
+		}
+		preview, err := executeTemplate(name, body, sample)
+		if err != nil {
+			b.sendMessage(chatID, "❌ Ошибка в шаблоне: "+err.Error())
+			return
+		}
+		if fields[0] == "/template_preview" {
+			b.sendMessage(chatID, "👀 Предпросмотр:\n"+preview)
+			return
+		}
+
+		err = b.storage.SetMessageTemplate(context.Background(), &models.MessageTemplate{
+			Name:      name,
+			Lang:      lang,
+			Body:      body,
+			UpdatedBy: userID,
+		})
+		if err != nil {
+			log.Printf("Ошибка сохранения шаблона: %v", err)
+			b.sendMessage(chatID, "⚠️ Не удалось сохранить шаблон.")
+			return
+		}
+		b.sendMessage(chatID, fmt.Sprintf("✅ Шаблон %s (%s) сохранён. Предпросмотр:\n%s", name, lang, preview))
+
+	case "/template_reset":
+		err := b.storage.DeleteMessageTemplate(context.Background(), name, lang)
+		if errors.Is(err, sql.ErrNoRows) {
+			b.sendMessage(chatID, "Шаблон не менялся.")
+			return
+		}
+		if err != nil {
+			log.Printf("Ошибка удаления шаблона: %v", err)
+			b.sendMessage(chatID, "⚠️ Не удалось сбросить шаблон.")
+			return
+		}
+		b.sendMessage(chatID, fmt.Sprintf("✅ Шаблон %s (%s) сброшен на текст по умолчанию", name, lang))
+
+	default:
+		b.sendMessage(chatID, templatesHelp)
+	}
+}
+
+func (b *CarWashBot) showTemplates(chatID int64) {
+	templates, err := b.storage.GetMessageTemplates(context.Background())
+	if err != nil {
+		log.Printf("Ошибка получения шаблонов: %v", err)
+		b.sendMessage(chatID, "⚠️ Ошибка загрузки шаблонов.")
+		return
+	}
+
+	var sb strings.Builder
+	sb.WriteString("📝 Изменённые шаблоны:\n")
+	if len(templates) == 0 {
+		sb.WriteString("нет, везде текст по умолчанию\n")
+	}
+	for _, t := range templates {
+		sb.WriteString(fmt.Sprintf("%s (%s) — %s\n", t.Name, t.Lang, t.UpdatedAt.Format("02.01.2006 15:04")))
+	}
+	sb.WriteString("\n" + templatesHelp)
+	b.sendMessage(chatID, sb.String())
+}
+
+// runReminders напоминает клиентам о записи за cfg.Reminder до начала
+func (b *CarWashBot) runReminders() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		b.sendReminders(time.Now())
+	}
+}
+
+func (b *CarWashBot) sendReminders(now time.Time) {
+	// Напоминание может выпасть на запись завтрашнего дня
+	dates := []string{now.Format("02.01.2006")}
+	if last := now.Add(b.cfg.Reminder).Format("02.01.2006"); last != dates[0] {
+		dates = append(dates, last)
+	}
+	bookings, err := b.storage.GetBookingsToRemind(context.Background(), dates...)
+	if err != nil {
+		log.Printf("Ошибка получения записей для напоминания: %v", err)
+		return
+	}
+
+	for _, booking := range bookings {
+		start, err := bookingStart(booking)
+		if err != nil || !start.After(now) || start.Sub(now) > b.cfg.Reminder {
+			continue
+		}
+
+		customer, err := b.storage.GetUserByID(context.Background(), booking.UserID)
+		if err != nil || customer == nil {
+			log.Printf("Ошибка получения клиента: %v", err)
+			continue
+		}
+
+		l := b.tr(customer.TelegramID)
+		text, _ := b.messageText(models.TemplateReminder, l, b.bookingTemplateData(l, booking))
+		b.deliver(tgbotapi.NewMessage(customer.TelegramID, text))
+
+		if err := b.storage.MarkReminded(context.Background(), booking.ID); err != nil {
+			log.Printf("Ошибка отметки напоминания: %v", err)
+		}
+	}
+}
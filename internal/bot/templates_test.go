@@ -0,0 +1,35 @@
+package bot
+
+import (
+	"carwash-bot/internal/models"
+	"context"
+	"testing"
+)
+
+func TestWelcomeTemplateUsesCustomer(t *testing.T) {
+	b, telegram, store := newTestBot(t, testConfig())
+	ctx := context.Background()
+
+	if err := store.CreateOrUpdateUser(ctx, &models.User{TelegramID: 42, FirstName: "Айгуль"}); err != nil {
+		t.Fatalf("CreateOrUpdateUser: %v", err)
+	}
+	org := &models.Organization{Name: "Такси Плюс"}
+	if err := store.CreateOrganization(ctx, org); err != nil {
+		t.Fatalf("CreateOrganization: %v", err)
+	}
+	err := store.AddOrganizationMember(ctx, &models.OrganizationMember{OrganizationID: org.ID, TelegramID: 42, Role: models.RoleDriver})
+	if err != nil {
+		t.Fatalf("AddOrganizationMember: %v", err)
+	}
+	err = store.SetMessageTemplate(ctx, &models.MessageTemplate{Name: models.TemplateWelcome, Lang: "ru",
+		Body: "Здравствуйте, {{.Name}} ({{.Organization}})!", UpdatedBy: 1000})
+	if err != nil {
+		t.Fatalf("SetMessageTemplate: %v", err)
+	}
+
+	b.sendWelcomeMessage(42)
+	texts := telegram.texts(42)
+	if want := "Здравствуйте, Айгуль (Такси Плюс)!"; len(texts) != 1 || texts[0] != want {
+		t.Fatalf("приветствие %q; нужно %q", texts, want)
+	}
+}
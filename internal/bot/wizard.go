@@ -0,0 +1,248 @@
+package bot
+
+import (
+	"carwash-bot/internal/i18n"
+	"carwash-bot/internal/models"
+	"carwash-bot/internal/services"
+	"carwash-bot/internal/storage"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Количество кнопок времени в строке
+const slotColumns = 3
+
+// showWizardStep показывает шаг записи, редактируя сообщение мастера.
+// Если редактировать нечего или не получилось, отправляет новое сообщение
+func (b *CarWashBot) showWizardStep(chatID, userID int64, text string, markup tgbotapi.InlineKeyboardMarkup) {
+	state := b.userStates[userID]
+	if state.Notice != "" {
+		text = state.Notice + "\n\n" + text
+		state.Notice = ""
+	}
+
+	state.UpdatedAt = b.now()
+
+	if state.MessageID != 0 {
+		edit := tgbotapi.NewEditMessageTextAndMarkup(chatID, state.MessageID, text, markup)
+		_, err := b.botAPI.Request(edit)
+		if err == nil {
+			b.userStates[userID] = state
+			return
+		}
+		log.Printf("Ошибка редактирования сообщения: %v", err)
+	}
+
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ReplyMarkup = markup
+	sent, err := b.botAPI.Send(msg)
+	if err != nil {
+		log.Printf("Ошибка отправки сообщения: %v", err)
+		return
+	}
+	state.MessageID = sent.MessageID
+	b.userStates[userID] = state
+
+	b.msgIDLock.Lock()
+	b.lastMessageID[chatID] = sent.MessageID
+	b.msgIDLock.Unlock()
+}
+
+// finishWizard заменяет сообщение мастера итоговым текстом и сбрасывает состояние
+func (b *CarWashBot) finishWizard(chatID, userID int64, text string) {
+	b.showWizardStep(chatID, userID, text, tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(b.tr(userID).T("button.main_menu"), "main_menu"),
+		),
+	))
+	delete(b.userStates, userID)
+}
+
+// setNotice запоминает предупреждение, которое покажется над следующим шагом
+func (b *CarWashBot) setNotice(userID int64, notice string) {
+	state := b.userStates[userID]
+	state.Notice = notice
+	b.userStates[userID] = state
+}
+
+// backButton — строка с возвратом на шаг step и отменой записи
+func backButton(l *i18n.Localizer, step string) []tgbotapi.InlineKeyboardButton {
+	return tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(l.T("back."+step), "back_"+step),
+		tgbotapi.NewInlineKeyboardButtonData(l.T("button.abort"), "wizard_abort"),
+	)
+}
+
+// isAbort сообщает, что пользователь хочет прервать запись
+func isAbort(text string) bool {
+	text = strings.TrimSpace(text)
+	return strings.EqualFold(text, "/stop") || strings.EqualFold(text, "отмена") ||
+		i18n.Matches("button.abort", text)
+}
+
+// wizardExpired сообщает, что мастер записи брошен дольше timeout назад
+func wizardExpired(state models.UserState, now time.Time, timeout time.Duration) bool {
+	return timeout > 0 && !state.UpdatedAt.IsZero() && now.Sub(state.UpdatedAt) > timeout
+}
+
+// expireWizard молча сбрасывает брошенную запись, чтобы старые кнопки
+// и ввод не попадали в устаревшее состояние
+func (b *CarWashBot) expireWizard(userID int64) {
+	state, exists := b.userStates[userID]
+	if exists && wizardExpired(state, b.now(), b.cfg.Wizard) {
+		b.resetWizard(userID)
+	}
+}
+
+// resetWizard сбрасывает состояние записи и освобождает удержанное время
+func (b *CarWashBot) resetWizard(userID int64) {
+	if _, exists := b.userStates[userID]; !exists {
+		return
+	}
+	b.releaseHold(userID)
+	delete(b.userStates, userID)
+}
+
+// abortWizard прерывает запись на любом шаге. messageID — сообщение
+// с нажатой кнопкой «Отмена», 0 для команды /stop
+func (b *CarWashBot) abortWizard(chatID, userID int64, messageID int) {
+	state, exists := b.userStates[userID]
+	if !exists && messageID == 0 {
+		b.sendMessage(chatID, b.tr(userID).T("wizard.nothing"))
+		b.sendWelcomeMessage(chatID)
+		return
+	}
+
+	b.releaseHold(userID)
+	if state.MessageID == 0 {
+		state.MessageID = messageID
+	}
+	state.Notice = ""
+	b.userStates[userID] = state
+	b.finishWizard(chatID, userID, b.tr(userID).T("wizard.aborted"))
+}
+
+// handleWizardBack возвращает пользователя на предыдущий шаг записи
+func (b *CarWashBot) handleWizardBack(chatID, userID int64, step string) {
+	state, exists := b.userStates[userID]
+	if !exists {
+		b.sendWelcomeMessage(chatID)
+		return
+	}
+
+	if step == "confirm" {
+		if state.Draft == nil {
+			b.sendWelcomeMessage(chatID)
+			return
+		}
+		b.renderConfirmation(chatID, userID)
+		return
+	}
+
+	state.AwaitingLocation = false
+	state.AwaitingTime = false
+	state.AwaitingService = false
+	state.AwaitingCarInfo = false
+	state.AwaitingPromo = false
+	state.AwaitingConfirm = false
+	state.Draft = nil
+
+	switch step {
+	case "location":
+		// Выбирают другую мойку — удержанное время больше не нужно
+		b.releaseHold(userID)
+		state.AwaitingLocation = true
+		b.userStates[userID] = state
+		b.showLocationSelection(chatID, userID)
+	case "calendar":
+		// Выбирают другой день — удержанное время больше не нужно
+		b.releaseHold(userID)
+		b.userStates[userID] = state
+		month, err := time.ParseInLocation("02.01.2006", state.SelectedDate, time.Local)
+		if err != nil {
+			month = time.Now()
+		}
+		b.showCalendar(chatID, month, state.MessageID)
+	case "time":
+		state.AwaitingTime = true
+		b.userStates[userID] = state
+		b.showTimeSlots(chatID, userID, state.SelectedDate)
+	case "service":
+		state.AwaitingService = true
+		b.userStates[userID] = state
+		b.showServiceSelection(chatID, userID)
+	case "car":
+		b.userStates[userID] = state
+		b.showCarSelection(chatID, userID)
+	case "promo":
+		state.AwaitingPromo = true
+		b.userStates[userID] = state
+		b.askPromoCode(chatID, userID)
+	default:
+		log.Printf("Неизвестный шаг записи: %s", step)
+	}
+}
+
+// slotLabel подписывает кнопку времени: «09:00 · 2/3» или «🔴 09:00»
+func slotLabel(slot string, free, total int) string {
+	if free <= 0 {
+		return "🔴 " + slot
+	}
+	return fmt.Sprintf("%s · %d/%d", slot, free, total)
+}
+
+// customerID возвращает ID пользователя в базе по Telegram ID или 0
+func (b *CarWashBot) customerID(userID int64) int64 {
+	user, err := b.storage.GetUserByTelegramID(context.Background(), userID)
+	if err != nil || user == nil {
+		log.Printf("Ошибка получения пользователя: %v", err)
+		return 0
+	}
+	return user.ID
+}
+
+// holdSlot удерживает время на выбранной мойке за пользователем на время
+// оформления записи
+func (b *CarWashBot) holdSlot(userID int64, date, clock string) error {
+	user, err := b.storage.GetUserByTelegramID(context.Background(), userID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return fmt.Errorf("пользователь %d не найден", userID)
+	}
+	return b.bookings.Hold(context.Background(), user.ID, b.selectedLocation(userID), date, clock)
+}
+
+func (b *CarWashBot) releaseHold(userID int64) {
+	if id := b.customerID(userID); id != 0 {
+		if err := b.bookings.ReleaseHold(context.Background(), id); err != nil {
+			log.Printf("Ошибка снятия удержания: %v", err)
+		}
+	}
+}
+
+// isSlotError сообщает, что на выбранное время больше нельзя записаться:
+// пост заняли, время прошло или день закрыли
+func isSlotError(err error) bool {
+	for _, target := range []error{
+		storage.ErrSlotTaken,
+		services.ErrPastSlot,
+		services.ErrUnknownSlot,
+		services.ErrInvalidDate,
+		services.ErrPastDay,
+		services.ErrBeyondHorizon,
+		services.ErrClosedDay,
+	} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
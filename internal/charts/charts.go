@@ -0,0 +1,105 @@
+// Package charts рисует простые PNG-графики средствами стандартной
+// библиотеки. Подписей на картинках нет, оси объясняются в подписи к фото
+package charts
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+)
+
+const (
+	barsWidth  = 720
+	barsHeight = 320
+	padding    = 16
+	cellSize   = 32
+	cellGap    = 2
+)
+
+var (
+	background = color.RGBA{R: 255, G: 255, B: 255, A: 255}
+	axis       = color.RGBA{R: 160, G: 160, B: 160, A: 255}
+	bar        = color.RGBA{R: 52, G: 120, B: 246, A: 255}
+	empty      = color.RGBA{R: 238, G: 238, B: 238, A: 255}
+)
+
+// Bars рисует столбчатую диаграмму: по столбцу на значение слева направо
+func Bars(values []int) ([]byte, error) {
+	img := image.NewRGBA(image.Rect(0, 0, barsWidth, barsHeight))
+	draw.Draw(img, img.Bounds(), &image.Uniform{background}, image.Point{}, draw.Src)
+
+	bottom := barsHeight - padding
+	fill(img, image.Rect(padding, bottom, barsWidth-padding, bottom+1), axis)
+
+	peak := maxValue(values)
+	if len(values) > 0 && peak > 0 {
+		step := (barsWidth - 2*padding) / len(values)
+		width := max(step*3/4, 1)
+		for i, v := range values {
+			height := v * (bottom - padding) / peak
+			left := padding + i*step + (step-width)/2
+			fill(img, image.Rect(left, bottom-height, left+width, bottom), bar)
+		}
+	}
+	return encode(img)
+}
+
+// Heatmap рисует таблицу grid[строка][столбец]: чем больше значение,
+// тем насыщеннее клетка, нули — серые
+func Heatmap(grid [][]int) ([]byte, error) {
+	columns := 0
+	peak := 0
+	for _, row := range grid {
+		columns = max(columns, len(row))
+		peak = max(peak, maxValue(row))
+	}
+
+	width := 2*padding + max(columns, 1)*cellSize
+	height := 2*padding + max(len(grid), 1)*cellSize
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), &image.Uniform{background}, image.Point{}, draw.Src)
+
+	for r, row := range grid {
+		for c, v := range row {
+			x := padding + c*cellSize
+			y := padding + r*cellSize
+			fill(img, image.Rect(x, y, x+cellSize-cellGap, y+cellSize-cellGap), shade(v, peak))
+		}
+	}
+	return encode(img)
+}
+
+// shade смешивает белый с цветом столбцов пропорционально v/peak
+func shade(v, peak int) color.RGBA {
+	if v <= 0 || peak <= 0 {
+		return empty
+	}
+	// Даже одна запись должна быть заметна
+	ratio := 0.2 + 0.8*float64(v)/float64(peak)
+	mix := func(from, to uint8) uint8 {
+		return uint8(float64(from) + (float64(to)-float64(from))*ratio)
+	}
+	return color.RGBA{R: mix(255, bar.R), G: mix(255, bar.G), B: mix(255, bar.B), A: 255}
+}
+
+func fill(img *image.RGBA, rect image.Rectangle, c color.RGBA) {
+	draw.Draw(img, rect, &image.Uniform{c}, image.Point{}, draw.Src)
+}
+
+func maxValue(values []int) int {
+	peak := 0
+	for _, v := range values {
+		peak = max(peak, v)
+	}
+	return peak
+}
+
+func encode(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,34 @@
+package export
+
+import (
+	"encoding/csv"
+	"io"
+)
+
+// WriteCSV записывает таблицу в CSV так, как его ожидает Excel с русской
+// локалью: UTF-8 с BOM и точка с запятой в качестве разделителя
+func WriteCSV(w io.Writer, table *Table) error {
+	if _, err := io.WriteString(w, "\ufeff"); err != nil {
+		return err
+	}
+
+	cw := csv.NewWriter(w)
+	cw.Comma = ';'
+	cw.UseCRLF = true
+	if err := cw.Write(table.Header); err != nil {
+		return err
+	}
+
+	record := make([]string, len(table.Header))
+	for _, row := range table.Rows {
+		record = record[:0]
+		for _, v := range row {
+			record = append(record, cellText(v))
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,157 @@
+// Package export выгружает записи и клиентов в CSV и XLSX для бухгалтерии
+package export
+
+import (
+	"carwash-bot/config"
+	"carwash-bot/internal/i18n"
+	"carwash-bot/internal/models"
+	"carwash-bot/internal/storage"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Форматы выгрузки
+const (
+	FormatCSV  = "csv"
+	FormatXLSX = "xlsx"
+)
+
+// Table — лист выгрузки. Ячейки — string или int, числа в XLSX
+// записываются числами, чтобы их можно было суммировать
+type Table struct {
+	Name   string
+	Header []string
+	Rows   [][]any
+}
+
+// Store — выборки, которые нужны для выгрузки
+type Store interface {
+	storage.ScheduleRepository
+	storage.LocationRepository
+}
+
+type Exporter struct {
+	storage Store
+	cfg     *config.Config
+}
+
+func New(store Store, cfg *config.Config) *Exporter {
+	return &Exporter{storage: store, cfg: cfg}
+}
+
+// Bookings выгружает записи с from по to включительно. Если в сети
+// несколько моек, добавляется колонка с мойкой
+func (e *Exporter) Bookings(ctx context.Context, from, to time.Time, filter models.ScheduleFilter) (*Table, error) {
+	bookings, err := e.storage.GetBookingsInPeriod(ctx, from, to, filter)
+	if err != nil {
+		return nil, err
+	}
+	locations, err := e.locationNames(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	table := &Table{
+		Name: "Записи",
+		Header: []string{"Дата", "Время", "Пост", "Статус", "Услуга", "Стоимость, ₽",
+			"Марка", "Номер", "Клиент", "Username", "Телефон", "Telegram ID", "ID записи"},
+	}
+	if len(locations) > 1 {
+		table.Header = append(table.Header, "Мойка")
+	}
+	// Выгрузка для бухгалтерии всегда на основном языке
+	statuses := i18n.New(i18n.Default)
+	for _, b := range bookings {
+		row := []any{
+			b.Date, b.Time, b.Bay, statuses.Status(b.Status), e.serviceName(b.Service), b.Price,
+			b.CarModel, b.CarNumber, b.User.FirstName, b.User.Username, b.User.Phone, b.User.TelegramID, b.ID,
+		}
+		if len(locations) > 1 {
+			row = append(row, locations[b.LocationID])
+		}
+		table.Rows = append(table.Rows, row)
+	}
+	return table, nil
+}
+
+// locationNames возвращает названия моек сети по ID
+func (e *Exporter) locationNames(ctx context.Context) (map[int64]string, error) {
+	locations, err := e.storage.GetLocations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	names := map[int64]string{0: "Основная мойка"}
+	if e.cfg.Address != "" {
+		names[0] = e.cfg.Address
+	}
+	for _, location := range locations {
+		names[location.ID] = location.Name
+	}
+	return names, nil
+}
+
+// Customers выгружает всех клиентов с итогами по записям
+func (e *Exporter) Customers(ctx context.Context) (*Table, error) {
+	customers, err := e.storage.GetCustomers(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	table := &Table{
+		Name: "Клиенты",
+		Header: []string{"Telegram ID", "Имя", "Фамилия", "Username", "Телефон", "Язык", "Зарегистрирован",
+			"Записей", "Выполнено", "Оплачено, ₽", "Последний визит", "Поздних отмен", "Неявок"},
+	}
+	for _, c := range customers {
+		lastVisit := ""
+		if !c.LastVisit.IsZero() {
+			lastVisit = c.LastVisit.Format("02.01.2006")
+		}
+		table.Rows = append(table.Rows, []any{
+			c.User.TelegramID, c.User.FirstName, c.User.LastName, c.User.Username, c.User.Phone, c.User.Language,
+			c.User.CreatedAt.Format("02.01.2006"), c.Bookings, c.Completed, c.Spent, lastVisit,
+			c.User.LateCancels, c.User.NoShows,
+		})
+	}
+	return table, nil
+}
+
+func (e *Exporter) serviceName(code string) string {
+	if service, ok := e.cfg.GetService(code); ok {
+		return service.Name
+	}
+	return code
+}
+
+// Write записывает таблицу в формате format
+func Write(w io.Writer, format string, table *Table) error {
+	switch strings.ToLower(format) {
+	case FormatCSV:
+		return WriteCSV(w, table)
+	case FormatXLSX:
+		return WriteXLSX(w, table)
+	}
+	return fmt.Errorf("неизвестный формат: %s", format)
+}
+
+// FileName — имя файла выгрузки, например «bookings_01.10.2026-31.10.2026.xlsx»
+func FileName(kind, period, format string) string {
+	if period == "" {
+		return kind + "." + format
+	}
+	return kind + "_" + period + "." + format
+}
+
+func cellText(v any) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case nil:
+		return ""
+	}
+	return fmt.Sprint(v)
+}
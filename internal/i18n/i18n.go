@@ -0,0 +1,221 @@
+// Package i18n хранит переводы сообщений бота. Каталоги лежат в
+// locales/*.json и встраиваются в бинарник. Значение ключа — строка
+// для fmt.Sprintf или объект с формами множественного числа
+// (one, few, many, other). Отсутствующие ключи берутся из русского каталога
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+)
+
+// Default — язык по умолчанию и источник недостающих переводов
+const Default = "ru"
+
+// Languages — поддерживаемые языки в порядке показа в настройках
+var Languages = []string{"ru", "en", "kk", "uz"}
+
+//go:embed locales/*.json
+var localeFS embed.FS
+
+var catalogs = mustLoad()
+
+type message struct {
+	text  string
+	forms map[string]string
+}
+
+func (m *message) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &m.text); err == nil {
+		return nil
+	}
+	return json.Unmarshal(data, &m.forms)
+}
+
+func mustLoad() map[string]map[string]message {
+	result := make(map[string]map[string]message)
+	for _, lang := range Languages {
+		data, err := localeFS.ReadFile(path.Join("locales", lang+".json"))
+		if err != nil {
+			panic(fmt.Sprintf("i18n: каталог %s: %v", lang, err))
+		}
+		catalog := make(map[string]message)
+		if err := json.Unmarshal(data, &catalog); err != nil {
+			panic(fmt.Sprintf("i18n: каталог %s: %v", lang, err))
+		}
+		result[lang] = catalog
+	}
+	return result
+}
+
+// Supported сообщает, есть ли каталог для языка
+func Supported(lang string) bool {
+	_, ok := catalogs[lang]
+	return ok
+}
+
+// Detect выбирает язык по language_code из Telegram, например «en-US».
+// Неизвестные языки получают язык по умолчанию
+func Detect(code string) string {
+	lang, _, _ := strings.Cut(strings.ToLower(code), "-")
+	if Supported(lang) {
+		return lang
+	}
+	return Default
+}
+
+// Matches сообщает, что text — перевод ключа key на любой из языков.
+// Нужен для кнопок обычной клавиатуры, которые приходят как текст
+func Matches(key, text string) bool {
+	for _, lang := range Languages {
+		if m, ok := catalogs[lang][key]; ok && m.text == text {
+			return true
+		}
+	}
+	return false
+}
+
+// Localizer переводит сообщения на язык пользователя
+type Localizer struct {
+	Lang string
+}
+
+// New возвращает переводчик; для неизвестного языка — на язык по умолчанию
+func New(lang string) *Localizer {
+	if !Supported(lang) {
+		lang = Default
+	}
+	return &Localizer{Lang: lang}
+}
+
+func (l *Localizer) lookup(key string) (message, bool) {
+	if m, ok := catalogs[l.Lang][key]; ok {
+		return m, true
+	}
+	m, ok := catalogs[Default][key]
+	return m, ok
+}
+
+// IsLabel сообщает, что text — перевод одного из ключей с префиксом
+// prefix на язык переводчика, например любой кнопки меню «menu.»
+func (l *Localizer) IsLabel(prefix, text string) bool {
+	for _, lang := range []string{l.Lang, Default} {
+		for key, m := range catalogs[lang] {
+			if strings.HasPrefix(key, prefix) && m.text == text {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// T возвращает перевод ключа, подставляя args через fmt.Sprintf
+func (l *Localizer) T(key string, args ...any) string {
+	m, ok := l.lookup(key)
+	if !ok {
+		return key
+	}
+	return format(m.text, args)
+}
+
+// N возвращает форму перевода для числа n. n подставляется первым аргументом
+func (l *Localizer) N(key string, n int, args ...any) string {
+	m, ok := l.lookup(key)
+	if !ok {
+		return key
+	}
+
+	text := m.forms[PluralForm(l.Lang, n)]
+	if text == "" {
+		text = m.forms["other"]
+	}
+	return format(text, append([]any{n}, args...))
+}
+
+func format(text string, args []any) string {
+	if len(args) == 0 {
+		return text
+	}
+	return fmt.Sprintf(text, args...)
+}
+
+// PluralForm возвращает категорию множественного числа по правилам CLDR
+func PluralForm(lang string, n int) string {
+	if n < 0 {
+		n = -n
+	}
+
+	switch lang {
+	case "ru":
+		mod10, mod100 := n%10, n%100
+		switch {
+		case mod10 == 1 && mod100 != 11:
+			return "one"
+		case mod10 >= 2 && mod10 <= 4 && (mod100 < 12 || mod100 > 14):
+			return "few"
+		default:
+			return "many"
+		}
+	}
+
+	if n == 1 {
+		return "one"
+	}
+	return "other"
+}
+
+// currencySigns — знаки валют, которые пишутся после суммы
+var currencySigns = map[string]string{
+	"":    "₽",
+	"RUB": "₽",
+	"KZT": "₸",
+	"USD": "$",
+	"EUR": "€",
+}
+
+// Money форматирует сумму в валюте с кодом ISO 4217, например «400 ₽».
+// Валюта без знака выводится кодом: «400 UZS»
+func Money(amount int, currency string) string {
+	if sign, ok := currencySigns[currency]; ok {
+		return fmt.Sprintf("%d %s", amount, sign)
+	}
+	return fmt.Sprintf("%d %s", amount, currency)
+}
+
+// Name возвращает название языка на нём самом, например «English»
+func Name(lang string) string {
+	return New(lang).T("language.name")
+}
+
+// Weekday возвращает название дня недели
+func (l *Localizer) Weekday(day time.Weekday) string {
+	return l.T(fmt.Sprintf("weekday.%d", day))
+}
+
+// WeekdayShort возвращает сокращённое название дня недели для календаря
+func (l *Localizer) WeekdayShort(day time.Weekday) string {
+	return l.T(fmt.Sprintf("weekday_short.%d", day))
+}
+
+// Month возвращает название месяца, например для заголовка календаря
+func (l *Localizer) Month(month time.Month) string {
+	return l.T(fmt.Sprintf("month.%d", month))
+}
+
+// Status возвращает название статуса записи. Неизвестный статус
+// возвращается как есть
+func (l *Localizer) Status(status string) string {
+	if _, ok := l.lookup("status." + status); !ok {
+		return status
+	}
+	return l.T("status." + status)
+}
+
+// Date форматирует дату с днём недели, например «Пятница, 7 марта»
+func (l *Localizer) Date(t time.Time) string {
+	return l.T("date.long", l.Weekday(t.Weekday()), t.Day(), l.T(fmt.Sprintf("month_of.%d", t.Month())))
+}
@@ -0,0 +1,64 @@
+package i18n
+
+import (
+	"regexp"
+	"slices"
+	"testing"
+)
+
+var verbs = regexp.MustCompile(`%(\[\d+\])?[-+# 0]*\d*(\.\d+)?[a-zA-Z%]`)
+
+// TestCatalogsComplete проверяет, что в каждом каталоге есть все ключи
+// русского и переводы ждут те же аргументы
+func TestCatalogsComplete(t *testing.T) {
+	for _, lang := range Languages[1:] {
+		for key, want := range catalogs[Default] {
+			got, ok := catalogs[lang][key]
+			if !ok {
+				t.Errorf("%s: нет перевода %q", lang, key)
+				continue
+			}
+			if want.forms != nil {
+				continue
+			}
+			if w, g := verbList(want.text), verbList(got.text); !slices.Equal(w, g) {
+				t.Errorf("%s %q: аргументы %v, в русском %v", lang, key, g, w)
+			}
+		}
+	}
+}
+
+func verbList(text string) []string {
+	list := verbs.FindAllString(text, -1)
+	slices.Sort(list)
+	return list
+}
+
+func TestMoney(t *testing.T) {
+	for _, tc := range []struct {
+		currency, want string
+	}{
+		{"RUB", "400 ₽"},
+		{"", "400 ₽"},
+		{"KZT", "400 ₸"},
+		{"UZS", "400 UZS"},
+	} {
+		if got := Money(400, tc.currency); got != tc.want {
+			t.Errorf("Money(400, %q) = %q; нужно %q", tc.currency, got, tc.want)
+		}
+	}
+}
+
+func TestStatus(t *testing.T) {
+	for _, tc := range []struct {
+		lang, status, want string
+	}{
+		{"ru", "no_show", "Неявка"},
+		{"en", "awaiting_payment", "Awaiting payment"},
+		{"en", "archived", "archived"},
+	} {
+		if got := New(tc.lang).Status(tc.status); got != tc.want {
+			t.Errorf("New(%q).Status(%q) = %q; нужно %q", tc.lang, tc.status, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package ical
+
+import (
+	"carwash-bot/config"
+	"carwash-bot/internal/models"
+	"time"
+)
+
+// BookingEvent заполняет время, UID и статус события по записи. Название,
+// описание и напоминание вызывающий задаёт сам
+func BookingEvent(booking *models.Booking) (Event, error) {
+	start, err := time.ParseInLocation("02.01.2006 15:04", booking.Date+" "+booking.Time, time.Local)
+	if err != nil {
+		return Event{}, err
+	}
+
+	e := Event{
+		// UID не меняется при переносе, поэтому календарь обновит событие,
+		// а не создаст второе
+		UID:   booking.ID + "@carwash-bot",
+		Start: start,
+		End:   start.Add(config.SlotLength),
+		Stamp: booking.CreatedAt,
+	}
+	if booking.Status == models.StatusCancelled || booking.Status == models.StatusExpired {
+		e.Cancelled = true
+		e.Sequence = 1
+		if !booking.CancelledAt.IsZero() {
+			e.Stamp = booking.CancelledAt
+		}
+	}
+	return e, nil
+}
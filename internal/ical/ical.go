@@ -0,0 +1,135 @@
+// Package ical формирует календари iCalendar (RFC 5545): файлы .ics
+// для клиентов и ленту записей для календарей сотрудников
+package ical
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Длина строки в октетах, после которой строка переносится
+const lineLimit = 75
+
+const prodID = "-//carwash-bot//RU"
+
+type Event struct {
+	UID         string
+	Start       time.Time
+	End         time.Time
+	Summary     string
+	Description string
+	Location    string
+	Alarm       time.Duration // За сколько до начала напомнить, 0 — без напоминания
+	Cancelled   bool
+	Sequence    int // Растёт при каждом изменении события
+	Stamp       time.Time
+}
+
+// Calendar возвращает календарь с событиями. name — название календаря
+// в приложении, для одиночного файла может быть пустым
+func Calendar(name string, events ...Event) []byte {
+	var sb strings.Builder
+	writeLine(&sb, "BEGIN:VCALENDAR")
+	writeLine(&sb, "VERSION:2.0")
+	writeLine(&sb, "PRODID:"+prodID)
+	writeLine(&sb, "CALSCALE:GREGORIAN")
+	writeLine(&sb, "METHOD:PUBLISH")
+	if name != "" {
+		writeLine(&sb, "X-WR-CALNAME:"+escape(name))
+	}
+
+	for _, e := range events {
+		writeEvent(&sb, e)
+	}
+
+	writeLine(&sb, "END:VCALENDAR")
+	return []byte(sb.String())
+}
+
+func writeEvent(sb *strings.Builder, e Event) {
+	stamp := e.Stamp
+	if stamp.IsZero() {
+		stamp = time.Now()
+	}
+
+	writeLine(sb, "BEGIN:VEVENT")
+	writeLine(sb, "UID:"+escape(e.UID))
+	writeLine(sb, "DTSTAMP:"+formatTime(stamp))
+	writeLine(sb, "DTSTART:"+formatTime(e.Start))
+	writeLine(sb, "DTEND:"+formatTime(e.End))
+	writeLine(sb, fmt.Sprintf("SEQUENCE:%d", e.Sequence))
+	writeLine(sb, "SUMMARY:"+escape(e.Summary))
+	if e.Description != "" {
+		writeLine(sb, "DESCRIPTION:"+escape(e.Description))
+	}
+	if e.Location != "" {
+		writeLine(sb, "LOCATION:"+escape(e.Location))
+	}
+	if e.Cancelled {
+		writeLine(sb, "STATUS:CANCELLED")
+	} else {
+		writeLine(sb, "STATUS:CONFIRMED")
+	}
+
+	if e.Alarm > 0 && !e.Cancelled {
+		writeLine(sb, "BEGIN:VALARM")
+		writeLine(sb, "ACTION:DISPLAY")
+		writeLine(sb, "DESCRIPTION:"+escape(e.Summary))
+		writeLine(sb, "TRIGGER:-"+formatDuration(e.Alarm))
+		writeLine(sb, "END:VALARM")
+	}
+	writeLine(sb, "END:VEVENT")
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format("20060102T150405Z")
+}
+
+// formatDuration записывает длительность в виде PT1H30M
+func formatDuration(d time.Duration) string {
+	minutes := int(d.Round(time.Minute).Minutes())
+	if minutes <= 0 {
+		return "PT0M"
+	}
+	s := "PT"
+	if minutes >= 60 {
+		s += fmt.Sprintf("%dH", minutes/60)
+	}
+	if minutes%60 != 0 {
+		s += fmt.Sprintf("%dM", minutes%60)
+	}
+	return s
+}
+
+// escape экранирует текстовое значение свойства
+func escape(s string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+	).Replace(s)
+}
+
+// writeLine пишет строку с переносом по 75 октетов, не разрывая
+// многобайтные символы, и завершает её CRLF
+func writeLine(sb *strings.Builder, line string) {
+	limit := lineLimit
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !isRuneStart(line[cut]) {
+			cut--
+		}
+		sb.WriteString(line[:cut] + "\r\n ")
+		line = line[cut:]
+		// Пробел в начале продолжения тоже занимает октет
+		limit = lineLimit - 1
+	}
+	sb.WriteString(line + "\r\n")
+}
+
+func isRuneStart(b byte) bool {
+	return b&0xC0 != 0x80
+}
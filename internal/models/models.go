@@ -11,30 +11,142 @@ type Booking struct {
 	Time      string    `json:"time" db:"time"` // Формат: "15:00"
 	CarModel  string    `json:"car_model" db:"car_model"`
 	CarNumber string    `json:"car_number" db:"car_number"`
+	Service   string    `json:"service" db:"service"` // Код услуги из каталога
+	Price     int       `json:"price" db:"price"`     // Стоимость в рублях
 	CreatedAt time.Time `json:"created_at" db:"created_at"`
 
+	// Запись за счёт организации, 0 — личная запись
+	OrganizationID int64 `json:"organization_id" db:"organization_id"`
+
+	Status     string `json:"status" db:"status"`
+	Discount   int    `json:"discount" db:"discount"`       // Скидка в рублях, уже учтённая в Price
+	PointsUsed int    `json:"points_used" db:"points_used"` // Списанные бонусные баллы
+	PromoCode  string `json:"promo_code,omitempty" db:"promo_code"`
+
+	// Срок оплаты для записей в статусе StatusAwaitingPayment
+	PaymentDeadline time.Time `json:"payment_deadline,omitempty" db:"payment_deadline"`
+
+	CancelledAt time.Time `json:"cancelled_at,omitempty" db:"cancelled_at"`
+	LateCancel  bool      `json:"late_cancel,omitempty" db:"late_cancel"` // Отменена позже срока бесплатной отмены
+
+	Bay int `json:"bay" db:"bay"` // Номер моечного поста
+
+	// Мойка сети, 0 — основная мойка из настроек
+	LocationID int64 `json:"location_id" db:"location_id"`
+
 	// Дополнительные поля для JOIN-запросов
 	User *User `json:"user,omitempty" db:"-"`
 }
 
+// Статусы записи
+const (
+	StatusActive          = "active"
+	StatusCompleted       = "completed"
+	StatusAwaitingPayment = "awaiting_payment"
+	StatusExpired         = "expired" // Не оплачена вовремя, слот освобождён
+	StatusPendingApproval = "pending_approval"
+	StatusCancelled       = "cancelled"
+	StatusNoShow          = "no_show"
+)
+
 type User struct {
 	ID         int64     `json:"id" db:"id"`
 	TelegramID int64     `json:"telegram_id" db:"telegram_id"`
 	Username   string    `json:"username" db:"username"`
 	FirstName  string    `json:"first_name" db:"first_name"`
 	LastName   string    `json:"last_name" db:"last_name"`
+	Language   string    `json:"language" db:"language"` // Код языка интерфейса, например «ru»
+	Phone      string    `json:"phone,omitempty" db:"phone"`
 	CreatedAt  time.Time `json:"created_at" db:"created_at"`
 
+	// Нарушения правил записи
+	LateCancels int `json:"late_cancels" db:"late_cancels"`
+	NoShows     int `json:"no_shows" db:"no_shows"`
+
 	// Дополнительные отношения
 	Bookings []*Booking `json:"bookings,omitempty" db:"-"`
 }
 
 type UserState struct {
+	AwaitingLocation bool  `json:"awaiting_location"`
+	SelectedLocation int64 `json:"selected_location"` // Мойка сети, 0 — основная
+
 	AwaitingDay     bool   `json:"awaiting_day"`
 	AwaitingTime    bool   `json:"awaiting_time"`
+	AwaitingService bool   `json:"awaiting_service"`
 	AwaitingCarInfo bool   `json:"awaiting_car_info"`
+	AwaitingPromo   bool   `json:"awaiting_promo"`
 	SelectedDate    string `json:"selected_date"`
 	SelectedTime    string `json:"selected_time"`
+	SelectedService string `json:"selected_service"`
+	CarModel        string `json:"car_model"`
+	CarNumber       string `json:"car_number"`
+
+	MessageID int    `json:"message_id"`       // Сообщение мастера записи, которое редактируется на каждом шаге
+	Notice    string `json:"notice,omitempty"` // Предупреждение для следующего шага, например «время занято»
+
+	AwaitingConfirm bool     `json:"awaiting_confirm"`
+	Draft           *Booking `json:"draft,omitempty"` // Запись, которую пользователь подтверждает
+
+	UpdatedAt time.Time `json:"updated_at"` // Когда мастер записи последний раз показывал шаг
+}
+
+// Location - мойка сети. Основная мойка с ID 0 в базе не хранится:
+// её адрес, часы работы и количество постов задаются настройками
+type Location struct {
+	ID        int64     `json:"id" db:"id"`
+	Name      string    `json:"name" db:"name"`
+	Address   string    `json:"address" db:"address"`
+	Latitude  float64   `json:"latitude" db:"latitude"`
+	Longitude float64   `json:"longitude" db:"longitude"`
+	StartTime int       `json:"start_time" db:"start_time"` // Час первого слота
+	EndTime   int       `json:"end_time" db:"end_time"`     // Час последнего слота
+	Bays      int       `json:"bays" db:"bays"`
+	Services  []string  `json:"services,omitempty" db:"services"` // Коды услуг, пусто — весь каталог
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+
+	// Telegram ID администраторов мойки, заполняется при выборке
+	Admins []int64 `json:"admins,omitempty" db:"-"`
+}
+
+// HasCoordinates сообщает, что у мойки указано место на карте
+func (l *Location) HasCoordinates() bool {
+	return l.Latitude != 0 || l.Longitude != 0
+}
+
+// Роли участников организации
+const (
+	RoleManager = "manager"
+	RoleDriver  = "driver"
+)
+
+// Organization - корпоративный клиент (таксопарк, служба доставки)
+type Organization struct {
+	ID        int64     `json:"id" db:"id"`
+	Name      string    `json:"name" db:"name"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+
+	// Дополнительные отношения
+	Members  []*OrganizationMember `json:"members,omitempty" db:"-"`
+	Vehicles []*Vehicle            `json:"vehicles,omitempty" db:"-"`
+}
+
+type OrganizationMember struct {
+	OrganizationID int64  `json:"organization_id" db:"organization_id"`
+	TelegramID     int64  `json:"telegram_id" db:"telegram_id"`
+	Role           string `json:"role" db:"role"`
+
+	// Дополнительные поля для JOIN-запросов
+	Organization *Organization `json:"organization,omitempty" db:"-"`
+}
+
+// Vehicle - автомобиль, принадлежащий организации
+type Vehicle struct {
+	ID             int64     `json:"id" db:"id"`
+	OrganizationID int64     `json:"organization_id" db:"organization_id"`
+	CarModel       string    `json:"car_model" db:"car_model"`
+	CarNumber      string    `json:"car_number" db:"car_number"`
+	CreatedAt      time.Time `json:"created_at" db:"created_at"`
 }
 
 type TimeSlot struct {
@@ -44,3 +156,191 @@ type TimeSlot struct {
 	CarModel  string `json:"car_model,omitempty"`
 	CarNumber string `json:"car_number,omitempty"`
 }
+
+// Причины движения бонусных баллов
+const (
+	LoyaltyCashback = "cashback"
+	LoyaltyRedeem   = "redeem"
+	LoyaltyRefund   = "refund"
+	LoyaltyManual   = "manual"
+)
+
+// LoyaltyAccount - счётчик визитов и бонусный баланс клиента
+type LoyaltyAccount struct {
+	UserID    int64     `json:"user_id" db:"user_id"`
+	Visits    int       `json:"visits" db:"visits"`
+	Points    int       `json:"points" db:"points"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// LoyaltyTransaction - запись журнала начислений и списаний баллов
+type LoyaltyTransaction struct {
+	ID        int64     `json:"id" db:"id"`
+	UserID    int64     `json:"user_id" db:"user_id"`
+	Delta     int       `json:"delta" db:"delta"`
+	Reason    string    `json:"reason" db:"reason"`
+	Comment   string    `json:"comment" db:"comment"`
+	BookingID string    `json:"booking_id" db:"booking_id"`
+	AdminID   int64     `json:"admin_id" db:"admin_id"` // Кто изменил баланс вручную
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// PromoCode - промокод на скидку с ограничениями по времени и числу использований
+type PromoCode struct {
+	Code         string    `json:"code" db:"code"`
+	Percent      int       `json:"percent" db:"percent"`
+	ValidFrom    time.Time `json:"valid_from" db:"valid_from"`
+	ValidTo      time.Time `json:"valid_to" db:"valid_to"` // Включительно, до конца дня
+	MaxUses      int       `json:"max_uses" db:"max_uses"` // 0 — без ограничений
+	PerUserLimit int       `json:"per_user_limit" db:"per_user_limit"`
+	Services     []string  `json:"services,omitempty" db:"services"` // Пусто — все услуги
+	Weekdays     []int     `json:"weekdays,omitempty" db:"weekdays"` // 1 — понедельник, 7 — воскресенье
+	HourFrom     int       `json:"hour_from" db:"hour_from"`
+	HourTo       int       `json:"hour_to" db:"hour_to"` // Не включительно, 0 — до конца дня
+	Active       bool      `json:"active" db:"active"`
+	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+
+	// Количество использований, заполняется при выборке списка
+	Uses int `json:"uses" db:"-"`
+}
+
+// Статусы платежа
+const (
+	PaymentPending       = "pending"
+	PaymentPaid          = "paid"
+	PaymentCancelled     = "cancelled"
+	PaymentRefunded      = "refunded"
+	PaymentRefundPending = "refund_pending" // Возврат нужно провести вручную
+)
+
+// Payment - предоплата записи
+type Payment struct {
+	ID               string    `json:"id" db:"id"`
+	BookingID        string    `json:"booking_id" db:"booking_id"`
+	UserID           int64     `json:"user_id" db:"user_id"`
+	Amount           int       `json:"amount" db:"amount"` // в рублях
+	Currency         string    `json:"currency" db:"currency"`
+	Provider         string    `json:"provider" db:"provider"`
+	Status           string    `json:"status" db:"status"`
+	TelegramChargeID string    `json:"telegram_charge_id,omitempty" db:"telegram_charge_id"`
+	ProviderChargeID string    `json:"provider_charge_id,omitempty" db:"provider_charge_id"`
+	RefundedAmount   int       `json:"refunded_amount" db:"refunded_amount"`
+	CreatedAt        time.Time `json:"created_at" db:"created_at"`
+}
+
+// BookingLimits - ограничения на записи одного клиента. 0 — без ограничения
+type BookingLimits struct {
+	MaxActive int           `json:"max_active" db:"max_active"`   // Предстоящих записей одновременно
+	MaxPerDay int           `json:"max_per_day" db:"max_per_day"` // Записей на один день
+	MinGap    time.Duration `json:"min_gap" db:"min_gap_minutes"` // Между началом двух записей
+}
+
+// DayReport - итоги дня для вечернего отчёта администратору
+type DayReport struct {
+	Date        string         `json:"date"`
+	Completed   int            `json:"completed"`
+	NoShows     int            `json:"no_shows"`
+	Cancelled   int            `json:"cancelled"`
+	LateCancels int            `json:"late_cancels"` // Из отменённых — поздно
+	Unmarked    int            `json:"unmarked"`     // Записи, которые так и не отметили выполненными
+	Revenue     int            `json:"revenue"`      // Выручка по выполненным мойкам
+	Services    []ServiceStats `json:"services"`
+	Bays        map[int]int    `json:"bays"` // Записей по постам, кроме отменённых
+}
+
+// ServiceStats - выполненные мойки и выручка по услуге
+type ServiceStats struct {
+	Service string `json:"service"`
+	Count   int    `json:"count"`
+	Revenue int    `json:"revenue"`
+}
+
+// Stats - показатели мойки за период для владельца
+type Stats struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"` // Включительно
+
+	Bookings    int `json:"bookings"` // Все записи, кроме неоплаченных вовремя
+	Completed   int `json:"completed"`
+	Cancelled   int `json:"cancelled"`
+	LateCancels int `json:"late_cancels"`
+	NoShows     int `json:"no_shows"`
+	Revenue     int `json:"revenue"` // По выполненным мойкам
+
+	// Записи без отменённых: по дням (02.01.2006) и по дню недели и часу
+	PerDay  map[string]int `json:"per_day"`
+	Heatmap [7][24]int     `json:"heatmap"`
+
+	LeadTime time.Duration `json:"lead_time"` // Среднее время от записи до мойки
+
+	NewCustomers       int `json:"new_customers"` // Впервые приехали в этом периоде
+	ReturningCustomers int `json:"returning_customers"`
+
+	TopServices []ServiceStats `json:"top_services"`
+}
+
+// CustomerSummary - клиент с итогами по записям для выгрузки
+type CustomerSummary struct {
+	User      User      `json:"user"`
+	Bookings  int       `json:"bookings"` // Кроме неоплаченных вовремя
+	Completed int       `json:"completed"`
+	Spent     int       `json:"spent"`      // Оплачено за выполненные мойки
+	LastVisit time.Time `json:"last_visit"` // Нулевое, если клиент ещё не приезжал
+}
+
+// ScheduleFilter - отбор записей для расписания. Пустые поля не ограничивают выборку
+type ScheduleFilter struct {
+	Bay     int    `json:"bay,omitempty"`
+	Service string `json:"service,omitempty"`
+	Status  string `json:"status,omitempty"` // Без статуса — все неотменённые записи
+
+	// Мойки, записи которых попадают в выборку, nil — все мойки сети
+	Locations []int64 `json:"locations,omitempty"`
+}
+
+// BlockedUser - Telegram ID, которому запрещено записываться
+type BlockedUser struct {
+	TelegramID int64     `json:"telegram_id" db:"telegram_id"`
+	Reason     string    `json:"reason" db:"reason"`
+	AdminID    int64     `json:"admin_id" db:"admin_id"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+}
+
+// Статусы сообщений в очереди отправки
+const (
+	OutboxPending = "pending"
+	OutboxSending = "sending" // Сообщение отправляется, next_attempt_at — до какого времени
+	OutboxSent    = "sent"
+	OutboxDead    = "dead" // Попытки исчерпаны, нужен разбор администратором
+)
+
+// Шаблоны сообщений, которые администратор может изменить
+const (
+	TemplateWelcome      = "welcome"
+	TemplateConfirmation = "confirmation"
+	TemplateReminder     = "reminder"
+	TemplateAdminBooking = "admin_booking"
+)
+
+// MessageTemplate - текст сообщения в формате text/template
+type MessageTemplate struct {
+	Name      string    `json:"name" db:"name"`
+	Lang      string    `json:"lang" db:"lang"`
+	Body      string    `json:"body" db:"body"`
+	UpdatedBy int64     `json:"updated_by" db:"updated_by"` // Telegram ID администратора
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// OutboxMessage - сообщение, доставку которого бот повторяет до успеха
+type OutboxMessage struct {
+	ID            int64     `json:"id" db:"id"`
+	ChatID        int64     `json:"chat_id" db:"chat_id"`
+	Text          string    `json:"text" db:"text"`
+	ParseMode     string    `json:"parse_mode,omitempty" db:"parse_mode"`
+	ReplyMarkup   string    `json:"reply_markup,omitempty" db:"reply_markup"` // JSON клавиатуры
+	Status        string    `json:"status" db:"status"`
+	Attempts      int       `json:"attempts" db:"attempts"`
+	LastError     string    `json:"last_error,omitempty" db:"last_error"`
+	NextAttemptAt time.Time `json:"next_attempt_at" db:"next_attempt_at"`
+	CreatedAt     time.Time `json:"created_at" db:"created_at"`
+}
@@ -0,0 +1,76 @@
+package payments
+
+import (
+	"carwash-bot/internal/models"
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// Fake - тестовый провайдер без реальных денег. Оплата проводится
+// кнопкой в чате, возвраты сохраняются в памяти
+type Fake struct {
+	mu      sync.Mutex
+	charges map[string]int // chargeID -> сумма
+	refunds map[string]int // chargeID -> возвращённая сумма
+
+	// FailCharges и FailRefunds позволяют проверить обработку ошибок
+	FailCharges bool
+	FailRefunds bool
+}
+
+func NewFake() *Fake {
+	return &Fake{
+		charges: make(map[string]int),
+		refunds: make(map[string]int),
+	}
+}
+
+func (f *Fake) Name() string {
+	return "fake"
+}
+
+func (f *Fake) Token() string {
+	return ""
+}
+
+func (f *Fake) Charge(ctx context.Context, payment *models.Payment) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.FailCharges {
+		return "", errors.New("тестовый провайдер: оплата отклонена")
+	}
+
+	chargeID := "fake-" + uuid.New().String()
+	f.charges[chargeID] = payment.Amount
+	return chargeID, nil
+}
+
+func (f *Fake) Refund(ctx context.Context, payment *models.Payment, amount int) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.FailRefunds {
+		return errors.New("тестовый провайдер: возврат отклонён")
+	}
+
+	// После перезапуска бота история платежей теряется, поэтому сумму
+	// проверяем только для известных платежей
+	charged, ok := f.charges[payment.ProviderChargeID]
+	if ok && f.refunds[payment.ProviderChargeID]+amount > charged {
+		return errors.New("тестовый провайдер: сумма возврата больше оплаты")
+	}
+
+	f.refunds[payment.ProviderChargeID] += amount
+	return nil
+}
+
+// Refunded возвращает сумму, возвращённую по платежу
+func (f *Fake) Refunded(chargeID string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.refunds[chargeID]
+}
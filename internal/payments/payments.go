@@ -0,0 +1,48 @@
+package payments
+
+import (
+	"carwash-bot/internal/models"
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrManualRefund означает, что провайдер не умеет возвращать деньги
+// автоматически и возврат нужно провести вручную в кабинете провайдера
+var ErrManualRefund = errors.New("возврат выполняется вручную")
+
+// Provider - платёжный провайдер для предоплаты записей
+type Provider interface {
+	// Name - идентификатор провайдера, сохраняется вместе с платежом
+	Name() string
+
+	// Token - provider_token для счетов Telegram. Пустой токен означает,
+	// что счёт оплачивается без Telegram (см. OfflineCharger)
+	Token() string
+
+	// Refund возвращает клиенту amount рублей по оплаченному платежу
+	Refund(ctx context.Context, payment *models.Payment, amount int) error
+}
+
+// OfflineCharger реализуют провайдеры, которые проводят оплату сами,
+// без счёта Telegram. Бот вызывает Charge вместо отправки invoice
+type OfflineCharger interface {
+	Charge(ctx context.Context, payment *models.Payment) (chargeID string, err error)
+}
+
+// New создаёт провайдера по имени из конфигурации.
+// Пустое имя отключает предоплату
+func New(name, token string) (Provider, error) {
+	switch name {
+	case "":
+		return nil, nil
+	case "telegram":
+		if token == "" {
+			return nil, errors.New("для провайдера telegram нужен PAYMENT_PROVIDER_TOKEN")
+		}
+		return NewTelegram(token), nil
+	case "fake":
+		return NewFake(), nil
+	}
+	return nil, fmt.Errorf("неизвестный платёжный провайдер %q", name)
+}
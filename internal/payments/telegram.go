@@ -0,0 +1,29 @@
+package payments
+
+import (
+	"carwash-bot/internal/models"
+	"context"
+)
+
+// Telegram - оплата через Telegram Payments с токеном платёжного провайдера
+type Telegram struct {
+	token string
+}
+
+func NewTelegram(token string) *Telegram {
+	return &Telegram{token: token}
+}
+
+func (t *Telegram) Name() string {
+	return "telegram"
+}
+
+func (t *Telegram) Token() string {
+	return t.token
+}
+
+// Refund - Bot API не умеет возвращать платежи картой, поэтому возврат
+// только фиксируется и проводится администратором у провайдера
+func (t *Telegram) Refund(ctx context.Context, payment *models.Payment, amount int) error {
+	return ErrManualRefund
+}
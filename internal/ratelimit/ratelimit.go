@@ -0,0 +1,109 @@
+// Package ratelimit реализует token bucket с отдельной корзиной на каждый
+// ключ (пользователя, чат)
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Сколько корзин держать в памяти, прежде чем удалять заполненные
+const pruneThreshold = 1024
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+type Limiter struct {
+	mu      sync.Mutex
+	rate    float64 // Токенов в секунду
+	burst   float64
+	buckets map[int64]*bucket
+
+	// Now возвращает текущее время, в тестах его можно подменить
+	Now func() time.Time
+}
+
+// New создаёт ограничитель на n событий за период per с запасом burst
+func New(n int, per time.Duration, burst int) *Limiter {
+	return &Limiter{
+		rate:    float64(n) / per.Seconds(),
+		burst:   float64(max(burst, 1)),
+		buckets: make(map[int64]*bucket),
+		Now:     time.Now,
+	}
+}
+
+// Allow забирает токен, если он есть, и сообщает, разрешено ли событие
+func (l *Limiter) Allow(key int64) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b := l.refill(key)
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// Reserve забирает токен в долг и возвращает, сколько нужно подождать
+// до события
+func (l *Limiter) Reserve(key int64) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b := l.refill(key)
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / l.rate * float64(time.Second))
+}
+
+// Wait ждёт, пока событие станет разрешено
+func (l *Limiter) Wait(ctx context.Context, key int64) error {
+	delay := l.Reserve(key)
+	if delay == 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// refill пополняет корзину ключа по прошедшему времени. Вызывать под мьютексом
+func (l *Limiter) refill(key int64) *bucket {
+	now := l.Now()
+	b, ok := l.buckets[key]
+	if !ok {
+		if len(l.buckets) >= pruneThreshold {
+			l.prune(now)
+		}
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+		return b
+	}
+
+	b.tokens = min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+	return b
+}
+
+// prune удаляет корзины, которые успели заполниться: они ничем
+// не отличаются от новых
+func (l *Limiter) prune(now time.Time) {
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+// Package scheduler запускает задачи раз в день в заданное время
+package scheduler
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+type job struct {
+	name    string
+	hour    int
+	minute  int
+	run     func(now time.Time)
+	lastRun string // Дата последнего запуска в формате 02.01.2006
+}
+
+type Scheduler struct {
+	mu   sync.Mutex
+	jobs []*job
+
+	// Now возвращает текущее время, в тестах его можно подменить
+	Now func() time.Time
+}
+
+func New() *Scheduler {
+	return &Scheduler{Now: time.Now}
+}
+
+// Daily добавляет задачу, которая выполняется каждый день в at («08:30»).
+// Если сегодня это время уже прошло, первый запуск будет завтра: после
+// перезапуска бота задачи не повторяются
+func (s *Scheduler) Daily(name, at string, run func(now time.Time)) error {
+	clock, err := time.Parse("15:04", at)
+	if err != nil {
+		return fmt.Errorf("неверное время задачи %s: %q", name, at)
+	}
+
+	j := &job{name: name, hour: clock.Hour(), minute: clock.Minute(), run: run}
+	now := s.Now()
+	if !now.Before(j.due(now)) {
+		j.lastRun = now.Format("02.01.2006")
+	}
+
+	s.mu.Lock()
+	s.jobs = append(s.jobs, j)
+	s.mu.Unlock()
+	return nil
+}
+
+// due возвращает время запуска задачи в день now
+func (j *job) due(now time.Time) time.Time {
+	return time.Date(now.Year(), now.Month(), now.Day(), j.hour, j.minute, 0, 0, now.Location())
+}
+
+// Tick выполняет задачи, время которых наступило и которые сегодня
+// ещё не запускались
+func (s *Scheduler) Tick() {
+	now := s.Now()
+	today := now.Format("02.01.2006")
+
+	s.mu.Lock()
+	var ready []*job
+	for _, j := range s.jobs {
+		if j.lastRun != today && !now.Before(j.due(now)) {
+			j.lastRun = today
+			ready = append(ready, j)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, j := range ready {
+		log.Printf("Запуск задачи: %s", j.name)
+		j.run(now)
+	}
+}
+
+// Run проверяет задачи каждые interval. Не возвращается
+func (s *Scheduler) Run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		s.Tick()
+	}
+}
@@ -0,0 +1,375 @@
+// Package services содержит правила записи на мойку, общие для бота и
+// HTTP API
+package services
+
+import (
+	"carwash-bot/config"
+	"carwash-bot/internal/models"
+	"carwash-bot/internal/storage"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// Ошибки правил записи. Занятость поста хранилище сообщает своей
+// ошибкой storage.ErrSlotTaken, лимиты клиента — storage.ErrTooManyActive и др.
+var (
+	ErrInvalidDate     = errors.New("дата должна быть в формате ДД.ММ.ГГГГ")
+	ErrPastDay         = errors.New("этот день уже прошёл")
+	ErrBeyondHorizon   = errors.New("на этот день запись ещё не открыта")
+	ErrClosedDay       = errors.New("в этот день мойка не работает")
+	ErrUnknownSlot     = errors.New("на это время записи нет")
+	ErrPastSlot        = errors.New("это время уже прошло")
+	ErrUnknownService  = errors.New("неизвестная услуга")
+	ErrUnknownLocation = errors.New("мойка не найдена")
+	ErrNotUpcoming     = errors.New("запись уже выполнена или отменена")
+	ErrStarted         = errors.New("мойка уже началась")
+	ErrNotStarted      = errors.New("мойка ещё не началась")
+	ErrLateCancel      = errors.New("срок бесплатной отмены прошёл")
+)
+
+// BookingService отвечает за доступность времени, проверку, создание,
+// отмену и перенос записей. Часы работы, посты и услуги у каждой мойки
+// сети свои, выходные и горизонт записи — общие
+type BookingService struct {
+	repo storage.BookingRepository
+	cfg  *config.Config
+
+	// Now возвращает текущее время, в тестах его можно подменить
+	Now func() time.Time
+}
+
+func NewBookingService(repo storage.BookingRepository, cfg *config.Config) *BookingService {
+	return &BookingService{repo: repo, cfg: cfg, Now: time.Now}
+}
+
+// Slot — время начала слота и число свободных постов на него
+type Slot struct {
+	Time string
+	Free int
+}
+
+// Location возвращает мойку по ID. Мойка 0 — основная, она описана
+// в настройках
+func (s *BookingService) Location(ctx context.Context, locationID int64) (*models.Location, error) {
+	if locationID == 0 {
+		return s.mainLocation(), nil
+	}
+	location, err := s.repo.GetLocation(ctx, locationID)
+	if err != nil {
+		return nil, err
+	}
+	if location == nil {
+		return nil, ErrUnknownLocation
+	}
+	return location, nil
+}
+
+// Locations возвращает основную мойку и остальные мойки сети
+func (s *BookingService) Locations(ctx context.Context) ([]*models.Location, error) {
+	locations, err := s.repo.GetLocations(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return append([]*models.Location{s.mainLocation()}, locations...), nil
+}
+
+func (s *BookingService) mainLocation() *models.Location {
+	return &models.Location{
+		Address:   s.cfg.Address,
+		Latitude:  s.cfg.Latitude,
+		Longitude: s.cfg.Longitude,
+		StartTime: s.cfg.StartTime,
+		EndTime:   s.cfg.EndTime,
+		Bays:      s.cfg.Bays,
+	}
+}
+
+// Bays возвращает количество моечных постов мойки
+func (s *BookingService) Bays(location *models.Location) int {
+	return max(location.Bays, 1)
+}
+
+// SlotTimes возвращает время начала всех слотов рабочего дня мойки
+func (s *BookingService) SlotTimes(location *models.Location) []string {
+	var times []string
+	for hour := location.StartTime; hour <= location.EndTime; hour++ {
+		times = append(times, fmt.Sprintf("%02d:00", hour))
+	}
+	return times
+}
+
+// Services возвращает услуги каталога, которые оказывает мойка
+func (s *BookingService) Services(location *models.Location) []config.Service {
+	if len(location.Services) == 0 {
+		return s.cfg.Services
+	}
+	var services []config.Service
+	for _, service := range s.cfg.Services {
+		if slices.Contains(location.Services, service.Code) {
+			services = append(services, service)
+		}
+	}
+	return services
+}
+
+// OffersService сообщает, что услуга есть в каталоге и мойка её оказывает
+func (s *BookingService) OffersService(location *models.Location, code string) bool {
+	if _, ok := s.cfg.GetService(code); !ok {
+		return false
+	}
+	return len(location.Services) == 0 || slices.Contains(location.Services, code)
+}
+
+// Window возвращает первый и последний день, на которые можно записаться
+func (s *BookingService) Window() (time.Time, time.Time) {
+	now := s.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	return today, today.AddDate(0, 0, max(s.cfg.Horizon, 1)-1)
+}
+
+// ValidateDay проверяет, что на день date можно записаться. Выходные
+// общие для сети, поэтому мойка записи не нужна
+func (s *BookingService) ValidateDay(date string) (time.Time, error) {
+	day, err := time.ParseInLocation("02.01.2006", date, time.Local)
+	if err != nil {
+		return time.Time{}, ErrInvalidDate
+	}
+
+	first, last := s.Window()
+	switch {
+	case day.Before(first):
+		return day, ErrPastDay
+	case day.After(last):
+		return day, ErrBeyondHorizon
+	case s.cfg.IsClosed(day):
+		return day, ErrClosedDay
+	}
+	return day, nil
+}
+
+// ValidateSlot проверяет день и время записи на мойку. Свободен ли пост,
+// проверяет хранилище при удержании и сохранении
+func (s *BookingService) ValidateSlot(location *models.Location, date, clock string) error {
+	if _, err := s.ValidateDay(date); err != nil {
+		return err
+	}
+	if !slices.Contains(s.SlotTimes(location), clock) {
+		return ErrUnknownSlot
+	}
+	start, err := time.ParseInLocation("02.01.2006 15:04", date+" "+clock, time.Local)
+	if err != nil || !start.After(s.Now()) {
+		return ErrPastSlot
+	}
+	return nil
+}
+
+// DaySlots возвращает ещё не прошедшие слоты дня на мойке со свободными
+// постами. Удержание пользователя exceptUserID не считается занятым
+func (s *BookingService) DaySlots(ctx context.Context, location *models.Location, date string, exceptUserID int64) ([]Slot, error) {
+	occupancy, err := s.repo.GetDayOccupancy(ctx, location.ID, date, exceptUserID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.Now()
+	var slots []Slot
+	for _, clock := range s.SlotTimes(location) {
+		start, err := time.ParseInLocation("02.01.2006 15:04", date+" "+clock, time.Local)
+		if err != nil || start.Before(now) {
+			continue
+		}
+		slots = append(slots, Slot{Time: clock, Free: max(s.Bays(location)-occupancy[clock], 0)})
+	}
+	return slots, nil
+}
+
+// FreeSlots считает слоты дня на мойке, в которых ещё есть свободный пост
+// и которые не прошли. occupancy — занятые посты по времени
+func (s *BookingService) FreeSlots(location *models.Location, date time.Time, occupancy map[string]int) int {
+	now := s.Now()
+	free := 0
+	for _, clock := range s.SlotTimes(location) {
+		start, err := time.ParseInLocation("02.01.2006 15:04", date.Format("02.01.2006")+" "+clock, time.Local)
+		if err != nil || start.Before(now) || occupancy[clock] >= s.Bays(location) {
+			continue
+		}
+		free++
+	}
+	return free
+}
+
+// DayOccupancy возвращает занятые посты мойки по времени на день
+func (s *BookingService) DayOccupancy(ctx context.Context, location *models.Location, date string) (map[string]int, error) {
+	return s.repo.GetDayOccupancy(ctx, location.ID, date, 0)
+}
+
+// MonthOccupancy возвращает занятые посты мойки по дням и времени за месяц
+func (s *BookingService) MonthOccupancy(ctx context.Context, location *models.Location, month time.Month, year int) (map[string]map[string]int, error) {
+	return s.repo.GetMonthOccupancy(ctx, location.ID, month, year, 0)
+}
+
+// IsTimeAvailable сообщает, что на время можно записаться и свободный
+// пост на мойке есть
+func (s *BookingService) IsTimeAvailable(ctx context.Context, location *models.Location, date, clock string) (bool, error) {
+	if err := s.ValidateSlot(location, date, clock); err != nil {
+		return false, nil
+	}
+	occupancy, err := s.repo.GetDayOccupancy(ctx, location.ID, date, 0)
+	if err != nil {
+		return false, err
+	}
+	return occupancy[clock] < s.Bays(location), nil
+}
+
+// Hold удерживает время на мойке за пользователем на время оформления записи
+func (s *BookingService) Hold(ctx context.Context, userID int64, location *models.Location, date, clock string) error {
+	if err := s.ValidateSlot(location, date, clock); err != nil {
+		return err
+	}
+	return s.repo.HoldSlot(ctx, userID, location.ID, date, clock, s.Now().Add(s.cfg.Hold))
+}
+
+func (s *BookingService) ReleaseHold(ctx context.Context, userID int64) error {
+	return s.repo.ReleaseHold(ctx, userID)
+}
+
+// Create проверяет и сохраняет запись. Цену, скидку и статус задаёт
+// вызывающий: они зависят от промокода, баллов и предоплаты
+func (s *BookingService) Create(ctx context.Context, booking *models.Booking) error {
+	location, err := s.Location(ctx, booking.LocationID)
+	if err != nil {
+		return err
+	}
+	if err := s.ValidateSlot(location, booking.Date, booking.Time); err != nil {
+		return err
+	}
+	if !s.OffersService(location, booking.Service) {
+		return fmt.Errorf("%w: %s", ErrUnknownService, booking.Service)
+	}
+	return s.repo.CreateBooking(ctx, booking)
+}
+
+// CheckCancellation проверяет, можно ли отменить запись, и будет ли
+// отмена поздней
+func (s *BookingService) CheckCancellation(booking *models.Booking) (late bool, err error) {
+	start, err := s.checkUpcoming(booking)
+	if err != nil {
+		return false, err
+	}
+
+	// Неоплаченные и неподтверждённые записи отменяются без последствий
+	if booking.Status != models.StatusActive {
+		return false, nil
+	}
+	return start.Sub(s.Now()) < s.cfg.Cancel.FreeBefore, nil
+}
+
+// Cancel отменяет запись. Поздняя отмена выполняется только с
+// confirmLate, иначе возвращается ErrLateCancel, чтобы клиент мог
+// передумать. Поздняя отмена засчитывается клиенту как нарушение
+func (s *BookingService) Cancel(ctx context.Context, booking *models.Booking, confirmLate bool) (*models.Booking, error) {
+	late, err := s.CheckCancellation(booking)
+	if err != nil {
+		return nil, err
+	}
+	if late && !confirmLate {
+		return nil, ErrLateCancel
+	}
+	return s.repo.CancelBooking(ctx, booking.ID, booking.UserID, late)
+}
+
+// CancelByStaff отменяет запись по решению сотрудника. Такая отмена не
+// засчитывается клиенту как нарушение, даже если до мойки осталось мало
+// времени
+func (s *BookingService) CancelByStaff(ctx context.Context, booking *models.Booking) (*models.Booking, error) {
+	if _, err := s.checkUpcoming(booking); err != nil {
+		return nil, err
+	}
+	return s.repo.CancelBooking(ctx, booking.ID, booking.UserID, false)
+}
+
+// Reject отменяет неподтверждённую администратором запись без нарушения
+// для клиента
+func (s *BookingService) Reject(ctx context.Context, booking *models.Booking) (*models.Booking, error) {
+	if booking.Status != models.StatusPendingApproval {
+		return nil, ErrNotUpcoming
+	}
+	return s.repo.CancelBooking(ctx, booking.ID, booking.UserID, false)
+}
+
+// Reschedule переносит предстоящую запись на другое время на той же мойке
+func (s *BookingService) Reschedule(ctx context.Context, booking *models.Booking, date, clock string) (*models.Booking, error) {
+	if _, err := s.checkUpcoming(booking); err != nil {
+		return nil, err
+	}
+	if booking.Date == date && booking.Time == clock {
+		return booking, nil
+	}
+	location, err := s.Location(ctx, booking.LocationID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.ValidateSlot(location, date, clock); err != nil {
+		return nil, err
+	}
+	return s.repo.RescheduleBooking(ctx, booking.ID, date, clock)
+}
+
+// Approve подтверждает запись, ожидающую решения администратора
+func (s *BookingService) Approve(ctx context.Context, booking *models.Booking) (*models.Booking, error) {
+	if booking.Status != models.StatusPendingApproval {
+		return nil, ErrNotUpcoming
+	}
+	return s.repo.SetBookingStatus(ctx, booking.ID, models.StatusPendingApproval, models.StatusActive)
+}
+
+// Complete отмечает мойку выполненной и начисляет клиенту кэшбэк
+func (s *BookingService) Complete(ctx context.Context, booking *models.Booking) (*models.Booking, error) {
+	if booking.Status != models.StatusActive {
+		return nil, ErrNotUpcoming
+	}
+	return s.repo.CompleteBooking(ctx, booking.ID, s.Cashback(booking))
+}
+
+// Cashback считает баллы, начисляемые за выполненную мойку
+func (s *BookingService) Cashback(booking *models.Booking) int {
+	if s.cfg.Loyalty.Mode != config.LoyaltyCashback {
+		return 0
+	}
+	return booking.Price * s.cfg.Loyalty.CashbackPercent / 100
+}
+
+// MarkNoShow отмечает, что клиент не приехал. Отметить неявку можно
+// только после начала мойки, она засчитывается клиенту как нарушение
+func (s *BookingService) MarkNoShow(ctx context.Context, booking *models.Booking) (*models.Booking, error) {
+	if booking.Status != models.StatusActive {
+		return nil, ErrNotUpcoming
+	}
+	start, err := time.ParseInLocation("02.01.2006 15:04", booking.Date+" "+booking.Time, time.Local)
+	if err != nil {
+		return nil, err
+	}
+	if s.Now().Before(start) {
+		return nil, ErrNotStarted
+	}
+	return s.repo.MarkNoShow(ctx, booking.ID)
+}
+
+// checkUpcoming проверяет, что запись ещё предстоит, и возвращает время
+// её начала
+func (s *BookingService) checkUpcoming(booking *models.Booking) (time.Time, error) {
+	if !slices.Contains([]string{models.StatusActive, models.StatusAwaitingPayment, models.StatusPendingApproval}, booking.Status) {
+		return time.Time{}, ErrNotUpcoming
+	}
+	start, err := time.ParseInLocation("02.01.2006 15:04", booking.Date+" "+booking.Time, time.Local)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if !s.Now().Before(start) {
+		return time.Time{}, ErrStarted
+	}
+	return start, nil
+}
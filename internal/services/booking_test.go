@@ -0,0 +1,325 @@
+package services_test
+
+import (
+	"carwash-bot/config"
+	"carwash-bot/internal/models"
+	"carwash-bot/internal/services"
+	"carwash-bot/internal/storage"
+	"carwash-bot/internal/storage/memory"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// Часы в тестах стоят на понедельнике 04.03.2030. Удержания хранилище
+// в памяти сверяет с настоящим временем, поэтому дата взята в будущем
+var monday = time.Date(2030, time.March, 4, 8, 0, 0, 0, time.Local)
+
+type fixture struct {
+	service *services.BookingService
+	repo    *memory.Storage
+	now     time.Time
+}
+
+func newFixture(t *testing.T) *fixture {
+	t.Helper()
+	cfg := &config.Config{
+		StartTime: 9,
+		EndTime:   18,
+		Horizon:   14,
+		Bays:      1,
+		Hold:      10 * time.Minute,
+		Closed:    []int{7},
+		Services:  []config.Service{{Code: "express", Name: "Экспресс", Price: 400}},
+		Cancel:    config.CancellationConfig{FreeBefore: 2 * time.Hour},
+		Loyalty:   config.LoyaltyConfig{Mode: config.LoyaltyCashback, CashbackPercent: 5},
+	}
+	repo := memory.New()
+	repo.Bays = cfg.Bays
+
+	f := &fixture{repo: repo, now: monday}
+	f.service = services.NewBookingService(repo, cfg)
+	f.service.Now = func() time.Time { return f.now }
+	return f
+}
+
+// at переводит часы на время clock понедельника
+func (f *fixture) at(clock string) {
+	parsed, err := time.ParseInLocation("15:04", clock, time.Local)
+	if err != nil {
+		panic(err)
+	}
+	f.now = time.Date(monday.Year(), monday.Month(), monday.Day(), parsed.Hour(), parsed.Minute(), 0, 0, time.Local)
+}
+
+func (f *fixture) user(t *testing.T, telegramID int64) *models.User {
+	t.Helper()
+	ctx := context.Background()
+	if err := f.repo.CreateOrUpdateUser(ctx, &models.User{TelegramID: telegramID, FirstName: "Тест"}); err != nil {
+		t.Fatalf("CreateOrUpdateUser: %v", err)
+	}
+	user, err := f.repo.GetUserByTelegramID(ctx, telegramID)
+	if err != nil || user == nil {
+		t.Fatalf("GetUserByTelegramID(%d) = %v, %v", telegramID, user, err)
+	}
+	return user
+}
+
+func (f *fixture) book(t *testing.T, userID int64, date, clock, status string) *models.Booking {
+	t.Helper()
+	booking := &models.Booking{UserID: userID, Date: date, Time: clock, CarModel: "Kia", CarNumber: "A123BC",
+		Service: "express", Price: 400, Status: status}
+	if err := f.service.Create(context.Background(), booking); err != nil {
+		t.Fatalf("Create(%s %s): %v", date, clock, err)
+	}
+	return booking
+}
+
+func TestValidateDay(t *testing.T) {
+	f := newFixture(t)
+	tests := []struct {
+		date string
+		want error
+	}{
+		{"04.03.2030", nil},
+		{"16.03.2030", nil},
+		{"03.03.2030", services.ErrPastDay},
+		{"18.03.2030", services.ErrBeyondHorizon},
+		{"10.03.2030", services.ErrClosedDay},
+		{"2030-03-05", services.ErrInvalidDate},
+	}
+	for _, tt := range tests {
+		if _, err := f.service.ValidateDay(tt.date); !errors.Is(err, tt.want) {
+			t.Errorf("ValidateDay(%s) = %v; нужно %v", tt.date, err, tt.want)
+		}
+	}
+}
+
+func TestValidateSlot(t *testing.T) {
+	f := newFixture(t)
+	f.at("11:30")
+	main, _ := f.service.Location(context.Background(), 0)
+	early := &models.Location{ID: 1, StartTime: 7, EndTime: 12}
+
+	tests := []struct {
+		location *models.Location
+		date     string
+		clock    string
+		want     error
+	}{
+		{main, "04.03.2030", "12:00", nil},
+		{main, "04.03.2030", "11:00", services.ErrPastSlot},
+		{main, "05.03.2030", "08:00", services.ErrUnknownSlot},
+		{main, "05.03.2030", "19:00", services.ErrUnknownSlot},
+		{main, "05.03.2030", "10:30", services.ErrUnknownSlot},
+		{main, "10.03.2030", "10:00", services.ErrClosedDay},
+		{early, "05.03.2030", "08:00", nil},
+		{early, "05.03.2030", "13:00", services.ErrUnknownSlot},
+	}
+	for _, tt := range tests {
+		if err := f.service.ValidateSlot(tt.location, tt.date, tt.clock); !errors.Is(err, tt.want) {
+			t.Errorf("ValidateSlot(%d, %s %s) = %v; нужно %v", tt.location.ID, tt.date, tt.clock, err, tt.want)
+		}
+	}
+}
+
+func TestHoldAndCreateConflicts(t *testing.T) {
+	f := newFixture(t)
+	ctx := context.Background()
+	main, _ := f.service.Location(ctx, 0)
+	first, second := f.user(t, 1), f.user(t, 2)
+
+	if err := f.service.Hold(ctx, first.ID, main, "05.03.2030", "10:00"); err != nil {
+		t.Fatalf("Hold: %v", err)
+	}
+	if err := f.service.Hold(ctx, second.ID, main, "05.03.2030", "10:00"); !errors.Is(err, storage.ErrSlotTaken) {
+		t.Fatalf("Hold на удержанное время: %v; нужно ErrSlotTaken", err)
+	}
+	taken := &models.Booking{UserID: second.ID, Date: "05.03.2030", Time: "10:00", Service: "express"}
+	if err := f.service.Create(ctx, taken); !errors.Is(err, storage.ErrSlotTaken) {
+		t.Fatalf("Create на удержанное время: %v; нужно ErrSlotTaken", err)
+	}
+
+	// Своё удержание записи не мешает, после записи время занято
+	f.book(t, first.ID, "05.03.2030", "10:00", "")
+	if err := f.service.ReleaseHold(ctx, second.ID); err != nil {
+		t.Fatalf("ReleaseHold: %v", err)
+	}
+	if err := f.service.Hold(ctx, second.ID, main, "05.03.2030", "10:00"); !errors.Is(err, storage.ErrSlotTaken) {
+		t.Fatalf("Hold на занятое время: %v; нужно ErrSlotTaken", err)
+	}
+
+	if err := f.service.Hold(ctx, second.ID, main, "04.03.2030", "07:00"); !errors.Is(err, services.ErrUnknownSlot) {
+		t.Fatalf("Hold вне часов работы: %v; нужно ErrUnknownSlot", err)
+	}
+	unknown := &models.Booking{UserID: second.ID, Date: "05.03.2030", Time: "11:00", Service: "polish"}
+	if err := f.service.Create(ctx, unknown); !errors.Is(err, services.ErrUnknownService) {
+		t.Fatalf("Create с неизвестной услугой: %v; нужно ErrUnknownService", err)
+	}
+	past := &models.Booking{UserID: second.ID, Date: "03.03.2030", Time: "11:00", Service: "express"}
+	if err := f.service.Create(ctx, past); !errors.Is(err, services.ErrPastDay) {
+		t.Fatalf("Create на прошедший день: %v; нужно ErrPastDay", err)
+	}
+	elsewhere := &models.Booking{UserID: second.ID, LocationID: 404, Date: "05.03.2030", Time: "11:00",
+		Service: "express"}
+	if err := f.service.Create(ctx, elsewhere); !errors.Is(err, services.ErrUnknownLocation) {
+		t.Fatalf("Create на неизвестную мойку: %v; нужно ErrUnknownLocation", err)
+	}
+}
+
+func TestCancelLate(t *testing.T) {
+	f := newFixture(t)
+	ctx := context.Background()
+	user := f.user(t, 1)
+	booking := f.book(t, user.ID, "04.03.2030", "12:00", "")
+
+	// До мойки меньше FreeBefore: без подтверждения запись остаётся
+	f.at("10:30")
+	if _, err := f.service.Cancel(ctx, booking, false); !errors.Is(err, services.ErrLateCancel) {
+		t.Fatalf("Cancel без подтверждения: %v; нужно ErrLateCancel", err)
+	}
+	if got, _ := f.repo.GetBooking(ctx, booking.ID); got.Status != models.StatusActive {
+		t.Fatalf("запись после отказа от отмены = %s; нужно active", got.Status)
+	}
+
+	cancelled, err := f.service.Cancel(ctx, booking, true)
+	if err != nil {
+		t.Fatalf("Cancel с подтверждением: %v", err)
+	}
+	if cancelled.Status != models.StatusCancelled || !cancelled.LateCancel {
+		t.Fatalf("отменённая запись = %+v; нужна поздняя отмена", cancelled)
+	}
+	if got, _ := f.repo.GetUserByID(ctx, user.ID); got.LateCancels != 1 {
+		t.Fatalf("поздних отмен = %d; нужно 1", got.LateCancels)
+	}
+}
+
+func TestCancelFree(t *testing.T) {
+	f := newFixture(t)
+	ctx := context.Background()
+	user := f.user(t, 1)
+	active := f.book(t, user.ID, "04.03.2030", "12:00", "")
+	unpaid := f.book(t, user.ID, "04.03.2030", "11:00", models.StatusAwaitingPayment)
+	started := f.book(t, user.ID, "04.03.2030", "09:00", "")
+
+	// Ровно за FreeBefore отмена ещё бесплатная
+	f.at("10:00")
+	cancelled, err := f.service.Cancel(ctx, active, false)
+	if err != nil || cancelled.LateCancel {
+		t.Fatalf("Cancel за два часа = %+v, %v; нужна бесплатная отмена", cancelled, err)
+	}
+
+	// Неоплаченная запись отменяется без последствий даже перед самой мойкой
+	if late, err := f.service.CheckCancellation(unpaid); err != nil || late {
+		t.Fatalf("CheckCancellation неоплаченной = %v, %v; нужна бесплатная отмена", late, err)
+	}
+	if _, err := f.service.Cancel(ctx, unpaid, false); err != nil {
+		t.Fatalf("Cancel неоплаченной: %v", err)
+	}
+	if got, _ := f.repo.GetUserByID(ctx, user.ID); got.LateCancels != 0 {
+		t.Fatalf("поздних отмен = %d; бесплатные отмены не засчитываются", got.LateCancels)
+	}
+
+	if _, err := f.service.Cancel(ctx, started, true); !errors.Is(err, services.ErrStarted) {
+		t.Fatalf("Cancel начавшейся мойки: %v; нужно ErrStarted", err)
+	}
+	if _, err := f.service.Cancel(ctx, cancelled, true); !errors.Is(err, services.ErrNotUpcoming) {
+		t.Fatalf("Cancel отменённой записи: %v; нужно ErrNotUpcoming", err)
+	}
+}
+
+func TestReject(t *testing.T) {
+	f := newFixture(t)
+	ctx := context.Background()
+	user := f.user(t, 1)
+	pending := f.book(t, user.ID, "04.03.2030", "10:00", models.StatusPendingApproval)
+	active := f.book(t, user.ID, "04.03.2030", "11:00", "")
+
+	f.at("09:30")
+	rejected, err := f.service.Reject(ctx, pending)
+	if err != nil || rejected.Status != models.StatusCancelled || rejected.LateCancel {
+		t.Fatalf("Reject = %+v, %v; нужна отмена без нарушения", rejected, err)
+	}
+	if got, _ := f.repo.GetUserByID(ctx, user.ID); got.LateCancels != 0 {
+		t.Fatalf("поздних отмен = %d; отклонение не засчитывается", got.LateCancels)
+	}
+	if _, err := f.service.Reject(ctx, active); !errors.Is(err, services.ErrNotUpcoming) {
+		t.Fatalf("Reject подтверждённой записи: %v; нужно ErrNotUpcoming", err)
+	}
+}
+
+func TestReschedule(t *testing.T) {
+	f := newFixture(t)
+	ctx := context.Background()
+	user := f.user(t, 1)
+	booking := f.book(t, user.ID, "05.03.2030", "10:00", "")
+	f.book(t, f.user(t, 2).ID, "05.03.2030", "12:00", "")
+
+	if same, err := f.service.Reschedule(ctx, booking, "05.03.2030", "10:00"); err != nil || same.Time != "10:00" {
+		t.Fatalf("Reschedule на то же время = %+v, %v", same, err)
+	}
+	if _, err := f.service.Reschedule(ctx, booking, "05.03.2030", "12:00"); !errors.Is(err, storage.ErrSlotTaken) {
+		t.Fatalf("Reschedule на занятое время: %v; нужно ErrSlotTaken", err)
+	}
+	if _, err := f.service.Reschedule(ctx, booking, "10.03.2030", "12:00"); !errors.Is(err, services.ErrClosedDay) {
+		t.Fatalf("Reschedule на выходной: %v; нужно ErrClosedDay", err)
+	}
+	if _, err := f.service.Reschedule(ctx, booking, "04.03.2030", "07:00"); !errors.Is(err, services.ErrUnknownSlot) {
+		t.Fatalf("Reschedule вне часов работы: %v; нужно ErrUnknownSlot", err)
+	}
+
+	moved, err := f.service.Reschedule(ctx, booking, "06.03.2030", "15:00")
+	if err != nil || moved.Date != "06.03.2030" || moved.Time != "15:00" {
+		t.Fatalf("Reschedule = %+v, %v; нужно 06.03.2030 15:00", moved, err)
+	}
+	occupancy, _ := f.repo.GetDayOccupancy(ctx, 0, "05.03.2030", 0)
+	if occupancy["10:00"] != 0 {
+		t.Fatalf("после переноса старое время занято: %v", occupancy)
+	}
+
+	f.now = time.Date(2030, time.March, 6, 15, 30, 0, 0, time.Local)
+	if _, err := f.service.Reschedule(ctx, moved, "07.03.2030", "10:00"); !errors.Is(err, services.ErrStarted) {
+		t.Fatalf("Reschedule начавшейся мойки: %v; нужно ErrStarted", err)
+	}
+}
+
+func TestVisits(t *testing.T) {
+	f := newFixture(t)
+	ctx := context.Background()
+	user := f.user(t, 1)
+	pending := f.book(t, user.ID, "04.03.2030", "10:00", models.StatusPendingApproval)
+	missed := f.book(t, user.ID, "04.03.2030", "11:00", "")
+
+	approved, err := f.service.Approve(ctx, pending)
+	if err != nil || approved.Status != models.StatusActive {
+		t.Fatalf("Approve = %+v, %v; нужен статус active", approved, err)
+	}
+	if _, err := f.service.Approve(ctx, approved); !errors.Is(err, services.ErrNotUpcoming) {
+		t.Fatalf("повторное Approve: %v; нужно ErrNotUpcoming", err)
+	}
+
+	f.at("10:30")
+	if _, err := f.service.MarkNoShow(ctx, missed); !errors.Is(err, services.ErrNotStarted) {
+		t.Fatalf("MarkNoShow до начала: %v; нужно ErrNotStarted", err)
+	}
+	if cashback := f.service.Cashback(approved); cashback != 20 {
+		t.Fatalf("Cashback = %d; нужно 5%% от 400", cashback)
+	}
+	completed, err := f.service.Complete(ctx, approved)
+	if err != nil || completed.Status != models.StatusCompleted {
+		t.Fatalf("Complete = %+v, %v; нужен статус completed", completed, err)
+	}
+	if _, err := f.service.Complete(ctx, completed); !errors.Is(err, services.ErrNotUpcoming) {
+		t.Fatalf("повторное Complete: %v; нужно ErrNotUpcoming", err)
+	}
+
+	f.at("11:00")
+	noShow, err := f.service.MarkNoShow(ctx, missed)
+	if err != nil || noShow.Status != models.StatusNoShow {
+		t.Fatalf("MarkNoShow = %+v, %v; нужен статус no_show", noShow, err)
+	}
+	if got, _ := f.repo.GetUserByID(ctx, user.ID); got.NoShows != 1 {
+		t.Fatalf("неявок = %d; нужно 1", got.NoShows)
+	}
+}
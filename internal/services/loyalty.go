@@ -0,0 +1,29 @@
+package services
+
+import (
+	"carwash-bot/config"
+	"carwash-bot/internal/models"
+	"carwash-bot/internal/storage"
+	"context"
+)
+
+// LoyaltyService следит, чтобы бесплатной оставалась только каждая N-я
+// мойка клиента. Бот и HTTP API пересчитывают её по одним правилам
+type LoyaltyService struct {
+	repo storage.LoyaltyRepository
+	cfg  *config.Config
+}
+
+func NewLoyaltyService(repo storage.LoyaltyRepository, cfg *config.Config) *LoyaltyService {
+	return &LoyaltyService{repo: repo, cfg: cfg}
+}
+
+// RecheckFreeVisits вызывается после отмены или истечения записи клиента:
+// порядковые номера остальных записей сдвигаются, и лишняя бесплатная
+// мойка снова стоит полную цену. Возвращает такие записи
+func (s *LoyaltyService) RecheckFreeVisits(ctx context.Context, userID int64) ([]*models.Booking, error) {
+	if s.cfg.Loyalty.Mode != config.LoyaltyNthFree || s.cfg.Loyalty.EveryNth <= 0 {
+		return nil, nil
+	}
+	return s.repo.RevokeFreeVisits(ctx, userID, s.cfg.Loyalty.EveryNth)
+}
@@ -0,0 +1,81 @@
+package services
+
+import (
+	"carwash-bot/config"
+	"carwash-bot/internal/models"
+	"carwash-bot/internal/payments"
+	"carwash-bot/internal/storage"
+	"context"
+	"errors"
+	"log"
+)
+
+// RefundService возвращает предоплату отменённых записей. Бот и HTTP API
+// возвращают её по одним правилам
+type RefundService struct {
+	repo     storage.PaymentRepository
+	provider payments.Provider // nil, если предоплата отключена
+	cfg      *config.Config
+}
+
+func NewRefundService(repo storage.PaymentRepository, provider payments.Provider, cfg *config.Config) *RefundService {
+	return &RefundService{repo: repo, provider: provider, cfg: cfg}
+}
+
+// Refund — возврат по платежу. Manual — провайдер не вернул деньги сам,
+// и вернуть их должен администратор
+type Refund struct {
+	Payment *models.Payment
+	Amount  int
+	Manual  bool
+}
+
+// RefundCancelled возвращает предоплату отменённой записи: полностью,
+// а после поздней отмены — LateRefundPercent процентов. nil без ошибки —
+// возвращать нечего
+func (s *RefundService) RefundCancelled(ctx context.Context, booking *models.Booking) (*Refund, error) {
+	percent := 100
+	if booking.LateCancel {
+		percent = s.cfg.Cancel.LateRefundPercent
+	}
+
+	payment, err := s.repo.GetBookingPayment(ctx, booking.ID)
+	if err != nil {
+		return nil, err
+	}
+	if payment == nil || payment.Status != models.PaymentPaid {
+		return nil, nil
+	}
+	return s.Refund(ctx, payment, min(payment.Amount*percent/100, payment.Amount-payment.RefundedAmount))
+}
+
+// Refund возвращает amount рублей по платежу. Если провайдер сменился
+// после оплаты или не умеет возвращать сам, возврат ждёт администратора
+func (s *RefundService) Refund(ctx context.Context, payment *models.Payment, amount int) (*Refund, error) {
+	if payment == nil || amount <= 0 {
+		return nil, nil
+	}
+
+	provider := s.provider
+	if provider == nil || provider.Name() != payment.Provider {
+		provider = nil
+	}
+
+	refund := &Refund{Payment: payment, Amount: amount, Manual: provider == nil}
+	if provider != nil {
+		err := provider.Refund(ctx, payment, amount)
+		if err != nil && !errors.Is(err, payments.ErrManualRefund) {
+			log.Printf("Ошибка возврата платежа %s: %v", payment.ID, err)
+		}
+		refund.Manual = err != nil
+	}
+
+	status := models.PaymentRefunded
+	if refund.Manual {
+		status = models.PaymentRefundPending
+	}
+	if err := s.repo.RecordRefund(ctx, payment.ID, amount, status); err != nil {
+		return refund, err
+	}
+	return refund, nil
+}
@@ -0,0 +1,50 @@
+package storage
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"time"
+)
+
+// CalendarFeedToken возвращает секретный токен ленты календаря для
+// telegramID, создавая его при необходимости. С reset выдаётся новый
+// токен, а старая ссылка перестаёт работать
+func (s *Storage) CalendarFeedToken(ctx context.Context, telegramID int64, reset bool) (string, error) {
+	if !reset {
+		var token string
+		err := s.DB.QueryRowContext(ctx, `
+		SELECT token FROM calendar_feeds WHERE telegram_id = ?`, telegramID).Scan(&token)
+		if err == nil {
+			return token, nil
+		}
+		if err != sql.ErrNoRows {
+			return "", err
+		}
+	}
+
+	raw := make([]byte, 20)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(raw)
+
+	_, err := s.DB.ExecContext(ctx, `
+	INSERT INTO calendar_feeds (token, telegram_id, created_at) VALUES (?, ?, ?)
+	ON CONFLICT(telegram_id) DO UPDATE SET token = excluded.token, created_at = excluded.created_at`,
+		token, telegramID, time.Now())
+	return token, err
+}
+
+// GetCalendarFeedOwner возвращает Telegram ID владельца ленты, 0 — если
+// такого токена нет
+func (s *Storage) GetCalendarFeedOwner(ctx context.Context, token string) (int64, error) {
+	var telegramID int64
+	err := s.DB.QueryRowContext(ctx, `
+	SELECT telegram_id FROM calendar_feeds WHERE token = ?`, token).Scan(&telegramID)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return telegramID, err
+}
@@ -0,0 +1,109 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"strings"
+)
+
+// DB — подключение к SQLite или PostgreSQL. Запросы хранилища пишутся
+// с плейсхолдерами «?», для PostgreSQL они переписываются в $1, $2, …
+type DB struct {
+	*sql.DB
+	postgres bool
+}
+
+func (db *DB) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	query, args = bind(db.postgres, query, args)
+	return db.DB.ExecContext(ctx, query, args...)
+}
+
+func (db *DB) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	query, args = bind(db.postgres, query, args)
+	return db.DB.QueryContext(ctx, query, args...)
+}
+
+func (db *DB) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	query, args = bind(db.postgres, query, args)
+	return db.DB.QueryRowContext(ctx, query, args...)
+}
+
+func (db *DB) Exec(query string, args ...any) (sql.Result, error) {
+	return db.ExecContext(context.Background(), query, args...)
+}
+
+func (db *DB) Query(query string, args ...any) (*sql.Rows, error) {
+	return db.QueryContext(context.Background(), query, args...)
+}
+
+func (db *DB) QueryRow(query string, args ...any) *sql.Row {
+	return db.QueryRowContext(context.Background(), query, args...)
+}
+
+func (db *DB) BeginTx(ctx context.Context, opts *sql.TxOptions) (*Tx, error) {
+	tx, err := db.DB.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &Tx{Tx: tx, postgres: db.postgres}, nil
+}
+
+// Tx — транзакция с теми же правилами плейсхолдеров, что и DB
+type Tx struct {
+	*sql.Tx
+	postgres bool
+}
+
+func (tx *Tx) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	query, args = bind(tx.postgres, query, args)
+	return tx.Tx.ExecContext(ctx, query, args...)
+}
+
+func (tx *Tx) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	query, args = bind(tx.postgres, query, args)
+	return tx.Tx.QueryContext(ctx, query, args...)
+}
+
+func (tx *Tx) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	query, args = bind(tx.postgres, query, args)
+	return tx.Tx.QueryRowContext(ctx, query, args...)
+}
+
+// bind готовит запрос и аргументы для PostgreSQL: нумерует плейсхолдеры
+// вне строковых литералов и передаёт флаги числами, потому что колонки
+// флагов, как и в SQLite, имеют тип INTEGER
+func bind(postgres bool, query string, args []any) (string, []any) {
+	if !postgres {
+		return query, args
+	}
+
+	var (
+		sb     strings.Builder
+		n      int
+		quoted bool
+	)
+	for _, r := range query {
+		switch {
+		case r == '\'':
+			quoted = !quoted
+		case r == '?' && !quoted:
+			n++
+			sb.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		sb.WriteRune(r)
+	}
+
+	bound := make([]any, len(args))
+	for i, arg := range args {
+		if flag, ok := arg.(bool); ok {
+			arg = 0
+			if flag {
+				arg = 1
+			}
+		}
+		bound[i] = arg
+	}
+	return sb.String(), bound
+}
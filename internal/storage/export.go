@@ -0,0 +1,76 @@
+package storage
+
+import (
+	"carwash-bot/internal/models"
+	"context"
+	"database/sql"
+	"time"
+)
+
+// GetBookingsInPeriod возвращает записи с from по to включительно вместе
+// с клиентами. Без статуса в фильтре возвращаются записи в любом статусе
+func (s *Storage) GetBookingsInPeriod(ctx context.Context, from, to time.Time, filter models.ScheduleFilter) ([]*models.Booking, error) {
+	query := `SELECT ` + scheduleColumns + `
+		FROM bookings b LEFT JOIN users u ON u.id = b.user_id
+		WHERE ` + bookingsIsoDate + ` BETWEEN ? AND ?`
+	args := []any{from.Format("2006-01-02"), to.Format("2006-01-02")}
+
+	if filter.Status != "" {
+		query += ` AND b.status = ?`
+		args = append(args, filter.Status)
+	}
+	if filter.Bay > 0 {
+		query += ` AND b.bay = ?`
+		args = append(args, filter.Bay)
+	}
+	if filter.Service != "" {
+		query += ` AND b.service = ?`
+		args = append(args, filter.Service)
+	}
+	clause, locationArgs := locationFilter("b.location_id", filter.Locations)
+	query += clause
+	args = append(args, locationArgs...)
+
+	rows, err := s.DB.QueryContext(ctx, query+` ORDER BY `+bookingsIsoDate+`, b.time, b.location_id, b.bay`, args...)
+	if err != nil {
+		return nil, err
+	}
+	return scanScheduleRows(rows)
+}
+
+// GetCustomers возвращает всех клиентов с итогами по их записям
+func (s *Storage) GetCustomers(ctx context.Context) ([]*models.CustomerSummary, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+	SELECT u.id, u.telegram_id, COALESCE(u.username, ''), COALESCE(u.first_name, ''),
+		COALESCE(u.last_name, ''), u.phone, u.language, u.created_at, u.late_cancels, u.no_shows,
+		COUNT(b.id),
+		COALESCE(SUM(CASE WHEN b.status = ? THEN 1 ELSE 0 END), 0),
+		COALESCE(SUM(CASE WHEN b.status = ? THEN b.price ELSE 0 END), 0),
+		MAX(CASE WHEN b.status = ? THEN `+bookingsIsoDate+` END)
+	FROM users u LEFT JOIN bookings b ON b.user_id = u.id AND b.status <> ?
+	GROUP BY u.id
+	ORDER BY u.id`,
+		models.StatusCompleted, models.StatusCompleted, models.StatusCompleted, models.StatusExpired)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var customers []*models.CustomerSummary
+	for rows.Next() {
+		var (
+			c         = &models.CustomerSummary{}
+			lastVisit sql.NullString
+		)
+		if err := rows.Scan(&c.User.ID, &c.User.TelegramID, &c.User.Username, &c.User.FirstName,
+			&c.User.LastName, &c.User.Phone, &c.User.Language, &c.User.CreatedAt, &c.User.LateCancels,
+			&c.User.NoShows, &c.Bookings, &c.Completed, &c.Spent, &lastVisit); err != nil {
+			return nil, err
+		}
+		if lastVisit.Valid {
+			c.LastVisit, _ = time.ParseInLocation("2006-01-02", lastVisit.String, time.Local)
+		}
+		customers = append(customers, c)
+	}
+	return customers, rows.Err()
+}
@@ -0,0 +1,55 @@
+package storage
+
+import (
+	"context"
+	"time"
+)
+
+// HoldSlot удерживает время на мойке за пользователем до until, пока он
+// подтверждает запись. Прежнее удержание пользователя снимается.
+// Возвращает ErrSlotTaken, если свободных постов на это время нет
+func (s *Storage) HoldSlot(ctx context.Context, userID, locationID int64, date, clock string, until time.Time) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Заодно убираем истёкшие удержания
+	if _, err = tx.ExecContext(ctx, `DELETE FROM slot_holds WHERE expires_at <= ?`, time.Now()); err != nil {
+		return err
+	}
+
+	if err = s.lockSlot(ctx, tx, locationID, date, clock); err != nil {
+		return err
+	}
+
+	bays, err := s.locationBays(ctx, tx, locationID)
+	if err != nil {
+		return err
+	}
+	load, err := s.slotLoad(ctx, tx, locationID, date, clock, userID)
+	if err != nil {
+		return err
+	}
+	if load >= bays {
+		return ErrSlotTaken
+	}
+
+	_, err = tx.ExecContext(ctx, `
+	INSERT INTO slot_holds (user_id, location_id, date, time, expires_at) VALUES (?, ?, ?, ?, ?)
+	ON CONFLICT(user_id) DO UPDATE SET
+		location_id = excluded.location_id, date = excluded.date, time = excluded.time,
+		expires_at = excluded.expires_at`,
+		userID, locationID, date, clock, until)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ReleaseHold снимает удержание времени пользователем
+func (s *Storage) ReleaseHold(ctx context.Context, userID int64) error {
+	_, err := s.DB.ExecContext(ctx, `DELETE FROM slot_holds WHERE user_id = ?`, userID)
+	return err
+}
@@ -0,0 +1,185 @@
+package storage
+
+import (
+	"carwash-bot/internal/models"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrUserBlocked     = errors.New("запись для вашего аккаунта закрыта администратором")
+	ErrTooManyActive   = errors.New("слишком много предстоящих записей")
+	ErrTooManyPerDay   = errors.New("слишком много записей на этот день")
+	ErrBookingTooClose = errors.New("записи слишком близко друг к другу")
+)
+
+// GetUserLimits возвращает ограничения для клиента и признак того,
+// что администратор задал их отдельно
+func (s *Storage) GetUserLimits(ctx context.Context, userID int64) (models.BookingLimits, bool, error) {
+	return s.userLimits(ctx, s.DB, userID)
+}
+
+func (s *Storage) userLimits(ctx context.Context, q queryRower, userID int64) (models.BookingLimits, bool, error) {
+	var (
+		limits models.BookingLimits
+		gap    int
+	)
+	err := q.QueryRowContext(ctx, `
+	SELECT max_active, max_per_day, min_gap_minutes FROM user_limits WHERE user_id = ?`, userID).Scan(
+		&limits.MaxActive, &limits.MaxPerDay, &gap)
+	if err == sql.ErrNoRows {
+		return s.Limits, false, nil
+	}
+	if err != nil {
+		return s.Limits, false, err
+	}
+	limits.MinGap = time.Duration(gap) * time.Minute
+	return limits, true, nil
+}
+
+func (s *Storage) SetUserLimits(ctx context.Context, userID int64, limits models.BookingLimits) error {
+	_, err := s.DB.ExecContext(ctx, `
+	INSERT INTO user_limits (user_id, max_active, max_per_day, min_gap_minutes)
+	VALUES (?, ?, ?, ?)
+	ON CONFLICT(user_id) DO UPDATE SET
+		max_active = excluded.max_active,
+		max_per_day = excluded.max_per_day,
+		min_gap_minutes = excluded.min_gap_minutes`,
+		userID, limits.MaxActive, limits.MaxPerDay, int(limits.MinGap/time.Minute))
+	return err
+}
+
+// ResetUserLimits возвращает клиенту ограничения по умолчанию
+func (s *Storage) ResetUserLimits(ctx context.Context, userID int64) error {
+	_, err := s.DB.ExecContext(ctx, `DELETE FROM user_limits WHERE user_id = ?`, userID)
+	return err
+}
+
+// checkBookingLimits проверяет блокировку и ограничения клиента перед
+// созданием записи. Корпоративные записи ограничениям не подлежат
+func (s *Storage) checkBookingLimits(ctx context.Context, tx *Tx, booking *models.Booking) error {
+	var blocked int
+	err := tx.QueryRowContext(ctx, `
+	SELECT COUNT(*) FROM blocked_users
+	WHERE telegram_id = (SELECT telegram_id FROM users WHERE id = ?)`, booking.UserID).Scan(&blocked)
+	if err != nil {
+		return err
+	}
+	if blocked > 0 {
+		return ErrUserBlocked
+	}
+
+	if booking.OrganizationID != 0 {
+		return nil
+	}
+
+	limits, _, err := s.userLimits(ctx, tx, booking.UserID)
+	if err != nil {
+		return err
+	}
+
+	start, err := time.ParseInLocation("02.01.2006 15:04", booking.Date+" "+booking.Time, time.Local)
+	if err != nil {
+		return err
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+	SELECT date, time FROM bookings
+	WHERE user_id = ? AND organization_id = 0 AND status IN `+upcomingStatuses, booking.UserID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	now := time.Now()
+	var active, sameDay int
+	for rows.Next() {
+		var date, clock string
+		if err := rows.Scan(&date, &clock); err != nil {
+			return err
+		}
+		at, err := time.ParseInLocation("02.01.2006 15:04", date+" "+clock, time.Local)
+		if err != nil || at.Before(now) {
+			// Прошедшие записи, которые не отметили выполненными, не считаются
+			continue
+		}
+
+		active++
+		if date == booking.Date {
+			sameDay++
+		}
+		if limits.MinGap > 0 && at.Sub(start).Abs() < limits.MinGap {
+			return fmt.Errorf("%w: между записями должно быть не меньше %d мин", ErrBookingTooClose,
+				int(limits.MinGap/time.Minute))
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if limits.MaxActive > 0 && active >= limits.MaxActive {
+		return fmt.Errorf("%w: можно иметь не больше %d", ErrTooManyActive, limits.MaxActive)
+	}
+	if limits.MaxPerDay > 0 && sameDay >= limits.MaxPerDay {
+		return fmt.Errorf("%w: можно не больше %d в день", ErrTooManyPerDay, limits.MaxPerDay)
+	}
+	return nil
+}
+
+func (s *Storage) BlockUser(ctx context.Context, blocked *models.BlockedUser) error {
+	blocked.CreatedAt = time.Now()
+	_, err := s.DB.ExecContext(ctx, `
+	INSERT INTO blocked_users (telegram_id, reason, admin_id, created_at)
+	VALUES (?, ?, ?, ?)
+	ON CONFLICT(telegram_id) DO UPDATE SET reason = excluded.reason, admin_id = excluded.admin_id`,
+		blocked.TelegramID, blocked.Reason, blocked.AdminID, blocked.CreatedAt)
+	return err
+}
+
+// UnblockUser снимает блокировку. Возвращает sql.ErrNoRows, если ID не был заблокирован
+func (s *Storage) UnblockUser(ctx context.Context, telegramID int64) error {
+	res, err := s.DB.ExecContext(ctx, `DELETE FROM blocked_users WHERE telegram_id = ?`, telegramID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (s *Storage) GetBlockedUser(ctx context.Context, telegramID int64) (*models.BlockedUser, error) {
+	var b models.BlockedUser
+	err := s.DB.QueryRowContext(ctx, `
+	SELECT telegram_id, reason, admin_id, created_at FROM blocked_users WHERE telegram_id = ?`, telegramID).Scan(
+		&b.TelegramID, &b.Reason, &b.AdminID, &b.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+func (s *Storage) GetBlockedUsers(ctx context.Context) ([]*models.BlockedUser, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+	SELECT telegram_id, reason, admin_id, created_at FROM blocked_users ORDER BY created_at DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var blocked []*models.BlockedUser
+	for rows.Next() {
+		var b models.BlockedUser
+		if err := rows.Scan(&b.TelegramID, &b.Reason, &b.AdminID, &b.CreatedAt); err != nil {
+			return nil, err
+		}
+		blocked = append(blocked, &b)
+	}
+	return blocked, rows.Err()
+}
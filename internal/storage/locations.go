@@ -0,0 +1,213 @@
+package storage
+
+import (
+	"carwash-bot/internal/models"
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+)
+
+var ErrLocationNotFound = errors.New("мойка не найдена")
+
+func (s *Storage) CreateLocation(ctx context.Context, location *models.Location) error {
+	location.CreatedAt = time.Now()
+
+	return s.DB.QueryRowContext(ctx, `
+	INSERT INTO locations (name, address, latitude, longitude, start_time, end_time, bays, services, created_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	RETURNING id`,
+		location.Name,
+		location.Address,
+		location.Latitude,
+		location.Longitude,
+		location.StartTime,
+		location.EndTime,
+		location.Bays,
+		strings.Join(location.Services, ","),
+		location.CreatedAt).Scan(&location.ID)
+}
+
+// UpdateLocation сохраняет новые адрес, часы работы, посты и услуги мойки.
+// Уже созданные записи остаются на своих постах
+func (s *Storage) UpdateLocation(ctx context.Context, location *models.Location) error {
+	res, err := s.DB.ExecContext(ctx, `
+	UPDATE locations SET name = ?, address = ?, latitude = ?, longitude = ?,
+		start_time = ?, end_time = ?, bays = ?, services = ?
+	WHERE id = ?`,
+		location.Name,
+		location.Address,
+		location.Latitude,
+		location.Longitude,
+		location.StartTime,
+		location.EndTime,
+		location.Bays,
+		strings.Join(location.Services, ","),
+		location.ID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrLocationNotFound
+	}
+	return nil
+}
+
+func (s *Storage) GetLocation(ctx context.Context, locationID int64) (*models.Location, error) {
+	location, err := scanLocation(s.DB.QueryRowContext(ctx, `
+	SELECT id, name, address, latitude, longitude, start_time, end_time, bays, services, created_at
+	FROM locations WHERE id = ?`, locationID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	admins, err := s.locationAdmins(ctx)
+	if err != nil {
+		return nil, err
+	}
+	location.Admins = admins[location.ID]
+	return location, nil
+}
+
+func (s *Storage) GetLocations(ctx context.Context) ([]*models.Location, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+	SELECT id, name, address, latitude, longitude, start_time, end_time, bays, services, created_at
+	FROM locations ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var locations []*models.Location
+	for rows.Next() {
+		location, err := scanLocation(rows)
+		if err != nil {
+			return nil, err
+		}
+		locations = append(locations, location)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	admins, err := s.locationAdmins(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, location := range locations {
+		location.Admins = admins[location.ID]
+	}
+	return locations, nil
+}
+
+// AddLocationAdmin назначает пользователя администратором мойки
+func (s *Storage) AddLocationAdmin(ctx context.Context, locationID, telegramID int64) error {
+	_, err := s.DB.ExecContext(ctx, `
+	INSERT INTO location_admins (location_id, telegram_id) VALUES (?, ?)
+	ON CONFLICT(location_id, telegram_id) DO NOTHING`, locationID, telegramID)
+	return err
+}
+
+func (s *Storage) RemoveLocationAdmin(ctx context.Context, locationID, telegramID int64) error {
+	res, err := s.DB.ExecContext(ctx, `
+	DELETE FROM location_admins WHERE location_id = ? AND telegram_id = ?`, locationID, telegramID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// GetAdminLocations возвращает ID моек, которыми управляет пользователь
+func (s *Storage) GetAdminLocations(ctx context.Context, telegramID int64) ([]int64, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+	SELECT location_id FROM location_admins WHERE telegram_id = ? ORDER BY location_id`, telegramID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// locationAdmins возвращает администраторов по ID мойки
+func (s *Storage) locationAdmins(ctx context.Context) (map[int64][]int64, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+	SELECT location_id, telegram_id FROM location_admins ORDER BY location_id, telegram_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	admins := make(map[int64][]int64)
+	for rows.Next() {
+		var locationID, telegramID int64
+		if err := rows.Scan(&locationID, &telegramID); err != nil {
+			return nil, err
+		}
+		admins[locationID] = append(admins[locationID], telegramID)
+	}
+	return admins, rows.Err()
+}
+
+// locationBays возвращает количество постов мойки. Посты основной мойки
+// задаются настройками
+func (s *Storage) locationBays(ctx context.Context, q queryRower, locationID int64) (int, error) {
+	if locationID == 0 {
+		return s.bays(), nil
+	}
+
+	var bays int
+	err := q.QueryRowContext(ctx, `SELECT bays FROM locations WHERE id = ?`, locationID).Scan(&bays)
+	if err == sql.ErrNoRows {
+		return 0, ErrLocationNotFound
+	}
+	return max(bays, 1), err
+}
+
+// locationFilter возвращает условие отбора по мойкам для колонки column
+// и его аргументы. Для nil условие пустое
+func locationFilter(column string, locations []int64) (string, []any) {
+	if locations == nil {
+		return "", nil
+	}
+	if len(locations) == 0 {
+		return ` AND 1 = 0`, nil
+	}
+
+	args := make([]any, len(locations))
+	for i, id := range locations {
+		args[i] = id
+	}
+	return ` AND ` + column + ` IN (?` + strings.Repeat(", ?", len(locations)-1) + `)`, args
+}
+
+func scanLocation(row rowScanner) (*models.Location, error) {
+	var (
+		location models.Location
+		services string
+	)
+	err := row.Scan(&location.ID, &location.Name, &location.Address, &location.Latitude, &location.Longitude,
+		&location.StartTime, &location.EndTime, &location.Bays, &services, &location.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if services != "" {
+		location.Services = strings.Split(services, ",")
+	}
+	return &location, nil
+}
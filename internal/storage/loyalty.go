@@ -0,0 +1,235 @@
+package storage
+
+import (
+	"carwash-bot/internal/models"
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+var ErrInsufficientPoints = errors.New("недостаточно бонусных баллов")
+
+// GetLoyaltyAccount возвращает бонусный счёт пользователя.
+// Если визитов ещё не было, возвращается пустой счёт
+func (s *Storage) GetLoyaltyAccount(ctx context.Context, userID int64) (*models.LoyaltyAccount, error) {
+	account := &models.LoyaltyAccount{UserID: userID}
+	err := s.DB.QueryRowContext(ctx, `
+	SELECT visits, points, updated_at FROM loyalty_accounts WHERE user_id = ?`, userID).Scan(
+		&account.Visits,
+		&account.Points,
+		&account.UpdatedAt)
+
+	if err == sql.ErrNoRows {
+		return account, nil
+	}
+	return account, err
+}
+
+func (s *Storage) GetLoyaltyTransactions(ctx context.Context, userID int64, limit int) ([]*models.LoyaltyTransaction, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+	SELECT id, user_id, delta, reason, comment, booking_id, admin_id, created_at
+	FROM loyalty_transactions WHERE user_id = ?
+	ORDER BY id DESC LIMIT ?`, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var transactions []*models.LoyaltyTransaction
+	for rows.Next() {
+		var t models.LoyaltyTransaction
+		if err := rows.Scan(&t.ID, &t.UserID, &t.Delta, &t.Reason, &t.Comment,
+			&t.BookingID, &t.AdminID, &t.CreatedAt); err != nil {
+			return nil, err
+		}
+		transactions = append(transactions, &t)
+	}
+	return transactions, rows.Err()
+}
+
+// CountUpcomingBookings возвращает число предстоящих записей пользователя,
+// включая неоплаченные и неподтверждённые
+func (s *Storage) CountUpcomingBookings(ctx context.Context, userID int64) (int, error) {
+	var count int
+	err := s.DB.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM bookings WHERE user_id = ? AND status IN `+upcomingStatuses,
+		userID).Scan(&count)
+	return count, err
+}
+
+// RevokeFreeVisits после отмены записи оставляет пользователю столько
+// бесплатных моек, сколько каждых everyNth по счёту среди его визитов
+// и предстоящих записей. Лишним, начиная с последних оформленных,
+// возвращается полная цена. Бесплатная по программе лояльности запись —
+// частная, без промокода и баллов, с нулевой ценой и скидкой
+func (s *Storage) RevokeFreeVisits(ctx context.Context, userID int64, everyNth int) ([]*models.Booking, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var visits int
+	err = tx.QueryRowContext(ctx, `
+	SELECT visits FROM loyalty_accounts WHERE user_id = ?`, userID).Scan(&visits)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+	SELECT id, user_id, date, time, car_model, car_number, service, price,
+		discount, promo_code, points_used, organization_id, location_id, status
+	FROM bookings WHERE user_id = ? AND status IN `+upcomingStatuses+`
+	ORDER BY created_at DESC`+s.forUpdate(), userID)
+	if err != nil {
+		return nil, err
+	}
+	var upcoming, free []*models.Booking
+	for rows.Next() {
+		var b models.Booking
+		if err := rows.Scan(&b.ID, &b.UserID, &b.Date, &b.Time, &b.CarModel, &b.CarNumber,
+			&b.Service, &b.Price, &b.Discount, &b.PromoCode, &b.PointsUsed,
+			&b.OrganizationID, &b.LocationID, &b.Status); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		upcoming = append(upcoming, &b)
+		if b.OrganizationID == 0 && b.Price == 0 && b.Discount > 0 && b.PromoCode == "" && b.PointsUsed == 0 {
+			free = append(free, &b)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Сколько бесплатных моек приходится на визиты с visits+1 по visits+len(upcoming)
+	allowed := (visits+len(upcoming))/everyNth - visits/everyNth
+	var revoked []*models.Booking
+	for _, b := range free[:max(len(free)-allowed, 0)] {
+		// Промокод при бесплатной мойке не расходовался, вся скидка — её цена
+		b.Price, b.Discount = b.Discount, 0
+		if _, err := tx.ExecContext(ctx, `
+		UPDATE bookings SET price = ?, discount = 0 WHERE id = ?`, b.Price, b.ID); err != nil {
+			return nil, err
+		}
+		revoked = append(revoked, b)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return revoked, nil
+}
+
+// CompleteBooking отмечает мойку выполненной, засчитывает визит
+// и начисляет кэшбэк, если он положен
+func (s *Storage) CompleteBooking(ctx context.Context, bookingID string, cashback int) (*models.Booking, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var booking models.Booking
+	err = tx.QueryRowContext(ctx, `
+	SELECT id, user_id, date, time, car_model, car_number, service, price
+	FROM bookings WHERE id = ? AND status = ?`+s.forUpdate(),
+		bookingID, models.StatusActive).Scan(
+		&booking.ID,
+		&booking.UserID,
+		&booking.Date,
+		&booking.Time,
+		&booking.CarModel,
+		&booking.CarNumber,
+		&booking.Service,
+		&booking.Price)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = tx.ExecContext(ctx, `
+	UPDATE bookings SET status = ? WHERE id = ?`,
+		models.StatusCompleted, bookingID); err != nil {
+		return nil, err
+	}
+	booking.Status = models.StatusCompleted
+
+	if err = s.ensureLoyaltyAccount(ctx, tx, booking.UserID); err != nil {
+		return nil, err
+	}
+	if _, err = tx.ExecContext(ctx, `
+	UPDATE loyalty_accounts SET visits = visits + 1, updated_at = ? WHERE user_id = ?`,
+		time.Now(), booking.UserID); err != nil {
+		return nil, err
+	}
+
+	if cashback > 0 {
+		err = s.changeLoyaltyPoints(ctx, tx, &models.LoyaltyTransaction{
+			UserID:    booking.UserID,
+			Delta:     cashback,
+			Reason:    models.LoyaltyCashback,
+			BookingID: booking.ID,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &booking, nil
+}
+
+// AdjustLoyaltyPoints вручную меняет баланс, сохраняя запись в журнале
+func (s *Storage) AdjustLoyaltyPoints(ctx context.Context, transaction *models.LoyaltyTransaction) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := s.changeLoyaltyPoints(ctx, tx, transaction); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *Storage) ensureLoyaltyAccount(ctx context.Context, tx *Tx, userID int64) error {
+	_, err := tx.ExecContext(ctx, `
+	INSERT INTO loyalty_accounts (user_id, updated_at) VALUES (?, ?)
+	ON CONFLICT(user_id) DO NOTHING`, userID, time.Now())
+	return err
+}
+
+// changeLoyaltyPoints меняет баланс в рамках транзакции и пишет журнал.
+// Баланс не может стать отрицательным
+func (s *Storage) changeLoyaltyPoints(ctx context.Context, tx *Tx, t *models.LoyaltyTransaction) error {
+	if err := s.ensureLoyaltyAccount(ctx, tx, t.UserID); err != nil {
+		return err
+	}
+
+	var points int
+	if err := tx.QueryRowContext(ctx, `
+	SELECT points FROM loyalty_accounts WHERE user_id = ?`+s.forUpdate(), t.UserID).Scan(&points); err != nil {
+		return err
+	}
+	if points+t.Delta < 0 {
+		return ErrInsufficientPoints
+	}
+
+	t.CreatedAt = time.Now()
+	if _, err := tx.ExecContext(ctx, `
+	UPDATE loyalty_accounts SET points = points + ?, updated_at = ? WHERE user_id = ?`,
+		t.Delta, t.CreatedAt, t.UserID); err != nil {
+		return err
+	}
+
+	return tx.QueryRowContext(ctx, `
+	INSERT INTO loyalty_transactions (user_id, delta, reason, comment, booking_id, admin_id, created_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?)
+	RETURNING id`,
+		t.UserID, t.Delta, t.Reason, t.Comment, t.BookingID, t.AdminID, t.CreatedAt).Scan(&t.ID)
+}
@@ -0,0 +1,453 @@
+// Package memory — хранилище записей, пользователей и моек в памяти для
+// тестов и локальной разработки. Лимиты клиентов, блокировки, промокоды и
+// бонусные баллы в нём не поддерживаются
+package memory
+
+import (
+	"carwash-bot/internal/models"
+	"carwash-bot/internal/storage"
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// hold — время на мойке, удерживаемое пользователем, пока он оформляет запись
+type hold struct {
+	location    int64
+	date, clock string
+	until       time.Time
+}
+
+type Storage struct {
+	mu           sync.Mutex
+	bookings     map[string]*models.Booking
+	users        map[int64]*models.User     // по ID пользователя
+	holds        map[int64]hold             // по ID пользователя
+	locations    map[int64]*models.Location // по ID мойки
+	lastUser     int64
+	lastLocation int64
+
+	// Количество моечных постов основной мойки: столько машин можно
+	// записать на одно время
+	Bays int
+}
+
+var _ storage.Repository = (*Storage)(nil)
+
+func New() *Storage {
+	return &Storage{
+		bookings:  make(map[string]*models.Booking),
+		users:     make(map[int64]*models.User),
+		holds:     make(map[int64]hold),
+		locations: make(map[int64]*models.Location),
+	}
+}
+
+// Записи в этих статусах не занимают слот
+var releasedStatuses = []string{models.StatusExpired, models.StatusCancelled}
+
+// Записи в этих статусах ещё предстоят
+var upcomingStatuses = []string{models.StatusActive, models.StatusAwaitingPayment, models.StatusPendingApproval}
+
+func (s *Storage) CreateBooking(ctx context.Context, booking *models.Booking) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	bay, err := s.freeBay(booking.UserID, booking.LocationID, booking.Date, booking.Time)
+	if err != nil {
+		return err
+	}
+
+	booking.ID = uuid.New().String()
+	booking.CreatedAt = time.Now()
+	if booking.Status == "" {
+		booking.Status = models.StatusActive
+	}
+	booking.Bay = bay
+
+	stored := *booking
+	stored.User = nil
+	s.bookings[booking.ID] = &stored
+
+	// Слот больше не нужно удерживать
+	delete(s.holds, booking.UserID)
+	return nil
+}
+
+func (s *Storage) GetBooking(ctx context.Context, bookingID string) (*models.Booking, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	booking, ok := s.bookings[bookingID]
+	if !ok {
+		return nil, nil
+	}
+	b := *booking
+	return &b, nil
+}
+
+func (s *Storage) GetUserBookings(ctx context.Context, userID int64) ([]*models.Booking, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var bookings []*models.Booking
+	for _, booking := range s.bookings {
+		if booking.UserID == userID && slices.Contains(upcomingStatuses, booking.Status) {
+			b := *booking
+			bookings = append(bookings, &b)
+		}
+	}
+	sort.Slice(bookings, func(i, j int) bool {
+		return sortKey(bookings[i]) < sortKey(bookings[j])
+	})
+	return bookings, nil
+}
+
+// CancelBooking отменяет предстоящую запись пользователя. Поздняя отмена
+// засчитывается пользователю как нарушение
+func (s *Storage) CancelBooking(ctx context.Context, bookingID string, userID int64, late bool) (*models.Booking, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	booking, ok := s.bookings[bookingID]
+	if !ok || booking.UserID != userID || !slices.Contains(upcomingStatuses, booking.Status) {
+		return nil, sql.ErrNoRows
+	}
+
+	booking.Status = models.StatusCancelled
+	booking.CancelledAt = time.Now()
+	booking.LateCancel = late
+	if user := s.users[userID]; late && user != nil {
+		user.LateCancels++
+	}
+
+	b := *booking
+	return &b, nil
+}
+
+// RescheduleBooking переносит предстоящую запись на другое время на той же мойке
+func (s *Storage) RescheduleBooking(ctx context.Context, bookingID, date, clock string) (*models.Booking, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	booking, ok := s.bookings[bookingID]
+	if !ok || !slices.Contains(upcomingStatuses, booking.Status) {
+		return nil, sql.ErrNoRows
+	}
+
+	bay, err := s.freeBay(booking.UserID, booking.LocationID, date, clock)
+	if err != nil {
+		return nil, err
+	}
+	booking.Date, booking.Time, booking.Bay = date, clock, bay
+
+	b := *booking
+	return &b, nil
+}
+
+// SetBookingStatus переводит запись из статуса from в статус to
+func (s *Storage) SetBookingStatus(ctx context.Context, bookingID, from, to string) (*models.Booking, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	booking, ok := s.bookings[bookingID]
+	if !ok || booking.Status != from {
+		return nil, sql.ErrNoRows
+	}
+	booking.Status = to
+
+	b := *booking
+	return &b, nil
+}
+
+// CompleteBooking отмечает мойку выполненной. Бонусные баллы не
+// поддерживаются, cashback не начисляется
+func (s *Storage) CompleteBooking(ctx context.Context, bookingID string, cashback int) (*models.Booking, error) {
+	return s.SetBookingStatus(ctx, bookingID, models.StatusActive, models.StatusCompleted)
+}
+
+// MarkNoShow отмечает, что клиент не приехал, и засчитывает ему неявку
+func (s *Storage) MarkNoShow(ctx context.Context, bookingID string) (*models.Booking, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	booking, ok := s.bookings[bookingID]
+	if !ok || booking.Status != models.StatusActive {
+		return nil, sql.ErrNoRows
+	}
+	booking.Status = models.StatusNoShow
+	if user := s.users[booking.UserID]; user != nil {
+		user.NoShows++
+	}
+
+	b := *booking
+	return &b, nil
+}
+
+// GetDayOccupancy возвращает количество занятых и удерживаемых другими
+// пользователями постов мойки по времени: время → записей
+func (s *Storage) GetDayOccupancy(ctx context.Context, locationID int64, date string, exceptUserID int64) (map[string]int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	occupancy := make(map[string]int)
+	s.eachTaken(locationID, exceptUserID, func(d, clock string) {
+		if d == date {
+			occupancy[clock]++
+		}
+	})
+	return occupancy, nil
+}
+
+// GetMonthOccupancy возвращает занятость постов мойки по дням месяца
+// с учётом чужих удержаний: дата → время → записей
+func (s *Storage) GetMonthOccupancy(ctx context.Context, locationID int64, month time.Month, year int, exceptUserID int64) (map[string]map[string]int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	suffix := fmt.Sprintf(".%02d.%d", month, year)
+	occupancy := make(map[string]map[string]int)
+	s.eachTaken(locationID, exceptUserID, func(date, clock string) {
+		if !strings.HasSuffix(date, suffix) {
+			return
+		}
+		if occupancy[date] == nil {
+			occupancy[date] = make(map[string]int)
+		}
+		occupancy[date][clock]++
+	})
+	return occupancy, nil
+}
+
+// HoldSlot удерживает время на мойке за пользователем до until. Прежнее
+// удержание пользователя снимается. ErrSlotTaken — если свободных постов нет
+func (s *Storage) HoldSlot(ctx context.Context, userID, locationID int64, date, clock string, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for id, h := range s.holds {
+		if !h.until.After(now) {
+			delete(s.holds, id)
+		}
+	}
+
+	bays, err := s.bays(locationID)
+	if err != nil {
+		return err
+	}
+	if s.slotLoad(locationID, date, clock, userID) >= bays {
+		return storage.ErrSlotTaken
+	}
+	s.holds[userID] = hold{location: locationID, date: date, clock: clock, until: until}
+	return nil
+}
+
+func (s *Storage) ReleaseHold(ctx context.Context, userID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.holds, userID)
+	return nil
+}
+
+// CreateOrUpdateUser сохраняет пользователя. Язык записывается только
+// новым пользователям: выбранный в настройках язык не перезаписывается
+func (s *Storage) CreateOrUpdateUser(ctx context.Context, user *models.User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if existing := s.userByTelegramID(user.TelegramID); existing != nil {
+		existing.Username = user.Username
+		existing.FirstName = user.FirstName
+		existing.LastName = user.LastName
+		if existing.Language == "" {
+			existing.Language = user.Language
+		}
+		return nil
+	}
+
+	s.lastUser++
+	s.users[s.lastUser] = &models.User{
+		ID:         s.lastUser,
+		TelegramID: user.TelegramID,
+		Username:   user.Username,
+		FirstName:  user.FirstName,
+		LastName:   user.LastName,
+		Language:   user.Language,
+		CreatedAt:  time.Now(),
+	}
+	return nil
+}
+
+func (s *Storage) GetUserByID(ctx context.Context, userID int64) (*models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return copyUser(s.users[userID]), nil
+}
+
+func (s *Storage) GetUserByTelegramID(ctx context.Context, telegramID int64) (*models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return copyUser(s.userByTelegramID(telegramID)), nil
+}
+
+func (s *Storage) SetUserPhone(ctx context.Context, telegramID int64, phone string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if user := s.userByTelegramID(telegramID); user != nil {
+		user.Phone = phone
+	}
+	return nil
+}
+
+func (s *Storage) SetUserLanguage(ctx context.Context, telegramID int64, lang string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if user := s.userByTelegramID(telegramID); user != nil {
+		user.Language = lang
+	}
+	return nil
+}
+
+func (s *Storage) CreateLocation(ctx context.Context, location *models.Location) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastLocation++
+	location.ID = s.lastLocation
+	location.CreatedAt = time.Now()
+
+	stored := *location
+	stored.Services = slices.Clone(location.Services)
+	stored.Admins = nil
+	s.locations[location.ID] = &stored
+	return nil
+}
+
+func (s *Storage) GetLocation(ctx context.Context, locationID int64) (*models.Location, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	location, ok := s.locations[locationID]
+	if !ok {
+		return nil, nil
+	}
+	l := *location
+	return &l, nil
+}
+
+func (s *Storage) GetLocations(ctx context.Context) ([]*models.Location, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var locations []*models.Location
+	for _, location := range s.locations {
+		l := *location
+		locations = append(locations, &l)
+	}
+	sort.Slice(locations, func(i, j int) bool { return locations[i].ID < locations[j].ID })
+	return locations, nil
+}
+
+func (s *Storage) userByTelegramID(telegramID int64) *models.User {
+	for _, user := range s.users {
+		if user.TelegramID == telegramID {
+			return user
+		}
+	}
+	return nil
+}
+
+func copyUser(user *models.User) *models.User {
+	if user == nil {
+		return nil
+	}
+	u := *user
+	return &u
+}
+
+// bays возвращает количество постов мойки, у основной мойки — Bays
+func (s *Storage) bays(locationID int64) (int, error) {
+	if locationID == 0 {
+		return max(s.Bays, 1), nil
+	}
+	location, ok := s.locations[locationID]
+	if !ok {
+		return 0, storage.ErrLocationNotFound
+	}
+	return max(location.Bays, 1), nil
+}
+
+// eachTaken вызывает fn для каждой записи мойки, занимающей пост, и для
+// каждого действующего чужого удержания на ней
+func (s *Storage) eachTaken(locationID, exceptUserID int64, fn func(date, clock string)) {
+	for _, booking := range s.bookings {
+		if booking.LocationID == locationID && !slices.Contains(releasedStatuses, booking.Status) {
+			fn(booking.Date, booking.Time)
+		}
+	}
+	now := time.Now()
+	for userID, h := range s.holds {
+		if h.location == locationID && userID != exceptUserID && h.until.After(now) {
+			fn(h.date, h.clock)
+		}
+	}
+}
+
+// slotLoad считает записи и чужие удержания на время на мойке
+func (s *Storage) slotLoad(locationID int64, date, clock string, exceptUserID int64) int {
+	load := 0
+	s.eachTaken(locationID, exceptUserID, func(d, c string) {
+		if d == date && c == clock {
+			load++
+		}
+	})
+	return load
+}
+
+// freeBay подбирает свободный пост на мойке на время записи с учётом
+// чужих удержаний
+func (s *Storage) freeBay(userID, locationID int64, date, clock string) (int, error) {
+	bays, err := s.bays(locationID)
+	if err != nil {
+		return 0, err
+	}
+	if s.slotLoad(locationID, date, clock, userID) >= bays {
+		return 0, storage.ErrSlotTaken
+	}
+
+	taken := make(map[int]bool)
+	for _, booking := range s.bookings {
+		if booking.LocationID == locationID && booking.Date == date && booking.Time == clock &&
+			!slices.Contains(releasedStatuses, booking.Status) {
+			taken[booking.Bay] = true
+		}
+	}
+	for bay := 1; bay <= bays; bay++ {
+		if !taken[bay] {
+			return bay, nil
+		}
+	}
+	return 0, storage.ErrSlotTaken
+}
+
+// sortKey упорядочивает записи по дате и времени: «2006-01-02 15:04»
+func sortKey(b *models.Booking) string {
+	day, err := time.ParseInLocation("02.01.2006", b.Date, time.Local)
+	if err != nil {
+		return b.Date + " " + b.Time
+	}
+	return day.Format("2006-01-02") + " " + b.Time
+}
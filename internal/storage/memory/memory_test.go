@@ -0,0 +1,16 @@
+package memory_test
+
+import (
+	"carwash-bot/internal/storage"
+	"carwash-bot/internal/storage/memory"
+	"carwash-bot/internal/storage/storagetest"
+	"testing"
+)
+
+func TestMemory(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T, bays int) storage.Repository {
+		repo := memory.New()
+		repo.Bays = bays
+		return repo
+	})
+}
@@ -0,0 +1,206 @@
+package storage
+
+import (
+	"carwash-bot/internal/models"
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+func (s *Storage) CreateOrganization(ctx context.Context, org *models.Organization) error {
+	org.CreatedAt = time.Now()
+
+	return s.DB.QueryRowContext(ctx, `
+	INSERT INTO organizations (name, created_at) VALUES (?, ?)
+	RETURNING id`, org.Name, org.CreatedAt).Scan(&org.ID)
+}
+
+func (s *Storage) GetOrganization(ctx context.Context, orgID int64) (*models.Organization, error) {
+	org := &models.Organization{}
+	err := s.DB.QueryRowContext(ctx, `
+	SELECT id, name, created_at FROM organizations WHERE id = ?`, orgID).Scan(
+		&org.ID,
+		&org.Name,
+		&org.CreatedAt)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return org, err
+}
+
+func (s *Storage) GetAllOrganizations(ctx context.Context) ([]*models.Organization, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+	SELECT id, name, created_at FROM organizations ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var orgs []*models.Organization
+	for rows.Next() {
+		var o models.Organization
+		if err := rows.Scan(&o.ID, &o.Name, &o.CreatedAt); err != nil {
+			return nil, err
+		}
+		orgs = append(orgs, &o)
+	}
+	return orgs, rows.Err()
+}
+
+// AddOrganizationMember добавляет участника или меняет его роль
+func (s *Storage) AddOrganizationMember(ctx context.Context, member *models.OrganizationMember) error {
+	_, err := s.DB.ExecContext(ctx, `
+	INSERT INTO organization_members (organization_id, telegram_id, role)
+	VALUES (?, ?, ?)
+	ON CONFLICT(organization_id, telegram_id) DO UPDATE SET
+		role = excluded.role`,
+		member.OrganizationID, member.TelegramID, member.Role)
+	return err
+}
+
+func (s *Storage) RemoveOrganizationMember(ctx context.Context, orgID, telegramID int64) error {
+	res, err := s.DB.ExecContext(ctx, `
+	DELETE FROM organization_members
+	WHERE organization_id = ? AND telegram_id = ?`, orgID, telegramID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (s *Storage) GetOrganizationMember(ctx context.Context, orgID, telegramID int64) (*models.OrganizationMember, error) {
+	m := &models.OrganizationMember{}
+	err := s.DB.QueryRowContext(ctx, `
+	SELECT organization_id, telegram_id, role
+	FROM organization_members WHERE organization_id = ? AND telegram_id = ?`,
+		orgID, telegramID).Scan(&m.OrganizationID, &m.TelegramID, &m.Role)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return m, err
+}
+
+func (s *Storage) GetOrganizationMembers(ctx context.Context, orgID int64) ([]*models.OrganizationMember, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+	SELECT organization_id, telegram_id, role
+	FROM organization_members WHERE organization_id = ? ORDER BY role, telegram_id`, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var members []*models.OrganizationMember
+	for rows.Next() {
+		var m models.OrganizationMember
+		if err := rows.Scan(&m.OrganizationID, &m.TelegramID, &m.Role); err != nil {
+			return nil, err
+		}
+		members = append(members, &m)
+	}
+	return members, rows.Err()
+}
+
+// GetUserMemberships возвращает все организации, в которых состоит пользователь
+func (s *Storage) GetUserMemberships(ctx context.Context, telegramID int64) ([]*models.OrganizationMember, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+	SELECT m.organization_id, m.telegram_id, m.role, o.name, o.created_at
+	FROM organization_members m
+	JOIN organizations o ON o.id = m.organization_id
+	WHERE m.telegram_id = ? ORDER BY o.name`, telegramID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var members []*models.OrganizationMember
+	for rows.Next() {
+		m := models.OrganizationMember{Organization: &models.Organization{}}
+		if err := rows.Scan(&m.OrganizationID, &m.TelegramID, &m.Role,
+			&m.Organization.Name, &m.Organization.CreatedAt); err != nil {
+			return nil, err
+		}
+		m.Organization.ID = m.OrganizationID
+		members = append(members, &m)
+	}
+	return members, rows.Err()
+}
+
+func (s *Storage) AddVehicle(ctx context.Context, vehicle *models.Vehicle) error {
+	vehicle.CreatedAt = time.Now()
+
+	return s.DB.QueryRowContext(ctx, `
+	INSERT INTO vehicles (organization_id, car_model, car_number, created_at)
+	VALUES (?, ?, ?, ?)
+	RETURNING id`,
+		vehicle.OrganizationID, vehicle.CarModel, vehicle.CarNumber, vehicle.CreatedAt).Scan(&vehicle.ID)
+}
+
+func (s *Storage) GetVehicle(ctx context.Context, vehicleID int64) (*models.Vehicle, error) {
+	v := &models.Vehicle{}
+	err := s.DB.QueryRowContext(ctx, `
+	SELECT id, organization_id, car_model, car_number, created_at
+	FROM vehicles WHERE id = ?`, vehicleID).Scan(
+		&v.ID, &v.OrganizationID, &v.CarModel, &v.CarNumber, &v.CreatedAt)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return v, err
+}
+
+func (s *Storage) GetOrganizationVehicles(ctx context.Context, orgID int64) ([]*models.Vehicle, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+	SELECT id, organization_id, car_model, car_number, created_at
+	FROM vehicles WHERE organization_id = ? ORDER BY car_number`, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var vehicles []*models.Vehicle
+	for rows.Next() {
+		var v models.Vehicle
+		if err := rows.Scan(&v.ID, &v.OrganizationID, &v.CarModel, &v.CarNumber, &v.CreatedAt); err != nil {
+			return nil, err
+		}
+		vehicles = append(vehicles, &v)
+	}
+	return vehicles, rows.Err()
+}
+
+// GetOrganizationStatement возвращает все мойки организации за месяц
+// вместе с водителями, оформившими запись
+func (s *Storage) GetOrganizationStatement(ctx context.Context, orgID int64, month, year int) ([]*models.Booking, error) {
+	// Даты хранятся в формате "02.01.2006", поэтому месяц ищем по суффиксу
+	suffix := fmt.Sprintf("%%.%02d.%04d", month, year)
+
+	rows, err := s.DB.QueryContext(ctx, `
+	SELECT b.id, b.user_id, b.date, b.time, b.car_model, b.car_number, b.service, b.price,
+		u.telegram_id, COALESCE(u.username, ''), COALESCE(u.first_name, '')
+	FROM bookings b
+	JOIN users u ON u.id = b.user_id
+	WHERE b.organization_id = ? AND b.date LIKE ? AND b.status IN (?, ?)
+	ORDER BY substr(b.date, 1, 2), b.time`, orgID, suffix, models.StatusActive, models.StatusCompleted)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var bookings []*models.Booking
+	for rows.Next() {
+		b := models.Booking{OrganizationID: orgID, User: &models.User{}}
+		if err := rows.Scan(&b.ID, &b.UserID, &b.Date, &b.Time, &b.CarModel, &b.CarNumber,
+			&b.Service, &b.Price, &b.User.TelegramID, &b.User.Username, &b.User.FirstName); err != nil {
+			return nil, err
+		}
+		b.User.ID = b.UserID
+		bookings = append(bookings, &b)
+	}
+	return bookings, rows.Err()
+}
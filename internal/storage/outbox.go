@@ -0,0 +1,145 @@
+package storage
+
+import (
+	"carwash-bot/internal/models"
+	"context"
+	"database/sql"
+	"time"
+)
+
+// EnqueueMessage сохраняет сообщение в очередь отправки
+func (s *Storage) EnqueueMessage(ctx context.Context, msg *models.OutboxMessage) error {
+	msg.Status = models.OutboxPending
+	msg.CreatedAt = time.Now()
+	if msg.NextAttemptAt.IsZero() {
+		msg.NextAttemptAt = msg.CreatedAt
+	}
+
+	return s.DB.QueryRowContext(ctx, `
+	INSERT INTO outbox (chat_id, text, parse_mode, reply_markup, status, attempts, last_error,
+		next_attempt_at, created_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	RETURNING id`,
+		msg.ChatID,
+		msg.Text,
+		msg.ParseMode,
+		msg.ReplyMarkup,
+		msg.Status,
+		msg.Attempts,
+		msg.LastError,
+		msg.NextAttemptAt,
+		msg.CreatedAt).Scan(&msg.ID)
+}
+
+const outboxColumns = `id, chat_id, text, parse_mode, reply_markup, status, attempts, last_error,
+	next_attempt_at, created_at`
+
+func scanOutboxMessage(row rowScanner) (*models.OutboxMessage, error) {
+	var m models.OutboxMessage
+	err := row.Scan(&m.ID, &m.ChatID, &m.Text, &m.ParseMode, &m.ReplyMarkup, &m.Status,
+		&m.Attempts, &m.LastError, &m.NextAttemptAt, &m.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+func (s *Storage) queryOutbox(ctx context.Context, query string, args ...any) ([]*models.OutboxMessage, error) {
+	rows, err := s.DB.QueryContext(ctx, `SELECT `+outboxColumns+` FROM outbox `+query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var messages []*models.OutboxMessage
+	for rows.Next() {
+		m, err := scanOutboxMessage(rows)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, m)
+	}
+	return messages, rows.Err()
+}
+
+// GetDueMessages возвращает сообщения, которые пора отправить, и
+// сообщения, отправка которых не завершилась до until при захвате
+func (s *Storage) GetDueMessages(ctx context.Context, now time.Time, limit int) ([]*models.OutboxMessage, error) {
+	return s.queryOutbox(ctx, `WHERE status IN (?, ?) AND next_attempt_at <= ? ORDER BY next_attempt_at, id LIMIT ?`,
+		models.OutboxPending, models.OutboxSending, now, limit)
+}
+
+// ClaimMessage захватывает сообщение для отправки до until. Сообщение
+// захватывается, только если его пора отправить на момент now и его не
+// отправляет кто-то другой, поэтому одно сообщение не уходит дважды.
+// Если отправитель не отметит результат до until, сообщение снова
+// попадёт в GetDueMessages
+func (s *Storage) ClaimMessage(ctx context.Context, id int64, now, until time.Time) (bool, error) {
+	res, err := s.DB.ExecContext(ctx, `
+	UPDATE outbox SET status = ?, next_attempt_at = ?
+	WHERE id = ? AND status IN (?, ?) AND next_attempt_at <= ?`,
+		models.OutboxSending, until, id, models.OutboxPending, models.OutboxSending, now)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+// GetUndeliveredMessages возвращает сообщения, которые не удалось
+// отправить хотя бы один раз
+func (s *Storage) GetUndeliveredMessages(ctx context.Context, limit int) ([]*models.OutboxMessage, error) {
+	return s.queryOutbox(ctx, `WHERE status = ? OR (status IN (?, ?) AND attempts > 0) ORDER BY created_at DESC LIMIT ?`,
+		models.OutboxDead, models.OutboxPending, models.OutboxSending, limit)
+}
+
+func (s *Storage) GetOutboxMessage(ctx context.Context, id int64) (*models.OutboxMessage, error) {
+	m, err := scanOutboxMessage(s.DB.QueryRowContext(ctx, `
+	SELECT `+outboxColumns+` FROM outbox WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return m, err
+}
+
+func (s *Storage) MarkMessageSent(ctx context.Context, id int64) error {
+	_, err := s.DB.ExecContext(ctx, `
+	UPDATE outbox SET status = ?, attempts = attempts + 1, last_error = '' WHERE id = ?`,
+		models.OutboxSent, id)
+	return err
+}
+
+// MarkMessageFailed записывает неудачную попытку. Сообщение повторится
+// в nextAttempt или, если dead, попадёт в недоставленные
+func (s *Storage) MarkMessageFailed(ctx context.Context, id int64, errText string, nextAttempt time.Time, dead bool) error {
+	status := models.OutboxPending
+	if dead {
+		status = models.OutboxDead
+	}
+	_, err := s.DB.ExecContext(ctx, `
+	UPDATE outbox SET status = ?, attempts = attempts + 1, last_error = ?, next_attempt_at = ?
+	WHERE id = ?`, status, errText, nextAttempt, id)
+	return err
+}
+
+// RetryMessage возвращает недоставленное сообщение в очередь. Сообщение,
+// которое сейчас отправляется, не трогается
+func (s *Storage) RetryMessage(ctx context.Context, id int64) error {
+	res, err := s.DB.ExecContext(ctx, `
+	UPDATE outbox SET status = ?, attempts = 0, next_attempt_at = ?
+	WHERE id = ? AND status IN (?, ?)`, models.OutboxPending, time.Now(), id, models.OutboxPending, models.OutboxDead)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// DeleteSentMessages удаляет доставленные сообщения старше before
+func (s *Storage) DeleteSentMessages(ctx context.Context, before time.Time) error {
+	_, err := s.DB.ExecContext(ctx, `
+	DELETE FROM outbox WHERE status = ? AND created_at < ?`, models.OutboxSent, before)
+	return err
+}
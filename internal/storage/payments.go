@@ -0,0 +1,240 @@
+package storage
+
+import (
+	"carwash-bot/internal/models"
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrPaymentNotPending = errors.New("платёж уже обработан")
+	ErrPaymentExpired    = errors.New("время на оплату истекло")
+)
+
+func (s *Storage) CreatePayment(ctx context.Context, payment *models.Payment) error {
+	payment.ID = uuid.New().String()
+	payment.CreatedAt = time.Now()
+	if payment.Status == "" {
+		payment.Status = models.PaymentPending
+	}
+
+	_, err := s.DB.ExecContext(ctx, `
+	INSERT INTO payments (id, booking_id, user_id, amount, currency, provider, status, created_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		payment.ID,
+		payment.BookingID,
+		payment.UserID,
+		payment.Amount,
+		payment.Currency,
+		payment.Provider,
+		payment.Status,
+		payment.CreatedAt)
+	return err
+}
+
+const paymentColumns = `id, booking_id, user_id, amount, currency, provider, status,
+	telegram_charge_id, provider_charge_id, refunded_amount, created_at`
+
+func scanPayment(row rowScanner) (*models.Payment, error) {
+	var p models.Payment
+	err := row.Scan(&p.ID, &p.BookingID, &p.UserID, &p.Amount, &p.Currency, &p.Provider, &p.Status,
+		&p.TelegramChargeID, &p.ProviderChargeID, &p.RefundedAmount, &p.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (s *Storage) GetPayment(ctx context.Context, paymentID string) (*models.Payment, error) {
+	payment, err := scanPayment(s.DB.QueryRowContext(ctx, `
+	SELECT `+paymentColumns+` FROM payments WHERE id = ?`, paymentID))
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return payment, err
+}
+
+// GetBookingPayment возвращает последний платёж по записи
+func (s *Storage) GetBookingPayment(ctx context.Context, bookingID string) (*models.Payment, error) {
+	payment, err := scanPayment(s.DB.QueryRowContext(ctx, `
+	SELECT `+paymentColumns+` FROM payments WHERE booking_id = ?
+	ORDER BY created_at DESC LIMIT 1`, bookingID))
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return payment, err
+}
+
+// ConfirmPayment отмечает платёж оплаченным и активирует запись
+func (s *Storage) ConfirmPayment(ctx context.Context, paymentID, telegramChargeID, providerChargeID string) (*models.Booking, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var (
+		bookingID string
+		status    string
+	)
+	err = tx.QueryRowContext(ctx, `
+	SELECT booking_id, status FROM payments WHERE id = ?`, paymentID).Scan(&bookingID, &status)
+	if err != nil {
+		return nil, err
+	}
+	if status != models.PaymentPending {
+		return nil, ErrPaymentNotPending
+	}
+
+	res, err := tx.ExecContext(ctx, `
+	UPDATE bookings SET status = ?, payment_deadline = NULL
+	WHERE id = ? AND status = ?`,
+		models.StatusActive, bookingID, models.StatusAwaitingPayment)
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, ErrPaymentExpired
+	}
+
+	if _, err = tx.ExecContext(ctx, `
+	UPDATE payments SET status = ?, telegram_charge_id = ?, provider_charge_id = ?
+	WHERE id = ?`,
+		models.PaymentPaid, telegramChargeID, providerChargeID, paymentID); err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+	return s.GetBooking(ctx, bookingID)
+}
+
+// RecordPaymentCharge сохраняет идентификаторы оплаты, которую не удалось
+// привязать к записи (например, оплата пришла после истечения срока)
+func (s *Storage) RecordPaymentCharge(ctx context.Context, paymentID, telegramChargeID, providerChargeID string) error {
+	_, err := s.DB.ExecContext(ctx, `
+	UPDATE payments SET status = ?, telegram_charge_id = ?, provider_charge_id = ?
+	WHERE id = ?`,
+		models.PaymentPaid, telegramChargeID, providerChargeID, paymentID)
+	return err
+}
+
+// RecordRefund фиксирует возврат по платежу. status — PaymentRefunded,
+// если деньги уже вернулись, или PaymentRefundPending для ручного возврата
+func (s *Storage) RecordRefund(ctx context.Context, paymentID string, amount int, status string) error {
+	_, err := s.DB.ExecContext(ctx, `
+	UPDATE payments SET status = ?, refunded_amount = refunded_amount + ?
+	WHERE id = ?`, status, amount, paymentID)
+	return err
+}
+
+// GetPaymentsByStatus возвращает платежи в указанном статусе, например
+// ожидающие ручного возврата
+func (s *Storage) GetPaymentsByStatus(ctx context.Context, status string) ([]*models.Payment, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+	SELECT `+paymentColumns+` FROM payments WHERE status = ? ORDER BY created_at`, status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var payments []*models.Payment
+	for rows.Next() {
+		p, err := scanPayment(rows)
+		if err != nil {
+			return nil, err
+		}
+		payments = append(payments, p)
+	}
+	return payments, rows.Err()
+}
+
+// ReleaseExpiredBookings освобождает слоты записей, не оплаченных до срока.
+// Списанные баллы возвращаются, ожидающие платежи отменяются
+func (s *Storage) ReleaseExpiredBookings(ctx context.Context, now time.Time) ([]*models.Booking, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+	SELECT id, user_id, date, time, car_model, car_number, points_used, payment_deadline
+	FROM bookings WHERE status = ?`, models.StatusAwaitingPayment)
+	if err != nil {
+		return nil, err
+	}
+
+	var expired []*models.Booking
+	for rows.Next() {
+		var (
+			b        models.Booking
+			deadline sql.NullTime
+		)
+		if err := rows.Scan(&b.ID, &b.UserID, &b.Date, &b.Time, &b.CarModel, &b.CarNumber,
+			&b.PointsUsed, &deadline); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if deadline.Valid && deadline.Time.Before(now) {
+			expired = append(expired, &b)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var released []*models.Booking
+	for _, booking := range expired {
+		ok, err := s.expireBooking(ctx, booking)
+		if err != nil {
+			return released, err
+		}
+		if ok {
+			booking.Status = models.StatusExpired
+			released = append(released, booking)
+		}
+	}
+	return released, nil
+}
+
+func (s *Storage) expireBooking(ctx context.Context, booking *models.Booking) (bool, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	// Запись могли оплатить, пока мы собирали список
+	res, err := tx.ExecContext(ctx, `
+	UPDATE bookings SET status = ? WHERE id = ? AND status = ?`,
+		models.StatusExpired, booking.ID, models.StatusAwaitingPayment)
+	if err != nil {
+		return false, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return false, nil
+	}
+
+	if _, err = tx.ExecContext(ctx, `
+	UPDATE payments SET status = ? WHERE booking_id = ? AND status = ?`,
+		models.PaymentCancelled, booking.ID, models.PaymentPending); err != nil {
+		return false, err
+	}
+
+	if booking.PointsUsed > 0 {
+		err = s.changeLoyaltyPoints(ctx, tx, &models.LoyaltyTransaction{
+			UserID:    booking.UserID,
+			Delta:     booking.PointsUsed,
+			Reason:    models.LoyaltyRefund,
+			BookingID: booking.ID,
+		})
+		if err != nil {
+			return false, err
+		}
+	}
+
+	return true, tx.Commit()
+}
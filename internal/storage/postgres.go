@@ -0,0 +1,328 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+// NewPostgresStorage подключается к PostgreSQL по адресу databaseURL
+// и применяет недостающие миграции
+func NewPostgresStorage(databaseURL string) (*Storage, error) {
+	db, err := sql.Open("pgx", databaseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	s := &Storage{DB: &DB{DB: db, postgres: true}}
+	if err := s.migratePostgres(context.Background()); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("миграции PostgreSQL: %w", err)
+	}
+	return s, nil
+}
+
+// Ключ advisory-блокировки, чтобы бот и выгрузка, запущенные
+// одновременно, не применяли миграции дважды
+const migrationLock = 7316504
+
+// postgresMigrations — схема базы PostgreSQL. Миграция применяется один
+// раз, её номер — позиция в списке. Уже выпущенные миграции не меняются,
+// изменения схемы добавляются в конец
+var postgresMigrations = []string{
+	// 1: схема на момент перехода с SQLite
+	`
+    CREATE TABLE users (
+        id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+        telegram_id BIGINT UNIQUE NOT NULL,
+        username TEXT,
+        first_name TEXT,
+        last_name TEXT,
+        language TEXT NOT NULL DEFAULT '',
+        phone TEXT NOT NULL DEFAULT '',
+        late_cancels INTEGER NOT NULL DEFAULT 0,
+        no_shows INTEGER NOT NULL DEFAULT 0,
+        created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+    );
+
+    CREATE TABLE bookings (
+        id TEXT PRIMARY KEY,
+        user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+        date TEXT NOT NULL,
+        time TEXT NOT NULL,
+        car_model TEXT NOT NULL,
+        car_number TEXT NOT NULL,
+        service TEXT NOT NULL DEFAULT '',
+        price INTEGER NOT NULL DEFAULT 0,
+        organization_id BIGINT NOT NULL DEFAULT 0,
+        status TEXT NOT NULL DEFAULT 'active',
+        discount INTEGER NOT NULL DEFAULT 0,
+        points_used INTEGER NOT NULL DEFAULT 0,
+        promo_code TEXT NOT NULL DEFAULT '',
+        payment_deadline TIMESTAMPTZ,
+        cancelled_at TIMESTAMPTZ,
+        late_cancel INTEGER NOT NULL DEFAULT 0,
+        bay INTEGER NOT NULL DEFAULT 1,
+        reminded INTEGER NOT NULL DEFAULT 0,
+        created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+    );
+
+    CREATE INDEX idx_bookings_user ON bookings(user_id);
+    CREATE INDEX idx_bookings_date ON bookings(date, time);
+    CREATE INDEX idx_bookings_org ON bookings(organization_id);
+    CREATE INDEX idx_bookings_status ON bookings(status);
+    CREATE INDEX idx_bookings_promo ON bookings(promo_code);
+
+    -- Строка на каждое время, на которое записывались. Её блокирует
+    -- транзакция, выбирающая пост, чтобы два клиента не заняли один пост
+    CREATE TABLE slot_locks (
+        date TEXT NOT NULL,
+        time TEXT NOT NULL,
+        PRIMARY KEY (date, time)
+    );
+
+    CREATE TABLE slot_holds (
+        user_id BIGINT PRIMARY KEY,
+        date TEXT NOT NULL,
+        time TEXT NOT NULL,
+        expires_at TIMESTAMPTZ NOT NULL
+    );
+
+    CREATE TABLE organizations (
+        id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+        name TEXT NOT NULL,
+        created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+    );
+
+    CREATE TABLE organization_members (
+        organization_id BIGINT NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+        telegram_id BIGINT NOT NULL,
+        role TEXT NOT NULL,
+        created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+        PRIMARY KEY (organization_id, telegram_id)
+    );
+
+    CREATE TABLE vehicles (
+        id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+        organization_id BIGINT NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+        car_model TEXT NOT NULL,
+        car_number TEXT NOT NULL,
+        created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+    );
+
+    CREATE TABLE loyalty_accounts (
+        user_id BIGINT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+        visits INTEGER NOT NULL DEFAULT 0,
+        points INTEGER NOT NULL DEFAULT 0,
+        updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+    );
+
+    CREATE TABLE loyalty_transactions (
+        id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+        user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+        delta INTEGER NOT NULL,
+        reason TEXT NOT NULL,
+        comment TEXT NOT NULL DEFAULT '',
+        booking_id TEXT NOT NULL DEFAULT '',
+        admin_id BIGINT NOT NULL DEFAULT 0,
+        created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+    );
+
+    CREATE INDEX idx_loyalty_transactions_user ON loyalty_transactions(user_id);
+
+    CREATE TABLE promo_codes (
+        code TEXT PRIMARY KEY,
+        percent INTEGER NOT NULL,
+        valid_from TIMESTAMPTZ NOT NULL,
+        valid_to TIMESTAMPTZ NOT NULL,
+        max_uses INTEGER NOT NULL DEFAULT 0,
+        per_user_limit INTEGER NOT NULL DEFAULT 0,
+        services TEXT NOT NULL DEFAULT '',
+        weekdays TEXT NOT NULL DEFAULT '',
+        hour_from INTEGER NOT NULL DEFAULT 0,
+        hour_to INTEGER NOT NULL DEFAULT 0,
+        active INTEGER NOT NULL DEFAULT 1,
+        created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+    );
+
+    CREATE TABLE payments (
+        id TEXT PRIMARY KEY,
+        booking_id TEXT NOT NULL,
+        user_id BIGINT NOT NULL,
+        amount INTEGER NOT NULL,
+        currency TEXT NOT NULL,
+        provider TEXT NOT NULL,
+        status TEXT NOT NULL,
+        telegram_charge_id TEXT NOT NULL DEFAULT '',
+        provider_charge_id TEXT NOT NULL DEFAULT '',
+        refunded_amount INTEGER NOT NULL DEFAULT 0,
+        created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+    );
+
+    CREATE INDEX idx_payments_booking ON payments(booking_id);
+
+    CREATE TABLE user_limits (
+        user_id BIGINT PRIMARY KEY,
+        max_active INTEGER NOT NULL,
+        max_per_day INTEGER NOT NULL,
+        min_gap_minutes INTEGER NOT NULL
+    );
+
+    CREATE TABLE outbox (
+        id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+        chat_id BIGINT NOT NULL,
+        text TEXT NOT NULL,
+        parse_mode TEXT NOT NULL DEFAULT '',
+        reply_markup TEXT NOT NULL DEFAULT '',
+        status TEXT NOT NULL,
+        attempts INTEGER NOT NULL DEFAULT 0,
+        last_error TEXT NOT NULL DEFAULT '',
+        next_attempt_at TIMESTAMPTZ NOT NULL,
+        created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+    );
+
+    CREATE INDEX idx_outbox_status ON outbox(status, next_attempt_at);
+
+    CREATE TABLE blocked_users (
+        telegram_id BIGINT PRIMARY KEY,
+        reason TEXT NOT NULL DEFAULT '',
+        admin_id BIGINT NOT NULL DEFAULT 0,
+        created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+    );
+
+    CREATE TABLE message_templates (
+        name TEXT NOT NULL,
+        lang TEXT NOT NULL,
+        body TEXT NOT NULL,
+        updated_by BIGINT NOT NULL DEFAULT 0,
+        updated_at TIMESTAMPTZ NOT NULL,
+        PRIMARY KEY (name, lang)
+    );
+
+    CREATE TABLE calendar_feeds (
+        token TEXT PRIMARY KEY,
+        telegram_id BIGINT UNIQUE NOT NULL,
+        created_at TIMESTAMPTZ NOT NULL
+    );
+    `,
+
+	// 2: мойки сети. Записи, удержания и блокировки времени относятся
+	// к мойке, 0 — основная мойка из настроек
+	`
+    CREATE TABLE locations (
+        id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+        name TEXT NOT NULL,
+        address TEXT NOT NULL DEFAULT '',
+        latitude DOUBLE PRECISION NOT NULL DEFAULT 0,
+        longitude DOUBLE PRECISION NOT NULL DEFAULT 0,
+        start_time INTEGER NOT NULL,
+        end_time INTEGER NOT NULL,
+        bays INTEGER NOT NULL DEFAULT 1,
+        services TEXT NOT NULL DEFAULT '',
+        created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+    );
+
+    CREATE TABLE location_admins (
+        location_id BIGINT NOT NULL REFERENCES locations(id) ON DELETE CASCADE,
+        telegram_id BIGINT NOT NULL,
+        PRIMARY KEY (location_id, telegram_id)
+    );
+
+    ALTER TABLE bookings ADD COLUMN location_id BIGINT NOT NULL DEFAULT 0;
+    CREATE INDEX idx_bookings_location ON bookings(location_id, date);
+
+    ALTER TABLE slot_holds ADD COLUMN location_id BIGINT NOT NULL DEFAULT 0;
+
+    ALTER TABLE slot_locks ADD COLUMN location_id BIGINT NOT NULL DEFAULT 0;
+    ALTER TABLE slot_locks DROP CONSTRAINT slot_locks_pkey;
+    ALTER TABLE slot_locks ADD PRIMARY KEY (location_id, date, time);
+    `,
+}
+
+// migratePostgres применяет миграции, которых ещё нет в schema_migrations.
+// Все миграции выполняются в одной транзакции: при ошибке схема не меняется
+func (s *Storage) migratePostgres(ctx context.Context) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(?)`, migrationLock); err != nil {
+		return err
+	}
+	if _, err = tx.ExecContext(ctx, `
+    CREATE TABLE IF NOT EXISTS schema_migrations (
+        version INTEGER PRIMARY KEY,
+        applied_at TIMESTAMPTZ NOT NULL
+    )`); err != nil {
+		return err
+	}
+
+	var applied int
+	if err = tx.QueryRowContext(ctx, `
+	SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&applied); err != nil {
+		return err
+	}
+	if applied > len(postgresMigrations) {
+		return fmt.Errorf("версия схемы %d новее программы (%d)", applied, len(postgresMigrations))
+	}
+
+	for i := applied; i < len(postgresMigrations); i++ {
+		if _, err = tx.ExecContext(ctx, postgresMigrations[i]); err != nil {
+			return fmt.Errorf("миграция %d: %w", i+1, err)
+		}
+		if _, err = tx.ExecContext(ctx, `
+		INSERT INTO schema_migrations (version, applied_at) VALUES (?, ?)`, i+1, time.Now()); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// lockSlot не даёт параллельным транзакциям занять один пост на одно
+// время: в PostgreSQL блокируется строка времени мойки в slot_locks до
+// конца транзакции. SQLite и так выполняет пишущие транзакции по одной
+func (s *Storage) lockSlot(ctx context.Context, tx *Tx, locationID int64, date, clock string) error {
+	if !s.DB.postgres {
+		return nil
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+	INSERT INTO slot_locks (location_id, date, time) VALUES (?, ?, ?) ON CONFLICT DO NOTHING`,
+		locationID, date, clock); err != nil {
+		return err
+	}
+	_, err := tx.ExecContext(ctx, `
+	SELECT 1 FROM slot_locks WHERE location_id = ? AND date = ? AND time = ? FOR UPDATE`,
+		locationID, date, clock)
+	return err
+}
+
+// lockRow блокирует строку до конца транзакции в PostgreSQL, чтобы
+// параллельные транзакции проверяли лимиты клиента и промокода по очереди
+func (s *Storage) lockRow(ctx context.Context, tx *Tx, query string, args ...any) error {
+	if !s.DB.postgres {
+		return nil
+	}
+	_, err := tx.ExecContext(ctx, query+" FOR UPDATE", args...)
+	return err
+}
+
+// forUpdate дописывается к выборке строки, которую транзакция затем
+// меняет, чтобы параллельная транзакция дождалась её окончания.
+// SQLite FOR UPDATE не поддерживает
+func (s *Storage) forUpdate() string {
+	if s.DB.postgres {
+		return " FOR UPDATE"
+	}
+	return ""
+}
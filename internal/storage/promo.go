@@ -0,0 +1,197 @@
+package storage
+
+import (
+	"carwash-bot/internal/models"
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrPromoNotFound      = errors.New("промокод не найден")
+	ErrPromoExpired       = errors.New("срок действия промокода истёк")
+	ErrPromoNotStarted    = errors.New("промокод ещё не действует")
+	ErrPromoExhausted     = errors.New("промокод больше не действует: лимит использований исчерпан")
+	ErrPromoUserLimit     = errors.New("вы уже использовали этот промокод")
+	ErrPromoWrongService  = errors.New("промокод не действует на выбранную услугу")
+	ErrPromoWrongSchedule = errors.New("промокод не действует в выбранный день или час")
+)
+
+func (s *Storage) CreatePromoCode(ctx context.Context, promo *models.PromoCode) error {
+	promo.CreatedAt = time.Now()
+	promo.Active = true
+
+	_, err := s.DB.ExecContext(ctx, `
+	INSERT INTO promo_codes (code, percent, valid_from, valid_to, max_uses, per_user_limit,
+		services, weekdays, hour_from, hour_to, active, created_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, 1, ?)`,
+		promo.Code,
+		promo.Percent,
+		promo.ValidFrom,
+		promo.ValidTo,
+		promo.MaxUses,
+		promo.PerUserLimit,
+		strings.Join(promo.Services, ","),
+		joinInts(promo.Weekdays),
+		promo.HourFrom,
+		promo.HourTo,
+		promo.CreatedAt)
+	return err
+}
+
+func (s *Storage) GetPromoCode(ctx context.Context, code string) (*models.PromoCode, error) {
+	promo, err := scanPromoCode(s.DB.QueryRowContext(ctx, `
+	SELECT code, percent, valid_from, valid_to, max_uses, per_user_limit,
+		services, weekdays, hour_from, hour_to, active, created_at,
+		(SELECT COUNT(*) FROM bookings WHERE promo_code = promo_codes.code AND status NOT IN `+releasedStatuses+`)
+	FROM promo_codes WHERE code = ?`, code))
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return promo, err
+}
+
+func (s *Storage) GetAllPromoCodes(ctx context.Context) ([]*models.PromoCode, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+	SELECT code, percent, valid_from, valid_to, max_uses, per_user_limit,
+		services, weekdays, hour_from, hour_to, active, created_at,
+		(SELECT COUNT(*) FROM bookings WHERE promo_code = promo_codes.code AND status NOT IN `+releasedStatuses+`)
+	FROM promo_codes ORDER BY created_at DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var promos []*models.PromoCode
+	for rows.Next() {
+		promo, err := scanPromoCode(rows)
+		if err != nil {
+			return nil, err
+		}
+		promos = append(promos, promo)
+	}
+	return promos, rows.Err()
+}
+
+func (s *Storage) DeactivatePromoCode(ctx context.Context, code string) error {
+	res, err := s.DB.ExecContext(ctx, `
+	UPDATE promo_codes SET active = 0 WHERE code = ?`, code)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrPromoNotFound
+	}
+	return nil
+}
+
+// ValidatePromoCode проверяет, что промокод можно применить к записи
+// пользователя на указанную услугу и время
+func (s *Storage) ValidatePromoCode(ctx context.Context, code string, userID int64, service string, at time.Time) (*models.PromoCode, error) {
+	promo, err := s.GetPromoCode(ctx, code)
+	if err != nil {
+		return nil, err
+	}
+	if promo == nil || !promo.Active {
+		return nil, ErrPromoNotFound
+	}
+
+	if at.Before(promo.ValidFrom) {
+		return nil, ErrPromoNotStarted
+	}
+	// Промокод действует до конца последнего дня
+	if !at.Before(promo.ValidTo.AddDate(0, 0, 1)) {
+		return nil, ErrPromoExpired
+	}
+
+	if len(promo.Services) > 0 && !slices.Contains(promo.Services, service) {
+		return nil, ErrPromoWrongService
+	}
+
+	weekday := int(at.Weekday())
+	if weekday == 0 {
+		weekday = 7
+	}
+	if len(promo.Weekdays) > 0 && !slices.Contains(promo.Weekdays, weekday) {
+		return nil, ErrPromoWrongSchedule
+	}
+	if at.Hour() < promo.HourFrom || (promo.HourTo > 0 && at.Hour() >= promo.HourTo) {
+		return nil, ErrPromoWrongSchedule
+	}
+
+	if err := s.checkPromoUsage(ctx, s.DB, code, userID, ""); err != nil {
+		return nil, err
+	}
+	return promo, nil
+}
+
+type queryRower interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// checkPromoUsage проверяет общий лимит и лимит на пользователя.
+// Запись exceptBookingID (только что созданная) в подсчёт не входит
+func (s *Storage) checkPromoUsage(ctx context.Context, q queryRower, code string, userID int64, exceptBookingID string) error {
+	var maxUses, perUser, total, byUser int
+	err := q.QueryRowContext(ctx, `
+	SELECT max_uses, per_user_limit,
+		(SELECT COUNT(*) FROM bookings WHERE promo_code = ? AND id <> ? AND status NOT IN `+releasedStatuses+`),
+		(SELECT COUNT(*) FROM bookings WHERE promo_code = ? AND id <> ? AND user_id = ? AND status NOT IN `+releasedStatuses+`)
+	FROM promo_codes WHERE code = ?`,
+		code, exceptBookingID, code, exceptBookingID, userID, code).Scan(&maxUses, &perUser, &total, &byUser)
+	if err == sql.ErrNoRows {
+		return ErrPromoNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	if maxUses > 0 && total >= maxUses {
+		return ErrPromoExhausted
+	}
+	if perUser > 0 && byUser >= perUser {
+		return ErrPromoUserLimit
+	}
+	return nil
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanPromoCode(row rowScanner) (*models.PromoCode, error) {
+	var (
+		promo    models.PromoCode
+		services string
+		weekdays string
+	)
+	err := row.Scan(&promo.Code, &promo.Percent, &promo.ValidFrom, &promo.ValidTo,
+		&promo.MaxUses, &promo.PerUserLimit, &services, &weekdays,
+		&promo.HourFrom, &promo.HourTo, &promo.Active, &promo.CreatedAt, &promo.Uses)
+	if err != nil {
+		return nil, err
+	}
+
+	if services != "" {
+		promo.Services = strings.Split(services, ",")
+	}
+	for _, day := range strings.Split(weekdays, ",") {
+		if n, err := strconv.Atoi(day); err == nil {
+			promo.Weekdays = append(promo.Weekdays, n)
+		}
+	}
+	return &promo, nil
+}
+
+func joinInts(values []int) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = strconv.Itoa(v)
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,73 @@
+package storage
+
+import (
+	"carwash-bot/internal/models"
+	"context"
+	"sort"
+)
+
+// GetDayReport подводит итоги дня по записям на дату date на мойках
+// locations, nil — по всей сети
+func (s *Storage) GetDayReport(ctx context.Context, date string, locations []int64) (*models.DayReport, error) {
+	report := &models.DayReport{Date: date, Bays: make(map[int]int)}
+
+	clause, locationArgs := locationFilter("location_id", locations)
+	rows, err := s.DB.QueryContext(ctx, `
+	SELECT status, late_cancel, bay, service, price FROM bookings
+	WHERE date = ? AND status <> ?`+clause, append([]any{date, models.StatusExpired}, locationArgs...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	services := make(map[string]*models.ServiceStats)
+	for rows.Next() {
+		var (
+			status, service string
+			late            bool
+			bay, price      int
+		)
+		if err := rows.Scan(&status, &late, &bay, &service, &price); err != nil {
+			return nil, err
+		}
+
+		switch status {
+		case models.StatusCancelled:
+			report.Cancelled++
+			if late {
+				report.LateCancels++
+			}
+			continue
+		case models.StatusCompleted:
+			report.Completed++
+			report.Revenue += price
+			stats, ok := services[service]
+			if !ok {
+				stats = &models.ServiceStats{Service: service}
+				services[service] = stats
+			}
+			stats.Count++
+			stats.Revenue += price
+		case models.StatusNoShow:
+			report.NoShows++
+		default:
+			report.Unmarked++
+		}
+		report.Bays[bay]++
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, stats := range services {
+		report.Services = append(report.Services, *stats)
+	}
+	// Сначала услуги, принёсшие больше выручки
+	sort.Slice(report.Services, func(i, j int) bool {
+		if report.Services[i].Revenue != report.Services[j].Revenue {
+			return report.Services[i].Revenue > report.Services[j].Revenue
+		}
+		return report.Services[i].Service < report.Services[j].Service
+	})
+	return report, nil
+}
@@ -0,0 +1,188 @@
+package storage
+
+import (
+	"carwash-bot/internal/models"
+	"context"
+	"time"
+)
+
+// BookingRepository — записи и занятость постов. Занятость считается
+// отдельно по каждой мойке сети. Поведение, которого ждут от реализаций,
+// описывает набор проверок в storagetest
+type BookingRepository interface {
+	LocationRepository
+
+	// CreateBooking выдаёт записи ID и свободный пост на её мойке.
+	// ErrSlotTaken — если постов на это время не осталось с учётом чужих
+	// удержаний
+	CreateBooking(ctx context.Context, booking *models.Booking) error
+	// GetBooking возвращает nil без ошибки, если записи нет
+	GetBooking(ctx context.Context, bookingID string) (*models.Booking, error)
+	// GetUserBookings возвращает предстоящие записи пользователя по дате и времени
+	GetUserBookings(ctx context.Context, userID int64) ([]*models.Booking, error)
+	CancelBooking(ctx context.Context, bookingID string, userID int64, late bool) (*models.Booking, error)
+	RescheduleBooking(ctx context.Context, bookingID, date, clock string) (*models.Booking, error)
+	// SetBookingStatus переводит запись из статуса from в статус to,
+	// sql.ErrNoRows — если запись уже в другом статусе
+	SetBookingStatus(ctx context.Context, bookingID, from, to string) (*models.Booking, error)
+	// CompleteBooking и MarkNoShow отмечают визит по активной записи.
+	// Неявка засчитывается клиенту как нарушение
+	CompleteBooking(ctx context.Context, bookingID string, cashback int) (*models.Booking, error)
+	MarkNoShow(ctx context.Context, bookingID string) (*models.Booking, error)
+
+	GetDayOccupancy(ctx context.Context, locationID int64, date string, exceptUserID int64) (map[string]int, error)
+	GetMonthOccupancy(ctx context.Context, locationID int64, month time.Month, year int, exceptUserID int64) (map[string]map[string]int, error)
+	HoldSlot(ctx context.Context, userID, locationID int64, date, clock string, until time.Time) error
+	ReleaseHold(ctx context.Context, userID int64) error
+}
+
+// LocationRepository — мойки сети. Основная мойка с ID 0 описана в
+// настройках, её посты — поле Bays хранилища
+type LocationRepository interface {
+	CreateLocation(ctx context.Context, location *models.Location) error
+	// GetLocation возвращает nil без ошибки, если мойки нет
+	GetLocation(ctx context.Context, locationID int64) (*models.Location, error)
+	// GetLocations возвращает мойки из хранилища в порядке создания
+	GetLocations(ctx context.Context) ([]*models.Location, error)
+}
+
+// UserRepository — пользователи бота
+type UserRepository interface {
+	CreateOrUpdateUser(ctx context.Context, user *models.User) error
+	// GetUserByID и GetUserByTelegramID возвращают nil без ошибки, если
+	// пользователя нет
+	GetUserByID(ctx context.Context, userID int64) (*models.User, error)
+	GetUserByTelegramID(ctx context.Context, telegramID int64) (*models.User, error)
+	SetUserPhone(ctx context.Context, telegramID int64, phone string) error
+	SetUserLanguage(ctx context.Context, telegramID int64, lang string) error
+}
+
+// Repository объединяет записи и пользователей. Его реализуют и SQL-хранилище,
+// и хранилище в памяти
+type Repository interface {
+	BookingRepository
+	UserRepository
+}
+
+// ScheduleRepository — выборки записей для расписания, отчётов,
+// напоминаний и выгрузок
+type ScheduleRepository interface {
+	GetSchedule(ctx context.Context, dates []string, filter models.ScheduleFilter) ([]*models.Booking, error)
+	GetBookingsInPeriod(ctx context.Context, from, to time.Time, filter models.ScheduleFilter) ([]*models.Booking, error)
+	GetAllBookings(ctx context.Context) ([]*models.Booking, error)
+	GetDayReport(ctx context.Context, date string, locations []int64) (*models.DayReport, error)
+	GetStats(ctx context.Context, from, to time.Time) (*models.Stats, error)
+	GetCustomers(ctx context.Context) ([]*models.CustomerSummary, error)
+	GetBookingsToRemind(ctx context.Context, dates ...string) ([]*models.Booking, error)
+	MarkReminded(ctx context.Context, bookingID string) error
+}
+
+// LocationAdminRepository — изменение моек сети и их администраторов
+type LocationAdminRepository interface {
+	UpdateLocation(ctx context.Context, location *models.Location) error
+	AddLocationAdmin(ctx context.Context, locationID, telegramID int64) error
+	RemoveLocationAdmin(ctx context.Context, locationID, telegramID int64) error
+	GetAdminLocations(ctx context.Context, telegramID int64) ([]int64, error)
+}
+
+// RestrictionRepository — нарушения, лимиты и блокировки клиентов
+type RestrictionRepository interface {
+	ResetUserStrikes(ctx context.Context, userID int64) error
+	GetUserLimits(ctx context.Context, userID int64) (models.BookingLimits, bool, error)
+	SetUserLimits(ctx context.Context, userID int64, limits models.BookingLimits) error
+	ResetUserLimits(ctx context.Context, userID int64) error
+	BlockUser(ctx context.Context, blocked *models.BlockedUser) error
+	UnblockUser(ctx context.Context, telegramID int64) error
+	GetBlockedUser(ctx context.Context, telegramID int64) (*models.BlockedUser, error)
+	GetBlockedUsers(ctx context.Context) ([]*models.BlockedUser, error)
+}
+
+// LoyaltyRepository — бонусные счета клиентов
+type LoyaltyRepository interface {
+	GetLoyaltyAccount(ctx context.Context, userID int64) (*models.LoyaltyAccount, error)
+	GetLoyaltyTransactions(ctx context.Context, userID int64, limit int) ([]*models.LoyaltyTransaction, error)
+	CountUpcomingBookings(ctx context.Context, userID int64) (int, error)
+	RevokeFreeVisits(ctx context.Context, userID int64, everyNth int) ([]*models.Booking, error)
+	AdjustLoyaltyPoints(ctx context.Context, transaction *models.LoyaltyTransaction) error
+}
+
+// OrganizationRepository — организации, их участники и автопарк
+type OrganizationRepository interface {
+	CreateOrganization(ctx context.Context, org *models.Organization) error
+	GetOrganization(ctx context.Context, orgID int64) (*models.Organization, error)
+	GetAllOrganizations(ctx context.Context) ([]*models.Organization, error)
+	AddOrganizationMember(ctx context.Context, member *models.OrganizationMember) error
+	RemoveOrganizationMember(ctx context.Context, orgID, telegramID int64) error
+	GetOrganizationMember(ctx context.Context, orgID, telegramID int64) (*models.OrganizationMember, error)
+	GetOrganizationMembers(ctx context.Context, orgID int64) ([]*models.OrganizationMember, error)
+	GetUserMemberships(ctx context.Context, telegramID int64) ([]*models.OrganizationMember, error)
+	AddVehicle(ctx context.Context, vehicle *models.Vehicle) error
+	GetVehicle(ctx context.Context, vehicleID int64) (*models.Vehicle, error)
+	GetOrganizationVehicles(ctx context.Context, orgID int64) ([]*models.Vehicle, error)
+	GetOrganizationStatement(ctx context.Context, orgID int64, month, year int) ([]*models.Booking, error)
+}
+
+// OutboxRepository — очередь исходящих сообщений
+type OutboxRepository interface {
+	EnqueueMessage(ctx context.Context, msg *models.OutboxMessage) error
+	GetDueMessages(ctx context.Context, now time.Time, limit int) ([]*models.OutboxMessage, error)
+	ClaimMessage(ctx context.Context, id int64, now, until time.Time) (bool, error)
+	GetUndeliveredMessages(ctx context.Context, limit int) ([]*models.OutboxMessage, error)
+	GetOutboxMessage(ctx context.Context, id int64) (*models.OutboxMessage, error)
+	MarkMessageSent(ctx context.Context, id int64) error
+	MarkMessageFailed(ctx context.Context, id int64, errText string, nextAttempt time.Time, dead bool) error
+	RetryMessage(ctx context.Context, id int64) error
+	DeleteSentMessages(ctx context.Context, before time.Time) error
+}
+
+// PaymentRepository — предоплаты и возвраты
+type PaymentRepository interface {
+	CreatePayment(ctx context.Context, payment *models.Payment) error
+	GetPayment(ctx context.Context, paymentID string) (*models.Payment, error)
+	GetBookingPayment(ctx context.Context, bookingID string) (*models.Payment, error)
+	ConfirmPayment(ctx context.Context, paymentID, telegramChargeID, providerChargeID string) (*models.Booking, error)
+	RecordPaymentCharge(ctx context.Context, paymentID, telegramChargeID, providerChargeID string) error
+	RecordRefund(ctx context.Context, paymentID string, amount int, status string) error
+	GetPaymentsByStatus(ctx context.Context, status string) ([]*models.Payment, error)
+	ReleaseExpiredBookings(ctx context.Context, now time.Time) ([]*models.Booking, error)
+}
+
+// PromoRepository — промокоды
+type PromoRepository interface {
+	CreatePromoCode(ctx context.Context, promo *models.PromoCode) error
+	GetPromoCode(ctx context.Context, code string) (*models.PromoCode, error)
+	GetAllPromoCodes(ctx context.Context) ([]*models.PromoCode, error)
+	DeactivatePromoCode(ctx context.Context, code string) error
+	ValidatePromoCode(ctx context.Context, code string, userID int64, service string, at time.Time) (*models.PromoCode, error)
+}
+
+// TemplateRepository — тексты сообщений, изменённые администратором
+type TemplateRepository interface {
+	GetMessageTemplate(ctx context.Context, name, lang string) (string, error)
+	SetMessageTemplate(ctx context.Context, tmpl *models.MessageTemplate) error
+	DeleteMessageTemplate(ctx context.Context, name, lang string) error
+	GetMessageTemplates(ctx context.Context) ([]*models.MessageTemplate, error)
+}
+
+// CalendarRepository — секретные ссылки на ленту календаря
+type CalendarRepository interface {
+	CalendarFeedToken(ctx context.Context, telegramID int64, reset bool) (string, error)
+	GetCalendarFeedOwner(ctx context.Context, token string) (int64, error)
+}
+
+// Store — всё хранилище, которым пользуется бот
+type Store interface {
+	Repository
+	ScheduleRepository
+	LocationAdminRepository
+	RestrictionRepository
+	LoyaltyRepository
+	OrganizationRepository
+	OutboxRepository
+	PaymentRepository
+	PromoRepository
+	TemplateRepository
+	CalendarRepository
+}
+
+var _ Store = (*Storage)(nil)
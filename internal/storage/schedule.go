@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"carwash-bot/internal/models"
+	"context"
+	"database/sql"
+	"strings"
+)
+
+// Поля записи и клиента для расписания
+const scheduleColumns = `b.id, b.user_id, b.date, b.time, b.car_model, b.car_number, b.service, b.price,
+			b.organization_id, b.status, b.bay, b.location_id,
+			COALESCE(u.telegram_id, 0), COALESCE(u.username, ''), COALESCE(u.first_name, ''),
+			COALESCE(u.phone, '')`
+
+// GetSchedule возвращает записи на указанные даты вместе с клиентами,
+// упорядоченные по времени и посту
+func (s *Storage) GetSchedule(ctx context.Context, dates []string, filter models.ScheduleFilter) ([]*models.Booking, error) {
+	if len(dates) == 0 {
+		return nil, nil
+	}
+
+	query := `SELECT ` + scheduleColumns + `
+		FROM bookings b LEFT JOIN users u ON u.id = b.user_id
+		WHERE b.date IN (?` + strings.Repeat(", ?", len(dates)-1) + `)`
+	args := make([]any, 0, len(dates)+3)
+	for _, date := range dates {
+		args = append(args, date)
+	}
+
+	if filter.Status != "" {
+		query += ` AND b.status = ?`
+		args = append(args, filter.Status)
+	} else {
+		query += ` AND b.status NOT IN ` + releasedStatuses
+	}
+	if filter.Bay > 0 {
+		query += ` AND b.bay = ?`
+		args = append(args, filter.Bay)
+	}
+	if filter.Service != "" {
+		query += ` AND b.service = ?`
+		args = append(args, filter.Service)
+	}
+	clause, locationArgs := locationFilter("b.location_id", filter.Locations)
+	query += clause
+	args = append(args, locationArgs...)
+
+	rows, err := s.DB.QueryContext(ctx, query+` ORDER BY b.time, b.location_id, b.bay`, args...)
+	if err != nil {
+		return nil, err
+	}
+	return scanScheduleRows(rows)
+}
+
+func scanScheduleRows(rows *sql.Rows) ([]*models.Booking, error) {
+	defer rows.Close()
+
+	var bookings []*models.Booking
+	for rows.Next() {
+		b := models.Booking{User: &models.User{}}
+		if err := rows.Scan(&b.ID, &b.UserID, &b.Date, &b.Time, &b.CarModel, &b.CarNumber,
+			&b.Service, &b.Price, &b.OrganizationID, &b.Status, &b.Bay, &b.LocationID,
+			&b.User.TelegramID, &b.User.Username, &b.User.FirstName, &b.User.Phone); err != nil {
+			return nil, err
+		}
+		b.User.ID = b.UserID
+		bookings = append(bookings, &b)
+	}
+	return bookings, rows.Err()
+}
@@ -3,6 +3,9 @@ package storage
 import (
 	"carwash-bot/internal/models"
 	"context"
+	"database/sql"
+	"errors"
+	"fmt"
 	"time"
 
 	"github.com/google/uuid"
@@ -17,6 +20,10 @@ func (s *Storage) Init() error {
         username TEXT,
         first_name TEXT,
         last_name TEXT,
+        language TEXT NOT NULL DEFAULT '',
+        phone TEXT NOT NULL DEFAULT '',
+        late_cancels INTEGER NOT NULL DEFAULT 0,
+        no_shows INTEGER NOT NULL DEFAULT 0,
         created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
     );
     
@@ -27,37 +34,366 @@ func (s *Storage) Init() error {
         time TEXT NOT NULL,
         car_model TEXT NOT NULL,
         car_number TEXT NOT NULL,
+        service TEXT NOT NULL DEFAULT '',
+        price INTEGER NOT NULL DEFAULT 0,
+        organization_id INTEGER NOT NULL DEFAULT 0,
+        status TEXT NOT NULL DEFAULT 'active',
+        discount INTEGER NOT NULL DEFAULT 0,
+        points_used INTEGER NOT NULL DEFAULT 0,
+        promo_code TEXT NOT NULL DEFAULT '',
+        payment_deadline TIMESTAMP,
+        cancelled_at TIMESTAMP,
+        late_cancel INTEGER NOT NULL DEFAULT 0,
+        bay INTEGER NOT NULL DEFAULT 1,
+        reminded INTEGER NOT NULL DEFAULT 0,
+        location_id INTEGER NOT NULL DEFAULT 0,
         created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
         FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
     );
     
     CREATE INDEX IF NOT EXISTS idx_bookings_user ON bookings(user_id);
     CREATE INDEX IF NOT EXISTS idx_bookings_date ON bookings(date);
+
+    CREATE TABLE IF NOT EXISTS organizations (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        name TEXT NOT NULL,
+        created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+    );
+
+    CREATE TABLE IF NOT EXISTS organization_members (
+        organization_id INTEGER NOT NULL,
+        telegram_id INTEGER NOT NULL,
+        role TEXT NOT NULL,
+        created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+        PRIMARY KEY (organization_id, telegram_id),
+        FOREIGN KEY (organization_id) REFERENCES organizations(id) ON DELETE CASCADE
+    );
+
+    CREATE TABLE IF NOT EXISTS vehicles (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        organization_id INTEGER NOT NULL,
+        car_model TEXT NOT NULL,
+        car_number TEXT NOT NULL,
+        created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+        FOREIGN KEY (organization_id) REFERENCES organizations(id) ON DELETE CASCADE
+    );
+
+    CREATE TABLE IF NOT EXISTS loyalty_accounts (
+        user_id INTEGER PRIMARY KEY,
+        visits INTEGER NOT NULL DEFAULT 0,
+        points INTEGER NOT NULL DEFAULT 0,
+        updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+        FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+    );
+
+    CREATE TABLE IF NOT EXISTS loyalty_transactions (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        user_id INTEGER NOT NULL,
+        delta INTEGER NOT NULL,
+        reason TEXT NOT NULL,
+        comment TEXT NOT NULL DEFAULT '',
+        booking_id TEXT NOT NULL DEFAULT '',
+        admin_id INTEGER NOT NULL DEFAULT 0,
+        created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+        FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+    );
+
+    CREATE INDEX IF NOT EXISTS idx_loyalty_transactions_user ON loyalty_transactions(user_id);
+
+    CREATE TABLE IF NOT EXISTS promo_codes (
+        code TEXT PRIMARY KEY,
+        percent INTEGER NOT NULL,
+        valid_from TIMESTAMP NOT NULL,
+        valid_to TIMESTAMP NOT NULL,
+        max_uses INTEGER NOT NULL DEFAULT 0,
+        per_user_limit INTEGER NOT NULL DEFAULT 0,
+        services TEXT NOT NULL DEFAULT '',
+        weekdays TEXT NOT NULL DEFAULT '',
+        hour_from INTEGER NOT NULL DEFAULT 0,
+        hour_to INTEGER NOT NULL DEFAULT 0,
+        active INTEGER NOT NULL DEFAULT 1,
+        created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+    );
+
+    CREATE TABLE IF NOT EXISTS payments (
+        id TEXT PRIMARY KEY,
+        booking_id TEXT NOT NULL,
+        user_id INTEGER NOT NULL,
+        amount INTEGER NOT NULL,
+        currency TEXT NOT NULL,
+        provider TEXT NOT NULL,
+        status TEXT NOT NULL,
+        telegram_charge_id TEXT NOT NULL DEFAULT '',
+        provider_charge_id TEXT NOT NULL DEFAULT '',
+        refunded_amount INTEGER NOT NULL DEFAULT 0,
+        created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+    );
+
+    CREATE INDEX IF NOT EXISTS idx_payments_booking ON payments(booking_id);
+
+    CREATE TABLE IF NOT EXISTS user_limits (
+        user_id INTEGER PRIMARY KEY,
+        max_active INTEGER NOT NULL,
+        max_per_day INTEGER NOT NULL,
+        min_gap_minutes INTEGER NOT NULL
+    );
+
+    CREATE TABLE IF NOT EXISTS outbox (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        chat_id INTEGER NOT NULL,
+        text TEXT NOT NULL,
+        parse_mode TEXT NOT NULL DEFAULT '',
+        reply_markup TEXT NOT NULL DEFAULT '',
+        status TEXT NOT NULL,
+        attempts INTEGER NOT NULL DEFAULT 0,
+        last_error TEXT NOT NULL DEFAULT '',
+        next_attempt_at TIMESTAMP NOT NULL,
+        created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+    );
+
+    CREATE INDEX IF NOT EXISTS idx_outbox_status ON outbox(status, next_attempt_at);
+
+    CREATE TABLE IF NOT EXISTS slot_holds (
+        user_id INTEGER PRIMARY KEY,
+        location_id INTEGER NOT NULL DEFAULT 0,
+        date TEXT NOT NULL,
+        time TEXT NOT NULL,
+        expires_at TIMESTAMP NOT NULL
+    );
+
+    CREATE TABLE IF NOT EXISTS blocked_users (
+        telegram_id INTEGER PRIMARY KEY,
+        reason TEXT NOT NULL DEFAULT '',
+        admin_id INTEGER NOT NULL DEFAULT 0,
+        created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+    );
+
+    CREATE TABLE IF NOT EXISTS message_templates (
+        name TEXT NOT NULL,
+        lang TEXT NOT NULL,
+        body TEXT NOT NULL,
+        updated_by INTEGER NOT NULL DEFAULT 0,
+        updated_at TIMESTAMP NOT NULL,
+        PRIMARY KEY (name, lang)
+    );
+
+    CREATE TABLE IF NOT EXISTS calendar_feeds (
+        token TEXT PRIMARY KEY,
+        telegram_id INTEGER UNIQUE NOT NULL,
+        created_at TIMESTAMP NOT NULL
+    );
+
+    CREATE TABLE IF NOT EXISTS locations (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        name TEXT NOT NULL,
+        address TEXT NOT NULL DEFAULT '',
+        latitude REAL NOT NULL DEFAULT 0,
+        longitude REAL NOT NULL DEFAULT 0,
+        start_time INTEGER NOT NULL,
+        end_time INTEGER NOT NULL,
+        bays INTEGER NOT NULL DEFAULT 1,
+        services TEXT NOT NULL DEFAULT '',
+        created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+    );
+
+    CREATE TABLE IF NOT EXISTS location_admins (
+        location_id INTEGER NOT NULL,
+        telegram_id INTEGER NOT NULL,
+        PRIMARY KEY (location_id, telegram_id),
+        FOREIGN KEY (location_id) REFERENCES locations(id) ON DELETE CASCADE
+    );
+    `)
+	if err != nil {
+		return err
+	}
+
+	// Базы, созданные старыми версиями, не содержат новых колонок
+	err = s.migrate([]column{
+		{"users", "last_name", "TEXT", "''"},
+		{"users", "created_at", "TIMESTAMP", "CURRENT_TIMESTAMP"},
+		{"bookings", "service", "TEXT NOT NULL DEFAULT ''", ""},
+		{"bookings", "price", "INTEGER NOT NULL DEFAULT 0", ""},
+		{"bookings", "organization_id", "INTEGER NOT NULL DEFAULT 0", ""},
+		{"bookings", "status", "TEXT NOT NULL DEFAULT 'active'", ""},
+		{"bookings", "discount", "INTEGER NOT NULL DEFAULT 0", ""},
+		{"bookings", "points_used", "INTEGER NOT NULL DEFAULT 0", ""},
+		{"bookings", "promo_code", "TEXT NOT NULL DEFAULT ''", ""},
+		{"bookings", "payment_deadline", "TIMESTAMP", ""},
+		{"bookings", "cancelled_at", "TIMESTAMP", ""},
+		{"bookings", "late_cancel", "INTEGER NOT NULL DEFAULT 0", ""},
+		{"bookings", "bay", "INTEGER NOT NULL DEFAULT 1", ""},
+		{"users", "late_cancels", "INTEGER NOT NULL DEFAULT 0", ""},
+		{"users", "no_shows", "INTEGER NOT NULL DEFAULT 0", ""},
+		{"users", "language", "TEXT NOT NULL DEFAULT ''", ""},
+		{"bookings", "reminded", "INTEGER NOT NULL DEFAULT 0", ""},
+		{"users", "phone", "TEXT NOT NULL DEFAULT ''", ""},
+		{"bookings", "location_id", "INTEGER NOT NULL DEFAULT 0", ""},
+		{"slot_holds", "location_id", "INTEGER NOT NULL DEFAULT 0", ""},
+	})
+	if err != nil {
+		return err
+	}
+
+	// Индексы по добавленным колонкам создаём после миграции
+	_, err = s.DB.Exec(`
+    CREATE INDEX IF NOT EXISTS idx_bookings_org ON bookings(organization_id);
+    CREATE INDEX IF NOT EXISTS idx_bookings_status ON bookings(status);
+    CREATE INDEX IF NOT EXISTS idx_bookings_promo ON bookings(promo_code);
+    CREATE INDEX IF NOT EXISTS idx_bookings_location ON bookings(location_id, date);
     `)
 	return err
 }
 
+type column struct {
+	table      string
+	name       string
+	definition string
+	// Значение для уже существующих строк, если колонка nullable
+	backfill string
+}
+
+// migrate добавляет недостающие колонки в существующие таблицы
+func (s *Storage) migrate(columns []column) error {
+	for _, c := range columns {
+		exists, err := s.hasColumn(c.table, c.name)
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+		if _, err := s.DB.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", c.table, c.name, c.definition)); err != nil {
+			return err
+		}
+		if c.backfill != "" {
+			if _, err := s.DB.Exec(fmt.Sprintf("UPDATE %s SET %s = %s WHERE %s IS NULL", c.table, c.name, c.backfill, c.name)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *Storage) hasColumn(table, name string) (bool, error) {
+	rows, err := s.DB.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid        int
+			colName    string
+			colType    string
+			notNull    int
+			defaultVal sql.NullString
+			pk         int
+		)
+		if err := rows.Scan(&cid, &colName, &colType, &notNull, &defaultVal, &pk); err != nil {
+			return false, err
+		}
+		if colName == name {
+			return true, nil
+		}
+	}
+	return false, rows.Err()
+}
+
+var ErrSlotTaken = errors.New("это время уже занято")
+
+// Записи в этих статусах не занимают слот и не считаются использованием промокода
+const releasedStatuses = `('expired', 'cancelled')`
+
+// Записи в этих статусах клиент видит в «Моих записях» и может отменить
+const upcomingStatuses = `('active', 'awaiting_payment', 'pending_approval')`
+
 func (s *Storage) CreateBooking(ctx context.Context, booking *models.Booking) error {
 	booking.ID = uuid.New().String()
 	booking.CreatedAt = time.Now()
+	if booking.Status == "" {
+		booking.Status = models.StatusActive
+	}
 
-	_, err := s.DB.ExecContext(ctx, `
-	INSERT INTO bookings (id, user_id, date, time, car_model, car_number)
-	VALUES (?, ?, ?, ?, ?, ?)`,
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Записи одного клиента создаются по очереди, иначе параллельные
+	// запросы на разное время пройдут лимиты клиента вместе
+	if err = s.lockRow(ctx, tx, `SELECT id FROM users WHERE id = ?`, booking.UserID); err != nil {
+		return err
+	}
+	if err = s.checkBookingLimits(ctx, tx, booking); err != nil {
+		return err
+	}
+	if booking.Bay, err = s.freeBay(ctx, tx, booking.UserID, booking.LocationID, booking.Date, booking.Time); err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+	INSERT INTO bookings (id, user_id, date, time, car_model, car_number, service, price,
+		organization_id, status, discount, points_used, promo_code, payment_deadline, bay, location_id)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
 		booking.ID,
 		booking.UserID,
 		booking.Date,
 		booking.Time,
 		booking.CarModel,
-		booking.CarNumber)
+		booking.CarNumber,
+		booking.Service,
+		booking.Price,
+		booking.OrganizationID,
+		booking.Status,
+		booking.Discount,
+		booking.PointsUsed,
+		booking.PromoCode,
+		nullTime(booking.PaymentDeadline),
+		booking.Bay,
+		booking.LocationID)
+	if err != nil {
+		return err
+	}
 
-	return err
+	// Слот больше не нужно удерживать
+	if _, err = tx.ExecContext(ctx, `DELETE FROM slot_holds WHERE user_id = ?`, booking.UserID); err != nil {
+		return err
+	}
+
+	// Лимиты промокода проверяем повторно уже внутри транзакции, под
+	// блокировкой промокода
+	if booking.PromoCode != "" {
+		if err = s.lockRow(ctx, tx, `SELECT code FROM promo_codes WHERE code = ?`, booking.PromoCode); err != nil {
+			return err
+		}
+		if err = s.checkPromoUsage(ctx, tx, booking.PromoCode, booking.UserID, booking.ID); err != nil {
+			return err
+		}
+	}
+
+	// Бонусные баллы списываются вместе с созданием записи
+	if booking.PointsUsed > 0 {
+		err = s.changeLoyaltyPoints(ctx, tx, &models.LoyaltyTransaction{
+			UserID:    booking.UserID,
+			Delta:     -booking.PointsUsed,
+			Reason:    models.LoyaltyRedeem,
+			BookingID: booking.ID,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
 }
 
 func (s *Storage) GetUserBookings(ctx context.Context, userID int64) ([]*models.Booking, error) {
 	rows, err := s.DB.QueryContext(ctx, `
-	SELECT id, date, time, car_model, car_number, created_at
-	FROM bookings WHERE user_id = ? ORDER BY date, time`, userID)
+	SELECT id, date, time, car_model, car_number, service, price, organization_id,
+		status, discount, points_used, location_id, created_at
+	FROM bookings WHERE user_id = ? AND status IN `+upcomingStatuses+` ORDER BY date, time`, userID)
 	if err != nil {
 		return nil, err
 	}
@@ -66,44 +402,183 @@ func (s *Storage) GetUserBookings(ctx context.Context, userID int64) ([]*models.
 	var bookings []*models.Booking
 	for rows.Next() {
 		var b models.Booking
-		if err := rows.Scan(&b.ID, &b.Date, &b.Time, &b.CarModel, &b.CarNumber, &b.CreatedAt); err != nil {
+		if err := rows.Scan(&b.ID, &b.Date, &b.Time, &b.CarModel, &b.CarNumber,
+			&b.Service, &b.Price, &b.OrganizationID,
+			&b.Status, &b.Discount, &b.PointsUsed, &b.LocationID, &b.CreatedAt); err != nil {
 			return nil, err
 		}
 		bookings = append(bookings, &b)
 	}
 	return bookings, nil
 }
+func (s *Storage) GetBooking(ctx context.Context, bookingID string) (*models.Booking, error) {
+	var (
+		b        models.Booking
+		deadline sql.NullTime
+	)
+	err := s.DB.QueryRowContext(ctx, `
+	SELECT id, user_id, date, time, car_model, car_number, service, price, organization_id,
+		status, discount, points_used, promo_code, payment_deadline, bay, location_id, created_at
+	FROM bookings WHERE id = ?`, bookingID).Scan(&b.ID, &b.UserID, &b.Date, &b.Time,
+		&b.CarModel, &b.CarNumber, &b.Service, &b.Price, &b.OrganizationID,
+		&b.Status, &b.Discount, &b.PointsUsed, &b.PromoCode, &deadline, &b.Bay, &b.LocationID, &b.CreatedAt)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	b.PaymentDeadline = deadline.Time
+	return &b, err
+}
+
 func (s *Storage) Close() error {
 	return s.DB.Close()
 }
-func (s *Storage) IsTimeAvailable(ctx context.Context, date, time string) (bool, error) {
+
+// slotLoad считает записи и чужие удержания на время на мойке.
+// Удержание пользователя exceptUserID не учитывается
+func (s *Storage) slotLoad(ctx context.Context, q queryRower, locationID int64, date, clock string, exceptUserID int64) (int, error) {
 	var count int
-	err := s.DB.QueryRowContext(ctx,
-		`SELECT COUNT(*) FROM bookings WHERE date = ? AND time = ?`,
-		date, time).Scan(&count)
-	return count == 0, err
+	err := q.QueryRowContext(ctx, `
+	SELECT
+		(SELECT COUNT(*) FROM bookings
+		 WHERE location_id = ? AND date = ? AND time = ? AND status NOT IN `+releasedStatuses+`) +
+		(SELECT COUNT(*) FROM slot_holds
+		 WHERE location_id = ? AND date = ? AND time = ? AND user_id <> ? AND expires_at > ?)`,
+		locationID, date, clock, locationID, date, clock, exceptUserID, time.Now()).Scan(&count)
+	return count, err
 }
 
-func (s *Storage) GetAllBookings(ctx context.Context) ([]*models.Booking, error) {
+// bays возвращает количество моечных постов основной мойки
+func (s *Storage) bays() int {
+	return max(s.Bays, 1)
+}
+
+// freeBay подбирает свободный пост на мойке на время записи с учётом
+// чужих удержаний
+func (s *Storage) freeBay(ctx context.Context, tx *Tx, userID, locationID int64, date, time string) (int, error) {
+	if err := s.lockSlot(ctx, tx, locationID, date, time); err != nil {
+		return 0, err
+	}
+
+	bays, err := s.locationBays(ctx, tx, locationID)
+	if err != nil {
+		return 0, err
+	}
+	load, err := s.slotLoad(ctx, tx, locationID, date, time, userID)
+	if err != nil {
+		return 0, err
+	}
+	if load >= bays {
+		return 0, ErrSlotTaken
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+	SELECT bay FROM bookings
+	WHERE location_id = ? AND date = ? AND time = ? AND status NOT IN `+releasedStatuses,
+		locationID, date, time)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	taken := make(map[int]bool)
+	for rows.Next() {
+		var bay int
+		if err := rows.Scan(&bay); err != nil {
+			return 0, err
+		}
+		taken[bay] = true
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for bay := 1; bay <= bays; bay++ {
+		if !taken[bay] {
+			return bay, nil
+		}
+	}
+	return 0, ErrSlotTaken
+}
+
+// GetDayOccupancy возвращает количество занятых и удерживаемых другими
+// пользователями постов мойки по времени: время → записей
+func (s *Storage) GetDayOccupancy(ctx context.Context, locationID int64, date string, exceptUserID int64) (map[string]int, error) {
 	rows, err := s.DB.QueryContext(ctx, `
-		SELECT id, date, time, car_model, car_number 
-		FROM bookings ORDER BY date, time`)
+	SELECT time, COUNT(*) FROM (
+		SELECT time FROM bookings
+		WHERE location_id = ? AND date = ? AND status NOT IN `+releasedStatuses+`
+		UNION ALL
+		SELECT time FROM slot_holds
+		WHERE location_id = ? AND date = ? AND user_id <> ? AND expires_at > ?
+	) AS slots GROUP BY time`, locationID, date, locationID, date, exceptUserID, time.Now())
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var bookings []*models.Booking
+	occupancy := make(map[string]int)
 	for rows.Next() {
-		var b models.Booking
-		if err := rows.Scan(&b.ID, &b.Date, &b.Time, &b.CarModel, &b.CarNumber); err != nil {
+		var (
+			clock string
+			count int
+		)
+		if err := rows.Scan(&clock, &count); err != nil {
 			return nil, err
 		}
-		bookings = append(bookings, &b)
+		occupancy[clock] = count
 	}
-	return bookings, nil
+	return occupancy, rows.Err()
+}
+
+// GetMonthOccupancy возвращает занятость постов мойки по дням месяца
+// с учётом чужих удержаний: дата → время → записей
+func (s *Storage) GetMonthOccupancy(ctx context.Context, locationID int64, month time.Month, year int, exceptUserID int64) (map[string]map[string]int, error) {
+	suffix := fmt.Sprintf("%%.%02d.%d", month, year)
+	rows, err := s.DB.QueryContext(ctx, `
+	SELECT date, time, COUNT(*) FROM (
+		SELECT date, time FROM bookings
+		WHERE location_id = ? AND date LIKE ? AND status NOT IN `+releasedStatuses+`
+		UNION ALL
+		SELECT date, time FROM slot_holds
+		WHERE location_id = ? AND date LIKE ? AND user_id <> ? AND expires_at > ?
+	) AS slots GROUP BY date, time`, locationID, suffix, locationID, suffix, exceptUserID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	occupancy := make(map[string]map[string]int)
+	for rows.Next() {
+		var (
+			date, clock string
+			count       int
+		)
+		if err := rows.Scan(&date, &clock, &count); err != nil {
+			return nil, err
+		}
+		if occupancy[date] == nil {
+			occupancy[date] = make(map[string]int)
+		}
+		occupancy[date][clock] = count
+	}
+	return occupancy, rows.Err()
+}
+
+func (s *Storage) GetAllBookings(ctx context.Context) ([]*models.Booking, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT `+scheduleColumns+`
+		FROM bookings b LEFT JOIN users u ON u.id = b.user_id
+		WHERE b.status NOT IN `+releasedStatuses+` ORDER BY b.date, b.time, b.bay`)
+	if err != nil {
+		return nil, err
+	}
+	return scanScheduleRows(rows)
 }
-func (s *Storage) CancelBooking(ctx context.Context, bookingID string, userID int64) (*models.Booking, error) {
+
+// CancelBooking отменяет запись пользователя. Поздняя отмена (late)
+// засчитывается пользователю как нарушение
+func (s *Storage) CancelBooking(ctx context.Context, bookingID string, userID int64, late bool) (*models.Booking, error) {
 	// Начинаем транзакцию
 	tx, err := s.DB.BeginTx(ctx, nil)
 	if err != nil {
@@ -111,32 +586,68 @@ func (s *Storage) CancelBooking(ctx context.Context, bookingID string, userID in
 	}
 	defer tx.Rollback()
 
-	// Получаем данные брони перед удалением
+	// Получаем данные брони перед отменой
 	var booking models.Booking
 	err = tx.QueryRowContext(ctx, `
-        SELECT id, date, time, car_model, car_number 
+        SELECT id, user_id, date, time, car_model, car_number, service, price, points_used, location_id
         FROM bookings 
-        WHERE id = ? AND user_id = ?`,
+        WHERE id = ? AND user_id = ? AND status IN `+upcomingStatuses+s.forUpdate(),
 		bookingID, userID).Scan(
 		&booking.ID,
+		&booking.UserID,
 		&booking.Date,
 		&booking.Time,
 		&booking.CarModel,
-		&booking.CarNumber)
+		&booking.CarNumber,
+		&booking.Service,
+		&booking.Price,
+		&booking.PointsUsed,
+		&booking.LocationID)
 
 	if err != nil {
 		return nil, err
 	}
 
-	// Удаляем бронь
+	// Возвращаем списанные за запись бонусные баллы
+	if booking.PointsUsed > 0 {
+		err = s.changeLoyaltyPoints(ctx, tx, &models.LoyaltyTransaction{
+			UserID:    booking.UserID,
+			Delta:     booking.PointsUsed,
+			Reason:    models.LoyaltyRefund,
+			BookingID: booking.ID,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Неоплаченный счёт больше не действует
+	if _, err = tx.ExecContext(ctx, `
+        UPDATE payments SET status = ?
+        WHERE booking_id = ? AND status = ?`,
+		models.PaymentCancelled, bookingID, models.PaymentPending); err != nil {
+		return nil, err
+	}
+
+	// Запись остаётся в истории для статистики отмен
+	booking.Status = models.StatusCancelled
+	booking.CancelledAt = time.Now()
+	booking.LateCancel = late
 	_, err = tx.ExecContext(ctx, `
-        DELETE FROM bookings 
-        WHERE id = ? AND user_id = ?`,
-		bookingID, userID)
+        UPDATE bookings SET status = ?, cancelled_at = ?, late_cancel = ?
+        WHERE id = ?`,
+		booking.Status, booking.CancelledAt, late, bookingID)
 	if err != nil {
 		return nil, err
 	}
 
+	if late {
+		if _, err = tx.ExecContext(ctx, `
+        UPDATE users SET late_cancels = late_cancels + 1 WHERE id = ?`, userID); err != nil {
+			return nil, err
+		}
+	}
+
 	// Фиксируем транзакцию
 	if err = tx.Commit(); err != nil {
 		return nil, err
@@ -144,14 +655,129 @@ func (s *Storage) CancelBooking(ctx context.Context, bookingID string, userID in
 
 	return &booking, nil
 }
+
+// RescheduleBooking переносит предстоящую запись на другое время на той же
+// мойке. Пост подбирается заново, а напоминание придёт ещё раз уже о
+// новом времени
+func (s *Storage) RescheduleBooking(ctx context.Context, bookingID, date, clock string) (*models.Booking, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var userID, locationID int64
+	err = tx.QueryRowContext(ctx, `
+	SELECT user_id, location_id FROM bookings WHERE id = ? AND status IN `+upcomingStatuses+s.forUpdate(),
+		bookingID).Scan(&userID, &locationID)
+	if err != nil {
+		return nil, err
+	}
+
+	bay, err := s.freeBay(ctx, tx, userID, locationID, date, clock)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = tx.ExecContext(ctx, `
+	UPDATE bookings SET date = ?, time = ?, bay = ?, reminded = 0 WHERE id = ?`,
+		date, clock, bay, bookingID); err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+	return s.GetBooking(ctx, bookingID)
+}
+
+// MarkNoShow отмечает, что клиент не приехал, и засчитывает ему неявку
+func (s *Storage) MarkNoShow(ctx context.Context, bookingID string) (*models.Booking, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var booking models.Booking
+	err = tx.QueryRowContext(ctx, `
+	SELECT id, user_id, date, time, car_model, car_number
+	FROM bookings WHERE id = ? AND status = ?`+s.forUpdate(),
+		bookingID, models.StatusActive).Scan(
+		&booking.ID,
+		&booking.UserID,
+		&booking.Date,
+		&booking.Time,
+		&booking.CarModel,
+		&booking.CarNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	booking.Status = models.StatusNoShow
+	if _, err = tx.ExecContext(ctx, `
+	UPDATE bookings SET status = ? WHERE id = ?`, booking.Status, bookingID); err != nil {
+		return nil, err
+	}
+	if _, err = tx.ExecContext(ctx, `
+	UPDATE users SET no_shows = no_shows + 1 WHERE id = ?`, booking.UserID); err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &booking, nil
+}
+
+// SetBookingStatus переводит запись из статуса from в статус to
+func (s *Storage) SetBookingStatus(ctx context.Context, bookingID, from, to string) (*models.Booking, error) {
+	res, err := s.DB.ExecContext(ctx, `
+	UPDATE bookings SET status = ? WHERE id = ? AND status = ?`, to, bookingID, from)
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return s.GetBooking(ctx, bookingID)
+}
+
+// ResetUserStrikes обнуляет счётчики поздних отмен и неявок
+func (s *Storage) ResetUserStrikes(ctx context.Context, userID int64) error {
+	_, err := s.DB.ExecContext(ctx, `
+	UPDATE users SET late_cancels = 0, no_shows = 0 WHERE id = ?`, userID)
+	return err
+}
+
+// CreateOrUpdateUser сохраняет пользователя. Язык записывается только
+// новым пользователям: выбранный в настройках язык не перезаписывается
 func (s *Storage) CreateOrUpdateUser(ctx context.Context, user *models.User) error {
 	_, err := s.DB.ExecContext(ctx, `
-    INSERT INTO users (telegram_id, username, first_name, last_name)
-    VALUES (?, ?, ?, ?)
+    INSERT INTO users (telegram_id, username, first_name, last_name, language, created_at)
+    VALUES (?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
     ON CONFLICT(telegram_id) DO UPDATE SET
         username = excluded.username,
         first_name = excluded.first_name,
-        last_name = excluded.last_name`,
-		user.TelegramID, user.Username, user.FirstName, user.LastName)
+        last_name = excluded.last_name,
+        language = CASE WHEN users.language = '' THEN excluded.language ELSE users.language END`,
+		user.TelegramID, user.Username, user.FirstName, user.LastName, user.Language)
 	return err
 }
+
+// SetUserPhone сохраняет телефон, которым пользователь поделился
+func (s *Storage) SetUserPhone(ctx context.Context, telegramID int64, phone string) error {
+	_, err := s.DB.ExecContext(ctx, `
+	UPDATE users SET phone = ? WHERE telegram_id = ?`, phone, telegramID)
+	return err
+}
+
+// SetUserLanguage меняет язык интерфейса пользователя
+func (s *Storage) SetUserLanguage(ctx context.Context, telegramID int64, lang string) error {
+	_, err := s.DB.ExecContext(ctx, `
+	UPDATE users SET language = ? WHERE telegram_id = ?`, lang, telegramID)
+	return err
+}
+
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
@@ -0,0 +1,158 @@
+package storage
+
+import (
+	"carwash-bot/internal/models"
+	"context"
+	"strconv"
+	"time"
+)
+
+// isoDate переводит дату записи из «02.01.2006» в «2006-01-02»,
+// чтобы сравнивать даты в SQL
+const isoDate = `(substr(date, 7, 4) || '-' || substr(date, 4, 2) || '-' || substr(date, 1, 2))`
+
+// bookingsIsoDate — то же для запросов, где таблица записей названа b
+const bookingsIsoDate = `(substr(b.date, 7, 4) || '-' || substr(b.date, 4, 2) || '-' || substr(b.date, 1, 2))`
+
+// Сколько услуг показывать в рейтинге
+const topServicesLimit = 5
+
+// GetStats считает показатели по записям с from по to включительно
+func (s *Storage) GetStats(ctx context.Context, from, to time.Time) (*models.Stats, error) {
+	stats := &models.Stats{From: from, To: to, PerDay: make(map[string]int)}
+	period := []any{from.Format("2006-01-02"), to.Format("2006-01-02")}
+
+	// Итоги по статусам
+	rows, err := s.DB.QueryContext(ctx, `
+	SELECT status, COUNT(*), SUM(late_cancel), SUM(price) FROM bookings
+	WHERE `+isoDate+` BETWEEN ? AND ? AND status <> ?
+	GROUP BY status`, append(period, models.StatusExpired)...)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var (
+			status               string
+			count, late, revenue int
+		)
+		if err := rows.Scan(&status, &count, &late, &revenue); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		stats.Bookings += count
+		switch status {
+		case models.StatusCompleted:
+			stats.Completed = count
+			stats.Revenue = revenue
+		case models.StatusCancelled:
+			stats.Cancelled = count
+			stats.LateCancels = late
+		case models.StatusNoShow:
+			stats.NoShows = count
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Загрузка по дням и часам
+	rows, err = s.DB.QueryContext(ctx, `
+	SELECT date, substr(time, 1, 2), COUNT(*) FROM bookings
+	WHERE `+isoDate+` BETWEEN ? AND ? AND status NOT IN `+releasedStatuses+`
+	GROUP BY date, substr(time, 1, 2)`, period...)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var (
+			date, hour string
+			count      int
+		)
+		if err := rows.Scan(&date, &hour, &count); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		stats.PerDay[date] += count
+
+		day, err := time.ParseInLocation("02.01.2006", date, time.Local)
+		h, herr := strconv.Atoi(hour)
+		if err == nil && herr == nil && h >= 0 && h < 24 {
+			stats.Heatmap[day.Weekday()][h] += count
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if stats.LeadTime, err = s.averageLeadTime(ctx, period); err != nil {
+		return nil, err
+	}
+
+	// Новый клиент — тот, чья первая запись пришлась на этот период
+	err = s.DB.QueryRowContext(ctx, `
+	SELECT COUNT(*), COALESCE(SUM(CASE WHEN first >= ? THEN 1 ELSE 0 END), 0) FROM (
+		SELECT user_id, MIN(`+isoDate+`) AS first FROM bookings
+		WHERE status NOT IN `+releasedStatuses+`
+		GROUP BY user_id
+		HAVING MAX(CASE WHEN `+isoDate+` BETWEEN ? AND ? THEN 1 ELSE 0 END) = 1
+	) AS customers`, period[0], period[0], period[1]).Scan(&stats.ReturningCustomers, &stats.NewCustomers)
+	if err != nil {
+		return nil, err
+	}
+	stats.ReturningCustomers -= stats.NewCustomers
+
+	rows, err = s.DB.QueryContext(ctx, `
+	SELECT service, COUNT(*), SUM(price) FROM bookings
+	WHERE `+isoDate+` BETWEEN ? AND ? AND status = ?
+	GROUP BY service ORDER BY COUNT(*) DESC, SUM(price) DESC LIMIT ?`,
+		append(period, models.StatusCompleted, topServicesLimit)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var service models.ServiceStats
+		if err := rows.Scan(&service.Service, &service.Count, &service.Revenue); err != nil {
+			return nil, err
+		}
+		stats.TopServices = append(stats.TopServices, service)
+	}
+	return stats, rows.Err()
+}
+
+// averageLeadTime — среднее время от оформления записи до начала мойки
+func (s *Storage) averageLeadTime(ctx context.Context, period []any) (time.Duration, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+	SELECT date, time, created_at FROM bookings
+	WHERE `+isoDate+` BETWEEN ? AND ? AND status NOT IN `+releasedStatuses, period...)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	var (
+		total time.Duration
+		count int
+	)
+	for rows.Next() {
+		var (
+			date, clock string
+			created     time.Time
+		)
+		if err := rows.Scan(&date, &clock, &created); err != nil {
+			return 0, err
+		}
+		start, err := time.ParseInLocation("02.01.2006 15:04", date+" "+clock, time.Local)
+		if err != nil || created.IsZero() || start.Before(created) {
+			continue
+		}
+		total += start.Sub(created)
+		count++
+	}
+	if count == 0 {
+		return 0, rows.Err()
+	}
+	return total / time.Duration(count), rows.Err()
+}
@@ -10,7 +10,15 @@ import (
 )
 
 type Storage struct {
-	DB *sql.DB
+	DB *DB
+
+	// Ограничения на записи по умолчанию, для отдельных клиентов их
+	// переопределяет администратор
+	Limits models.BookingLimits
+
+	// Количество моечных постов основной мойки: столько машин можно
+	// записать на одно время. Посты остальных моек хранятся в locations
+	Bays int
 }
 
 func New() *Storage {
@@ -19,30 +27,37 @@ func New() *Storage {
 		log.Fatal("Не удалось открыть базу данных:", err)
 	}
 
-	createUserTable := `
-	CREATE TABLE IF NOT EXISTS users (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		telegram_id INTEGER UNIQUE NOT NULL,
-		username TEXT,
-		first_name TEXT
-	);`
-
-	if _, err := db.Exec(createUserTable); err != nil {
-		log.Fatal("Ошибка при создании таблицы users:", err)
+	s := &Storage{DB: &DB{DB: db}}
+	if err := s.Init(); err != nil {
+		log.Fatal("Ошибка при создании таблиц:", err)
 	}
 
-	return &Storage{DB: db}
+	return s
 }
 func (s *Storage) SaveUser(telegramID int64, username, firstName string) error {
 	_, err := s.DB.Exec(`
-		INSERT OR IGNORE INTO users(telegram_id, username, first_name)
+		INSERT INTO users(telegram_id, username, first_name)
 		VALUES (?, ?, ?)
+		ON CONFLICT(telegram_id) DO NOTHING
 	`, telegramID, username, firstName)
 
 	return err
 }
+
+// Open подключается к PostgreSQL по адресу databaseURL, а если он пуст,
+// открывает локальную базу carwash.db
+func Open(databaseURL string) (*Storage, error) {
+	if databaseURL == "" {
+		return NewSQLiteStorage("carwash.db")
+	}
+	return NewPostgresStorage(databaseURL)
+}
+
+// NewSQLiteStorage открывает базу по пути path и создаёт таблицы.
+// Транзакции сразу берут блокировку на запись и ждут друг друга, а не
+// падают с «database is locked»
 func NewSQLiteStorage(path string) (*Storage, error) {
-	db, err := sql.Open("sqlite", "carwash.db")
+	db, err := sql.Open("sqlite", path+"?_txlock=immediate&_pragma=busy_timeout(5000)")
 	if err != nil {
 		return nil, err
 	}
@@ -51,32 +66,51 @@ func NewSQLiteStorage(path string) (*Storage, error) {
 		return nil, err
 	}
 
-	return &Storage{DB: db}, nil
+	s := &Storage{DB: &DB{DB: db}}
+	if err := s.Init(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
 }
 
-type BookingRepository interface {
-	CreateBooking(ctx context.Context, booking *models.Booking) error
-	GetUserBookings(ctx context.Context, userID int64) ([]models.Booking, error)
-	CancelBooking(ctx context.Context, bookingID int64) error
-	// Другие методы...
-}
+func (s *Storage) GetUserByTelegramID(ctx context.Context, telegramID int64) (*models.User, error) {
+	user := &models.User{}
+	err := s.DB.QueryRowContext(ctx, `
+	SELECT id, telegram_id, username, first_name, last_name, language, phone, created_at, late_cancels, no_shows
+	FROM users WHERE telegram_id = ?`, telegramID).Scan(
+		&user.ID,
+		&user.TelegramID,
+		&user.Username,
+		&user.FirstName,
+		&user.LastName,
+		&user.Language,
+		&user.Phone,
+		&user.CreatedAt,
+		&user.LateCancels,
+		&user.NoShows)
 
-type UserRepository interface {
-	CreateOrUpdateUser(ctx context.Context, user *models.User) error
-	GetUserByID(ctx context.Context, userID int64) (*models.User, error)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return user, err
 }
 
-func (s *Storage) GetUserByTelegramID(ctx context.Context, telegramID int64) (*models.User, error) {
+func (s *Storage) GetUserByID(ctx context.Context, userID int64) (*models.User, error) {
 	user := &models.User{}
 	err := s.DB.QueryRowContext(ctx, `
-	SELECT id, telegram_id, username, first_name, last_name, created_at
-	FROM users WHERE telegram_id = ?`, telegramID).Scan(
+	SELECT id, telegram_id, username, first_name, last_name, language, phone, created_at, late_cancels, no_shows
+	FROM users WHERE id = ?`, userID).Scan(
 		&user.ID,
 		&user.TelegramID,
 		&user.Username,
 		&user.FirstName,
 		&user.LastName,
-		&user.CreatedAt)
+		&user.Language,
+		&user.Phone,
+		&user.CreatedAt,
+		&user.LateCancels,
+		&user.NoShows)
 
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -0,0 +1,138 @@
+package storage_test
+
+import (
+	"carwash-bot/internal/models"
+	"carwash-bot/internal/storage"
+	"carwash-bot/internal/storage/storagetest"
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func openSQLite(t *testing.T, bays int) storage.Repository {
+	repo, err := storage.NewSQLiteStorage(filepath.Join(t.TempDir(), "carwash.db"))
+	if err != nil {
+		t.Fatalf("NewSQLiteStorage: %v", err)
+	}
+	t.Cleanup(func() { repo.Close() })
+	repo.Bays = bays
+	return repo
+}
+
+func TestSQLite(t *testing.T) {
+	storagetest.Run(t, openSQLite)
+	runLimitRaces(t, openSQLite)
+}
+
+func TestPostgres(t *testing.T) {
+	storagetest.Run(t, storagetest.Postgres)
+	runLimitRaces(t, storagetest.Postgres)
+}
+
+// runLimitRaces проверяет, что лимиты клиента и промокода не обходятся
+// одновременными записями на разное время
+func runLimitRaces(t *testing.T, open storagetest.Open) {
+	t.Run("UserLimitRace", func(t *testing.T) {
+		repo := open(t, 1).(*storage.Storage)
+		repo.Limits = models.BookingLimits{MaxActive: 1}
+		user := newUser(t, repo, 1)
+
+		errs := storagetest.Race(2, func(i int) error {
+			return repo.CreateBooking(context.Background(), booking(user.ID, []string{"10:00", "12:00"}[i], ""))
+		})
+		checkOneWins(t, errs, storage.ErrTooManyActive)
+	})
+
+	t.Run("PromoLimitRace", func(t *testing.T) {
+		repo := open(t, 1).(*storage.Storage)
+		err := repo.CreatePromoCode(context.Background(), &models.PromoCode{Code: "ONCE", Percent: 10, MaxUses: 1,
+			ValidFrom: time.Now().AddDate(0, 0, -1), ValidTo: time.Now().AddDate(0, 0, 30)})
+		if err != nil {
+			t.Fatalf("CreatePromoCode: %v", err)
+		}
+		first, second := newUser(t, repo, 1), newUser(t, repo, 2)
+
+		errs := storagetest.Race(2, func(i int) error {
+			userID := []int64{first.ID, second.ID}[i]
+			return repo.CreateBooking(context.Background(), booking(userID, []string{"10:00", "12:00"}[i], "ONCE"))
+		})
+		checkOneWins(t, errs, storage.ErrPromoExhausted)
+	})
+}
+
+func newUser(t *testing.T, repo *storage.Storage, telegramID int64) *models.User {
+	t.Helper()
+	ctx := context.Background()
+	if err := repo.CreateOrUpdateUser(ctx, &models.User{TelegramID: telegramID, FirstName: "Тест"}); err != nil {
+		t.Fatalf("CreateOrUpdateUser: %v", err)
+	}
+	user, err := repo.GetUserByTelegramID(ctx, telegramID)
+	if err != nil || user == nil {
+		t.Fatalf("GetUserByTelegramID(%d) = %v, %v", telegramID, user, err)
+	}
+	return user
+}
+
+func booking(userID int64, clock, promo string) *models.Booking {
+	return &models.Booking{UserID: userID, Date: time.Now().AddDate(0, 0, 3).Format("02.01.2006"), Time: clock,
+		CarModel: "Kia", CarNumber: "A123BC", Service: "express", Price: 400, PromoCode: promo}
+}
+
+// checkOneWins проверяет, что из двух одновременных записей прошла одна,
+// а вторая отклонена с ошибкой want
+func checkOneWins(t *testing.T, errs []error, want error) {
+	t.Helper()
+	created := 0
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			created++
+		case !errors.Is(err, want):
+			t.Fatalf("вторая запись: %v; нужно %v", err, want)
+		}
+	}
+	if created != 1 {
+		t.Fatalf("записей создано %d из двух; нужно 1 (ошибки %v)", created, errs)
+	}
+}
+
+// Отмена записи сдвигает порядковые номера следующих: бесплатная мойка,
+// которая больше не каждая N-я, снова стоит полную цену
+func TestRevokeFreeVisits(t *testing.T) {
+	repo := openSQLite(t, 1).(*storage.Storage)
+	ctx := context.Background()
+	user := newUser(t, repo, 1)
+
+	paid := booking(user.ID, "10:00", "")
+	paid.Status = models.StatusPendingApproval
+	if err := repo.CreateBooking(ctx, paid); err != nil {
+		t.Fatalf("CreateBooking: %v", err)
+	}
+	if n, err := repo.CountUpcomingBookings(ctx, user.ID); err != nil || n != 1 {
+		t.Fatalf("CountUpcomingBookings = %d, %v; нужна 1 неподтверждённая запись", n, err)
+	}
+	free := booking(user.ID, "12:00", "")
+	free.Price, free.Discount = 0, 400
+	if err := repo.CreateBooking(ctx, free); err != nil {
+		t.Fatalf("CreateBooking: %v", err)
+	}
+
+	revoked, err := repo.RevokeFreeVisits(ctx, user.ID, 2)
+	if err != nil || len(revoked) != 0 {
+		t.Fatalf("RevokeFreeVisits до отмены = %v, %v; вторая мойка бесплатна законно", revoked, err)
+	}
+
+	if _, err := repo.CancelBooking(ctx, paid.ID, user.ID, false); err != nil {
+		t.Fatalf("CancelBooking: %v", err)
+	}
+	revoked, err = repo.RevokeFreeVisits(ctx, user.ID, 2)
+	if err != nil || len(revoked) != 1 || revoked[0].ID != free.ID || revoked[0].Price != 400 {
+		t.Fatalf("RevokeFreeVisits после отмены = %v, %v; нужна бесплатная запись с ценой 400", revoked, err)
+	}
+	stored, err := repo.GetBooking(ctx, free.ID)
+	if err != nil || stored.Price != 400 || stored.Discount != 0 {
+		t.Fatalf("GetBooking = %+v, %v; нужна цена 400 без скидки", stored, err)
+	}
+}
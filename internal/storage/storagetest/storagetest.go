@@ -0,0 +1,511 @@
+// Package storagetest — общий набор проверок для реализаций
+// storage.Repository. Каждая реализация запускает его из своих тестов:
+//
+//	func TestMemory(t *testing.T) {
+//		storagetest.Run(t, func(t *testing.T, bays int) storage.Repository {
+//			repo := memory.New()
+//			repo.Bays = bays
+//			return repo
+//		})
+//	}
+//
+// Для PostgreSQL готово открытие Postgres: оно берёт базу из
+// TEST_DATABASE_URL и пропускает проверки, если база недоступна.
+package storagetest
+
+import (
+	"carwash-bot/internal/models"
+	"carwash-bot/internal/storage"
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Open создаёт пустое хранилище с bays моечными постами
+type Open func(t *testing.T, bays int) storage.Repository
+
+// Run проверяет, что реализация ведёт себя так же, как хранилище SQLite
+func Run(t *testing.T, open Open) {
+	t.Run("Users", func(t *testing.T) { testUsers(t, open(t, 1)) })
+	t.Run("CreateAndGet", func(t *testing.T) { testCreateAndGet(t, open(t, 1)) })
+	t.Run("SlotCapacity", func(t *testing.T) { testSlotCapacity(t, open(t, 2)) })
+	t.Run("LastSlotRace", func(t *testing.T) { testLastSlotRace(t, open(t, 2)) })
+	t.Run("Holds", func(t *testing.T) { testHolds(t, open(t, 1)) })
+	t.Run("Cancel", func(t *testing.T) { testCancel(t, open(t, 1)) })
+	t.Run("Reschedule", func(t *testing.T) { testReschedule(t, open(t, 1)) })
+	t.Run("Visits", func(t *testing.T) { testVisits(t, open(t, 3)) })
+	t.Run("Occupancy", func(t *testing.T) { testOccupancy(t, open(t, 2)) })
+	t.Run("UserBookings", func(t *testing.T) { testUserBookings(t, open(t, 1)) })
+	t.Run("Locations", func(t *testing.T) { testLocations(t, open(t, 1)) })
+}
+
+// day возвращает дату через n дней в формате базы
+func day(n int) string {
+	return time.Now().AddDate(0, 0, n).Format("02.01.2006")
+}
+
+func newUser(t *testing.T, repo storage.Repository, telegramID int64) *models.User {
+	t.Helper()
+	ctx := context.Background()
+	if err := repo.CreateOrUpdateUser(ctx, &models.User{TelegramID: telegramID, FirstName: "Тест", Language: "ru"}); err != nil {
+		t.Fatalf("CreateOrUpdateUser: %v", err)
+	}
+	user, err := repo.GetUserByTelegramID(ctx, telegramID)
+	if err != nil || user == nil {
+		t.Fatalf("GetUserByTelegramID(%d) = %v, %v", telegramID, user, err)
+	}
+	return user
+}
+
+func book(t *testing.T, repo storage.Repository, userID int64, date, clock string) *models.Booking {
+	t.Helper()
+	booking := &models.Booking{UserID: userID, Date: date, Time: clock, CarModel: "Kia", CarNumber: "A123BC",
+		Service: "express", Price: 400}
+	if err := repo.CreateBooking(context.Background(), booking); err != nil {
+		t.Fatalf("CreateBooking(%s %s): %v", date, clock, err)
+	}
+	return booking
+}
+
+func testUsers(t *testing.T, repo storage.Repository) {
+	ctx := context.Background()
+
+	if user, err := repo.GetUserByTelegramID(ctx, 404); err != nil || user != nil {
+		t.Fatalf("GetUserByTelegramID для неизвестного = %v, %v; нужно nil, nil", user, err)
+	}
+	if user, err := repo.GetUserByID(ctx, 404); err != nil || user != nil {
+		t.Fatalf("GetUserByID для неизвестного = %v, %v; нужно nil, nil", user, err)
+	}
+
+	user := newUser(t, repo, 100)
+	if user.ID == 0 || user.TelegramID != 100 || user.Language != "ru" {
+		t.Fatalf("новый пользователь = %+v", user)
+	}
+
+	// Повторное сохранение обновляет имя, но не выбранный язык
+	err := repo.CreateOrUpdateUser(ctx, &models.User{TelegramID: 100, FirstName: "Новое", Language: "en"})
+	if err != nil {
+		t.Fatalf("CreateOrUpdateUser: %v", err)
+	}
+	if err := repo.SetUserPhone(ctx, 100, "+70000000000"); err != nil {
+		t.Fatalf("SetUserPhone: %v", err)
+	}
+
+	got, err := repo.GetUserByID(ctx, user.ID)
+	if err != nil || got == nil {
+		t.Fatalf("GetUserByID = %v, %v", got, err)
+	}
+	if got.FirstName != "Новое" || got.Language != "ru" || got.Phone != "+70000000000" {
+		t.Fatalf("после обновления = %+v; нужно имя «Новое», язык ru и телефон", got)
+	}
+
+	if err := repo.SetUserLanguage(ctx, 100, "kk"); err != nil {
+		t.Fatalf("SetUserLanguage: %v", err)
+	}
+	if got, _ := repo.GetUserByTelegramID(ctx, 100); got == nil || got.Language != "kk" {
+		t.Fatalf("язык после SetUserLanguage = %+v; нужно kk", got)
+	}
+}
+
+func testCreateAndGet(t *testing.T, repo storage.Repository) {
+	ctx := context.Background()
+	user := newUser(t, repo, 1)
+
+	if booking, err := repo.GetBooking(ctx, "нет-такой"); err != nil || booking != nil {
+		t.Fatalf("GetBooking для неизвестной = %v, %v; нужно nil, nil", booking, err)
+	}
+
+	booking := book(t, repo, user.ID, day(3), "10:00")
+	if booking.ID == "" || booking.Bay != 1 || booking.Status != models.StatusActive || booking.CreatedAt.IsZero() {
+		t.Fatalf("созданная запись = %+v; нужны ID, пост 1, статус active и время создания", booking)
+	}
+
+	got, err := repo.GetBooking(ctx, booking.ID)
+	if err != nil || got == nil {
+		t.Fatalf("GetBooking = %v, %v", got, err)
+	}
+	if got.UserID != user.ID || got.Date != booking.Date || got.Time != "10:00" ||
+		got.Service != "express" || got.Price != 400 || got.CarNumber != "A123BC" {
+		t.Fatalf("GetBooking = %+v; сохранено %+v", got, booking)
+	}
+
+	// Заданный статус сохраняется
+	pending := &models.Booking{UserID: user.ID, Date: day(3), Time: "11:00", Service: "express",
+		Status: models.StatusPendingApproval}
+	if err := repo.CreateBooking(ctx, pending); err != nil {
+		t.Fatalf("CreateBooking: %v", err)
+	}
+	if got, _ := repo.GetBooking(ctx, pending.ID); got == nil || got.Status != models.StatusPendingApproval {
+		t.Fatalf("статус = %+v; нужно pending_approval", got)
+	}
+}
+
+func testSlotCapacity(t *testing.T, repo storage.Repository) {
+	ctx := context.Background()
+	date := day(4)
+
+	first := book(t, repo, newUser(t, repo, 1).ID, date, "12:00")
+	second := book(t, repo, newUser(t, repo, 2).ID, date, "12:00")
+	if first.Bay == second.Bay {
+		t.Fatalf("обе записи на посту %d; нужны разные посты", first.Bay)
+	}
+
+	third := &models.Booking{UserID: newUser(t, repo, 3).ID, Date: date, Time: "12:00", Service: "express"}
+	if err := repo.CreateBooking(ctx, third); !errors.Is(err, storage.ErrSlotTaken) {
+		t.Fatalf("третья запись на 2 поста: %v; нужно ErrSlotTaken", err)
+	}
+
+	// Отменённая запись освобождает пост
+	if _, err := repo.CancelBooking(ctx, first.ID, first.UserID, false); err != nil {
+		t.Fatalf("CancelBooking: %v", err)
+	}
+	if err := repo.CreateBooking(ctx, third); err != nil {
+		t.Fatalf("запись после отмены: %v", err)
+	}
+	if third.Bay != first.Bay {
+		t.Fatalf("пост после отмены = %d; нужно освободившийся %d", third.Bay, first.Bay)
+	}
+}
+
+// testLastSlotRace записывает двух клиентов одновременно на последний
+// свободный пост: запись должна получить только одна
+func testLastSlotRace(t *testing.T, repo storage.Repository) {
+	date := day(5)
+	first, second := newUser(t, repo, 1), newUser(t, repo, 2)
+	for _, clock := range []string{"10:00", "11:00", "12:00", "13:00", "14:00"} {
+		book(t, repo, newUser(t, repo, 3).ID, date, clock)
+
+		errs := Race(2, func(i int) error {
+			userID := []int64{first.ID, second.ID}[i]
+			return repo.CreateBooking(context.Background(), &models.Booking{UserID: userID, Date: date, Time: clock,
+				CarModel: "Kia", CarNumber: "A123BC", Service: "express"})
+		})
+		if ok := countNil(errs); ok != 1 {
+			t.Fatalf("%s: записей создано %d из двух; нужно 1 (ошибки %v)", clock, ok, errs)
+		}
+		for _, err := range errs {
+			if err != nil && !errors.Is(err, storage.ErrSlotTaken) {
+				t.Fatalf("%s: вторая запись: %v; нужно ErrSlotTaken", clock, err)
+			}
+		}
+	}
+}
+
+// Race запускает fn(0) … fn(n-1) одновременно и возвращает их ошибки
+func Race(n int, fn func(i int) error) []error {
+	errs := make([]error, n)
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			errs[i] = fn(i)
+		}()
+	}
+	close(start)
+	wg.Wait()
+	return errs
+}
+
+func countNil(errs []error) int {
+	n := 0
+	for _, err := range errs {
+		if err == nil {
+			n++
+		}
+	}
+	return n
+}
+
+func testHolds(t *testing.T, repo storage.Repository) {
+	ctx := context.Background()
+	date := day(5)
+	holder := newUser(t, repo, 1)
+	other := newUser(t, repo, 2)
+	until := time.Now().Add(5 * time.Minute)
+
+	if err := repo.HoldSlot(ctx, holder.ID, 0, date, "09:00", until); err != nil {
+		t.Fatalf("HoldSlot: %v", err)
+	}
+	// Чужое удержание занимает единственный пост
+	if err := repo.HoldSlot(ctx, other.ID, 0, date, "09:00", until); !errors.Is(err, storage.ErrSlotTaken) {
+		t.Fatalf("HoldSlot на удержанное время: %v; нужно ErrSlotTaken", err)
+	}
+	booking := &models.Booking{UserID: other.ID, Date: date, Time: "09:00", Service: "express"}
+	if err := repo.CreateBooking(ctx, booking); !errors.Is(err, storage.ErrSlotTaken) {
+		t.Fatalf("CreateBooking на удержанное время: %v; нужно ErrSlotTaken", err)
+	}
+
+	// Своё удержание не мешает записаться, а запись снимает удержание
+	book(t, repo, holder.ID, date, "09:00")
+	occupancy, err := repo.GetDayOccupancy(ctx, 0, date, other.ID)
+	if err != nil {
+		t.Fatalf("GetDayOccupancy: %v", err)
+	}
+	if occupancy["09:00"] != 1 {
+		t.Fatalf("занятость 09:00 = %d; нужно 1: запись без удержания", occupancy["09:00"])
+	}
+
+	// Новое удержание заменяет прежнее
+	if err := repo.HoldSlot(ctx, other.ID, 0, date, "10:00", until); err != nil {
+		t.Fatalf("HoldSlot: %v", err)
+	}
+	if err := repo.HoldSlot(ctx, other.ID, 0, date, "11:00", until); err != nil {
+		t.Fatalf("HoldSlot: %v", err)
+	}
+	occupancy, _ = repo.GetDayOccupancy(ctx, 0, date, 0)
+	if occupancy["10:00"] != 0 || occupancy["11:00"] != 1 {
+		t.Fatalf("занятость после смены удержания = %v", occupancy)
+	}
+
+	// Истёкшее и снятое удержания не занимают пост
+	if err := repo.ReleaseHold(ctx, other.ID); err != nil {
+		t.Fatalf("ReleaseHold: %v", err)
+	}
+	if err := repo.HoldSlot(ctx, holder.ID, 0, date, "12:00", time.Now().Add(-time.Minute)); err != nil {
+		t.Fatalf("HoldSlot: %v", err)
+	}
+	occupancy, _ = repo.GetDayOccupancy(ctx, 0, date, 0)
+	if occupancy["11:00"] != 0 || occupancy["12:00"] != 0 {
+		t.Fatalf("занятость после снятия удержаний = %v", occupancy)
+	}
+}
+
+func testCancel(t *testing.T, repo storage.Repository) {
+	ctx := context.Background()
+	user := newUser(t, repo, 1)
+	stranger := newUser(t, repo, 2)
+	booking := book(t, repo, user.ID, day(6), "15:00")
+
+	if _, err := repo.CancelBooking(ctx, booking.ID, stranger.ID, false); err == nil {
+		t.Fatal("CancelBooking чужой записи прошла; нужна ошибка")
+	}
+
+	cancelled, err := repo.CancelBooking(ctx, booking.ID, user.ID, true)
+	if err != nil {
+		t.Fatalf("CancelBooking: %v", err)
+	}
+	if cancelled.Status != models.StatusCancelled || !cancelled.LateCancel || cancelled.CancelledAt.IsZero() {
+		t.Fatalf("отменённая запись = %+v", cancelled)
+	}
+	if got, _ := repo.GetBooking(ctx, booking.ID); got == nil || got.Status != models.StatusCancelled {
+		t.Fatalf("запись после отмены = %+v; нужен статус cancelled", got)
+	}
+	if got, _ := repo.GetUserByID(ctx, user.ID); got == nil || got.LateCancels != 1 {
+		t.Fatalf("поздних отмен = %+v; нужно 1", got)
+	}
+
+	if _, err := repo.CancelBooking(ctx, booking.ID, user.ID, false); err == nil {
+		t.Fatal("повторная отмена прошла; нужна ошибка")
+	}
+}
+
+func testReschedule(t *testing.T, repo storage.Repository) {
+	ctx := context.Background()
+	date := day(7)
+	user := newUser(t, repo, 1)
+	booking := book(t, repo, user.ID, date, "10:00")
+	taken := book(t, repo, newUser(t, repo, 2).ID, date, "12:00")
+
+	if _, err := repo.RescheduleBooking(ctx, booking.ID, date, taken.Time); !errors.Is(err, storage.ErrSlotTaken) {
+		t.Fatalf("перенос на занятое время: %v; нужно ErrSlotTaken", err)
+	}
+
+	moved, err := repo.RescheduleBooking(ctx, booking.ID, day(8), "14:00")
+	if err != nil {
+		t.Fatalf("RescheduleBooking: %v", err)
+	}
+	if moved.ID != booking.ID || moved.Date != day(8) || moved.Time != "14:00" || moved.Bay != 1 {
+		t.Fatalf("перенесённая запись = %+v", moved)
+	}
+
+	// Старое время освободилось
+	occupancy, _ := repo.GetDayOccupancy(ctx, 0, date, 0)
+	if occupancy["10:00"] != 0 {
+		t.Fatalf("занятость старого времени = %d; нужно 0", occupancy["10:00"])
+	}
+
+	if _, err := repo.CancelBooking(ctx, booking.ID, user.ID, false); err != nil {
+		t.Fatalf("CancelBooking: %v", err)
+	}
+	if _, err := repo.RescheduleBooking(ctx, booking.ID, date, "16:00"); err == nil {
+		t.Fatal("перенос отменённой записи прошёл; нужна ошибка")
+	}
+}
+
+func testVisits(t *testing.T, repo storage.Repository) {
+	ctx := context.Background()
+	date := day(8)
+	user := newUser(t, repo, 1)
+	pending := &models.Booking{UserID: user.ID, Date: date, Time: "10:00", Service: "express",
+		Status: models.StatusPendingApproval}
+	if err := repo.CreateBooking(ctx, pending); err != nil {
+		t.Fatalf("CreateBooking: %v", err)
+	}
+
+	if _, err := repo.SetBookingStatus(ctx, pending.ID, models.StatusAwaitingPayment, models.StatusActive); err == nil {
+		t.Fatal("SetBookingStatus из чужого статуса прошёл; нужна ошибка")
+	}
+	approved, err := repo.SetBookingStatus(ctx, pending.ID, models.StatusPendingApproval, models.StatusActive)
+	if err != nil || approved.Status != models.StatusActive {
+		t.Fatalf("SetBookingStatus = %+v, %v; нужен статус active", approved, err)
+	}
+
+	completed, err := repo.CompleteBooking(ctx, pending.ID, 0)
+	if err != nil || completed.Status != models.StatusCompleted {
+		t.Fatalf("CompleteBooking = %+v, %v; нужен статус completed", completed, err)
+	}
+	if _, err := repo.CompleteBooking(ctx, pending.ID, 0); err == nil {
+		t.Fatal("повторное завершение прошло; нужна ошибка")
+	}
+	if _, err := repo.MarkNoShow(ctx, pending.ID); err == nil {
+		t.Fatal("неявка по выполненной записи прошла; нужна ошибка")
+	}
+
+	missed := book(t, repo, user.ID, date, "11:00")
+	noShow, err := repo.MarkNoShow(ctx, missed.ID)
+	if err != nil || noShow.Status != models.StatusNoShow {
+		t.Fatalf("MarkNoShow = %+v, %v; нужен статус no_show", noShow, err)
+	}
+	if got, _ := repo.GetBooking(ctx, missed.ID); got == nil || got.Status != models.StatusNoShow {
+		t.Fatalf("запись после неявки = %+v", got)
+	}
+	if got, _ := repo.GetUserByID(ctx, user.ID); got == nil || got.NoShows != 1 {
+		t.Fatalf("неявок = %+v; нужно 1", got)
+	}
+}
+
+func testOccupancy(t *testing.T, repo storage.Repository) {
+	ctx := context.Background()
+	date := day(9)
+	user := newUser(t, repo, 1)
+	book(t, repo, user.ID, date, "10:00")
+	book(t, repo, newUser(t, repo, 2).ID, date, "10:00")
+	book(t, repo, user.ID, date, "13:00")
+	book(t, repo, user.ID, day(10), "13:00")
+
+	occupancy, err := repo.GetDayOccupancy(ctx, 0, date, 0)
+	if err != nil {
+		t.Fatalf("GetDayOccupancy: %v", err)
+	}
+	if len(occupancy) != 2 || occupancy["10:00"] != 2 || occupancy["13:00"] != 1 {
+		t.Fatalf("занятость дня = %v; нужно 10:00 → 2, 13:00 → 1", occupancy)
+	}
+
+	at, _ := time.ParseInLocation("02.01.2006", date, time.Local)
+	month, err := repo.GetMonthOccupancy(ctx, 0, at.Month(), at.Year(), 0)
+	if err != nil {
+		t.Fatalf("GetMonthOccupancy: %v", err)
+	}
+	if month[date]["10:00"] != 2 || month[date]["13:00"] != 1 {
+		t.Fatalf("занятость месяца на %s = %v", date, month[date])
+	}
+	for d := range month {
+		if other, _ := time.ParseInLocation("02.01.2006", d, time.Local); other.Month() != at.Month() {
+			t.Fatalf("в занятости месяца день другого месяца: %s", d)
+		}
+	}
+}
+
+func testUserBookings(t *testing.T, repo storage.Repository) {
+	ctx := context.Background()
+	date := day(11)
+	user := newUser(t, repo, 1)
+	late := book(t, repo, user.ID, date, "16:00")
+	early := book(t, repo, user.ID, date, "09:00")
+	cancelled := book(t, repo, user.ID, date, "12:00")
+	book(t, repo, newUser(t, repo, 2).ID, date, "11:00")
+
+	if _, err := repo.CancelBooking(ctx, cancelled.ID, user.ID, false); err != nil {
+		t.Fatalf("CancelBooking: %v", err)
+	}
+
+	bookings, err := repo.GetUserBookings(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("GetUserBookings: %v", err)
+	}
+	if len(bookings) != 2 || bookings[0].ID != early.ID || bookings[1].ID != late.ID {
+		t.Fatalf("предстоящие записи = %v; нужно %s, затем %s без отменённой", ids(bookings), early.ID, late.ID)
+	}
+}
+
+func testLocations(t *testing.T, repo storage.Repository) {
+	ctx := context.Background()
+	date := day(12)
+
+	if location, err := repo.GetLocation(ctx, 404); err != nil || location != nil {
+		t.Fatalf("GetLocation для неизвестной = %v, %v; нужно nil, nil", location, err)
+	}
+
+	location := &models.Location{Name: "Север", Address: "ул. Лесная, 1", Latitude: 43.25, Longitude: 76.9,
+		StartTime: 9, EndTime: 18, Bays: 2, Services: []string{"express", "body"}}
+	if err := repo.CreateLocation(ctx, location); err != nil {
+		t.Fatalf("CreateLocation: %v", err)
+	}
+	if location.ID == 0 {
+		t.Fatal("CreateLocation не выдал ID")
+	}
+	got, err := repo.GetLocation(ctx, location.ID)
+	if err != nil || got == nil {
+		t.Fatalf("GetLocation = %v, %v", got, err)
+	}
+	if got.Name != "Север" || got.Bays != 2 || got.StartTime != 9 || got.EndTime != 18 ||
+		got.Latitude != 43.25 || len(got.Services) != 2 || got.Services[1] != "body" {
+		t.Fatalf("GetLocation = %+v; сохранено %+v", got, location)
+	}
+	if all, err := repo.GetLocations(ctx); err != nil || len(all) != 1 || all[0].ID != location.ID {
+		t.Fatalf("GetLocations = %v, %v; нужна одна мойка", all, err)
+	}
+
+	// У основной мойки один пост, у новой — два, и занятость у них своя
+	user := newUser(t, repo, 1)
+	book(t, repo, user.ID, date, "10:00")
+	booking := &models.Booking{UserID: newUser(t, repo, 2).ID, LocationID: location.ID, Date: date, Time: "10:00",
+		Service: "express"}
+	if err := repo.CreateBooking(ctx, booking); err != nil {
+		t.Fatalf("CreateBooking на другой мойке: %v", err)
+	}
+	if booking.Bay != 1 {
+		t.Fatalf("пост на другой мойке = %d; нужно 1", booking.Bay)
+	}
+	if got, _ := repo.GetBooking(ctx, booking.ID); got == nil || got.LocationID != location.ID {
+		t.Fatalf("мойка записи = %+v; нужно %d", got, location.ID)
+	}
+
+	holder := newUser(t, repo, 3)
+	if err := repo.HoldSlot(ctx, holder.ID, location.ID, date, "10:00", time.Now().Add(time.Minute)); err != nil {
+		t.Fatalf("HoldSlot на свободный второй пост: %v", err)
+	}
+	if err := repo.HoldSlot(ctx, holder.ID, 0, date, "10:00", time.Now().Add(time.Minute)); !errors.Is(err, storage.ErrSlotTaken) {
+		t.Fatalf("HoldSlot на основной мойке: %v; нужно ErrSlotTaken", err)
+	}
+
+	main, _ := repo.GetDayOccupancy(ctx, 0, date, 0)
+	other, _ := repo.GetDayOccupancy(ctx, location.ID, date, 0)
+	if main["10:00"] != 1 || other["10:00"] != 2 {
+		t.Fatalf("занятость основной = %v, другой = %v; нужно 1 и 2 с удержанием", main, other)
+	}
+
+	// Перенос остаётся на той же мойке
+	moved, err := repo.RescheduleBooking(ctx, booking.ID, date, "11:00")
+	if err != nil || moved.LocationID != location.ID {
+		t.Fatalf("RescheduleBooking = %+v, %v; нужна мойка %d", moved, err, location.ID)
+	}
+
+	unknown := &models.Booking{UserID: user.ID, LocationID: 404, Date: date, Time: "12:00", Service: "express"}
+	if err := repo.CreateBooking(ctx, unknown); err == nil {
+		t.Fatal("запись на неизвестную мойку прошла; нужна ошибка")
+	}
+}
+
+func ids(bookings []*models.Booking) []string {
+	var result []string
+	for _, b := range bookings {
+		result = append(result, b.ID)
+	}
+	return result
+}
@@ -0,0 +1,111 @@
+package storage
+
+import (
+	"carwash-bot/internal/models"
+	"context"
+	"database/sql"
+	"time"
+)
+
+// GetMessageTemplate возвращает текст шаблона или пустую строку,
+// если администратор его не менял
+func (s *Storage) GetMessageTemplate(ctx context.Context, name, lang string) (string, error) {
+	var body string
+	err := s.DB.QueryRowContext(ctx, `
+	SELECT body FROM message_templates WHERE name = ? AND lang = ?`, name, lang).Scan(&body)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return body, err
+}
+
+func (s *Storage) SetMessageTemplate(ctx context.Context, tmpl *models.MessageTemplate) error {
+	tmpl.UpdatedAt = time.Now()
+	_, err := s.DB.ExecContext(ctx, `
+	INSERT INTO message_templates (name, lang, body, updated_by, updated_at)
+	VALUES (?, ?, ?, ?, ?)
+	ON CONFLICT(name, lang) DO UPDATE SET
+		body = excluded.body,
+		updated_by = excluded.updated_by,
+		updated_at = excluded.updated_at`,
+		tmpl.Name, tmpl.Lang, tmpl.Body, tmpl.UpdatedBy, tmpl.UpdatedAt)
+	return err
+}
+
+// DeleteMessageTemplate возвращает шаблону текст по умолчанию.
+// Возвращает sql.ErrNoRows, если шаблон не менялся
+func (s *Storage) DeleteMessageTemplate(ctx context.Context, name, lang string) error {
+	res, err := s.DB.ExecContext(ctx, `
+	DELETE FROM message_templates WHERE name = ? AND lang = ?`, name, lang)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (s *Storage) GetMessageTemplates(ctx context.Context) ([]*models.MessageTemplate, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+	SELECT name, lang, body, updated_by, updated_at FROM message_templates ORDER BY name, lang`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var templates []*models.MessageTemplate
+	for rows.Next() {
+		var t models.MessageTemplate
+		if err := rows.Scan(&t.Name, &t.Lang, &t.Body, &t.UpdatedBy, &t.UpdatedAt); err != nil {
+			return nil, err
+		}
+		templates = append(templates, &t)
+	}
+	return templates, rows.Err()
+}
+
+// GetBookingsToRemind возвращает активные записи на указанные даты,
+// о которых клиенту ещё не напоминали
+func (s *Storage) GetBookingsToRemind(ctx context.Context, dates ...string) ([]*models.Booking, error) {
+	var bookings []*models.Booking
+	for _, date := range dates {
+		rows, err := s.DB.QueryContext(ctx, `
+		SELECT id FROM bookings WHERE date = ? AND status = ? AND reminded = 0`,
+			date, models.StatusActive)
+		if err != nil {
+			return nil, err
+		}
+
+		var ids []string
+		for rows.Next() {
+			var id string
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			ids = append(ids, id)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+
+		for _, id := range ids {
+			booking, err := s.GetBooking(ctx, id)
+			if err != nil {
+				return nil, err
+			}
+			if booking != nil {
+				bookings = append(bookings, booking)
+			}
+		}
+	}
+	return bookings, nil
+}
+
+func (s *Storage) MarkReminded(ctx context.Context, bookingID string) error {
+	_, err := s.DB.ExecContext(ctx, `
+	UPDATE bookings SET reminded = 1 WHERE id = ?`, bookingID)
+	return err
+}
@@ -0,0 +1,499 @@
+package web
+
+import (
+	"carwash-bot/internal/i18n"
+	"carwash-bot/internal/models"
+	"carwash-bot/internal/services"
+	"carwash-bot/internal/storage"
+	"cmp"
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Спецификация API в формате OpenAPI 3
+//
+//go:embed openapi.yaml
+var openAPISpec []byte
+
+// registerAPI подключает JSON API для сайта и планшета на ресепшене.
+// Записи через API создают сотрудники, поэтому предоплата и подтверждение
+// администратором к ним не применяются, а блокировки и лимиты клиента — да
+func (s *Server) registerAPI() {
+	s.mux.HandleFunc("GET /api/v1/openapi.yaml", s.handleOpenAPI)
+	s.mux.HandleFunc("GET /api/v1/locations", s.requireAPIKey(s.handleLocations))
+	s.mux.HandleFunc("GET /api/v1/slots", s.requireAPIKey(s.handleSlots))
+	s.mux.HandleFunc("GET /api/v1/schedule", s.requireAPIKey(s.handleAPISchedule))
+	s.mux.HandleFunc("POST /api/v1/bookings", s.requireAPIKey(s.handleCreateBooking))
+	s.mux.HandleFunc("GET /api/v1/bookings/{id}", s.requireAPIKey(s.handleGetBooking))
+	s.mux.HandleFunc("POST /api/v1/bookings/{id}/cancel", s.requireAPIKey(s.handleCancelBooking))
+	s.mux.HandleFunc("POST /api/v1/bookings/{id}/reschedule", s.requireAPIKey(s.handleRescheduleBooking))
+	s.mux.HandleFunc("GET /api/v1/users", s.requireAPIKey(s.handleUsers))
+	s.mux.HandleFunc("GET /api/v1/users/{telegram_id}", s.requireAPIKey(s.handleUser))
+}
+
+// requireAPIKey пропускает запросы с ключом из API_KEY в заголовке
+// X-API-Key или Authorization: Bearer
+func (s *Server) requireAPIKey(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("X-API-Key")
+		if key == "" {
+			key, _ = strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		}
+		if subtle.ConstantTimeCompare([]byte(key), []byte(s.cfg.HTTP.APIKey)) != 1 {
+			writeError(w, http.StatusUnauthorized, "неверный API-ключ")
+			return
+		}
+		next(w, r)
+	}
+}
+
+func (s *Server) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/yaml")
+	w.Write(openAPISpec)
+}
+
+type slot struct {
+	Time      string `json:"time"`
+	FreeBays  int    `json:"free_bays"`
+	Available bool   `json:"available"`
+}
+
+type daySlots struct {
+	Date       string `json:"date"`
+	LocationID int64  `json:"location_id"`
+	Closed     bool   `json:"closed"`
+	Slots      []slot `json:"slots"`
+}
+
+// handleLocations возвращает мойки сети, первая — основная мойка с ID 0
+func (s *Server) handleLocations(w http.ResponseWriter, r *http.Request) {
+	locations, err := s.bookings.Locations(r.Context())
+	if err != nil {
+		s.internalError(w, "Ошибка получения моек", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, locations)
+}
+
+// handleSlots показывает свободные посты по времени на день ?date=ДД.ММ.ГГГГ
+// на мойке ?location= (по умолчанию основная)
+func (s *Server) handleSlots(w http.ResponseWriter, r *http.Request) {
+	date := r.URL.Query().Get("date")
+	_, dayErr := s.bookings.ValidateDay(date)
+	if errors.Is(dayErr, services.ErrInvalidDate) {
+		writeError(w, http.StatusBadRequest, dayErr.Error())
+		return
+	}
+	location, ok := s.queryLocation(w, r)
+	if !ok {
+		return
+	}
+
+	day := daySlots{Date: date, LocationID: location.ID, Closed: errors.Is(dayErr, services.ErrClosedDay), Slots: []slot{}}
+	if day.Closed {
+		writeJSON(w, http.StatusOK, day)
+		return
+	}
+
+	slots, err := s.bookings.DaySlots(r.Context(), location, date, 0)
+	if err != nil {
+		s.internalError(w, "Ошибка получения занятости", err)
+		return
+	}
+	for _, sl := range slots {
+		day.Slots = append(day.Slots, slot{
+			Time:      sl.Time,
+			FreeBays:  sl.Free,
+			Available: sl.Free > 0 && dayErr == nil,
+		})
+	}
+	writeJSON(w, http.StatusOK, day)
+}
+
+// handleAPISchedule возвращает записи за период ?from=&to= (ДД.ММ.ГГГГ,
+// по умолчанию — сегодня) с отбором по status, service, bay и location
+func (s *Server) handleAPISchedule(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	today := time.Now().Format("02.01.2006")
+	from, err := time.ParseInLocation("02.01.2006", cmp.Or(query.Get("from"), today), time.Local)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "from должен быть в формате ДД.ММ.ГГГГ")
+		return
+	}
+	to, err := time.ParseInLocation("02.01.2006", cmp.Or(query.Get("to"), from.Format("02.01.2006")), time.Local)
+	if err != nil || to.Before(from) {
+		writeError(w, http.StatusBadRequest, "to должен быть в формате ДД.ММ.ГГГГ и не раньше from")
+		return
+	}
+
+	filter := models.ScheduleFilter{Status: query.Get("status"), Service: query.Get("service")}
+	if bay := query.Get("bay"); bay != "" {
+		if filter.Bay, err = strconv.Atoi(bay); err != nil || filter.Bay < 1 {
+			writeError(w, http.StatusBadRequest, "неверный номер поста")
+			return
+		}
+	}
+	if query.Has("location") {
+		location, ok := s.queryLocation(w, r)
+		if !ok {
+			return
+		}
+		filter.Locations = []int64{location.ID}
+	}
+
+	bookings, err := s.storage.GetBookingsInPeriod(r.Context(), from, to, filter)
+	if err != nil {
+		s.internalError(w, "Ошибка получения расписания", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, nonNil(bookings))
+}
+
+type bookingRequest struct {
+	TelegramID int64  `json:"telegram_id"`
+	LocationID int64  `json:"location_id"` // 0 — основная мойка
+	Date       string `json:"date"`
+	Time       string `json:"time"`
+	Service    string `json:"service"`
+	CarModel   string `json:"car_model"`
+	CarNumber  string `json:"car_number"`
+}
+
+func (s *Server) handleCreateBooking(w http.ResponseWriter, r *http.Request) {
+	var req bookingRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+	if req.CarModel == "" || req.CarNumber == "" {
+		writeError(w, http.StatusBadRequest, "укажите car_model и car_number")
+		return
+	}
+	service, ok := s.cfg.GetService(req.Service)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "неизвестная услуга: "+req.Service)
+		return
+	}
+
+	user, err := s.storage.GetUserByTelegramID(r.Context(), req.TelegramID)
+	if err != nil {
+		s.internalError(w, "Ошибка получения пользователя", err)
+		return
+	}
+	if user == nil {
+		writeError(w, http.StatusNotFound, "клиент не найден: сначала он должен написать боту")
+		return
+	}
+
+	booking := &models.Booking{
+		UserID:     user.ID,
+		Date:       req.Date,
+		Time:       req.Time,
+		LocationID: req.LocationID,
+		CarModel:   req.CarModel,
+		CarNumber:  req.CarNumber,
+		Service:    service.Code,
+		Price:      service.Price,
+	}
+	if err := s.bookings.Create(r.Context(), booking); err != nil {
+		s.bookingError(w, "Ошибка создания записи", err)
+		return
+	}
+
+	l := i18n.New(user.Language)
+	s.notifyCustomer(user.TelegramID, l.T("booking.confirmed")+"\n"+s.bookingSummary(l, booking))
+	s.notifyAdmin(r.Context(), booking.LocationID, fmt.Sprintf("🆕 Запись через API: %s %s, %s %s", booking.Date, booking.Time,
+		booking.CarModel, booking.CarNumber))
+	writeJSON(w, http.StatusCreated, booking)
+}
+
+func (s *Server) handleGetBooking(w http.ResponseWriter, r *http.Request) {
+	booking, ok := s.findBooking(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, booking)
+}
+
+// handleCancelBooking отменяет запись. Отмену через API делает сотрудник,
+// поэтому она не засчитывается клиенту как нарушение, а предоплата
+// возвращается полностью
+func (s *Server) handleCancelBooking(w http.ResponseWriter, r *http.Request) {
+	booking, ok := s.findBooking(w, r)
+	if !ok {
+		return
+	}
+	cancelled, err := s.bookings.CancelByStaff(r.Context(), booking)
+	if err != nil {
+		s.bookingError(w, "Ошибка отмены записи", err)
+		return
+	}
+	refund, err := s.refunds.RefundCancelled(r.Context(), cancelled)
+	if err != nil {
+		log.Printf("Ошибка возврата предоплаты: %v", err)
+	}
+	booking, err = s.storage.GetBooking(r.Context(), booking.ID)
+	if err != nil {
+		s.internalError(w, "Ошибка получения записи", err)
+		return
+	}
+
+	if refund != nil && refund.Manual {
+		s.notifyAdmin(r.Context(), booking.LocationID, fmt.Sprintf("💸 Требуется ручной возврат %s по платежу %s (запись %s)",
+			i18n.Money(refund.Amount, refund.Payment.Currency), refund.Payment.ID, refund.Payment.BookingID))
+	}
+	s.notifyBookingCustomer(r.Context(), booking, func(l *i18n.Localizer) string {
+		text := fmt.Sprintf("%s\n%s %s - %s %s", l.T("bookings.cancelled"),
+			booking.Date, booking.Time, booking.CarModel, booking.CarNumber)
+		if refund == nil {
+			return text
+		}
+		amount := i18n.Money(refund.Amount, refund.Payment.Currency)
+		if refund.Manual {
+			return text + "\n" + l.T("refund.pending", amount)
+		}
+		return text + "\n" + l.T("refund.done", amount)
+	})
+	s.recheckFreeVisits(r.Context(), booking.UserID)
+	s.notifyAdmin(r.Context(), booking.LocationID, fmt.Sprintf("❌ Запись отменена через API: %s %s, %s %s", booking.Date, booking.Time,
+		booking.CarModel, booking.CarNumber))
+	writeJSON(w, http.StatusOK, booking)
+}
+
+type rescheduleRequest struct {
+	Date string `json:"date"`
+	Time string `json:"time"`
+}
+
+func (s *Server) handleRescheduleBooking(w http.ResponseWriter, r *http.Request) {
+	var req rescheduleRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+	booking, ok := s.findBooking(w, r)
+	if !ok {
+		return
+	}
+	if booking.Date == req.Date && booking.Time == req.Time {
+		writeJSON(w, http.StatusOK, booking)
+		return
+	}
+
+	oldDate, oldTime := booking.Date, booking.Time
+	booking, err := s.bookings.Reschedule(r.Context(), booking, req.Date, req.Time)
+	if err != nil {
+		s.bookingError(w, "Ошибка переноса записи", err)
+		return
+	}
+
+	s.notifyBookingCustomer(r.Context(), booking, func(l *i18n.Localizer) string {
+		return l.T("booking.rescheduled", oldDate, oldTime, booking.Date, booking.Time)
+	})
+	s.notifyAdmin(r.Context(), booking.LocationID, fmt.Sprintf("🔄 Запись перенесена через API: %s %s → %s %s, %s %s", oldDate, oldTime,
+		booking.Date, booking.Time, booking.CarModel, booking.CarNumber))
+	writeJSON(w, http.StatusOK, booking)
+}
+
+func (s *Server) handleUsers(w http.ResponseWriter, r *http.Request) {
+	customers, err := s.storage.GetCustomers(r.Context())
+	if err != nil {
+		s.internalError(w, "Ошибка получения клиентов", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, nonNil(customers))
+}
+
+func (s *Server) handleUser(w http.ResponseWriter, r *http.Request) {
+	telegramID, err := strconv.ParseInt(r.PathValue("telegram_id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "неверный Telegram ID")
+		return
+	}
+	user, err := s.storage.GetUserByTelegramID(r.Context(), telegramID)
+	if err != nil {
+		s.internalError(w, "Ошибка получения пользователя", err)
+		return
+	}
+	if user == nil {
+		writeError(w, http.StatusNotFound, "клиент не найден")
+		return
+	}
+	writeJSON(w, http.StatusOK, user)
+}
+
+func (s *Server) findBooking(w http.ResponseWriter, r *http.Request) (*models.Booking, bool) {
+	booking, err := s.storage.GetBooking(r.Context(), r.PathValue("id"))
+	if err != nil {
+		s.internalError(w, "Ошибка получения записи", err)
+		return nil, false
+	}
+	if booking == nil {
+		writeError(w, http.StatusNotFound, "запись не найдена")
+		return nil, false
+	}
+	return booking, true
+}
+
+// queryLocation возвращает мойку из параметра ?location=, по умолчанию основную
+func (s *Server) queryLocation(w http.ResponseWriter, r *http.Request) (*models.Location, bool) {
+	var id int64
+	if value := r.URL.Query().Get("location"); value != "" {
+		var err error
+		if id, err = strconv.ParseInt(value, 10, 64); err != nil {
+			writeError(w, http.StatusBadRequest, "неверный ID мойки")
+			return nil, false
+		}
+	}
+
+	location, err := s.bookings.Location(r.Context(), id)
+	if errors.Is(err, services.ErrUnknownLocation) {
+		writeError(w, http.StatusNotFound, err.Error())
+		return nil, false
+	}
+	if err != nil {
+		s.internalError(w, "Ошибка получения мойки", err)
+		return nil, false
+	}
+	return location, true
+}
+
+// bookingError переводит ошибки хранилища в ответы API
+func (s *Server) bookingError(w http.ResponseWriter, what string, err error) {
+	switch {
+	case errors.Is(err, storage.ErrSlotTaken), errors.Is(err, sql.ErrNoRows),
+		errors.Is(err, services.ErrNotUpcoming), errors.Is(err, services.ErrStarted):
+		writeError(w, http.StatusConflict, err.Error())
+	case errors.Is(err, services.ErrInvalidDate), errors.Is(err, services.ErrPastDay),
+		errors.Is(err, services.ErrBeyondHorizon), errors.Is(err, services.ErrClosedDay),
+		errors.Is(err, services.ErrUnknownSlot), errors.Is(err, services.ErrPastSlot),
+		errors.Is(err, services.ErrUnknownLocation), errors.Is(err, services.ErrUnknownService):
+		writeError(w, http.StatusUnprocessableEntity, err.Error())
+	case errors.Is(err, storage.ErrUserBlocked):
+		writeError(w, http.StatusForbidden, err.Error())
+	case errors.Is(err, storage.ErrTooManyActive), errors.Is(err, storage.ErrTooManyPerDay),
+		errors.Is(err, storage.ErrBookingTooClose):
+		writeError(w, http.StatusUnprocessableEntity, err.Error())
+	default:
+		s.internalError(w, what, err)
+	}
+}
+
+func (s *Server) internalError(w http.ResponseWriter, what string, err error) {
+	log.Printf("%s: %v", what, err)
+	writeError(w, http.StatusInternalServerError, "внутренняя ошибка")
+}
+
+// notifyCustomer ставит сообщение клиенту в очередь отправки: его
+// доставит бот, даже если сейчас Telegram недоступен
+func (s *Server) notifyCustomer(telegramID int64, text string) {
+	if telegramID == 0 {
+		return
+	}
+	err := s.storage.EnqueueMessage(context.Background(), &models.OutboxMessage{ChatID: telegramID, Text: text})
+	if err != nil {
+		log.Printf("Ошибка сохранения сообщения в очередь: %v", err)
+	}
+}
+
+// notifyBookingCustomer сообщает автору записи текст на его языке
+func (s *Server) notifyBookingCustomer(ctx context.Context, booking *models.Booking, text func(l *i18n.Localizer) string) {
+	user, err := s.storage.GetUserByID(ctx, booking.UserID)
+	if err != nil || user == nil {
+		log.Printf("Ошибка получения клиента: %v", err)
+		return
+	}
+	s.notifyCustomer(user.TelegramID, text(i18n.New(user.Language)))
+}
+
+// recheckFreeVisits пересчитывает бесплатные мойки клиента после отмены
+// и сообщает ему, какие мойки снова платные
+func (s *Server) recheckFreeVisits(ctx context.Context, userID int64) {
+	revoked, err := s.loyalty.RecheckFreeVisits(ctx, userID)
+	if err != nil {
+		log.Printf("Ошибка пересчёта бесплатных моек: %v", err)
+		return
+	}
+	for _, booking := range revoked {
+		s.notifyBookingCustomer(ctx, booking, func(l *i18n.Localizer) string {
+			return l.T("loyalty.free_revoked", booking.Date, booking.Time,
+				s.cfg.Loyalty.EveryNth, i18n.Money(booking.Price, s.cfg.Payments.Currency))
+		})
+	}
+}
+
+// bookingSummary описывает запись для клиента теми же строками, что и бот
+func (s *Server) bookingSummary(l *i18n.Localizer, booking *models.Booking) string {
+	date := booking.Date
+	if t, err := time.ParseInLocation("02.01.2006", booking.Date, time.Local); err == nil {
+		date = l.Date(t)
+	}
+	service := booking.Service
+	if svc, ok := s.cfg.GetService(booking.Service); ok {
+		service = svc.Name
+	}
+	return strings.Join([]string{
+		l.T("booking.date", date),
+		l.T("booking.time", booking.Time),
+		l.T("booking.service", service),
+		l.T("booking.price", i18n.Money(booking.Price, s.cfg.Payments.Currency)),
+		l.T("booking.car", booking.CarModel, booking.CarNumber),
+	}, "\n")
+}
+
+// notifyAdmin сообщает о записи главному администратору и администраторам мойки
+func (s *Server) notifyAdmin(ctx context.Context, locationID int64, text string) {
+	s.notifyCustomer(s.cfg.AdminID, text)
+	if locationID == 0 {
+		return
+	}
+
+	location, err := s.storage.GetLocation(ctx, locationID)
+	if err != nil || location == nil {
+		log.Printf("Ошибка получения мойки: %v", err)
+		return
+	}
+	for _, adminID := range location.Admins {
+		if adminID != s.cfg.AdminID {
+			s.notifyCustomer(adminID, text)
+		}
+	}
+}
+
+func readJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, "неверный JSON: "+err.Error())
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Ошибка записи ответа: %v", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
+
+// nonNil заменяет nil на пустой срез, чтобы в JSON был [], а не null
+func nonNil[T any](items []T) []T {
+	if items == nil {
+		return []T{}
+	}
+	return items
+}
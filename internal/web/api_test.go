@@ -0,0 +1,314 @@
+package web
+
+import (
+	"bytes"
+	"carwash-bot/config"
+	"carwash-bot/internal/models"
+	"carwash-bot/internal/payments"
+	"carwash-bot/internal/storage"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testAPIKey = "secret"
+
+// Часы API в тестах стоят на понедельнике 04.03.2030, 08:00
+var monday = time.Date(2030, time.March, 4, 8, 0, 0, 0, time.Local)
+
+type apiFixture struct {
+	server   *httptest.Server
+	store    *storage.Storage
+	provider *payments.Fake
+	now      time.Time
+}
+
+func newAPIFixture(t *testing.T) *apiFixture {
+	t.Helper()
+	store, err := storage.NewSQLiteStorage(filepath.Join(t.TempDir(), "carwash.db"))
+	if err != nil {
+		t.Fatalf("NewSQLiteStorage: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	store.Bays = 1
+
+	cfg := &config.Config{
+		AdminID:   1000,
+		StartTime: 9,
+		EndTime:   18,
+		Horizon:   14,
+		Bays:      1,
+		Hold:      10 * time.Minute,
+		Services:  []config.Service{{Code: "express", Name: "Экспресс", Price: 400, Deposit: 200}},
+		Cancel:    config.CancellationConfig{FreeBefore: 2 * time.Hour, LateRefundPercent: 50},
+		HTTP:      config.HTTPConfig{APIKey: testAPIKey},
+	}
+
+	f := &apiFixture{store: store, provider: payments.NewFake(), now: monday}
+	s := New(store, cfg, f.provider)
+	s.bookings.Now = func() time.Time { return f.now }
+	f.server = httptest.NewServer(s)
+	t.Cleanup(f.server.Close)
+	return f
+}
+
+// do отправляет запрос с API-ключом и разбирает JSON-ответ в out
+func (f *apiFixture) do(t *testing.T, method, path string, body, out any) int {
+	t.Helper()
+	var reader bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&reader).Encode(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	req, err := http.NewRequest(method, f.server.URL+path, &reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("X-API-Key", testAPIKey)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatalf("%s %s: ответ не JSON: %v", method, path, err)
+		}
+	}
+	return resp.StatusCode
+}
+
+func (f *apiFixture) customer(t *testing.T, telegramID int64) *models.User {
+	t.Helper()
+	ctx := context.Background()
+	if err := f.store.CreateOrUpdateUser(ctx, &models.User{TelegramID: telegramID, FirstName: "Тест"}); err != nil {
+		t.Fatalf("CreateOrUpdateUser: %v", err)
+	}
+	user, err := f.store.GetUserByTelegramID(ctx, telegramID)
+	if err != nil || user == nil {
+		t.Fatalf("GetUserByTelegramID = %v, %v", user, err)
+	}
+	return user
+}
+
+func (f *apiFixture) create(t *testing.T, telegramID int64, date, clock string) *models.Booking {
+	t.Helper()
+	var booking models.Booking
+	status := f.do(t, http.MethodPost, "/api/v1/bookings", bookingRequest{TelegramID: telegramID, Date: date,
+		Time: clock, Service: "express", CarModel: "Kia", CarNumber: "A123BC"}, &booking)
+	if status != http.StatusCreated {
+		t.Fatalf("POST /api/v1/bookings = %d; нужно 201", status)
+	}
+	return &booking
+}
+
+// messages возвращает тексты сообщений в очереди для чата
+func (f *apiFixture) messages(t *testing.T, chatID int64) []string {
+	t.Helper()
+	due, err := f.store.GetDueMessages(context.Background(), time.Now().Add(time.Hour), 100)
+	if err != nil {
+		t.Fatalf("GetDueMessages: %v", err)
+	}
+	var texts []string
+	for _, msg := range due {
+		if msg.ChatID == chatID {
+			texts = append(texts, msg.Text)
+		}
+	}
+	return texts
+}
+
+func TestAPIRequiresKey(t *testing.T) {
+	f := newAPIFixture(t)
+
+	tests := []struct {
+		name   string
+		header string
+		value  string
+		want   int
+	}{
+		{"без ключа", "", "", http.StatusUnauthorized},
+		{"неверный ключ", "X-API-Key", "wrong", http.StatusUnauthorized},
+		{"неверный Bearer", "Authorization", "Bearer wrong", http.StatusUnauthorized},
+		{"X-API-Key", "X-API-Key", testAPIKey, http.StatusOK},
+		{"Bearer", "Authorization", "Bearer " + testAPIKey, http.StatusOK},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest(http.MethodGet, f.server.URL+"/api/v1/locations", nil)
+		if tt.header != "" {
+			req.Header.Set(tt.header, tt.value)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.want {
+			t.Errorf("%s: статус %d; нужно %d", tt.name, resp.StatusCode, tt.want)
+		}
+	}
+
+	// Спецификация открыта без ключа
+	resp, err := http.Get(f.server.URL + "/api/v1/openapi.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("openapi.yaml: статус %d; нужно 200", resp.StatusCode)
+	}
+}
+
+func TestAPIBookingLifecycle(t *testing.T) {
+	f := newAPIFixture(t)
+	user := f.customer(t, 42)
+
+	booking := f.create(t, user.TelegramID, "05.03.2030", "10:00")
+	if booking.ID == "" || booking.Status != models.StatusActive || booking.Price != 400 || booking.Bay != 1 {
+		t.Fatalf("созданная запись = %+v", booking)
+	}
+
+	var got models.Booking
+	if status := f.do(t, http.MethodGet, "/api/v1/bookings/"+booking.ID, nil, &got); status != http.StatusOK || got.ID != booking.ID {
+		t.Fatalf("GET запись = %d, %+v", status, got)
+	}
+	if status := f.do(t, http.MethodGet, "/api/v1/bookings/unknown", nil, nil); status != http.StatusNotFound {
+		t.Fatalf("GET неизвестной записи = %d; нужно 404", status)
+	}
+
+	var moved models.Booking
+	status := f.do(t, http.MethodPost, "/api/v1/bookings/"+booking.ID+"/reschedule",
+		rescheduleRequest{Date: "05.03.2030", Time: "14:00"}, &moved)
+	if status != http.StatusOK || moved.Date != "05.03.2030" || moved.Time != "14:00" {
+		t.Fatalf("перенос = %d, %+v; нужно 05.03.2030 14:00", status, moved)
+	}
+	var slots daySlots
+	f.do(t, http.MethodGet, "/api/v1/slots?date=05.03.2030", nil, &slots)
+	for _, sl := range slots.Slots {
+		if want := sl.Time != "14:00"; sl.Available != want {
+			t.Fatalf("после переноса слот %s доступен = %v; нужно %v", sl.Time, sl.Available, want)
+		}
+	}
+
+	// Сотрудник отменяет запись за час до мойки: нарушения клиенту нет
+	f.now = time.Date(2030, time.March, 5, 13, 0, 0, 0, time.Local)
+	var cancelled models.Booking
+	status = f.do(t, http.MethodPost, "/api/v1/bookings/"+booking.ID+"/cancel", nil, &cancelled)
+	if status != http.StatusOK || cancelled.Status != models.StatusCancelled || cancelled.LateCancel {
+		t.Fatalf("отмена = %d, %+v; нужна отмена без нарушения", status, cancelled)
+	}
+	if customer, _ := f.store.GetUserByID(context.Background(), user.ID); customer.LateCancels != 0 {
+		t.Fatalf("поздних отмен = %d; отмена сотрудником не засчитывается", customer.LateCancels)
+	}
+	if texts := f.messages(t, user.TelegramID); len(texts) != 3 || !strings.Contains(texts[2], "отменена") {
+		t.Fatalf("сообщения клиенту = %q; нужны запись, перенос и отмена", texts)
+	}
+
+	if status := f.do(t, http.MethodPost, "/api/v1/bookings/"+booking.ID+"/cancel", nil, nil); status != http.StatusConflict {
+		t.Fatalf("повторная отмена = %d; нужно 409", status)
+	}
+}
+
+func TestAPICancelRefundsPrepayment(t *testing.T) {
+	f := newAPIFixture(t)
+	ctx := context.Background()
+	user := f.customer(t, 42)
+	booking := f.create(t, user.TelegramID, "04.03.2030", "10:00")
+
+	payment := &models.Payment{BookingID: booking.ID, UserID: user.ID, Amount: 200, Currency: "RUB",
+		Provider: f.provider.Name(), Status: models.PaymentPaid}
+	if err := f.store.CreatePayment(ctx, payment); err != nil {
+		t.Fatalf("CreatePayment: %v", err)
+	}
+
+	f.now = time.Date(2030, time.March, 4, 9, 30, 0, 0, time.Local)
+	if status := f.do(t, http.MethodPost, "/api/v1/bookings/"+booking.ID+"/cancel", nil, nil); status != http.StatusOK {
+		t.Fatalf("отмена = %d; нужно 200", status)
+	}
+
+	refunded, err := f.store.GetPayment(ctx, payment.ID)
+	if err != nil || refunded.Status != models.PaymentRefunded || refunded.RefundedAmount != 200 {
+		t.Fatalf("платёж после отмены = %+v, %v; нужен полный возврат", refunded, err)
+	}
+	if f.provider.Refunded("") != 200 {
+		t.Fatalf("провайдер вернул %d ₽; нужно 200", f.provider.Refunded(""))
+	}
+	texts := f.messages(t, user.TelegramID)
+	if len(texts) == 0 || !strings.Contains(texts[len(texts)-1], "Предоплата 200 ₽ возвращена") {
+		t.Fatalf("сообщения клиенту = %q; нужно сообщение о возврате", texts)
+	}
+}
+
+func TestAPINotifiesInCustomerLanguage(t *testing.T) {
+	f := newAPIFixture(t)
+	user := f.customer(t, 42)
+	if err := f.store.SetUserLanguage(context.Background(), user.TelegramID, "en"); err != nil {
+		t.Fatalf("SetUserLanguage: %v", err)
+	}
+
+	booking := f.create(t, user.TelegramID, "05.03.2030", "10:00")
+	f.do(t, http.MethodPost, "/api/v1/bookings/"+booking.ID+"/reschedule", rescheduleRequest{Date: "05.03.2030", Time: "14:00"}, nil)
+	f.do(t, http.MethodPost, "/api/v1/bookings/"+booking.ID+"/cancel", nil, nil)
+
+	texts := f.messages(t, user.TelegramID)
+	want := []string{"💰 Price: 400 ₽", "🔄 Your booking has been moved from 05.03.2030 10:00", "Booking cancelled"}
+	if len(texts) != len(want) {
+		t.Fatalf("сообщения клиенту = %q; нужны запись, перенос и отмена", texts)
+	}
+	for i, text := range texts {
+		if !strings.Contains(text, want[i]) {
+			t.Errorf("сообщение %d = %q; нужно %q", i, text, want[i])
+		}
+	}
+}
+
+func TestAPIConflicts(t *testing.T) {
+	f := newAPIFixture(t)
+	first, second := f.customer(t, 1), f.customer(t, 2)
+	booking := f.create(t, first.TelegramID, "05.03.2030", "10:00")
+	other := f.create(t, second.TelegramID, "05.03.2030", "11:00")
+
+	var apiErr map[string]string
+	status := f.do(t, http.MethodPost, "/api/v1/bookings", bookingRequest{TelegramID: second.TelegramID,
+		Date: "05.03.2030", Time: "10:00", Service: "express", CarModel: "Lada", CarNumber: "B456CD"}, &apiErr)
+	if status != http.StatusConflict || apiErr["error"] == "" {
+		t.Fatalf("запись на занятое время = %d, %v; нужно 409", status, apiErr)
+	}
+
+	status = f.do(t, http.MethodPost, "/api/v1/bookings/"+other.ID+"/reschedule",
+		rescheduleRequest{Date: booking.Date, Time: booking.Time}, nil)
+	if status != http.StatusConflict {
+		t.Fatalf("перенос на занятое время = %d; нужно 409", status)
+	}
+
+	f.now = time.Date(2030, time.March, 5, 10, 30, 0, 0, time.Local)
+	if status := f.do(t, http.MethodPost, "/api/v1/bookings/"+booking.ID+"/cancel", nil, nil); status != http.StatusConflict {
+		t.Fatalf("отмена начавшейся мойки = %d; нужно 409", status)
+	}
+
+	tests := []struct {
+		name string
+		req  bookingRequest
+		want int
+	}{
+		{"прошедший день", bookingRequest{TelegramID: 1, Date: "01.03.2030", Time: "10:00", Service: "express",
+			CarModel: "Kia", CarNumber: "A1"}, http.StatusUnprocessableEntity},
+		{"неизвестная услуга", bookingRequest{TelegramID: 1, Date: "06.03.2030", Time: "10:00", Service: "polish",
+			CarModel: "Kia", CarNumber: "A1"}, http.StatusBadRequest},
+		{"незнакомый клиент", bookingRequest{TelegramID: 404, Date: "06.03.2030", Time: "10:00", Service: "express",
+			CarModel: "Kia", CarNumber: "A1"}, http.StatusNotFound},
+		{"без машины", bookingRequest{TelegramID: 1, Date: "06.03.2030", Time: "10:00", Service: "express"},
+			http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		if status := f.do(t, http.MethodPost, "/api/v1/bookings", tt.req, nil); status != tt.want {
+			t.Errorf("%s: статус %d; нужно %d", tt.name, status, tt.want)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package web
+
+import (
+	"carwash-bot/internal/i18n"
+	"carwash-bot/internal/ical"
+	"carwash-bot/internal/models"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Какие записи попадают в ленту календаря
+const (
+	feedPast   = 30  // дней назад
+	feedFuture = 365 // дней вперёд
+)
+
+// handleCalendarFeed отдаёт ленту записей по секретной ссылке
+// /calendar/<токен>.ics: главному администратору — всех моек сети,
+// администратору мойки — только его моек. Лента строится из базы на
+// каждый запрос, поэтому
+// новые, отменённые и перенесённые записи появляются при следующей
+// синхронизации календаря
+func (s *Server) handleCalendarFeed(w http.ResponseWriter, r *http.Request) {
+	token, ok := strings.CutSuffix(r.PathValue("file"), ".ics")
+	if !ok || token == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	owner, err := s.storage.GetCalendarFeedOwner(r.Context(), token)
+	if err != nil {
+		log.Printf("Ошибка проверки ленты календаря: %v", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	// Лента доступна только действующему сотруднику
+	if owner == 0 {
+		http.NotFound(w, r)
+		return
+	}
+	var filter models.ScheduleFilter
+	if owner != s.cfg.AdminID {
+		filter.Locations, err = s.storage.GetAdminLocations(r.Context(), owner)
+		if err != nil {
+			log.Printf("Ошибка получения моек администратора: %v", err)
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		if len(filter.Locations) == 0 {
+			http.NotFound(w, r)
+			return
+		}
+	}
+
+	now := time.Now()
+	bookings, err := s.storage.GetBookingsInPeriod(r.Context(),
+		now.AddDate(0, 0, -feedPast), now.AddDate(0, 0, feedFuture), filter)
+	if err != nil {
+		log.Printf("Ошибка получения записей для календаря: %v", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	locations, err := s.bookings.Locations(r.Context())
+	if err != nil {
+		log.Printf("Ошибка получения моек для календаря: %v", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	byID := make(map[int64]*models.Location, len(locations))
+	for _, location := range locations {
+		byID[location.ID] = location
+	}
+
+	events := make([]ical.Event, 0, len(bookings))
+	for _, booking := range bookings {
+		// Неоплаченная вовремя запись так и не состоялась
+		if booking.Status == models.StatusExpired {
+			continue
+		}
+		event, err := ical.BookingEvent(booking)
+		if err != nil {
+			continue
+		}
+		event.Summary = fmt.Sprintf("🚗 %s %s — %s", booking.CarModel, booking.CarNumber, s.serviceName(booking.Service))
+		location, ok := byID[booking.LocationID]
+		if !ok {
+			location = byID[0]
+		}
+		event.Description = s.feedDescription(booking, location)
+		event.Location = location.Address
+		events = append(events, event)
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Write(ical.Calendar("Автомойка — записи", events...))
+}
+
+func (s *Server) feedDescription(booking *models.Booking, location *models.Location) string {
+	var lines []string
+	if u := booking.User; u != nil {
+		client := u.FirstName
+		if u.Username != "" {
+			client += " @" + u.Username
+		}
+		if client = strings.TrimSpace(client); client != "" {
+			lines = append(lines, "Клиент: "+client)
+		}
+		if u.Phone != "" {
+			lines = append(lines, "Телефон: "+u.Phone)
+		}
+	}
+	lines = append(lines, "Статус: "+i18n.New(i18n.Default).Status(booking.Status))
+	lines = append(lines, fmt.Sprintf("Стоимость: %d ₽", booking.Price))
+	if s.bookings.Bays(location) > 1 && booking.Bay > 0 {
+		lines = append(lines, fmt.Sprintf("Пост: %d", booking.Bay))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (s *Server) serviceName(code string) string {
+	if service, ok := s.cfg.GetService(code); ok {
+		return service.Name
+	}
+	return code
+}
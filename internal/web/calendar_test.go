@@ -0,0 +1,70 @@
+package web
+
+import (
+	"carwash-bot/internal/models"
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// feed запрашивает ленту календаря владельца telegramID
+func (f *apiFixture) feed(t *testing.T, telegramID int64) (int, string) {
+	t.Helper()
+	token, err := f.store.CalendarFeedToken(context.Background(), telegramID, false)
+	if err != nil {
+		t.Fatalf("CalendarFeedToken: %v", err)
+	}
+	resp, err := http.Get(f.server.URL + "/calendar/" + token + ".ics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, string(body)
+}
+
+// Администратор мойки видит в ленте только записи своих моек, главный
+// администратор — всей сети, клиенту лента не положена
+func TestCalendarFeedScopedToStaffLocations(t *testing.T) {
+	f := newAPIFixture(t)
+	ctx := context.Background()
+
+	branch := &models.Location{Name: "Филиал", StartTime: 9, EndTime: 18, Bays: 1}
+	if err := f.store.CreateLocation(ctx, branch); err != nil {
+		t.Fatalf("CreateLocation: %v", err)
+	}
+	const branchAdmin = 2000
+	if err := f.store.AddLocationAdmin(ctx, branch.ID, branchAdmin); err != nil {
+		t.Fatalf("AddLocationAdmin: %v", err)
+	}
+
+	customer := f.customer(t, 42)
+	date := time.Now().AddDate(0, 0, 2).Format("02.01.2006")
+	for _, booking := range []*models.Booking{
+		{CarModel: "Kia", CarNumber: "MAIN01", LocationID: 0},
+		{CarModel: "Lada", CarNumber: "BRANCH1", LocationID: branch.ID},
+	} {
+		booking.UserID, booking.Date, booking.Time, booking.Service, booking.Price = customer.ID, date, "10:00", "express", 400
+		if err := f.store.CreateBooking(ctx, booking); err != nil {
+			t.Fatalf("CreateBooking: %v", err)
+		}
+	}
+
+	status, body := f.feed(t, branchAdmin)
+	if status != http.StatusOK || !strings.Contains(body, "BRANCH1") || strings.Contains(body, "MAIN01") {
+		t.Fatalf("лента администратора мойки = %d:\n%s\nнужна только запись филиала", status, body)
+	}
+	status, body = f.feed(t, 1000)
+	if status != http.StatusOK || !strings.Contains(body, "BRANCH1") || !strings.Contains(body, "MAIN01") {
+		t.Fatalf("лента главного администратора = %d:\n%s\nнужны записи всех моек", status, body)
+	}
+	if status, _ = f.feed(t, customer.TelegramID); status != http.StatusNotFound {
+		t.Fatalf("лента клиента = %d; нужно 404", status)
+	}
+}
@@ -0,0 +1,65 @@
+// Package web — встроенный HTTP-сервер бота: лента календаря для
+// сотрудников и JSON API для сайта и планшета на ресепшене
+package web
+
+import (
+	"carwash-bot/config"
+	"carwash-bot/internal/payments"
+	"carwash-bot/internal/services"
+	"carwash-bot/internal/storage"
+	"log"
+	"net/http"
+	"time"
+)
+
+// Store — то, что HTTP-серверу нужно от хранилища
+type Store interface {
+	storage.Repository
+	storage.ScheduleRepository
+	storage.OutboxRepository
+	storage.CalendarRepository
+	storage.PaymentRepository
+	storage.LoyaltyRepository
+	storage.LocationAdminRepository
+}
+
+type Server struct {
+	storage  Store
+	bookings *services.BookingService
+	refunds  *services.RefundService
+	loyalty  *services.LoyaltyService
+	cfg      *config.Config
+	mux      *http.ServeMux
+}
+
+// New создаёт сервер. provider — nil, если предоплата отключена
+func New(store Store, cfg *config.Config, provider payments.Provider) *Server {
+	s := &Server{
+		storage:  store,
+		bookings: services.NewBookingService(store, cfg),
+		refunds:  services.NewRefundService(store, provider, cfg),
+		loyalty:  services.NewLoyaltyService(store, cfg),
+		cfg:      cfg,
+		mux:      http.NewServeMux(),
+	}
+	s.mux.HandleFunc("GET /calendar/{file}", s.handleCalendarFeed)
+	if cfg.HTTP.APIKey != "" {
+		s.registerAPI()
+	}
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// ListenAndServe запускает сервер на адресе из HTTP_ADDR
+func (s *Server) ListenAndServe() error {
+	srv := &http.Server{
+		Addr:              s.cfg.HTTP.Addr,
+		Handler:           s,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	log.Printf("HTTP-сервер слушает %s", s.cfg.HTTP.Addr)
+	return srv.ListenAndServe()
+}
@@ -6,12 +6,21 @@ import (
 
 	"carwash-bot/config"
 	"carwash-bot/internal/bot"
+	"carwash-bot/internal/payments"
 	"carwash-bot/internal/storage"
+	"carwash-bot/internal/web"
 	"github.com/joho/godotenv"
 	_ "modernc.org/sqlite"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "export" {
+		if err := runExport(os.Args[2:]); err != nil {
+			log.Fatalf("Ошибка выгрузки: %v", err)
+		}
+		return
+	}
+
 	// Загружаем .env файл
 	if err := godotenv.Load(); err != nil {
 		log.Fatal("Error loading .env file")
@@ -26,12 +35,34 @@ func main() {
 	// Загружаем конфигурацию
 	cfg := config.Load()
 
-	// Инициализируем БД
-	db := storage.New()
+	// Инициализируем БД: PostgreSQL из DATABASE_URL или локальный carwash.db
+	db, err := storage.Open(cfg.DatabaseURL)
+	if err != nil {
+		log.Fatalf("Ошибка открытия базы данных: %v", err)
+	}
 	defer db.DB.Close()
+	db.Limits = cfg.Limits
+	db.Bays = cfg.Bays
+
+	// Платёжный провайдер общий для бота и API: через API отменяют
+	// записи, предоплату которых нужно вернуть
+	provider, err := payments.New(cfg.Payments.Provider, cfg.Payments.ProviderToken)
+	if err != nil {
+		log.Fatalf("Ошибка настройки платежей: %v", err)
+	}
+
+	// Встроенный HTTP-сервер: лента календаря и JSON API
+	if cfg.HTTP.Addr != "" {
+		server := web.New(db, cfg, provider)
+		go func() {
+			if err := server.ListenAndServe(); err != nil {
+				log.Printf("Ошибка HTTP-сервера: %v", err)
+			}
+		}()
+	}
 
 	// Создаём и запускаем бота
-	carWashBot, err := bot.New(cfg, db)
+	carWashBot, err := bot.New(cfg, db, provider)
 	if err != nil {
 		log.Fatalf("Ошибка создания бота: %v", err)
 	}